@@ -1,11 +1,20 @@
 package main
 
 import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
 	"net"
+	"strconv"
+	"strings"
+	"testing"
 	"time"
 
 	"github.com/codecrafters-io/redis-starter-go/app/database"
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
 	"github.com/libp2p/go-reuseport"
+	"github.com/stretchr/testify/require"
 )
 
 var (
@@ -53,3 +62,95 @@ func setTestServerReusePort(s *server, rs *replicaServer) {
 		}
 	}
 }
+
+// readReply reads one RESP reply, bulk and simple strings are returned as string,
+// integers as int, arrays as []any, null as nil and errors as error.
+// The RESP3 doubles are returned as float64, booleans as bool, maps as map[string]any,
+// sets and pushes as []any, verbatim strings as string, and the attributes are skipped.
+func readReply(r *bufio.Reader) (any, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return errors.New(line[1:]), nil
+	case ':':
+		return strconv.Atoi(line[1:])
+	case '$', '=':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		b := make([]byte, n+2)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		if line[0] == '=' {
+			// the verbatim strings are returned without their format
+			return string(b[4:n]), nil
+		}
+		return string(b[:n]), nil
+	case '*', '~', '>':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		arr := make([]any, n)
+		for i := range arr {
+			if arr[i], err = readReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return arr, nil
+	case '_':
+		return nil, nil
+	case ',':
+		return strconv.ParseFloat(line[1:], 64)
+	case '#':
+		return line[1:] == "t", nil
+	case '%', '|':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		m := make(map[string]any, n)
+		for i := 0; i < n; i++ {
+			k, err := readReply(r)
+			if err != nil {
+				return nil, err
+			}
+			if m[fmt.Sprint(k)], err = readReply(r); err != nil {
+				return nil, err
+			}
+		}
+		if line[0] == '|' {
+			return readReply(r)
+		}
+		return m, nil
+	}
+	return nil, fmt.Errorf("unknown reply %q", line)
+}
+
+// pipeClient starts the handler of s on one end of a net.Pipe and returns the other end.
+func pipeClient(t *testing.T, s *server) (net.Conn, *bufio.Reader) {
+	client, conn := net.Pipe()
+	require.NoError(t, client.SetDeadline(time.Now().Add(5*time.Second)))
+	t.Cleanup(func() { client.Close() })
+	go func() {
+		_ = s.handler(conn)
+	}()
+	return client, bufio.NewReader(client)
+}
+
+// do sends a command through the client and returns the reply.
+func do(t *testing.T, conn net.Conn, r *bufio.Reader, args ...string) any {
+	_, err := conn.Write(resp.NewCommand(args))
+	require.NoError(t, err)
+	res, err := readReply(r)
+	require.NoError(t, err)
+	return res
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/database"
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+var (
+	errBitOffset     = errors.New("bit offset is not an integer or out of range")
+	errNotInteger    = errors.New("value is not an integer or out of range")
+	errBitFieldType  = errors.New("Invalid bitfield type. Use something like i16 u8. Note that u64 is not supported but i64 is.")
+	errOverflowType  = errors.New("Invalid OVERFLOW type specified")
+	errBitFieldRO    = errors.New("BITFIELD_RO only supports the GET subcommand")
+	errBitPosBitArg  = errors.New("The bit argument must be 1 or 0.")
+	errBitValueRange = errors.New("bit is not an integer or out of range")
+)
+
+// parseBitOffset parses the offset of SETBIT, GETBIT and BITFIELD. With BITFIELD, "#N" is the Nth integer of bits bits.
+func parseBitOffset(s string, hashBits uint) (uint64, error) {
+	mul := uint64(1)
+	if hashBits > 0 && strings.HasPrefix(s, "#") {
+		s = s[1:]
+		mul = uint64(hashBits)
+	}
+	offset, err := strconv.ParseUint(s, 10, 64)
+	if err != nil || offset > database.MaxBitOffset/mul {
+		return 0, errBitOffset
+	}
+	return offset * mul, nil
+}
+
+func writeInt64(conn io.Writer, i int64) error {
+	if _, err := conn.Write(resp.NewInt(int(i))); err != nil {
+		return fmt.Errorf("error writing to connection: %s", err.Error())
+	}
+	return nil
+}
+
+func handleSetBit(conn io.Writer, arr []string, db *database.DB) error {
+	if len(arr) != 4 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	offset, err := parseBitOffset(arr[2], 0)
+	if err != nil {
+		return writeError(conn, err)
+	}
+	if arr[3] != "0" && arr[3] != "1" {
+		return writeError(conn, errBitValueRange)
+	}
+	old, err := db.SetBit(arr[1], offset, arr[3] == "1")
+	if err != nil {
+		return writeError(conn, err)
+	}
+	return writeInt64(conn, int64(old))
+}
+
+func handleGetBit(conn io.Writer, arr []string, db *database.DB) error {
+	if len(arr) != 3 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	offset, err := parseBitOffset(arr[2], 0)
+	if err != nil {
+		return writeError(conn, err)
+	}
+	bit, err := db.GetBit(arr[1], offset)
+	if err != nil {
+		return writeError(conn, err)
+	}
+	return writeInt64(conn, int64(bit))
+}
+
+// parseBitRange parses [start [end [BYTE | BIT]]], BITCOUNT requires the end when the start is given.
+func parseBitRange(args []string, endRequired bool) (database.BitRange, error) {
+	r := database.WholeString
+	if len(args) == 0 {
+		return r, nil
+	}
+	if len(args) > 3 || (endRequired && len(args) == 1) {
+		return r, errSyntax
+	}
+	var err error
+	if r.Start, err = strconv.ParseInt(args[0], 10, 64); err != nil {
+		return r, errNotInteger
+	}
+	if len(args) >= 2 {
+		if r.End, err = strconv.ParseInt(args[1], 10, 64); err != nil {
+			return r, errNotInteger
+		}
+		r.EndGiven = true
+	}
+	if len(args) == 3 {
+		switch strings.ToUpper(args[2]) {
+		case "BYTE":
+		case "BIT":
+			r.Bit = true
+		default:
+			return r, errSyntax
+		}
+	}
+	return r, nil
+}
+
+func handleBitCount(conn io.Writer, arr []string, db *database.DB) error {
+	if len(arr) < 2 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	r, err := parseBitRange(arr[2:], true)
+	if err != nil {
+		return writeError(conn, err)
+	}
+	count, err := db.BitCount(arr[1], r)
+	if err != nil {
+		return writeError(conn, err)
+	}
+	return writeInt64(conn, int64(count))
+}
+
+func handleBitPos(conn io.Writer, arr []string, db *database.DB) error {
+	if len(arr) < 3 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	if arr[2] != "0" && arr[2] != "1" {
+		return writeError(conn, errBitPosBitArg)
+	}
+	r, err := parseBitRange(arr[3:], false)
+	if err != nil {
+		return writeError(conn, err)
+	}
+	pos, err := db.BitPos(arr[1], int(arr[2][0]-'0'), r)
+	if err != nil {
+		return writeError(conn, err)
+	}
+	return writeInt64(conn, pos)
+}
+
+func handleBitOp(conn io.Writer, arr []string, db *database.DB) error {
+	if len(arr) < 4 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	op := strings.ToUpper(arr[1])
+	if _, ok := database.ValidBitOp(op); !ok {
+		return writeError(conn, errSyntax)
+	}
+	n, err := db.BitOp(op, arr[2], arr[3:])
+	if err != nil {
+		return writeError(conn, err)
+	}
+	return writeInt64(conn, int64(n))
+}
+
+// parseBitFieldOps parses the subcommands of BITFIELD, all of them are validated before any is executed.
+func parseBitFieldOps(args []string, readOnly bool) ([]database.BitFieldOp, error) {
+	ops := []database.BitFieldOp{}
+	overflow := database.OverflowWrap
+	for i := 0; i < len(args); i++ {
+		sub := strings.ToUpper(args[i])
+		left := len(args) - i - 1
+		if sub == "OVERFLOW" && left >= 1 {
+			var ok bool
+			if overflow, ok = database.ParseOverflow(args[i+1]); !ok {
+				return nil, errOverflowType
+			}
+			i++
+			continue
+		}
+		op := database.BitFieldOp{Overflow: overflow}
+		switch {
+		case sub == "GET" && left >= 2:
+			op.Op = database.BitFieldGet
+		case sub == "SET" && left >= 3:
+			op.Op = database.BitFieldSet
+		case sub == "INCRBY" && left >= 3:
+			op.Op = database.BitFieldIncrBy
+		default:
+			return nil, errSyntax
+		}
+		if readOnly && op.Op != database.BitFieldGet {
+			return nil, errBitFieldRO
+		}
+		var ok bool
+		if op.Signed, op.Bits, ok = database.ParseBitFieldType(args[i+1]); !ok {
+			return nil, errBitFieldType
+		}
+		var err error
+		if op.Offset, err = parseBitOffset(args[i+2], op.Bits); err != nil {
+			return nil, err
+		}
+		i += 2
+		if op.Op != database.BitFieldGet {
+			if op.Value, err = strconv.ParseInt(args[i+1], 10, 64); err != nil {
+				return nil, errNotInteger
+			}
+			i++
+		}
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
+
+func handleBitField(conn io.Writer, arr []string, db *database.DB) error {
+	if len(arr) < 2 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	ops, err := parseBitFieldOps(arr[2:], strings.EqualFold(arr[0], "BITFIELD_RO"))
+	if err != nil {
+		return writeError(conn, err)
+	}
+	vals, err := db.BitField(arr[1], ops)
+	if err != nil {
+		return writeError(conn, err)
+	}
+	res := make([][]byte, len(vals))
+	for i, v := range vals {
+		if v == nil {
+			res[i] = nullBulkString(conn)
+		} else {
+			res[i] = resp.NewInt(int(*v))
+		}
+	}
+	if _, err := conn.Write(resp.NewArray(res)); err != nil {
+		return fmt.Errorf("error writing to connection: %s", err.Error())
+	}
+	return nil
+}
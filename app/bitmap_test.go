@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/codecrafters-io/redis-starter-go/app/database"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBitmapCommands(t *testing.T) {
+	s := newServer(host, "0", []*database.DB{database.NewDB()}, RoleMaster, testCfg)
+	conn, r := pipeClient(t, s)
+
+	require.Equal(t, 0, do(t, conn, r, "SETBIT", "k", "7", "1"))
+	require.Equal(t, 1, do(t, conn, r, "GETBIT", "k", "7"))
+	require.Equal(t, "ERR bit is not an integer or out of range", do(t, conn, r, "SETBIT", "k", "7", "2").(error).Error())
+	require.Equal(t, "ERR bit offset is not an integer or out of range", do(t, conn, r, "SETBIT", "k", "4294967296", "1").(error).Error())
+	require.Equal(t, "ERR bit offset is not an integer or out of range", do(t, conn, r, "GETBIT", "k", "-1").(error).Error())
+
+	do(t, conn, r, "SET", "foo", "foobar")
+	require.Equal(t, 26, do(t, conn, r, "BITCOUNT", "foo"))
+	require.Equal(t, 17, do(t, conn, r, "BITCOUNT", "foo", "5", "30", "bit"))
+	require.Equal(t, "ERR syntax error", do(t, conn, r, "BITCOUNT", "foo", "1").(error).Error())
+	require.Equal(t, 1, do(t, conn, r, "BITPOS", "foo", "1"))
+	require.Equal(t, 9, do(t, conn, r, "BITPOS", "foo", "1", "1", "-1", "BYTE"))
+	require.Equal(t, "ERR The bit argument must be 1 or 0.", do(t, conn, r, "BITPOS", "foo", "2").(error).Error())
+
+	require.Equal(t, 6, do(t, conn, r, "BITOP", "NOT", "dst", "foo"))
+	require.Equal(t, "ERR BITOP NOT must be called with a single source key.", do(t, conn, r, "BITOP", "NOT", "dst", "foo", "k").(error).Error())
+	require.Equal(t, "ERR syntax error", do(t, conn, r, "BITOP", "NAND", "dst", "foo").(error).Error())
+
+	require.Equal(t, []any{1, 0}, do(t, conn, r, "BITFIELD", "bf", "INCRBY", "i5", "100", "1", "GET", "u4", "0"))
+	require.Equal(t, []any{0, 3, nil}, do(t, conn, r, "BITFIELD", "bf2", "SET", "u2", "#1", "3", "GET", "u2", "2", "OVERFLOW", "FAIL", "INCRBY", "u2", "#1", "1"))
+	require.Equal(t, []any{3}, do(t, conn, r, "BITFIELD_RO", "bf2", "GET", "u2", "#1"))
+	require.Equal(t, "ERR BITFIELD_RO only supports the GET subcommand", do(t, conn, r, "BITFIELD_RO", "bf2", "SET", "u2", "0", "1").(error).Error())
+	require.Equal(t, "ERR Invalid bitfield type. Use something like i16 u8. Note that u64 is not supported but i64 is.",
+		do(t, conn, r, "BITFIELD", "bf2", "GET", "u64", "0").(error).Error())
+	require.Equal(t, "ERR Invalid OVERFLOW type specified", do(t, conn, r, "BITFIELD", "bf2", "OVERFLOW", "foo").(error).Error())
+	require.Equal(t, []any{}, do(t, conn, r, "BITFIELD", "bf2"))
+}
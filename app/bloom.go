@@ -0,0 +1,314 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/database"
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+var (
+	errBadErrorRate       = errors.New("bad error rate")
+	errErrorRateRange     = errors.New("(0 < error rate range < 1)")
+	errBadCapacity        = errors.New("bad capacity")
+	errCapacityRange      = errors.New("(capacity should be larger than 0)")
+	errBadExpansion       = errors.New("bad expansion")
+	errExpansionRange     = errors.New("(expansion should be greater or equal to 1)")
+	errNonScalingExpand   = errors.New("Non scaling filters cannot expand")
+	errBadInfoValue       = errors.New("Invalid information value")
+	errCFBadCapacity      = errors.New("Bad capacity")
+	errCFBadBucketSize    = errors.New("Bad bucket size")
+	errCFBadMaxIterations = errors.New("Bad maxIterations")
+	errCFBadExpansion     = errors.New("Bad expansion")
+	errCFCapacityTooSmall = errors.New("Capacity must be at least (BucketSize * 2)")
+)
+
+func writeOK(conn io.Writer) error {
+	if _, err := conn.Write(resp.NewSimpleString("OK")); err != nil {
+		return fmt.Errorf("error writing to connection: %s", err.Error())
+	}
+	return nil
+}
+
+// writeBoolean replies b as a boolean, or as 1 or 0 in RESP2, like the filters of RedisBloom.
+func writeBoolean(conn io.Writer, b bool) error {
+	if _, err := conn.Write(boolReply(conn, b)); err != nil {
+		return fmt.Errorf("error writing to connection: %s", err.Error())
+	}
+	return nil
+}
+
+func writeBooleans(conn io.Writer, bs []bool) error {
+	res := make([][]byte, len(bs))
+	for i, b := range bs {
+		res[i] = boolReply(conn, b)
+	}
+	if _, err := conn.Write(resp.NewArray(res)); err != nil {
+		return fmt.Errorf("error writing to connection: %s", err.Error())
+	}
+	return nil
+}
+
+// writeInfo replies the fields of BF.INFO and CF.INFO as a map, or name, value pairs in RESP2,
+// nil values are replied as null.
+func writeInfo(conn io.Writer, fields []string, values []*int64) error {
+	res := make([][]byte, 0, 2*len(fields))
+	for i, f := range fields {
+		res = append(res, resp.NewSimpleString(f))
+		if values[i] == nil {
+			res = append(res, nullBulkString(conn))
+			continue
+		}
+		res = append(res, resp.NewInt(int(*values[i])))
+	}
+	if _, err := conn.Write(mapReply(conn, res)); err != nil {
+		return fmt.Errorf("error writing to connection: %s", err.Error())
+	}
+	return nil
+}
+
+func int64Ptr(i int64) *int64 {
+	return &i
+}
+
+// handleBFReserve limits the filter to maxValueSize, so that a large capacity cannot exhaust the memory.
+func (s *server) handleBFReserve(conn io.Writer, arr []string, db *database.DB) error {
+	if len(arr) < 4 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	errorRate, err := strconv.ParseFloat(arr[2], 64)
+	if err != nil {
+		return writeError(conn, errBadErrorRate)
+	}
+	if errorRate <= 0 || errorRate >= 1 {
+		return writeError(conn, errErrorRateRange)
+	}
+	capacity, err := strconv.ParseInt(arr[3], 10, 64)
+	if err != nil {
+		return writeError(conn, errBadCapacity)
+	}
+	if capacity <= 0 {
+		return writeError(conn, errCapacityRange)
+	}
+	expansion, nonScaling, expansionGiven := database.BloomDefaultExpansion, false, false
+	for i := 4; i < len(arr); i++ {
+		switch opt := strings.ToUpper(arr[i]); {
+		case opt == "NONSCALING":
+			nonScaling = true
+		case opt == "EXPANSION" && i+1 < len(arr):
+			if expansion, err = strconv.Atoi(arr[i+1]); err != nil {
+				return writeError(conn, errBadExpansion)
+			}
+			if expansion < 1 {
+				return writeError(conn, errExpansionRange)
+			}
+			expansionGiven = true
+			i++
+		default:
+			return writeError(conn, errSyntax)
+		}
+	}
+	if nonScaling {
+		if expansionGiven {
+			return writeError(conn, errNonScalingExpand)
+		}
+		expansion = 0
+	}
+	if err := db.BFReserve(arr[1], errorRate, capacity, expansion, s.maxValueSize()); err != nil {
+		return writeError(conn, err)
+	}
+	return writeOK(conn)
+}
+
+// handleBFAdd handles BF.ADD and BF.MADD, with BF.MADD the items which could not be added are replied as errors.
+func handleBFAdd(conn io.Writer, arr []string, db *database.DB) error {
+	multi := strings.EqualFold(arr[0], "BF.MADD")
+	if len(arr) < 3 || !multi && len(arr) != 3 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	added, err := db.BFAdd(arr[1], arr[2:])
+	if !multi {
+		if err != nil {
+			return writeError(conn, err)
+		}
+		return writeBoolean(conn, added[0])
+	}
+	if added == nil {
+		return writeError(conn, err)
+	}
+	res := make([][]byte, len(arr)-2)
+	for i := range res {
+		if i < len(added) {
+			res[i] = boolReply(conn, added[i])
+		} else {
+			res[i] = resp.NewErrorMSG(err.Error())
+		}
+	}
+	if _, err := conn.Write(resp.NewArray(res)); err != nil {
+		return fmt.Errorf("error writing to connection: %s", err.Error())
+	}
+	return nil
+}
+
+// handleBFExists handles BF.EXISTS and BF.MEXISTS.
+func handleBFExists(conn io.Writer, arr []string, db *database.DB) error {
+	multi := strings.EqualFold(arr[0], "BF.MEXISTS")
+	if len(arr) < 3 || !multi && len(arr) != 3 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	exists, err := db.BFExists(arr[1], arr[2:])
+	if err != nil {
+		return writeError(conn, err)
+	}
+	if !multi {
+		return writeBoolean(conn, exists[0])
+	}
+	return writeBooleans(conn, exists)
+}
+
+func handleBFInfo(conn io.Writer, arr []string, db *database.DB) error {
+	if len(arr) != 2 && len(arr) != 3 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	info, err := db.BFInfo(arr[1])
+	if err != nil {
+		return writeError(conn, err)
+	}
+	fields := []string{"Capacity", "Size", "Number of filters", "Number of items inserted", "Expansion rate"}
+	values := []*int64{&info.Capacity, &info.Size, int64Ptr(int64(info.Filters)), &info.Items, nil}
+	if info.Expansion > 0 {
+		values[4] = int64Ptr(int64(info.Expansion))
+	}
+	if len(arr) == 2 {
+		return writeInfo(conn, fields, values)
+	}
+	idx := map[string]int{"CAPACITY": 0, "SIZE": 1, "FILTERS": 2, "ITEMS": 3, "EXPANSION": 4}
+	i, ok := idx[strings.ToUpper(arr[2])]
+	if !ok {
+		return writeError(conn, errBadInfoValue)
+	}
+	v := nullBulkString(conn)
+	if values[i] != nil {
+		v = resp.NewInt(int(*values[i]))
+	}
+	if _, err := conn.Write(resp.NewArray([][]byte{v})); err != nil {
+		return fmt.Errorf("error writing to connection: %s", err.Error())
+	}
+	return nil
+}
+
+// handleCFReserve limits the filter to maxValueSize like handleBFReserve.
+func (s *server) handleCFReserve(conn io.Writer, arr []string, db *database.DB) error {
+	if len(arr) < 3 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	opts := database.DefaultCuckooOptions
+	var err error
+	if opts.Capacity, err = strconv.ParseInt(arr[2], 10, 64); err != nil || opts.Capacity <= 0 {
+		return writeError(conn, errCFBadCapacity)
+	}
+	for i := 3; i < len(arr); i += 2 {
+		if i+1 >= len(arr) {
+			return writeError(conn, errSyntax)
+		}
+		n, err := strconv.Atoi(arr[i+1])
+		switch strings.ToUpper(arr[i]) {
+		case "BUCKETSIZE":
+			if err != nil || n < 1 || n > 255 {
+				return writeError(conn, errCFBadBucketSize)
+			}
+			opts.BucketSize = n
+		case "MAXITERATIONS":
+			if err != nil || n < 1 || n > 65535 {
+				return writeError(conn, errCFBadMaxIterations)
+			}
+			opts.MaxIterations = n
+		case "EXPANSION":
+			if err != nil || n < 0 || n > 32768 {
+				return writeError(conn, errCFBadExpansion)
+			}
+			opts.Expansion = n
+		default:
+			return writeError(conn, errSyntax)
+		}
+	}
+	if opts.Capacity < int64(opts.BucketSize)*2 {
+		return writeError(conn, errCFCapacityTooSmall)
+	}
+	if err := db.CFReserve(arr[1], opts, s.maxValueSize()); err != nil {
+		return writeError(conn, err)
+	}
+	return writeOK(conn)
+}
+
+// handleCFAdd handles CF.ADD and CF.ADDNX.
+func handleCFAdd(conn io.Writer, arr []string, db *database.DB) error {
+	if len(arr) != 3 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	added, err := db.CFAdd(arr[1], arr[2], strings.EqualFold(arr[0], "CF.ADDNX"))
+	if err != nil {
+		return writeError(conn, err)
+	}
+	return writeBoolean(conn, added)
+}
+
+// handleCFExists handles CF.EXISTS and CF.MEXISTS.
+func handleCFExists(conn io.Writer, arr []string, db *database.DB) error {
+	multi := strings.EqualFold(arr[0], "CF.MEXISTS")
+	if len(arr) < 3 || !multi && len(arr) != 3 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	exists, err := db.CFExists(arr[1], arr[2:])
+	if err != nil {
+		return writeError(conn, err)
+	}
+	if !multi {
+		return writeBoolean(conn, exists[0])
+	}
+	return writeBooleans(conn, exists)
+}
+
+func handleCFDel(conn io.Writer, arr []string, db *database.DB) error {
+	if len(arr) != 3 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	deleted, err := db.CFDel(arr[1], arr[2])
+	if err != nil {
+		return writeError(conn, err)
+	}
+	return writeBoolean(conn, deleted)
+}
+
+func handleCFCount(conn io.Writer, arr []string, db *database.DB) error {
+	if len(arr) != 3 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	n, err := db.CFCount(arr[1], arr[2])
+	if err != nil {
+		return writeError(conn, err)
+	}
+	if _, err := conn.Write(resp.NewInt(n)); err != nil {
+		return fmt.Errorf("error writing to connection: %s", err.Error())
+	}
+	return nil
+}
+
+func handleCFInfo(conn io.Writer, arr []string, db *database.DB) error {
+	if len(arr) != 2 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	info, err := db.CFInfo(arr[1])
+	if err != nil {
+		return writeError(conn, err)
+	}
+	return writeInfo(conn,
+		[]string{"Size", "Number of buckets", "Number of filters", "Number of items inserted",
+			"Number of items deleted", "Bucket size", "Expansion rate", "Max iterations"},
+		[]*int64{&info.Size, int64Ptr(int64(info.Buckets)), int64Ptr(int64(info.Filters)), &info.Items,
+			&info.Deletes, int64Ptr(int64(info.BucketSize)), int64Ptr(int64(info.Expansion)), int64Ptr(int64(info.MaxIterations))})
+}
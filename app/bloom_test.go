@@ -0,0 +1,97 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/codecrafters-io/redis-starter-go/app/database"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBloomCommands(t *testing.T) {
+	s := newServer(host, "0", []*database.DB{database.NewDB()}, RoleMaster, testCfg)
+	conn, r := pipeClient(t, s)
+
+	require.Equal(t, "OK", do(t, conn, r, "BF.RESERVE", "bf", "0.01", "1000", "EXPANSION", "4"))
+	require.Equal(t, "ERR item exists", do(t, conn, r, "BF.RESERVE", "bf", "0.01", "1000").(error).Error())
+	require.Equal(t, 1, do(t, conn, r, "BF.ADD", "bf", "a"))
+	require.Equal(t, 0, do(t, conn, r, "BF.ADD", "bf", "a"))
+	require.Equal(t, []any{0, 1}, do(t, conn, r, "BF.MADD", "bf", "a", "b"))
+	require.Equal(t, 1, do(t, conn, r, "BF.EXISTS", "bf", "b"))
+	require.Equal(t, []any{1, 0}, do(t, conn, r, "BF.MEXISTS", "bf", "a", "c"))
+	require.Equal(t, 0, do(t, conn, r, "BF.EXISTS", "missing", "a"))
+	require.Equal(t, "MBbloom--", do(t, conn, r, "TYPE", "bf"))
+
+	info := do(t, conn, r, "BF.INFO", "bf").([]any)
+	require.Equal(t, []any{"Capacity", 1000}, info[:2])
+	require.Equal(t, []any{"Number of filters", 1, "Number of items inserted", 2, "Expansion rate", 4}, info[4:])
+	require.Equal(t, []any{2}, do(t, conn, r, "BF.INFO", "bf", "ITEMS"))
+	require.Equal(t, "ERR not found", do(t, conn, r, "BF.INFO", "missing").(error).Error())
+
+	require.Equal(t, "OK", do(t, conn, r, "BF.RESERVE", "small", "0.01", "1", "NONSCALING"))
+	require.Equal(t, []any{nil}, do(t, conn, r, "BF.INFO", "small", "EXPANSION"))
+	require.Equal(t, []any{1, "non scaling filter is full"}, errorsToStrings(do(t, conn, r, "BF.MADD", "small", "a", "b")))
+	require.Equal(t, "ERR (0 < error rate range < 1)", do(t, conn, r, "BF.RESERVE", "x", "1", "100").(error).Error())
+	require.Equal(t, "ERR bad capacity", do(t, conn, r, "BF.RESERVE", "x", "0.1", "foo").(error).Error())
+	require.Equal(t, "ERR Non scaling filters cannot expand", do(t, conn, r, "BF.RESERVE", "x", "0.1", "10", "EXPANSION", "2", "NONSCALING").(error).Error())
+}
+
+func TestCuckooCommands(t *testing.T) {
+	s := newServer(host, "0", []*database.DB{database.NewDB()}, RoleMaster, testCfg)
+	conn, r := pipeClient(t, s)
+
+	require.Equal(t, "OK", do(t, conn, r, "CF.RESERVE", "cf", "1000", "BUCKETSIZE", "4"))
+	require.Equal(t, 1, do(t, conn, r, "CF.ADD", "cf", "a"))
+	require.Equal(t, 1, do(t, conn, r, "CF.ADD", "cf", "a"))
+	require.Equal(t, 0, do(t, conn, r, "CF.ADDNX", "cf", "a"))
+	require.Equal(t, 2, do(t, conn, r, "CF.COUNT", "cf", "a"))
+	require.Equal(t, []any{1, 0}, do(t, conn, r, "CF.MEXISTS", "cf", "a", "b"))
+	require.Equal(t, 1, do(t, conn, r, "CF.DEL", "cf", "a"))
+	require.Equal(t, 1, do(t, conn, r, "CF.EXISTS", "cf", "a"))
+	require.Equal(t, 0, do(t, conn, r, "CF.DEL", "cf", "b"))
+	require.Equal(t, "MBbloomCF", do(t, conn, r, "TYPE", "cf"))
+	require.Equal(t, []any{
+		"Size", 1024, "Number of buckets", 256, "Number of filters", 1, "Number of items inserted", 1,
+		"Number of items deleted", 1, "Bucket size", 4, "Expansion rate", 1, "Max iterations", 20,
+	}, do(t, conn, r, "CF.INFO", "cf"))
+
+	require.Equal(t, "ERR Not found", do(t, conn, r, "CF.DEL", "missing", "a").(error).Error())
+	require.Equal(t, "ERR Capacity must be at least (BucketSize * 2)", do(t, conn, r, "CF.RESERVE", "x", "3", "BUCKETSIZE", "2").(error).Error())
+	require.Equal(t, "ERR Bad bucket size", do(t, conn, r, "CF.RESERVE", "x", "100", "BUCKETSIZE", "0").(error).Error())
+	do(t, conn, r, "SET", "s", "v")
+	require.Equal(t, "ERR wrong data type", do(t, conn, r, "CF.ADD", "s", "a").(error).Error())
+}
+
+func TestFilterReserveLimits(t *testing.T) {
+	s := newServer(host, "0", []*database.DB{database.NewDB()}, RoleMaster, testCfg)
+	conn, r := pipeClient(t, s)
+
+	tooLarge := "ERR Insufficient memory to create filter"
+	require.Equal(t, tooLarge, do(t, conn, r, "BF.RESERVE", "bf", "0.01", "1000000000000000").(error).Error())
+	require.Equal(t, tooLarge, do(t, conn, r, "BF.RESERVE", "bf", "1e-300", "9223372036854775807").(error).Error())
+	require.Equal(t, tooLarge, do(t, conn, r, "CF.RESERVE", "cf", "100000000000000000").(error).Error())
+	require.Equal(t, tooLarge, do(t, conn, r, "CF.RESERVE", "cf", "9223372036854775807", "BUCKETSIZE", "255").(error).Error())
+	require.Equal(t, "none", do(t, conn, r, "TYPE", "bf"))
+	require.Equal(t, "none", do(t, conn, r, "TYPE", "cf"))
+	require.Equal(t, "PONG", do(t, conn, r, "PING"))
+
+	// maxmemory lowers the limit below proto-max-bulk-len
+	cfg := testCfg
+	cfg.maxmemory = 1 << 20
+	s = newServer(host, "0", []*database.DB{database.NewDB()}, RoleMaster, cfg)
+	conn, r = pipeClient(t, s)
+	require.Equal(t, tooLarge, do(t, conn, r, "BF.RESERVE", "bf", "0.01", "10000000").(error).Error())
+	require.Equal(t, tooLarge, do(t, conn, r, "CF.RESERVE", "cf", "10000000").(error).Error())
+	require.Equal(t, "OK", do(t, conn, r, "BF.RESERVE", "bf", "0.01", "10000"))
+	require.Equal(t, "OK", do(t, conn, r, "CF.RESERVE", "cf", "10000"))
+}
+
+// errorsToStrings replaces the errors of an array reply with their message without the error code.
+func errorsToStrings(reply any) []any {
+	arr := reply.([]any)
+	for i, v := range arr {
+		if err, ok := v.(error); ok {
+			arr[i] = err.Error()[len("ERR "):]
+		}
+	}
+	return arr
+}
@@ -7,6 +7,7 @@ type TrackedBufioReader struct {
 }
 
 type reader interface {
+	Read(p []byte) (int, error)
 	ReadString(delim byte) (string, error)
 	ReadByte() (byte, error)
 }
@@ -24,6 +25,12 @@ func (t *TrackedBufioReader) ReadString(delim byte) (string, error) {
 	return s, nil
 }
 
+func (t *TrackedBufioReader) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	t.n += uint64(n)
+	return n, err
+}
+
 func (t *TrackedBufioReader) ReadByte() (byte, error) {
 	b, err := t.r.ReadByte()
 	if err != nil {
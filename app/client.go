@@ -0,0 +1,668 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/database"
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+// Types of clients, as used by CLIENT LIST and CLIENT KILL.
+const (
+	clientTypeNormal  = "normal"
+	clientTypeReplica = "replica"
+	clientTypeMaster  = "master"
+	clientTypePubsub  = "pubsub"
+)
+
+var (
+	errNoSuchClient  = errors.New("No such client")
+	errInvalidName   = errors.New("Client names cannot contain spaces, newlines or special characters.")
+	errClientTimeout = errors.New("timeout is not an integer or out of range")
+	errUnblocked     = &database.CodedError{Code: "UNBLOCKED", Msg: "client unblocked via CLIENT UNBLOCK"}
+)
+
+// client is the entry of a connection in the client registry. The connection goroutine owns the clientState,
+// it copies to the client the fields the other connections read with CLIENT LIST, so they are guarded by mu.
+type client struct {
+	id      int64
+	conn    net.Conn
+	out     *clientOutput
+	created time.Time
+
+	mu              sync.Mutex
+	typ             string
+	name            string
+	db              int
+	proto           int
+	multi           int // number of queued commands, -1 outside of MULTI
+	qbuf            int // size of the requests read but not executed yet
+	obl             int // size of the buffered replies
+	lastCmd         string
+	lastInteraction time.Time
+	noEvict         bool
+	killed          bool
+	unblock         chan struct{} // closed to wake the client while it is blocked, nil otherwise
+	unblockErr      bool          // whether the woken command replies an error rather than like a timeout
+	tracking        trackingOptions
+}
+
+func newClient(id int64, conn net.Conn, out *clientOutput) *client {
+	now := time.Now()
+	return &client{
+		id:              id,
+		conn:            conn,
+		out:             out,
+		created:         now,
+		typ:             clientTypeNormal,
+		proto:           resp.RESP2,
+		multi:           -1,
+		lastInteraction: now,
+	}
+}
+
+// commandStarted records the command about to be executed and the size of the requests pipelined after it.
+func (c *client) commandStarted(arr []string, qbuf int) {
+	name := strings.ToLower(arr[0])
+	if subcommandCommands[name] && len(arr) > 1 {
+		name += "|" + strings.ToLower(arr[1])
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastCmd = name
+	c.lastInteraction = time.Now()
+	c.qbuf = qbuf
+}
+
+// update copies the state of the connection once a command was executed.
+func (c *client) update(state *clientState, obl int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.db = state.db
+	c.proto = state.proto
+	c.multi = -1
+	if state.isMulti {
+		c.multi = len(state.cmdQueue)
+	}
+	c.qbuf = 0
+	c.obl = obl
+}
+
+// protocol returns the protocol version negotiated by the client.
+func (c *client) protocol() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.proto
+}
+
+// push sends a message to the client out of its replies, such as an invalidation or a message of a channel.
+// It does not wait for the client to read it, the client is disconnected if the message exceeds its output buffer limit.
+func (c *client) push(msg []byte) {
+	c.out.Push(msg)
+}
+
+func (c *client) getName() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.name
+}
+
+func (c *client) setName(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.name = name
+}
+
+func (c *client) getType() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.typ
+}
+
+func (c *client) setType(typ string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.typ = typ
+}
+
+func (c *client) isKilled() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.killed
+}
+
+// kill closes the connection, a blocked client is woken so that its goroutine notices it.
+// The connection of the current client is closed by its goroutine once the reply is sent.
+func (c *client) kill(self bool) {
+	c.mu.Lock()
+	c.killed = true
+	c.mu.Unlock()
+	c.wake(true)
+	if !self {
+		c.conn.Close()
+	}
+}
+
+// block marks the client as blocked, the returned channel is closed if the client is woken by CLIENT UNBLOCK.
+// The returned function marks the client as not blocked anymore and tells if it was woken with an error.
+func (c *client) block() (<-chan struct{}, func() bool) {
+	if c == nil {
+		return nil, func() bool { return false }
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan struct{})
+	c.unblock = ch
+	c.unblockErr = false
+	return ch, func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.unblock = nil
+		select {
+		case <-ch:
+			return c.unblockErr
+		default:
+			return false
+		}
+	}
+}
+
+// wake unblocks the client if it is blocked, it returns false otherwise.
+func (c *client) wake(withErr bool) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.unblock == nil {
+		return false
+	}
+	c.unblockErr = withErr
+	close(c.unblock)
+	c.unblock = nil
+	return true
+}
+
+// addrs returns the remote and local addresses of the client, both are the path of the socket for a unix socket client.
+func (c *client) addrs() (string, string) {
+	if c.conn == nil {
+		return "", ""
+	}
+	if local := c.conn.LocalAddr(); local.Network() == "unix" {
+		return local.String() + ":0", local.String() + ":0"
+	}
+	return c.conn.RemoteAddr().String(), c.conn.LocalAddr().String()
+}
+
+func (c *client) flags() string {
+	f := ""
+	if c.typ == clientTypeReplica {
+		f += "S"
+	}
+	if c.typ == clientTypeMaster {
+		f += "M"
+	}
+	if c.typ == clientTypePubsub {
+		f += "P"
+	}
+	if c.multi >= 0 {
+		f += "x"
+	}
+	if c.unblock != nil {
+		f += "b"
+	}
+	if c.tracking.on {
+		f += "t"
+	}
+	if c.tracking.brokenRedir {
+		f += "R"
+	}
+	if c.tracking.bcast {
+		f += "B"
+	}
+	if c.noEvict {
+		f += "e"
+	}
+	if f == "" {
+		f = "N"
+	}
+	return f
+}
+
+// info formats the client like a line of CLIENT LIST.
+// ref: https://github.com/redis/redis/blob/7.2.0/src/networking.c#L2759
+func (c *client) info(now time.Time) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	addr, laddr := c.addrs()
+	return fmt.Sprintf("id=%d addr=%s laddr=%s name=%s age=%d idle=%d flags=%s db=%d multi=%d qbuf=%d obl=%d omem=%d cmd=%s user=default redir=%d resp=%d",
+		c.id, addr, laddr, c.name, int(now.Sub(c.created).Seconds()), int(now.Sub(c.lastInteraction).Seconds()),
+		c.flags(), c.db, c.multi, c.qbuf, c.obl, c.obl, c.lastCmd, c.tracking.trackingRedirect(), c.proto)
+}
+
+// subcommandCommands are recorded with their subcommand as the last command of a client, e.g. client|list.
+var subcommandCommands = map[string]bool{
+	"client": true,
+	"config": true,
+	"memory": true,
+	"object": true,
+}
+
+// clientOf returns the registry entry of the client replied through w, nil for the commands applied from the master.
+func clientOf(w io.Writer) *client {
+	if rw, ok := w.(*respWriter); ok {
+		return rw.state.client
+	}
+	return nil
+}
+
+// clientRegistry tracks the connected clients.
+type clientRegistry struct {
+	mu      sync.RWMutex
+	clients map[int64]*client
+}
+
+func newClientRegistry() *clientRegistry {
+	return &clientRegistry{clients: map[int64]*client{}}
+}
+
+func (r *clientRegistry) add(c *client) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.clients[c.id] = c
+}
+
+func (r *clientRegistry) remove(c *client) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.clients, c.id)
+}
+
+func (r *clientRegistry) get(id int64) (*client, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	c, ok := r.clients[id]
+	return c, ok
+}
+
+// list returns the clients ordered by id.
+func (r *clientRegistry) list() []*client {
+	r.mu.RLock()
+	res := make([]*client, 0, len(r.clients))
+	for _, c := range r.clients {
+		res = append(res, c)
+	}
+	r.mu.RUnlock()
+	slices.SortFunc(res, func(a, b *client) int { return int(a.id - b.id) })
+	return res
+}
+
+// clientPause is the state of CLIENT PAUSE, resumed is closed once the clients are unpaused.
+type clientPause struct {
+	mu      sync.Mutex
+	end     time.Time
+	all     bool
+	resumed chan struct{}
+}
+
+// pause pauses the clients until end, a pause in progress is only extended or made more restrictive.
+func (p *clientPause) pause(end time.Time, all bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.resumed == nil {
+		p.resumed = make(chan struct{})
+		p.end, p.all = end, all
+		return
+	}
+	if end.After(p.end) {
+		p.end = end
+	}
+	p.all = p.all || all
+}
+
+func (p *clientPause) unpause() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.unpauseLocked()
+}
+
+func (p *clientPause) unpauseLocked() {
+	if p.resumed != nil {
+		close(p.resumed)
+		p.resumed = nil
+	}
+}
+
+// blocks tells if a command is paused, write tells if the command may write.
+func (p *clientPause) blocks(write bool) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.resumed != nil && !time.Now().Before(p.end) {
+		p.unpauseLocked()
+	}
+	return p.resumed != nil && (p.all || write)
+}
+
+// wait waits until a command is not paused anymore.
+func (p *clientPause) wait(write bool) {
+	for p.blocks(write) {
+		p.mu.Lock()
+		resumed, d := p.resumed, time.Until(p.end)
+		p.mu.Unlock()
+		if resumed == nil {
+			return
+		}
+		timer := time.NewTimer(d)
+		select {
+		case <-resumed:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// mayWrite tells if a command, or the transaction for EXEC, is paused by CLIENT PAUSE WRITE.
+func mayWrite(cmd string, state *clientState) bool {
+	if cmd == "EXEC" {
+		for _, queued := range state.cmdQueue {
+			if isWrite(strings.ToUpper(queued[0])) {
+				return true
+			}
+		}
+		return false
+	}
+	return isWrite(cmd)
+}
+
+// validClientName tells if the name can be set by CLIENT SETNAME, only the printable characters without spaces are allowed.
+func validClientName(name string) bool {
+	for i := 0; i < len(name); i++ {
+		if name[i] < '!' || name[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// handleClient handles the CLIENT subcommands.
+func (s *server) handleClient(conn io.Writer, arr []string, state *clientState) error {
+	if len(arr) < 2 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	c := state.client
+	sub := strings.ToUpper(arr[1])
+	switch {
+	// CLIENT ID
+	case sub == "ID" && len(arr) == 2:
+		if _, err := conn.Write(resp.NewInt(int(c.id))); err != nil {
+			return fmt.Errorf("error writing to connection: %s", err.Error())
+		}
+		return nil
+	// CLIENT SETNAME connection-name
+	case sub == "SETNAME" && len(arr) == 3:
+		if !validClientName(arr[2]) {
+			return writeError(conn, errInvalidName)
+		}
+		c.setName(arr[2])
+		return writeOK(conn)
+	// CLIENT GETNAME
+	case sub == "GETNAME" && len(arr) == 2:
+		name := c.getName()
+		return writeBulkStringOrNull(conn, name, name != "")
+	// CLIENT INFO
+	case sub == "INFO" && len(arr) == 2:
+		c.update(state, c.out.Buffered())
+		if _, err := conn.Write(verbatimReply(conn, c.info(time.Now())+"\n")); err != nil {
+			return fmt.Errorf("error writing to connection: %s", err.Error())
+		}
+		return nil
+	// CLIENT LIST [TYPE type] [ID client-id [client-id ...]]
+	case sub == "LIST":
+		return s.handleClientList(conn, arr, state)
+	// CLIENT KILL ip:port | CLIENT KILL <filter> <value> [<filter> <value> ...]
+	case sub == "KILL" && len(arr) >= 3:
+		return s.handleClientKill(conn, arr, c)
+	// CLIENT PAUSE timeout [WRITE | ALL]
+	case sub == "PAUSE" && (len(arr) == 3 || len(arr) == 4):
+		ms, err := strconv.ParseInt(arr[2], 10, 64)
+		if err != nil || ms < 0 {
+			return writeError(conn, errClientTimeout)
+		}
+		all := true
+		if len(arr) == 4 {
+			switch strings.ToUpper(arr[3]) {
+			case "WRITE":
+				all = false
+			case "ALL":
+			default:
+				return writeError(conn, errSyntax)
+			}
+		}
+		s.pause.pause(time.Now().Add(time.Duration(ms)*time.Millisecond), all)
+		return writeOK(conn)
+	// CLIENT UNPAUSE
+	case sub == "UNPAUSE" && len(arr) == 2:
+		s.pause.unpause()
+		return writeOK(conn)
+	// CLIENT REPLY ON | OFF | SKIP
+	case sub == "REPLY" && len(arr) == 3:
+		switch strings.ToUpper(arr[2]) {
+		case "ON":
+			state.replyOff = false
+			c.out.setDiscard(false)
+			return writeOK(conn)
+		case "OFF":
+			state.replyOff = true
+		case "SKIP":
+			state.replySkip = true
+		default:
+			return writeError(conn, errSyntax)
+		}
+		// the replies are discarded from this command
+		c.out.setDiscard(true)
+		return nil
+	// CLIENT NO-EVICT ON | OFF
+	case sub == "NO-EVICT" && len(arr) == 3:
+		switch strings.ToUpper(arr[2]) {
+		case "ON", "OFF":
+			c.mu.Lock()
+			c.noEvict = strings.EqualFold(arr[2], "ON")
+			c.mu.Unlock()
+			return writeOK(conn)
+		default:
+			return writeError(conn, errSyntax)
+		}
+	// CLIENT TRACKING ON | OFF [REDIRECT client-id] [PREFIX prefix [PREFIX prefix ...]] [BCAST] [OPTIN] [OPTOUT] [NOLOOP]
+	case sub == "TRACKING" && len(arr) >= 3:
+		return s.handleClientTracking(conn, arr, c)
+	// CLIENT CACHING YES | NO
+	case sub == "CACHING" && len(arr) == 3:
+		return handleClientCaching(conn, arr, state)
+	// CLIENT GETREDIR
+	case sub == "GETREDIR" && len(arr) == 2:
+		c.mu.Lock()
+		redirect := c.tracking.trackingRedirect()
+		c.mu.Unlock()
+		return writeInt64(conn, redirect)
+	// CLIENT TRACKINGINFO
+	case sub == "TRACKINGINFO" && len(arr) == 2:
+		return handleClientTrackingInfo(conn, state)
+	// CLIENT UNBLOCK client-id [TIMEOUT | ERROR]
+	case sub == "UNBLOCK" && (len(arr) == 3 || len(arr) == 4):
+		id, err := strconv.ParseInt(arr[2], 10, 64)
+		if err != nil {
+			return writeError(conn, errNotInteger)
+		}
+		withErr := false
+		if len(arr) == 4 {
+			switch strings.ToUpper(arr[3]) {
+			case "TIMEOUT":
+			case "ERROR":
+				withErr = true
+			default:
+				return writeError(conn, errors.New("CLIENT UNBLOCK reason should be TIMEOUT or ERROR"))
+			}
+		}
+		target, ok := s.clients.get(id)
+		return writeBoolInt(conn, ok && target.wake(withErr))
+	}
+	return writeError(conn, fmt.Errorf("unknown subcommand or wrong number of arguments for '%s'. Try CLIENT HELP.", arr[1]))
+}
+
+func (s *server) handleClientList(conn io.Writer, arr []string, state *clientState) error {
+	typ := ""
+	var ids map[int64]bool
+	for i := 2; i < len(arr); i++ {
+		switch opt := strings.ToUpper(arr[i]); {
+		case opt == "TYPE" && i+1 < len(arr):
+			t, ok := parseClientType(arr[i+1])
+			if !ok {
+				return writeError(conn, fmt.Errorf("Unknown client type '%s'", arr[i+1]))
+			}
+			typ = t
+			i++
+		case opt == "ID" && i+1 < len(arr):
+			ids = map[int64]bool{}
+			for i++; i < len(arr); i++ {
+				id, err := strconv.ParseInt(arr[i], 10, 64)
+				if err != nil || id <= 0 {
+					return writeError(conn, errors.New("Invalid client ID"))
+				}
+				ids[id] = true
+			}
+		default:
+			return writeError(conn, errSyntax)
+		}
+	}
+	state.client.update(state, state.client.out.Buffered())
+	now := time.Now()
+	var sb strings.Builder
+	for _, c := range s.clients.list() {
+		c.mu.Lock()
+		ct := c.typ
+		c.mu.Unlock()
+		if (typ != "" && ct != typ) || (ids != nil && !ids[c.id]) {
+			continue
+		}
+		sb.WriteString(c.info(now))
+		sb.WriteString("\n")
+	}
+	if _, err := conn.Write(verbatimReply(conn, sb.String())); err != nil {
+		return fmt.Errorf("error writing to connection: %s", err.Error())
+	}
+	return nil
+}
+
+// parseClientType parses a client type of CLIENT LIST and CLIENT KILL, slave is an alias of replica.
+func parseClientType(s string) (string, bool) {
+	switch t := strings.ToLower(s); t {
+	case clientTypeNormal, clientTypeReplica, clientTypeMaster, clientTypePubsub:
+		return t, true
+	case "slave":
+		return clientTypeReplica, true
+	}
+	return "", false
+}
+
+// handleClientKill kills the clients matching all the filters. The old form with a single address replies OK,
+// the new form replies the number of killed clients.
+func (s *server) handleClientKill(conn io.Writer, arr []string, self *client) error {
+	var (
+		id         int64
+		addr       string
+		laddr      string
+		user       string
+		typ        string
+		maxAge     int64
+		skipMe     = true
+		filtersSet = false
+	)
+	if len(arr) == 3 {
+		addr = arr[2]
+	} else {
+		if len(arr)%2 != 0 {
+			return writeError(conn, errSyntax)
+		}
+		filtersSet = true
+		for i := 2; i < len(arr); i += 2 {
+			v := arr[i+1]
+			switch strings.ToUpper(arr[i]) {
+			case "ID":
+				n, err := strconv.ParseInt(v, 10, 64)
+				if err != nil || n <= 0 {
+					return writeError(conn, errors.New("client-id should be greater than 0"))
+				}
+				id = n
+			case "ADDR":
+				addr = v
+			case "LADDR":
+				laddr = v
+			case "USER":
+				user = v
+			case "TYPE":
+				t, ok := parseClientType(v)
+				if !ok {
+					return writeError(conn, fmt.Errorf("Unknown client type '%s'", v))
+				}
+				typ = t
+			case "SKIPME":
+				switch strings.ToLower(v) {
+				case "yes":
+					skipMe = true
+				case "no":
+					skipMe = false
+				default:
+					return writeError(conn, errSyntax)
+				}
+			case "MAXAGE":
+				n, err := strconv.ParseInt(v, 10, 64)
+				if err != nil {
+					return writeError(conn, errNotInteger)
+				}
+				maxAge = n
+			default:
+				return writeError(conn, errSyntax)
+			}
+		}
+	}
+	// the old form kills the current client too
+	if !filtersSet {
+		skipMe = false
+	}
+	now := time.Now()
+	killed := 0
+	for _, c := range s.clients.list() {
+		c.mu.Lock()
+		ct := c.typ
+		c.mu.Unlock()
+		caddr, claddr := c.addrs()
+		switch {
+		case id != 0 && c.id != id,
+			addr != "" && caddr != addr,
+			laddr != "" && claddr != laddr,
+			// all the clients are authenticated as the default user
+			user != "" && user != "default",
+			typ != "" && ct != typ,
+			maxAge != 0 && int64(now.Sub(c.created).Seconds()) < maxAge,
+			skipMe && c == self:
+			continue
+		}
+		c.kill(c == self)
+		killed++
+	}
+	if !filtersSet {
+		if killed == 0 {
+			return writeError(conn, errNoSuchClient)
+		}
+		return writeOK(conn)
+	}
+	if _, err := conn.Write(resp.NewInt(killed)); err != nil {
+		return fmt.Errorf("error writing to connection: %s", err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/database"
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient(t *testing.T) {
+	s := newServer(host, "0", []*database.DB{database.NewDB(), database.NewDB()}, RoleMaster, testCfg)
+	conn, r, _ := tcpClient(t, s)
+	other, otherR, _ := tcpClient(t, s)
+
+	require.Equal(t, 1, do(t, conn, r, "CLIENT", "ID"))
+	require.Equal(t, 2, do(t, other, otherR, "CLIENT", "ID"))
+	require.Nil(t, do(t, conn, r, "CLIENT", "GETNAME"))
+	require.Equal(t, errors.New("ERR Client names cannot contain spaces, newlines or special characters."), do(t, conn, r, "CLIENT", "SETNAME", "a b"))
+	require.Equal(t, "OK", do(t, conn, r, "CLIENT", "SETNAME", "app"))
+	require.Equal(t, "app", do(t, conn, r, "CLIENT", "GETNAME"))
+	require.Equal(t, "OK", do(t, conn, r, "SELECT", "1"))
+	require.Equal(t, "OK", do(t, conn, r, "MULTI"))
+
+	info := do(t, conn, r, "CLIENT", "INFO")
+	require.Equal(t, "QUEUED", info)
+	require.Equal(t, "OK", do(t, conn, r, "DISCARD"))
+	info = do(t, conn, r, "CLIENT", "INFO").(string)
+	require.Contains(t, info, fmt.Sprintf("id=1 addr=%s laddr=%s name=app ", conn.LocalAddr(), conn.RemoteAddr()))
+	require.Contains(t, info, " flags=N db=1 multi=-1 ")
+	require.Contains(t, info, " cmd=client|info user=default ")
+
+	list := strings.Split(strings.TrimSuffix(do(t, other, otherR, "CLIENT", "LIST").(string), "\n"), "\n")
+	require.Len(t, list, 2)
+	require.True(t, strings.HasPrefix(list[0], "id=1 "))
+	require.Contains(t, list[0], " cmd=client|info ")
+	require.True(t, strings.HasPrefix(list[1], "id=2 "))
+	require.Equal(t, list[1:], strings.Split(strings.TrimSuffix(do(t, other, otherR, "CLIENT", "LIST", "ID", "2", "3").(string), "\n"), "\n"))
+	require.Equal(t, "", do(t, other, otherR, "CLIENT", "LIST", "TYPE", "replica"))
+	require.Equal(t, errors.New("ERR Unknown client type 'foo'"), do(t, other, otherR, "CLIENT", "LIST", "TYPE", "foo"))
+
+	// the replies are dropped until CLIENT REPLY ON, or for the next command with SKIP
+	_, err := conn.Write(pipeline(1, "CLIENT", "REPLY", "OFF"))
+	require.NoError(t, err)
+	_, err = conn.Write(pipeline(1, "SET", "k", "1"))
+	require.NoError(t, err)
+	require.Equal(t, "OK", do(t, conn, r, "CLIENT", "REPLY", "ON"))
+	_, err = conn.Write(pipeline(1, "CLIENT", "REPLY", "SKIP"))
+	require.NoError(t, err)
+	_, err = conn.Write(pipeline(1, "GET", "k"))
+	require.NoError(t, err)
+	require.Equal(t, "1", do(t, conn, r, "GET", "k"))
+}
+
+func TestClientKill(t *testing.T) {
+	s := newServer(host, "0", []*database.DB{database.NewDB()}, RoleMaster, testCfg)
+	conn, r, _ := tcpClient(t, s)
+	victim, victimR, _ := tcpClient(t, s)
+	require.Equal(t, "PONG", do(t, victim, victimR, "PING"))
+
+	require.Equal(t, errors.New("ERR No such client"), do(t, conn, r, "CLIENT", "KILL", "127.0.0.1:1"))
+	require.Equal(t, "OK", do(t, conn, r, "CLIENT", "KILL", victim.LocalAddr().String()))
+	_, err := readReply(victimR)
+	require.Error(t, err)
+
+	victim, victimR, _ = tcpClient(t, s)
+	require.Equal(t, "PONG", do(t, victim, victimR, "PING"))
+	require.Equal(t, 0, do(t, conn, r, "CLIENT", "KILL", "TYPE", "replica"))
+	require.Equal(t, 0, do(t, conn, r, "CLIENT", "KILL", "USER", "admin"))
+	require.Equal(t, errors.New("ERR syntax error"), do(t, conn, r, "CLIENT", "KILL", "ID", "2", "TYPE"))
+	// the current client is skipped unless SKIPME no
+	require.Equal(t, 1, do(t, conn, r, "CLIENT", "KILL", "TYPE", "normal", "USER", "default"))
+	_, err = readReply(victimR)
+	require.Error(t, err)
+	require.Equal(t, 1, do(t, conn, r, "CLIENT", "KILL", "ID", "1", "SKIPME", "no"))
+	_, err = readReply(r)
+	require.Error(t, err)
+	require.Eventually(t, func() bool { return len(s.clients.list()) == 0 }, time.Second, 10*time.Millisecond)
+}
+
+func TestClientPause(t *testing.T) {
+	s := newServer(host, "0", []*database.DB{database.NewDB()}, RoleMaster, testCfg)
+	conn, r, _ := tcpClient(t, s)
+	paused, pausedR, _ := tcpClient(t, s)
+
+	require.Equal(t, errors.New("ERR timeout is not an integer or out of range"), do(t, conn, r, "CLIENT", "PAUSE", "x"))
+	require.Equal(t, errors.New("ERR syntax error"), do(t, conn, r, "CLIENT", "PAUSE", "100", "READ"))
+
+	// the writes are held until CLIENT UNPAUSE, the reads are not
+	require.Equal(t, "OK", do(t, conn, r, "CLIENT", "PAUSE", "10000", "WRITE"))
+	require.Nil(t, do(t, paused, pausedR, "GET", "k"))
+	_, err := paused.Write(pipeline(1, "SET", "k", "v"))
+	require.NoError(t, err)
+	time.Sleep(50 * time.Millisecond)
+	require.Nil(t, do(t, conn, r, "GET", "k"))
+	require.Equal(t, "OK", do(t, conn, r, "CLIENT", "UNPAUSE"))
+	res, err := readReply(pausedR)
+	require.NoError(t, err)
+	require.Equal(t, "OK", res)
+
+	// all the commands are held until the timeout
+	start := time.Now()
+	require.Equal(t, "OK", do(t, conn, r, "CLIENT", "PAUSE", "100", "ALL"))
+	require.Equal(t, "v", do(t, paused, pausedR, "GET", "k"))
+	require.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+}
+
+func TestClientUnblock(t *testing.T) {
+	s := newServer(host, "0", []*database.DB{database.NewDB()}, RoleMaster, testCfg)
+	conn, r, _ := tcpClient(t, s)
+	blocked, blockedR, _ := tcpClient(t, s)
+
+	require.Equal(t, 0, do(t, conn, r, "CLIENT", "UNBLOCK", "2"))
+	require.Equal(t, errors.New("ERR CLIENT UNBLOCK reason should be TIMEOUT or ERROR"), do(t, conn, r, "CLIENT", "UNBLOCK", "2", "foo"))
+
+	for _, tc := range []struct {
+		args []string
+		want any
+	}{
+		{args: []string{"CLIENT", "UNBLOCK", "2"}, want: nil},
+		{args: []string{"CLIENT", "UNBLOCK", "2", "ERROR"}, want: errors.New("UNBLOCKED client unblocked via CLIENT UNBLOCK")},
+	} {
+		for _, block := range []string{"0", "10000"} {
+			_, err := blocked.Write(resp.NewCommand([]string{"XREAD", "BLOCK", block, "STREAMS", "s", "$"}))
+			require.NoError(t, err)
+			require.Eventually(t, func() bool {
+				return strings.Contains(do(t, conn, r, "CLIENT", "LIST", "ID", "2").(string), " flags=b ")
+			}, time.Second, 5*time.Millisecond)
+			require.Equal(t, 1, do(t, conn, r, tc.args...))
+			res, err := readReply(blockedR)
+			require.NoError(t, err)
+			require.Equal(t, tc.want, res)
+		}
+	}
+	// the entries added later are not sent to the abandoned waits
+	require.Equal(t, "1-1", do(t, conn, r, "XADD", "s", "1-1", "f", "v"))
+	require.Equal(t, "PONG", do(t, blocked, blockedR, "PING"))
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/database"
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+var (
+	errCMSBadWidth     = errors.New("CMS: invalid width")
+	errCMSBadDepth     = errors.New("CMS: invalid depth")
+	errCMSBadErrorRate = errors.New("CMS: invalid overestimation value")
+	errCMSBadProb      = errors.New("CMS: invalid prob value")
+	errCMSBadNumber    = errors.New("CMS: Cannot parse number")
+	errCMSBadNumKeys   = errors.New("CMS: invalid numkeys")
+	errCMSWrongNumKeys = errors.New("CMS: wrong number of keys")
+	errCMSBadWeight    = errors.New("CMS: invalid weight value")
+	errCMSWrongWeights = errors.New("CMS: wrong number of keys/weights")
+)
+
+func writeUint32s(conn io.Writer, counts []uint32) error {
+	res := make([][]byte, len(counts))
+	for i, c := range counts {
+		res[i] = resp.NewInt(int(c))
+	}
+	if _, err := conn.Write(resp.NewArray(res)); err != nil {
+		return fmt.Errorf("error writing to connection: %s", err.Error())
+	}
+	return nil
+}
+
+// handleCMSInit handles CMS.INITBYDIM and CMS.INITBYPROB, the sketch is limited to maxValueSize.
+func (s *server) handleCMSInit(conn io.Writer, arr []string, db *database.DB) error {
+	if len(arr) != 4 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	var width, depth uint64
+	if strings.EqualFold(arr[0], "CMS.INITBYDIM") {
+		var err error
+		if width, err = strconv.ParseUint(arr[2], 10, 32); err != nil || width == 0 {
+			return writeError(conn, errCMSBadWidth)
+		}
+		if depth, err = strconv.ParseUint(arr[3], 10, 32); err != nil || depth == 0 {
+			return writeError(conn, errCMSBadDepth)
+		}
+	} else {
+		errorRate, err := strconv.ParseFloat(arr[2], 64)
+		if err != nil || errorRate <= 0 || errorRate >= 1 {
+			return writeError(conn, errCMSBadErrorRate)
+		}
+		prob, err := strconv.ParseFloat(arr[3], 64)
+		if err != nil || prob <= 0 || prob >= 1 {
+			return writeError(conn, errCMSBadProb)
+		}
+		width, depth = database.CMSDimsByProb(errorRate, prob)
+	}
+	if err := db.CMSInitByDim(arr[1], width, depth, s.maxValueSize()); err != nil {
+		return writeError(conn, err)
+	}
+	return writeOK(conn)
+}
+
+func handleCMSIncrBy(conn io.Writer, arr []string, db *database.DB) error {
+	if len(arr) < 4 || len(arr)%2 != 0 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	incrs := make([]database.CMSIncr, 0, (len(arr)-2)/2)
+	for i := 2; i < len(arr); i += 2 {
+		n, err := strconv.ParseUint(arr[i+1], 10, 32)
+		if err != nil {
+			return writeError(conn, errCMSBadNumber)
+		}
+		incrs = append(incrs, database.CMSIncr{Item: arr[i], Value: uint32(n)})
+	}
+	counts, err := db.CMSIncrBy(arr[1], incrs)
+	if err != nil {
+		return writeError(conn, err)
+	}
+	return writeUint32s(conn, counts)
+}
+
+func handleCMSQuery(conn io.Writer, arr []string, db *database.DB) error {
+	if len(arr) < 3 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	counts, err := db.CMSQuery(arr[1], arr[2:])
+	if err != nil {
+		return writeError(conn, err)
+	}
+	return writeUint32s(conn, counts)
+}
+
+func handleCMSMerge(conn io.Writer, arr []string, db *database.DB) error {
+	if len(arr) < 4 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	numKeys, err := strconv.Atoi(arr[2])
+	if err != nil || numKeys < 1 {
+		return writeError(conn, errCMSBadNumKeys)
+	}
+	if len(arr) < 3+numKeys {
+		return writeError(conn, errCMSWrongNumKeys)
+	}
+	keys := arr[3 : 3+numKeys]
+	weights := make([]int64, numKeys)
+	for i := range weights {
+		weights[i] = 1
+	}
+	switch rest := arr[3+numKeys:]; {
+	case len(rest) == 0:
+	case strings.EqualFold(rest[0], "WEIGHTS") && len(rest) == numKeys+1:
+		for i, w := range rest[1:] {
+			if weights[i], err = strconv.ParseInt(w, 10, 64); err != nil {
+				return writeError(conn, errCMSBadWeight)
+			}
+		}
+	default:
+		return writeError(conn, errCMSWrongWeights)
+	}
+	if err := db.CMSMerge(arr[1], keys, weights); err != nil {
+		return writeError(conn, err)
+	}
+	return writeOK(conn)
+}
+
+func handleCMSInfo(conn io.Writer, arr []string, db *database.DB) error {
+	if len(arr) != 2 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	info, err := db.CMSInfo(arr[1])
+	if err != nil {
+		return writeError(conn, err)
+	}
+	return writeInfo(conn, []string{"width", "depth", "count"},
+		[]*int64{int64Ptr(int64(info.Width)), int64Ptr(int64(info.Depth)), int64Ptr(int64(info.Count))})
+}
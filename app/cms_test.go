@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/codecrafters-io/redis-starter-go/app/database"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCMSCommands(t *testing.T) {
+	s := newServer(host, "0", []*database.DB{database.NewDB()}, RoleMaster, testCfg)
+	conn, r := pipeClient(t, s)
+
+	require.Equal(t, "OK", do(t, conn, r, "CMS.INITBYDIM", "a", "1000", "5"))
+	require.Equal(t, "OK", do(t, conn, r, "CMS.INITBYPROB", "b", "0.002", "0.01"))
+	require.Equal(t, "ERR CMS: key already exists", do(t, conn, r, "CMS.INITBYDIM", "a", "10", "5").(error).Error())
+	require.Equal(t, []any{"width", 1000, "depth", 7, "count", 0}, do(t, conn, r, "CMS.INFO", "b"))
+	require.Equal(t, "CMSk-TYPE", do(t, conn, r, "TYPE", "a"))
+
+	require.Equal(t, []any{3, 1}, do(t, conn, r, "CMS.INCRBY", "a", "x", "3", "y", "1"))
+	require.Equal(t, []any{3, 1, 0}, do(t, conn, r, "CMS.QUERY", "a", "x", "y", "z"))
+	require.Equal(t, "OK", do(t, conn, r, "CMS.INITBYDIM", "dst", "1000", "5"))
+	require.Equal(t, "OK", do(t, conn, r, "CMS.MERGE", "dst", "2", "a", "a", "WEIGHTS", "2", "3"))
+	require.Equal(t, []any{15, 5}, do(t, conn, r, "CMS.QUERY", "dst", "x", "y"))
+
+	require.Equal(t, "ERR CMS: width/depth is not equal", do(t, conn, r, "CMS.MERGE", "dst", "1", "b").(error).Error())
+	require.Equal(t, "ERR CMS: wrong number of keys/weights", do(t, conn, r, "CMS.MERGE", "dst", "1", "a", "WEIGHTS").(error).Error())
+	require.Equal(t, "ERR CMS: wrong number of keys", do(t, conn, r, "CMS.MERGE", "dst", "3", "a", "a").(error).Error())
+	require.Equal(t, "ERR CMS: key does not exist", do(t, conn, r, "CMS.QUERY", "missing", "x").(error).Error())
+	require.Equal(t, "ERR CMS: Cannot parse number", do(t, conn, r, "CMS.INCRBY", "a", "x", "-1").(error).Error())
+	require.Equal(t, "ERR CMS: invalid width", do(t, conn, r, "CMS.INITBYDIM", "c", "0", "5").(error).Error())
+	require.Equal(t, "ERR CMS: invalid prob value", do(t, conn, r, "CMS.INITBYPROB", "c", "0.1", "1").(error).Error())
+}
+
+func TestSketchSizeLimits(t *testing.T) {
+	s := newServer(host, "0", []*database.DB{database.NewDB()}, RoleMaster, testCfg)
+	conn, r := pipeClient(t, s)
+
+	cmsTooLarge := "ERR CMS: Insufficient memory to create the sketch"
+	require.Equal(t, cmsTooLarge, do(t, conn, r, "CMS.INITBYDIM", "c", "4294967295", "4294967295").(error).Error())
+	require.Equal(t, cmsTooLarge, do(t, conn, r, "CMS.INITBYPROB", "c", "1e-300", "0.5").(error).Error())
+	topKTooLarge := "ERR TopK: Insufficient memory to create the filter"
+	require.Equal(t, topKTooLarge, do(t, conn, r, "TOPK.RESERVE", "t", "1", "4294967295", "4294967295", "0.9").(error).Error())
+	require.Equal(t, topKTooLarge, do(t, conn, r, "TOPK.RESERVE", "t", "4294967295").(error).Error())
+	require.Equal(t, "none", do(t, conn, r, "TYPE", "c"))
+	require.Equal(t, "none", do(t, conn, r, "TYPE", "t"))
+	require.Equal(t, "PONG", do(t, conn, r, "PING"))
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/database"
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+// formatFloat formats a score the way redis replies it: the shortest representation,
+// without exponent unless the value is very large or very small (like %.17g).
+func formatFloat(f float64) string {
+	switch abs := math.Abs(f); {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	case f == 0 || (abs >= 1e-4 && abs < 1e17):
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	default:
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+}
+
+func handleHSet(conn io.Writer, arr []string, db *database.DB) error {
+	if len(arr) < 4 || len(arr)%2 != 0 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	kvs := make([]database.KeyValue, 0, (len(arr)-2)/2)
+	for i := 2; i < len(arr); i += 2 {
+		kvs = append(kvs, database.KeyValue{Key: arr[i], Value: arr[i+1]})
+	}
+	added, err := db.HSet(arr[1], kvs)
+	if err != nil {
+		return writeError(conn, err)
+	}
+	if _, err := conn.Write(resp.NewInt(added)); err != nil {
+		return fmt.Errorf("error writing to connection: %s", err.Error())
+	}
+	return nil
+}
+
+func handleSAdd(conn io.Writer, arr []string, db *database.DB) error {
+	if len(arr) < 3 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	added, err := db.SAdd(arr[1], arr[2:])
+	if err != nil {
+		return writeError(conn, err)
+	}
+	if _, err := conn.Write(resp.NewInt(added)); err != nil {
+		return fmt.Errorf("error writing to connection: %s", err.Error())
+	}
+	return nil
+}
+
+func handleZAdd(conn io.Writer, arr []string, db *database.DB) error {
+	if len(arr) < 4 || len(arr)%2 != 0 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	members := make([]database.ZMember, 0, (len(arr)-2)/2)
+	for i := 2; i < len(arr); i += 2 {
+		score, err := strconv.ParseFloat(arr[i], 64)
+		if err != nil || math.IsNaN(score) {
+			return writeError(conn, fmt.Errorf("value is not a valid float"))
+		}
+		members = append(members, database.ZMember{Member: arr[i+1], Score: score})
+	}
+	added, err := db.ZAdd(arr[1], members)
+	if err != nil {
+		return writeError(conn, err)
+	}
+	if _, err := conn.Write(resp.NewInt(added)); err != nil {
+		return fmt.Errorf("error writing to connection: %s", err.Error())
+	}
+	return nil
+}
+
+// handlePush handles LPUSH and RPUSH.
+func handlePush(conn io.Writer, arr []string, db *database.DB) error {
+	if len(arr) < 3 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	push := db.RPush
+	if strings.EqualFold(arr[0], "LPUSH") {
+		push = db.LPush
+	}
+	n, err := push(arr[1], arr[2:])
+	if err != nil {
+		return writeError(conn, err)
+	}
+	if _, err := conn.Write(resp.NewInt(n)); err != nil {
+		return fmt.Errorf("error writing to connection: %s", err.Error())
+	}
+	return nil
+}
+
+func handleLRange(conn io.Writer, arr []string, db *database.DB) error {
+	if len(arr) != 4 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	start, err1 := strconv.Atoi(arr[2])
+	stop, err2 := strconv.Atoi(arr[3])
+	if err1 != nil || err2 != nil {
+		return writeError(conn, fmt.Errorf("value is not an integer or out of range"))
+	}
+	elems, err := db.LRange(arr[1], start, stop)
+	if err != nil {
+		return writeError(conn, err)
+	}
+	res := make([][]byte, len(elems))
+	for i, e := range elems {
+		res[i] = resp.NewBulkString(e)
+	}
+	if _, err := conn.Write(resp.NewArray(res)); err != nil {
+		return fmt.Errorf("error writing to connection: %s", err.Error())
+	}
+	return nil
+}
+
+func handleHGetAll(conn io.Writer, arr []string, db *database.DB) error {
+	if len(arr) != 2 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	kvs, err := db.HGetAll(arr[1])
+	if err != nil {
+		return writeError(conn, err)
+	}
+	res := make([][]byte, 0, len(kvs)*2)
+	for _, kv := range kvs {
+		res = append(res, resp.NewBulkString(kv.Key), resp.NewBulkString(kv.Value))
+	}
+	if _, err := conn.Write(mapReply(conn, res)); err != nil {
+		return fmt.Errorf("error writing to connection: %s", err.Error())
+	}
+	return nil
+}
+
+func handleZScore(conn io.Writer, arr []string, db *database.DB) error {
+	if len(arr) != 3 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	score, ok, err := db.ZScore(arr[1], arr[2])
+	if err != nil {
+		return writeError(conn, err)
+	}
+	res := nullBulkString(conn)
+	if ok {
+		res = doubleReply(conn, score)
+	}
+	if _, err := conn.Write(res); err != nil {
+		return fmt.Errorf("error writing to connection: %s", err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// commandFlags describe how a command is handled around its execution, like the command flags of redis.
+type commandFlags uint8
+
+const (
+	// cmdWrite commands modify their keys: they are propagated to the replicas, paused by CLIENT PAUSE WRITE
+	// and invalidate the keys tracked by the clients.
+	cmdWrite commandFlags = 1 << iota
+	// cmdDenyOOM commands are rejected when the memory is over maxmemory and nothing can be evicted.
+	cmdDenyOOM
+	// cmdNoPropagate write commands are not propagated by the dispatcher. XADD is not propagated yet since an
+	// auto-generated ID would differ on the replicas.
+	cmdNoPropagate
+)
+
+// keySpec gives the keys of a command like the key specs of redis: the index of the first key, the index of
+// the last key, negative to count from the end, and the step between the keys. The zero value has no keys.
+type keySpec struct {
+	first, last, step int
+}
+
+// commandInfo is the entry of a command in the command table.
+type commandInfo struct {
+	flags commandFlags
+	keys  keySpec
+	// getKeys returns the keys of the commands whose key positions depend on their arguments, instead of keys.
+	getKeys func(arr []string) []string
+}
+
+// commandTable has every command dispatched by handleWriteOnlyCmd, a command missing from it is unknown.
+// Adding a command therefore requires giving its flags and its keys, which client tracking relies on.
+var commandTable = map[string]commandInfo{
+	"PING":           {},
+	"ECHO":           {},
+	"SET":            {flags: cmdWrite | cmdDenyOOM, keys: keySpec{1, 1, 1}},
+	"GET":            {keys: keySpec{1, 1, 1}},
+	"INCR":           {flags: cmdWrite | cmdDenyOOM, keys: keySpec{1, 1, 1}},
+	"XADD":           {flags: cmdWrite | cmdDenyOOM | cmdNoPropagate, keys: keySpec{1, 1, 1}},
+	"XRANGE":         {keys: keySpec{1, 1, 1}},
+	"XREAD":          {getKeys: xreadKeys},
+	"MULTI":          {},
+	"TYPE":           {keys: keySpec{1, 1, 1}},
+	"KEYS":           {},
+	"SCAN":           {},
+	"HSCAN":          {keys: keySpec{1, 1, 1}},
+	"SSCAN":          {keys: keySpec{1, 1, 1}},
+	"ZSCAN":          {keys: keySpec{1, 1, 1}},
+	"HSET":           {flags: cmdWrite | cmdDenyOOM, keys: keySpec{1, 1, 1}},
+	"SADD":           {flags: cmdWrite | cmdDenyOOM, keys: keySpec{1, 1, 1}},
+	"ZADD":           {flags: cmdWrite | cmdDenyOOM, keys: keySpec{1, 1, 1}},
+	"HGETALL":        {keys: keySpec{1, 1, 1}},
+	"ZSCORE":         {keys: keySpec{1, 1, 1}},
+	"LPUSH":          {flags: cmdWrite | cmdDenyOOM, keys: keySpec{1, 1, 1}},
+	"RPUSH":          {flags: cmdWrite | cmdDenyOOM, keys: keySpec{1, 1, 1}},
+	"LRANGE":         {keys: keySpec{1, 1, 1}},
+	"SORT":           {flags: cmdDenyOOM, getKeys: sortKeys}, // handleSort propagates and invalidates a STORE
+	"SORT_RO":        {getKeys: sortKeys},
+	"SETBIT":         {flags: cmdWrite | cmdDenyOOM, keys: keySpec{1, 1, 1}},
+	"GETBIT":         {keys: keySpec{1, 1, 1}},
+	"BITCOUNT":       {keys: keySpec{1, 1, 1}},
+	"BITPOS":         {keys: keySpec{1, 1, 1}},
+	"BITOP":          {flags: cmdWrite | cmdDenyOOM, keys: keySpec{2, -1, 1}},
+	"BITFIELD":       {flags: cmdWrite | cmdDenyOOM, keys: keySpec{1, 1, 1}},
+	"BITFIELD_RO":    {keys: keySpec{1, 1, 1}},
+	"PFADD":          {flags: cmdWrite | cmdDenyOOM, keys: keySpec{1, 1, 1}},
+	"PFCOUNT":        {keys: keySpec{1, -1, 1}},
+	"PFMERGE":        {flags: cmdWrite | cmdDenyOOM, keys: keySpec{1, -1, 1}},
+	"PFDEBUG":        {keys: keySpec{2, 2, 1}},
+	"LCS":            {keys: keySpec{1, 2, 1}},
+	"GEOADD":         {flags: cmdWrite | cmdDenyOOM, keys: keySpec{1, 1, 1}},
+	"GEODIST":        {keys: keySpec{1, 1, 1}},
+	"GEOPOS":         {keys: keySpec{1, 1, 1}},
+	"GEOHASH":        {keys: keySpec{1, 1, 1}},
+	"GEOSEARCH":      {keys: keySpec{1, 1, 1}},
+	"GEOSEARCHSTORE": {flags: cmdWrite | cmdDenyOOM, keys: keySpec{1, 2, 1}},
+	"BF.RESERVE":     {flags: cmdWrite | cmdDenyOOM, keys: keySpec{1, 1, 1}},
+	"BF.ADD":         {flags: cmdWrite | cmdDenyOOM, keys: keySpec{1, 1, 1}},
+	"BF.MADD":        {flags: cmdWrite | cmdDenyOOM, keys: keySpec{1, 1, 1}},
+	"BF.EXISTS":      {keys: keySpec{1, 1, 1}},
+	"BF.MEXISTS":     {keys: keySpec{1, 1, 1}},
+	"BF.INFO":        {keys: keySpec{1, 1, 1}},
+	"CF.RESERVE":     {flags: cmdWrite | cmdDenyOOM, keys: keySpec{1, 1, 1}},
+	"CF.ADD":         {flags: cmdWrite | cmdDenyOOM, keys: keySpec{1, 1, 1}},
+	"CF.ADDNX":       {flags: cmdWrite | cmdDenyOOM, keys: keySpec{1, 1, 1}},
+	"CF.EXISTS":      {keys: keySpec{1, 1, 1}},
+	"CF.MEXISTS":     {keys: keySpec{1, 1, 1}},
+	"CF.DEL":         {flags: cmdWrite, keys: keySpec{1, 1, 1}},
+	"CF.COUNT":       {keys: keySpec{1, 1, 1}},
+	"CF.INFO":        {keys: keySpec{1, 1, 1}},
+	"CMS.INITBYDIM":  {flags: cmdWrite | cmdDenyOOM, keys: keySpec{1, 1, 1}},
+	"CMS.INITBYPROB": {flags: cmdWrite | cmdDenyOOM, keys: keySpec{1, 1, 1}},
+	"CMS.INCRBY":     {flags: cmdWrite, keys: keySpec{1, 1, 1}},
+	"CMS.QUERY":      {keys: keySpec{1, 1, 1}},
+	"CMS.MERGE":      {flags: cmdWrite | cmdDenyOOM, getKeys: cmsMergeKeys},
+	"CMS.INFO":       {keys: keySpec{1, 1, 1}},
+	"TOPK.RESERVE":   {flags: cmdWrite | cmdDenyOOM, keys: keySpec{1, 1, 1}},
+	"TOPK.ADD":       {flags: cmdWrite | cmdDenyOOM, keys: keySpec{1, 1, 1}},
+	"TOPK.INCRBY":    {flags: cmdWrite | cmdDenyOOM, keys: keySpec{1, 1, 1}},
+	"TOPK.QUERY":     {keys: keySpec{1, 1, 1}},
+	"TOPK.LIST":      {keys: keySpec{1, 1, 1}},
+	"TOPK.INFO":      {keys: keySpec{1, 1, 1}},
+	"JSON.SET":       {flags: cmdWrite | cmdDenyOOM, keys: keySpec{1, 1, 1}},
+	"JSON.GET":       {keys: keySpec{1, 1, 1}},
+	"JSON.MGET":      {keys: keySpec{1, -2, 1}},
+	"JSON.DEL":       {flags: cmdWrite, keys: keySpec{1, 1, 1}},
+	"JSON.FORGET":    {flags: cmdWrite, keys: keySpec{1, 1, 1}},
+	"JSON.TYPE":      {keys: keySpec{1, 1, 1}},
+	"JSON.NUMINCRBY": {flags: cmdWrite | cmdDenyOOM, keys: keySpec{1, 1, 1}},
+	"JSON.STRAPPEND": {flags: cmdWrite | cmdDenyOOM, keys: keySpec{1, 1, 1}},
+	"JSON.ARRAPPEND": {flags: cmdWrite | cmdDenyOOM, keys: keySpec{1, 1, 1}},
+	"JSON.ARRINDEX":  {keys: keySpec{1, 1, 1}},
+	"JSON.ARRINSERT": {flags: cmdWrite | cmdDenyOOM, keys: keySpec{1, 1, 1}},
+	"JSON.ARRLEN":    {keys: keySpec{1, 1, 1}},
+	"JSON.ARRPOP":    {flags: cmdWrite, keys: keySpec{1, 1, 1}},
+	"JSON.ARRTRIM":   {flags: cmdWrite, keys: keySpec{1, 1, 1}},
+	"JSON.OBJKEYS":   {keys: keySpec{1, 1, 1}},
+	"CLIENT":         {},
+	"SUBSCRIBE":      {},
+	"UNSUBSCRIBE":    {},
+	"PSUBSCRIBE":     {},
+	"PUNSUBSCRIBE":   {},
+	"PUBLISH":        {},
+	"HELLO":          {},
+	"AUTH":           {},
+	"SELECT":         {},
+	"RENAME":         {flags: cmdWrite, keys: keySpec{1, 2, 1}},
+	"RENAMENX":       {flags: cmdWrite, keys: keySpec{1, 2, 1}},
+	"MOVE":           {flags: cmdWrite, keys: keySpec{1, 1, 1}},
+	"COPY":           {flags: cmdWrite | cmdDenyOOM, keys: keySpec{1, 2, 1}},
+	"DEL":            {flags: cmdWrite, keys: keySpec{1, -1, 1}},
+	"UNLINK":         {flags: cmdWrite, keys: keySpec{1, -1, 1}},
+	"FLUSHDB":        {flags: cmdWrite},
+	"FLUSHALL":       {flags: cmdWrite},
+	"DBSIZE":         {},
+	"RANDOMKEY":      {},
+	"OBJECT":         {keys: keySpec{2, 2, 1}},
+	"DEBUG":          {},
+	"SWAPDB":         {flags: cmdWrite},
+	"MEMORY":         {keys: keySpec{2, 2, 1}},
+	"INFO":           {},
+	"REPLCONF":       {},
+	"WAIT":           {},
+	"CONFIG":         {},
+}
+
+// isWrite tells if a command modifies its keys.
+func isWrite(cmd string) bool {
+	return commandTable[cmd].flags&cmdWrite != 0
+}
+
+// commandKeys returns the keys read or written by a command.
+func commandKeys(cmd string, arr []string) []string {
+	info := commandTable[cmd]
+	if info.getKeys != nil {
+		return info.getKeys(arr)
+	}
+	spec := info.keys
+	if spec.step == 0 {
+		return nil
+	}
+	last := spec.last
+	if last < 0 {
+		last += len(arr)
+	}
+	if last >= len(arr) {
+		last = len(arr) - 1
+	}
+	var keys []string
+	for i := spec.first; i <= last; i += spec.step {
+		keys = append(keys, arr[i])
+	}
+	return keys
+}
+
+// xreadKeys returns the keys of XREAD [COUNT count] [BLOCK milliseconds] STREAMS key [key ...] id [id ...].
+func xreadKeys(arr []string) []string {
+	for i, arg := range arr {
+		if strings.ToUpper(arg) == "STREAMS" {
+			return arr[i+1 : i+1+(len(arr)-i-1)/2]
+		}
+	}
+	return nil
+}
+
+// cmsMergeKeys returns the keys of CMS.MERGE destination numKeys source [source ...] [WEIGHTS weight [weight ...]].
+func cmsMergeKeys(arr []string) []string {
+	if len(arr) < 3 {
+		return nil
+	}
+	n, err := strconv.Atoi(arr[2])
+	if err != nil || n < 0 || 3+n > len(arr) {
+		return arr[1:2]
+	}
+	return append([]string{arr[1]}, arr[3:3+n]...)
+}
+
+// sortKeys returns the keys of SORT and SORT_RO key [... STORE destination].
+func sortKeys(arr []string) []string {
+	var keys []string
+	if len(arr) > 1 {
+		keys = append(keys, arr[1])
+	}
+	for i := 2; i+1 < len(arr); i++ {
+		if strings.ToUpper(arr[i]) == "STORE" {
+			keys = append(keys, arr[i+1])
+		}
+	}
+	return keys
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/codecrafters-io/redis-starter-go/app/database"
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCommandTable checks that the command table has exactly the commands dispatched by handleWriteOnlyCmd.
+func TestCommandTable(t *testing.T) {
+	f, err := parser.ParseFile(token.NewFileSet(), "server.go", nil, 0)
+	require.NoError(t, err)
+	var dispatched []string
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Name.Name != "handleWriteOnlyCmd" {
+			continue
+		}
+		for _, stmt := range fn.Body.List {
+			sw, ok := stmt.(*ast.SwitchStmt)
+			if !ok {
+				continue
+			}
+			for _, clause := range sw.Body.List {
+				for _, expr := range clause.(*ast.CaseClause).List {
+					name, err := strconv.Unquote(expr.(*ast.BasicLit).Value)
+					require.NoError(t, err)
+					dispatched = append(dispatched, name)
+				}
+			}
+		}
+	}
+	var table []string
+	for name := range commandTable {
+		table = append(table, name)
+	}
+	slices.Sort(dispatched)
+	slices.Sort(table)
+	require.Equal(t, dispatched, table)
+}
+
+func TestCommandKeys(t *testing.T) {
+	require.Equal(t, []string{"k"}, commandKeys("GET", []string{"GET", "k"}))
+	require.Equal(t, []string{"a", "b"}, commandKeys("DEL", []string{"DEL", "a", "b"}))
+	require.Equal(t, []string{"dst", "a", "b"}, commandKeys("BITOP", []string{"BITOP", "AND", "dst", "a", "b"}))
+	require.Equal(t, []string{"a", "b"}, commandKeys("JSON.MGET", []string{"JSON.MGET", "a", "b", "$"}))
+	require.Equal(t, []string{"a", "b"}, commandKeys("XREAD", []string{"XREAD", "BLOCK", "0", "STREAMS", "a", "b", "0", "0"}))
+	require.Equal(t, []string{"dst", "a"}, commandKeys("CMS.MERGE", []string{"CMS.MERGE", "dst", "1", "a", "WEIGHTS", "1"}))
+	require.Equal(t, []string{"k", "dst"}, commandKeys("SORT", []string{"SORT", "k", "ALPHA", "STORE", "dst"}))
+	require.Equal(t, []string{"k"}, commandKeys("SORT_RO", []string{"SORT_RO", "k", "ALPHA"}))
+	require.Equal(t, []string{"k"}, commandKeys("BITFIELD_RO", []string{"BITFIELD_RO", "k", "GET", "u8", "0"}))
+	require.Equal(t, []string{"k"}, commandKeys("BF.MEXISTS", []string{"BF.MEXISTS", "k", "a", "b"}))
+	require.Equal(t, []string{"k"}, commandKeys("CF.MEXISTS", []string{"CF.MEXISTS", "k", "a", "b"}))
+	require.Nil(t, commandKeys("PING", []string{"PING"}))
+	require.Nil(t, commandKeys("OBJECT", []string{"OBJECT", "HELP"}))
+	require.Nil(t, commandKeys("NOSUCHCMD", []string{"NOSUCHCMD", "k"}))
+}
+
+func TestFailedWriteNotPropagated(t *testing.T) {
+	s := newServer(host, "0", []*database.DB{database.NewDB()}, RoleMaster, testCfg)
+	bl := s.replicationBacklog.RegisterReplica("test")
+	conn, r := pipeClient(t, s)
+
+	require.Equal(t, "OK", do(t, conn, r, "SET", "k", "v"))
+	require.Equal(t, "ERR value is not an integer or out of range", do(t, conn, r, "INCR", "k").(error).Error())
+	require.Error(t, do(t, conn, r, "SADD", "k", "m").(error))
+	require.Error(t, do(t, conn, r, "FLUSHALL", "FOO").(error))
+	require.Equal(t, "OK", do(t, conn, r, "SET", "k", "v2"))
+
+	require.Equal(t, append(resp.NewCommand([]string{"SELECT", "0"}), resp.NewCommand([]string{"SET", "k", "v"})...), (<-bl.Broadcast).Data)
+	require.Equal(t, resp.NewCommand([]string{"SET", "k", "v2"}), (<-bl.Broadcast).Data)
+}
@@ -0,0 +1,574 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"strings"
+)
+
+// MaxBitOffset is the largest bit offset of the bitmap commands, strings are limited to 512MB like proto-max-bulk-len.
+const MaxBitOffset = 512<<20*8 - 1
+
+var ErrBitOpNotSingleKey = errors.New("BITOP NOT must be called with a single source key.")
+
+// BitRange is the optional range of BITCOUNT and BITPOS, in bytes or in bits if Bit is set.
+// Negative indexes are offsets from the end of the string.
+type BitRange struct {
+	Start    int64
+	End      int64
+	EndGiven bool // BITPOS only, whether End was given
+	Bit      bool
+}
+
+// WholeString is the default range of BITCOUNT and BITPOS.
+var WholeString = BitRange{Start: 0, End: -1}
+
+// lookupString returns the value of the string stored at key, ok is false if the key does not exist.
+// caller should hold the write lock.
+func (d *DB) lookupString(key string) (*Data, bool, error) {
+	data, ok := d.lookup(key)
+	if !ok {
+		return nil, false, nil
+	}
+	if data.Type != TypeString {
+		return nil, false, ErrWrongType
+	}
+	return data, true, nil
+}
+
+// byteString is a string value, either stored in Value or in the mutable bytes of bitmaps.
+type byteString interface{ ~string | ~[]byte }
+
+// str returns the value of the string, copying it if it is stored as a bitmap.
+func (data *Data) str() string {
+	if data.bits != nil {
+		return string(data.bits)
+	}
+	return data.Value
+}
+
+// strLen returns the length of the string in bytes.
+func (data *Data) strLen() int {
+	if data.bits != nil {
+		return len(data.bits)
+	}
+	return len(data.Value)
+}
+
+// modifyString calls fn with the bytes of the string stored at key grown to at least size bytes.
+// The value is moved into mutable bytes the first time so that later writes only cost the bits they change,
+// like dbUnshareStringValue in redis. The key is created if it does not exist, its expire time is kept otherwise.
+// caller should hold the write lock.
+func (d *DB) modifyString(key string, size int, fn func(b []byte)) error {
+	data, ok, err := d.lookupString(key)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		data = NewString("", NO_EXPIRY)
+		d.setKey(key, data)
+	}
+	d.updateKey(data, func() {
+		if data.bits == nil {
+			data.bits = append(make([]byte, 0, max(len(data.Value), size)), data.Value...)
+			data.Value = ""
+		}
+		if size > len(data.bits) {
+			// append grows the capacity geometrically so that setting increasing offsets is amortized O(1)
+			data.bits = append(data.bits, make([]byte, size-len(data.bits))...)
+		}
+		fn(data.bits)
+	})
+	return nil
+}
+
+// SetBit sets or clears the bit at offset of the string stored at key, it returns the previous value of the bit.
+// The string is grown with zero bytes as needed.
+func (d *DB) SetBit(key string, offset uint64, on bool) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	old := 0
+	err := d.modifyString(key, int(offset>>3)+1, func(b []byte) {
+		mask := byte(1 << (7 - offset&7))
+		if b[offset>>3]&mask != 0 {
+			old = 1
+		}
+		if on {
+			b[offset>>3] |= mask
+		} else {
+			b[offset>>3] &^= mask
+		}
+	})
+	return old, err
+}
+
+// GetBit returns the bit at offset of the string stored at key, bits beyond the end of the string are 0.
+func (d *DB) GetBit(key string, offset uint64) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	data, ok, err := d.lookupString(key)
+	if err != nil || !ok {
+		return 0, err
+	}
+	if data.bits != nil {
+		return getBit(data.bits, offset), nil
+	}
+	return getBit(data.Value, offset), nil
+}
+
+func getBit[S byteString](s S, offset uint64) int {
+	if offset>>3 >= uint64(len(s)) {
+		return 0
+	}
+	return int(s[offset>>3]>>(7-offset&7)) & 1
+}
+
+// bitRange resolves the range on a string of n bytes to the first and last bytes, and the masks of the bits
+// of these bytes which are outside of the range. ok is false if the range is empty.
+// ref: https://github.com/redis/redis/blob/7.2.0/src/bitops.c#L800
+func (r BitRange) resolve(n int64) (start, end int64, firstMask, lastMask byte, ok bool) {
+	total := n
+	if r.Bit {
+		total = n << 3
+	}
+	start, end = r.Start, r.End
+	if start < 0 {
+		start = total + start
+	}
+	if end < 0 {
+		end = total + end
+	}
+	start, end = max(start, 0), max(end, 0)
+	end = min(end, total-1)
+	if start > end {
+		return 0, 0, 0, 0, false
+	}
+	if r.Bit {
+		firstMask = ^byte(1<<(8-start&7) - 1)
+		lastMask = byte(1<<(7-end&7) - 1)
+		start >>= 3
+		end >>= 3
+	}
+	return start, end, firstMask, lastMask, true
+}
+
+// BitCount returns the number of bits set in the range of the string stored at key.
+func (d *DB) BitCount(key string, r BitRange) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	data, ok, err := d.lookupString(key)
+	if err != nil || !ok {
+		return 0, err
+	}
+	if data.bits != nil {
+		return bitCount(data.bits, r), nil
+	}
+	return bitCount(data.Value, r), nil
+}
+
+func bitCount[S byteString](s S, r BitRange) int {
+	start, end, firstMask, lastMask, ok := r.resolve(int64(len(s)))
+	if !ok {
+		return 0
+	}
+	count := 0
+	for i := start; i <= end; i++ {
+		count += bits.OnesCount8(s[i])
+	}
+	count -= bits.OnesCount8(s[start]&firstMask) + bits.OnesCount8(s[end]&lastMask)
+	return count
+}
+
+// BitPos returns the position of the first bit set to bit in the range of the string stored at key, or -1.
+// When looking for a clear bit without an end, the string is considered padded with zeros on the right.
+func (d *DB) BitPos(key string, bit int, r BitRange) (int64, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	data, ok, err := d.lookupString(key)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		if bit == 1 {
+			return -1, nil
+		}
+		return 0, nil
+	}
+	if data.bits != nil {
+		return bitPos(data.bits, bit, r), nil
+	}
+	return bitPos(data.Value, bit, r), nil
+}
+
+func bitPos[S byteString](s S, bit int, r BitRange) int64 {
+	start, end, firstMask, lastMask, ok := r.resolve(int64(len(s)))
+	if !ok {
+		return -1
+	}
+	// the bits outside of the range are set to the opposite of the searched bit
+	skip := byte(0)
+	if bit == 0 {
+		skip = 0xff
+	}
+	for i := start; i <= end; i++ {
+		c := s[i]
+		mask := byte(0)
+		if i == start {
+			mask |= firstMask
+		}
+		if i == end {
+			mask |= lastMask
+		}
+		if bit == 1 {
+			c &^= mask
+		} else {
+			c |= mask
+		}
+		if c == skip {
+			continue
+		}
+		if bit == 1 {
+			return i<<3 + int64(bits.LeadingZeros8(c))
+		}
+		return i<<3 + int64(bits.LeadingZeros8(^c))
+	}
+	if bit == 1 || r.EndGiven {
+		return -1
+	}
+	return (end + 1) << 3
+}
+
+// BITOP operations. DIFF, DIFF1, ANDOR and ONE were added in redis 8.2.
+const (
+	BitOpAnd   = "AND"
+	BitOpOr    = "OR"
+	BitOpXor   = "XOR"
+	BitOpNot   = "NOT"
+	BitOpDiff  = "DIFF"  // bits of the first key which are not set in any other key
+	BitOpDiff1 = "DIFF1" // bits set in one of the other keys but not in the first key
+	BitOpAndOr = "ANDOR" // bits of the first key which are also set in one of the other keys
+	BitOpOne   = "ONE"   // bits set in exactly one key
+)
+
+// ValidBitOp reports whether op is a BITOP operation, and the minimum number of source keys.
+func ValidBitOp(op string) (int, bool) {
+	switch op {
+	case BitOpAnd, BitOpOr, BitOpXor, BitOpNot, BitOpOne:
+		return 1, true
+	case BitOpDiff, BitOpDiff1, BitOpAndOr:
+		return 2, true
+	}
+	return 0, false
+}
+
+// BitOp stores the result of the bitwise operation between the strings stored at keys in dst, it returns its length.
+// Missing keys are considered empty strings, shorter strings are padded with zeros. dst is deleted if the result is empty.
+// ref: https://github.com/redis/redis/blob/7.2.0/src/bitops.c#L593
+func (d *DB) BitOp(op, dst string, keys []string) (int, error) {
+	minKeys, ok := ValidBitOp(op)
+	if !ok {
+		return 0, fmt.Errorf("unknown BITOP operation %q", op)
+	}
+	if op == BitOpNot && len(keys) != 1 {
+		return 0, ErrBitOpNotSingleKey
+	}
+	if len(keys) < minKeys {
+		return 0, fmt.Errorf("BITOP %s must be called with at least two source keys.", op)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	srcs := make([]string, len(keys))
+	maxLen := 0
+	for i, key := range keys {
+		data, ok, err := d.lookupString(key)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			srcs[i] = data.str()
+			maxLen = max(maxLen, len(srcs[i]))
+		}
+	}
+	if maxLen == 0 {
+		d.deleteKey(dst)
+		return 0, nil
+	}
+	at := func(s string, i int) byte {
+		if i < len(s) {
+			return s[i]
+		}
+		return 0
+	}
+	res := make([]byte, maxLen)
+	for i := range res {
+		first := at(srcs[0], i)
+		// others is the OR of the other keys, once is the bits set in exactly one key
+		var others, and, xor, once, seen byte = 0, first, first, first, first
+		for _, s := range srcs[1:] {
+			c := at(s, i)
+			others |= c
+			and &= c
+			xor ^= c
+			once = (once &^ c) | (c &^ seen)
+			seen |= c
+		}
+		switch op {
+		case BitOpAnd:
+			res[i] = and
+		case BitOpOr:
+			res[i] = first | others
+		case BitOpXor:
+			res[i] = xor
+		case BitOpNot:
+			res[i] = ^first
+		case BitOpDiff:
+			res[i] = first &^ others
+		case BitOpDiff1:
+			res[i] = others &^ first
+		case BitOpAndOr:
+			res[i] = first & others
+		case BitOpOne:
+			res[i] = once
+		}
+	}
+	data := NewString("", NO_EXPIRY)
+	data.bits = res
+	d.setKey(dst, data)
+	return maxLen, nil
+}
+
+// BitField operations.
+const (
+	BitFieldGet = iota
+	BitFieldSet
+	BitFieldIncrBy
+)
+
+// Overflow behaviors of BITFIELD SET and INCRBY.
+const (
+	OverflowWrap = "WRAP"
+	OverflowSat  = "SAT"
+	OverflowFail = "FAIL"
+)
+
+// BitFieldOp is an operation of BITFIELD on the integer of Bits bits at Offset.
+type BitFieldOp struct {
+	Op       int
+	Signed   bool
+	Bits     uint
+	Offset   uint64
+	Value    int64 // value of SET or increment of INCRBY
+	Overflow string
+}
+
+// ParseBitFieldType parses a BITFIELD type like i16 or u8, u64 is not supported since the replies are signed.
+func ParseBitFieldType(typ string) (bool, uint, bool) {
+	if len(typ) < 2 || (typ[0] != 'i' && typ[0] != 'u' && typ[0] != 'I' && typ[0] != 'U') {
+		return false, 0, false
+	}
+	signed := typ[0] == 'i' || typ[0] == 'I'
+	n := uint(0)
+	for _, c := range typ[1:] {
+		if c < '0' || c > '9' || n > 64 {
+			return false, 0, false
+		}
+		n = n*10 + uint(c-'0')
+	}
+	if n < 1 || (signed && n > 64) || (!signed && n > 63) {
+		return false, 0, false
+	}
+	return signed, n, true
+}
+
+// BitField runs the operations on the string stored at key, the results are nil for the operations which failed
+// with the FAIL overflow behavior. The string is created or grown only if there are SET or INCRBY operations.
+// ref: https://github.com/redis/redis/blob/7.2.0/src/bitops.c#L1054
+func (d *DB) BitField(key string, ops []BitFieldOp) ([]*int64, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	size := 0
+	for _, op := range ops {
+		if op.Op != BitFieldGet {
+			size = max(size, int((op.Offset+uint64(op.Bits)-1)>>3)+1)
+		}
+	}
+	res := make([]*int64, 0, len(ops))
+	run := func(b []byte) {
+		for _, op := range ops {
+			res = append(res, op.apply(b))
+		}
+	}
+	if size == 0 {
+		data, ok, err := d.lookupString(key)
+		if err != nil {
+			return nil, err
+		}
+		for _, op := range ops {
+			var v int64
+			switch {
+			case !ok:
+			case data.bits != nil:
+				v = bitFieldGet(data.bits, op)
+			default:
+				v = bitFieldGet(data.Value, op)
+			}
+			res = append(res, &v)
+		}
+		return res, nil
+	}
+	if err := d.modifyString(key, size, run); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// bitFieldGet returns the integer read by op, signed or not.
+func bitFieldGet[S byteString](s S, op BitFieldOp) int64 {
+	v := getBits(s, op.Offset, op.Bits)
+	if op.Signed {
+		return signExtend(v, op.Bits)
+	}
+	return int64(v)
+}
+
+func (op BitFieldOp) apply(b []byte) *int64 {
+	oldU := getBits(b, op.Offset, op.Bits)
+	old := bitFieldGet(b, op)
+	if op.Op == BitFieldGet {
+		return &old
+	}
+	var newVal int64
+	var overflow bool
+	switch {
+	case op.Signed && op.Op == BitFieldIncrBy:
+		newVal, overflow = signedOverflow(old, op.Value, op.Bits, op.Overflow)
+	case op.Signed:
+		newVal, overflow = signedOverflow(op.Value, 0, op.Bits, op.Overflow)
+	case op.Op == BitFieldIncrBy:
+		var v uint64
+		v, overflow = unsignedOverflow(oldU, op.Value, op.Bits, op.Overflow)
+		newVal = int64(v)
+	default:
+		var v uint64
+		v, overflow = unsignedOverflow(uint64(op.Value), 0, op.Bits, op.Overflow)
+		newVal = int64(v)
+	}
+	if overflow && op.Overflow == OverflowFail {
+		return nil
+	}
+	setBits(b, op.Offset, op.Bits, uint64(newVal))
+	if op.Op == BitFieldSet {
+		return &old
+	}
+	return &newVal
+}
+
+// getBits reads the unsigned integer of n bits at offset, most significant bit first.
+// Bits beyond the end of b are 0.
+func getBits[S byteString](b S, offset uint64, n uint) uint64 {
+	var v uint64
+	for i := uint64(0); i < uint64(n); i++ {
+		pos := offset + i
+		bit := uint64(0)
+		if pos>>3 < uint64(len(b)) {
+			bit = uint64(b[pos>>3]>>(7-pos&7)) & 1
+		}
+		v = v<<1 | bit
+	}
+	return v
+}
+
+// setBits writes the n low bits of v at offset, most significant bit first.
+func setBits(b []byte, offset uint64, n uint, v uint64) {
+	for i := uint64(0); i < uint64(n); i++ {
+		pos := offset + i
+		mask := byte(1 << (7 - pos&7))
+		if v>>(uint64(n)-1-i)&1 == 1 {
+			b[pos>>3] |= mask
+		} else {
+			b[pos>>3] &^= mask
+		}
+	}
+}
+
+func signExtend(v uint64, n uint) int64 {
+	if n < 64 && v&(1<<(n-1)) != 0 {
+		v |= math.MaxUint64 << n
+	}
+	return int64(v)
+}
+
+// signedOverflow returns value+incr for a signed integer of n bits, overflow is set if it does not fit,
+// in which case the result is wrapped or saturated according to the overflow behavior.
+// The arithmetic is done on uint64 where it could overflow int64, like checkSignedBitfieldOverflow in redis.
+func signedOverflow(value, incr int64, n uint, behavior string) (int64, bool) {
+	maxV := int64(math.MaxInt64)
+	if n < 64 {
+		maxV = 1<<(n-1) - 1
+	}
+	minV := -maxV - 1
+	maxIncr := int64(uint64(maxV) - uint64(value))
+	minIncr := minV - value
+	wrap := func() int64 {
+		c := uint64(value) + uint64(incr)
+		if n < 64 {
+			mask := uint64(math.MaxUint64) << n
+			if c&(1<<(n-1)) != 0 {
+				c |= mask
+			} else {
+				c &^= mask
+			}
+		}
+		return int64(c)
+	}
+	switch {
+	case value > maxV || (n != 64 && incr > maxIncr) || (value >= 0 && incr > 0 && incr > maxIncr):
+		if behavior == OverflowSat {
+			return maxV, true
+		}
+		return wrap(), true
+	case value < minV || (n != 64 && incr < minIncr) || (value < 0 && incr < 0 && incr < minIncr):
+		if behavior == OverflowSat {
+			return minV, true
+		}
+		return wrap(), true
+	}
+	return value + incr, false
+}
+
+// unsignedOverflow is like signedOverflow for an unsigned integer of n bits.
+func unsignedOverflow(value uint64, incr int64, n uint, behavior string) (uint64, bool) {
+	maxV := uint64(math.MaxUint64)
+	if n < 64 {
+		maxV = 1<<n - 1
+	}
+	maxIncr := int64(maxV - value)
+	minIncr := -int64(value)
+	wrap := func() uint64 {
+		return (value + uint64(incr)) &^ (uint64(math.MaxUint64) << n)
+	}
+	switch {
+	case value > maxV || (incr > 0 && incr > maxIncr):
+		if behavior == OverflowSat {
+			return maxV, true
+		}
+		return wrap(), true
+	case incr < 0 && incr < minIncr:
+		if behavior == OverflowSat {
+			return 0, true
+		}
+		return wrap(), true
+	}
+	return value + uint64(incr), false
+}
+
+// ParseOverflow parses the behavior of BITFIELD OVERFLOW.
+func ParseOverflow(s string) (string, bool) {
+	switch b := strings.ToUpper(s); b {
+	case OverflowWrap, OverflowSat, OverflowFail:
+		return b, true
+	}
+	return "", false
+}
@@ -0,0 +1,216 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetGetBit(t *testing.T) {
+	db := NewDB()
+	old, err := db.SetBit("k", 7, true)
+	require.NoError(t, err)
+	require.Equal(t, 0, old)
+	require.Equal(t, "\x01", db.Get("k"))
+	bit, err := db.GetBit("k", 7)
+	require.NoError(t, err)
+	require.Equal(t, 1, bit)
+	bit, err = db.GetBit("k", 100)
+	require.NoError(t, err)
+	require.Equal(t, 0, bit)
+
+	old, err = db.SetBit("k", 7, false)
+	require.NoError(t, err)
+	require.Equal(t, 1, old)
+	_, err = db.SetBit("k", 17, true)
+	require.NoError(t, err)
+	require.Equal(t, "\x00\x00\x40", db.Get("k"))
+
+	_, err = db.HSet("h", []KeyValue{{Key: "f", Value: "v"}})
+	require.NoError(t, err)
+	_, err = db.SetBit("h", 0, true)
+	require.ErrorIs(t, err, ErrWrongType)
+}
+
+func TestSetBitInPlace(t *testing.T) {
+	db := NewDB()
+	const size = 12 << 20
+	_, err := db.SetBit("k", size*8-1, true)
+	require.NoError(t, err)
+	allocs := testing.AllocsPerRun(10, func() {
+		_, err := db.SetBit("k", 12345, true)
+		require.NoError(t, err)
+	})
+	require.Zero(t, allocs)
+	bit, err := db.GetBit("k", 12345)
+	require.NoError(t, err)
+	require.Equal(t, 1, bit)
+	n, err := db.BitCount("k", WholeString)
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	typ, l, _ := db.Len("k")
+	require.Equal(t, TypeString, typ)
+	require.Equal(t, size, l)
+
+	// values read with GET do not change with later writes
+	db.Set("s", "12")
+	_, err = db.SetBit("s", 15, true)
+	require.NoError(t, err)
+	v := db.Get("s")
+	require.Equal(t, "13", v)
+	_, err = db.SetBit("s", 13, true)
+	require.NoError(t, err)
+	require.Equal(t, "13", v)
+	require.Equal(t, "17", db.Get("s"))
+	data, _ := db.peek("s")
+	require.Equal(t, EncodingRaw, data.Encoding())
+}
+
+func TestBitCount(t *testing.T) {
+	db := NewDB()
+	db.Set("k", "foobar")
+	for _, tc := range []struct {
+		r    BitRange
+		want int
+	}{
+		{WholeString, 26},
+		{BitRange{Start: 0, End: 0}, 4},
+		{BitRange{Start: 1, End: 1}, 6},
+		{BitRange{Start: -2, End: -1}, 7},
+		{BitRange{Start: 5, End: 30, Bit: true}, 17},
+		{BitRange{Start: 3, End: 1}, 0},
+	} {
+		n, err := db.BitCount("k", tc.r)
+		require.NoError(t, err)
+		require.Equal(t, tc.want, n, tc.r)
+	}
+	n, err := db.BitCount("missing", WholeString)
+	require.NoError(t, err)
+	require.Equal(t, 0, n)
+}
+
+func TestBitPos(t *testing.T) {
+	db := NewDB()
+	db.Set("a", "\xff\xf0\x00")
+	db.Set("b", "\x00\xff\xf0")
+	db.Set("zeros", "\x00\x00\x00")
+	db.Set("ones", "\xff\xff\xff")
+	for _, tc := range []struct {
+		key  string
+		bit  int
+		r    BitRange
+		want int64
+	}{
+		{"a", 0, WholeString, 12},
+		{"b", 1, BitRange{Start: 0, End: -1}, 8},
+		{"b", 1, BitRange{Start: 2, End: -1}, 16},
+		{"b", 1, BitRange{Start: 2, End: -1, EndGiven: true}, 16},
+		{"b", 1, BitRange{Start: 7, End: 15, EndGiven: true, Bit: true}, 8},
+		{"b", 0, BitRange{Start: 9, End: 15, EndGiven: true, Bit: true}, -1},
+		{"zeros", 1, WholeString, -1},
+		{"ones", 0, WholeString, 24},
+		{"ones", 0, BitRange{Start: 0, End: 2, EndGiven: true}, -1},
+		{"missing", 0, WholeString, 0},
+		{"missing", 1, WholeString, -1},
+	} {
+		pos, err := db.BitPos(tc.key, tc.bit, tc.r)
+		require.NoError(t, err)
+		require.Equal(t, tc.want, pos, tc)
+	}
+}
+
+func TestBitOp(t *testing.T) {
+	db := NewDB()
+	db.Set("a", "foobar")
+	db.Set("b", "abcdef")
+	for op, want := range map[string]string{
+		BitOpAnd: "`bc`ab",
+		BitOpOr:  "goofev",
+		BitOpXor: "\x07\x0d\x0c\x06\x04\x14",
+	} {
+		n, err := db.BitOp(op, "dst", []string{"a", "b"})
+		require.NoError(t, err)
+		require.Equal(t, 6, n)
+		require.Equal(t, want, db.Get("dst"), op)
+	}
+
+	db.Set("x", "\xf0\x0f")
+	db.Set("y", "\xcc")
+	db.Set("z", "\xaa")
+	for op, want := range map[string]string{
+		BitOpNot:   "\x0f\xf0",
+		BitOpDiff:  "\x10\x0f", // x and not (y or z)
+		BitOpDiff1: "\x0e\x00", // (y or z) and not x
+		BitOpAndOr: "\xe0\x00", // x and (y or z)
+		BitOpOne:   "\x16\x0f", // set in exactly one key
+	} {
+		keys := []string{"x", "y", "z"}
+		if op == BitOpNot {
+			keys = keys[:1]
+		}
+		_, err := db.BitOp(op, "dst", keys)
+		require.NoError(t, err)
+		require.Equal(t, want, db.Get("dst"), op)
+	}
+
+	_, err := db.BitOp(BitOpNot, "dst", []string{"a", "b"})
+	require.ErrorIs(t, err, ErrBitOpNotSingleKey)
+	_, err = db.BitOp(BitOpDiff, "dst", []string{"a"})
+	require.Error(t, err)
+	n, err := db.BitOp(BitOpOr, "dst", []string{"missing"})
+	require.NoError(t, err)
+	require.Equal(t, 0, n)
+	require.Equal(t, "", db.Type("dst"))
+}
+
+func bitField(t *testing.T, db *DB, key string, ops ...BitFieldOp) []any {
+	vals, err := db.BitField(key, ops)
+	require.NoError(t, err)
+	res := make([]any, len(vals))
+	for i, v := range vals {
+		if v != nil {
+			res[i] = *v
+		}
+	}
+	return res
+}
+
+func TestBitField(t *testing.T) {
+	db := NewDB()
+	require.Equal(t, []any{int64(0)}, bitField(t, db, "k", BitFieldOp{Op: BitFieldGet, Bits: 8}))
+	require.Equal(t, "", db.Type("k"))
+
+	require.Equal(t, []any{int64(1), int64(0)}, bitField(t, db, "k",
+		BitFieldOp{Op: BitFieldIncrBy, Signed: true, Bits: 5, Offset: 100, Value: 1, Overflow: OverflowWrap},
+		BitFieldOp{Op: BitFieldGet, Bits: 4}))
+
+	// unsigned saturation and wrap
+	incr := func(overflow string) BitFieldOp {
+		return BitFieldOp{Op: BitFieldIncrBy, Bits: 2, Offset: 0, Value: 1, Overflow: overflow}
+	}
+	require.Equal(t, []any{int64(1), int64(2), int64(3), int64(3)}, bitField(t, db, "u",
+		incr(OverflowSat), incr(OverflowSat), incr(OverflowSat), incr(OverflowSat)))
+	require.Equal(t, []any{nil}, bitField(t, db, "u", incr(OverflowFail)))
+	require.Equal(t, []any{int64(0)}, bitField(t, db, "u", incr(OverflowWrap)))
+
+	// signed
+	require.Equal(t, []any{int64(0), int64(-56)}, bitField(t, db, "s",
+		BitFieldOp{Op: BitFieldSet, Signed: true, Bits: 8, Value: 200, Overflow: OverflowWrap},
+		BitFieldOp{Op: BitFieldGet, Signed: true, Bits: 8}))
+	require.Equal(t, []any{int64(127), int64(-128)}, bitField(t, db, "s",
+		BitFieldOp{Op: BitFieldIncrBy, Signed: true, Bits: 8, Value: 1000, Overflow: OverflowSat},
+		BitFieldOp{Op: BitFieldIncrBy, Signed: true, Bits: 8, Value: -1000, Overflow: OverflowSat}))
+	require.Equal(t, []any{int64(-9223372036854775808)}, bitField(t, db, "s64",
+		BitFieldOp{Op: BitFieldIncrBy, Signed: true, Bits: 64, Value: -9223372036854775808, Overflow: OverflowFail}))
+	require.Equal(t, []any{nil}, bitField(t, db, "s64",
+		BitFieldOp{Op: BitFieldIncrBy, Signed: true, Bits: 64, Value: -1, Overflow: OverflowFail}))
+	require.Equal(t, []any{int64(9223372036854775807)}, bitField(t, db, "s64",
+		BitFieldOp{Op: BitFieldIncrBy, Signed: true, Bits: 64, Value: -1, Overflow: OverflowWrap}))
+}
+
+func TestParseBitFieldType(t *testing.T) {
+	for typ, ok := range map[string]bool{"i1": true, "i64": true, "u63": true, "U8": true, "u64": false, "i65": false, "i0": false, "x8": false, "i": false, "i8a": false} {
+		_, _, got := ParseBitFieldType(typ)
+		require.Equal(t, ok, got, typ)
+	}
+}
@@ -0,0 +1,276 @@
+package database
+
+import (
+	"errors"
+	"math"
+)
+
+// Scalable Bloom filters, like BF.* of RedisBloom: a chain of Bloom filters where a new filter,
+// Expansion times larger and with a tighter error rate, is added when the last one is full.
+// ref: https://github.com/RedisBloom/RedisBloom/blob/v2.6.3/src/sb.c
+const (
+	TypeBloom = "MBbloom--"
+
+	BloomDefaultErrorRate = 0.01
+	BloomDefaultCapacity  = 100
+	BloomDefaultExpansion = 2
+	bloomTighteningRatio  = 0.5
+	bloomSeed             = 0xc6a4a7935bd1e995
+
+	// maxFilterBytes bounds the filters added when scaling, whose size is not checked by the commands creating them.
+	maxFilterBytes = 1 << 30
+)
+
+var (
+	ErrItemExists     = errors.New("item exists")
+	ErrFilterNotFound = errors.New("not found")
+	ErrNonScalingFull = errors.New("non scaling filter is full")
+	ErrFilterTooLarge = errors.New("Insufficient memory to create filter")
+)
+
+// bloomLayer is one Bloom filter of the chain.
+type bloomLayer struct {
+	bits      []byte
+	nbits     uint64
+	hashes    int
+	capacity  int64
+	items     int64
+	errorRate float64
+}
+
+type bloomFilter struct {
+	layers    []*bloomLayer
+	expansion int // 0 for a non scaling filter
+	items     int64
+}
+
+// bitsPerEntry is the number of bits per item giving the error rate.
+func bitsPerEntry(errorRate float64) float64 {
+	return -math.Log(errorRate) / (math.Ln2 * math.Ln2)
+}
+
+// BloomSize returns the size in bytes of a filter for capacity items with the error rate,
+// ok is false if it does not fit in an int64.
+func BloomSize(capacity int64, errorRate float64) (int64, bool) {
+	nbits := math.Ceil(float64(capacity) * bitsPerEntry(errorRate))
+	if !(nbits >= 0 && nbits < math.MaxInt64) {
+		return 0, false
+	}
+	return (int64(nbits) + 7) / 8, true
+}
+
+// newBloomLayer sizes the filter for capacity items with the error rate, without rounding the bits to a power of 2.
+// The size must have been checked with BloomSize.
+func newBloomLayer(capacity int64, errorRate float64) *bloomLayer {
+	nbytes, _ := BloomSize(capacity, errorRate)
+	return &bloomLayer{
+		bits:      make([]byte, nbytes),
+		nbits:     uint64(nbytes) * 8,
+		hashes:    int(math.Ceil(math.Ln2 * bitsPerEntry(errorRate))),
+		capacity:  capacity,
+		errorRate: errorRate,
+	}
+}
+
+func newBloomFilter(errorRate float64, capacity int64, expansion int) *bloomFilter {
+	return &bloomFilter{
+		layers:    []*bloomLayer{newBloomLayer(capacity, errorRate)},
+		expansion: expansion,
+	}
+}
+
+// bloomHash is the pair of hashes from which the positions of an item are derived.
+type bloomHash struct{ a, b uint64 }
+
+func newBloomHash(item string) bloomHash {
+	a := murmurHash64A(item, bloomSeed)
+	return bloomHash{a: a, b: murmurHash64A(item, a)}
+}
+
+// test reports whether the item may be in the layer, setting its bits if set is true.
+// It returns true if all the bits were already set.
+func (l *bloomLayer) test(h bloomHash, set bool) bool {
+	found := true
+	for i := 0; i < l.hashes; i++ {
+		pos := (h.a + uint64(i)*h.b) % l.nbits
+		mask := byte(1) << (pos % 8)
+		if l.bits[pos/8]&mask == 0 {
+			found = false
+			if !set {
+				return false
+			}
+			l.bits[pos/8] |= mask
+		}
+	}
+	return found
+}
+
+func (f *bloomFilter) exists(item string) bool {
+	h := newBloomHash(item)
+	for _, l := range f.layers {
+		if l.test(h, false) {
+			return true
+		}
+	}
+	return false
+}
+
+// add adds the item to the last layer, growing the chain if it is full. It returns false if the item may already exist.
+func (f *bloomFilter) add(item string) (bool, error) {
+	h := newBloomHash(item)
+	for _, l := range f.layers {
+		if l.test(h, false) {
+			return false, nil
+		}
+	}
+	last := f.layers[len(f.layers)-1]
+	if last.items >= last.capacity {
+		if f.expansion == 0 {
+			return false, ErrNonScalingFull
+		}
+		if last.capacity > math.MaxInt64/int64(f.expansion) {
+			return false, ErrFilterTooLarge
+		}
+		capacity, errorRate := last.capacity*int64(f.expansion), last.errorRate*bloomTighteningRatio
+		if size, ok := BloomSize(capacity, errorRate); !ok || size > maxFilterBytes {
+			return false, ErrFilterTooLarge
+		}
+		last = newBloomLayer(capacity, errorRate)
+		f.layers = append(f.layers, last)
+	}
+	last.test(h, true)
+	last.items++
+	f.items++
+	return true, nil
+}
+
+// size is the memory used by the bits of the filter.
+func (f *bloomFilter) size() int64 {
+	var size int64
+	for _, l := range f.layers {
+		size += int64(len(l.bits))
+	}
+	return size
+}
+
+func (f *bloomFilter) clone() *bloomFilter {
+	c := *f
+	c.layers = make([]*bloomLayer, len(f.layers))
+	for i, l := range f.layers {
+		cl := *l
+		cl.bits = append([]byte(nil), l.bits...)
+		c.layers[i] = &cl
+	}
+	return &c
+}
+
+// BloomInfo is the reply of BF.INFO.
+type BloomInfo struct {
+	Capacity  int64
+	Size      int64
+	Filters   int
+	Items     int64
+	Expansion int // 0 for a non scaling filter
+}
+
+// lookupBloom returns the Bloom filter stored at key, ok is false if the key does not exist.
+// caller should hold the write lock.
+func (d *DB) lookupBloom(key string) (*Data, bool, error) {
+	data, ok := d.lookup(key)
+	if !ok {
+		return nil, false, nil
+	}
+	if data.Type != TypeBloom {
+		return nil, false, ErrWrongType
+	}
+	return data, true, nil
+}
+
+// BFReserve creates an empty Bloom filter at key, expansion 0 creates a non scaling filter.
+// ErrFilterTooLarge is returned if the filter is larger than maxSize bytes.
+func (d *DB) BFReserve(key string, errorRate float64, capacity int64, expansion int, maxSize int64) error {
+	if size, ok := BloomSize(capacity, errorRate); !ok || size > maxSize {
+		return ErrFilterTooLarge
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.peek(key); ok {
+		return ErrItemExists
+	}
+	d.setBloom(key, errorRate, capacity, expansion)
+	return nil
+}
+
+// setBloom creates an empty Bloom filter at key.
+// caller should hold the write lock.
+func (d *DB) setBloom(key string, errorRate float64, capacity int64, expansion int) *Data {
+	data := newData(TypeBloom)
+	data.bloom = newBloomFilter(errorRate, capacity, expansion)
+	data.elemsMem = data.bloom.size()
+	d.setKey(key, data)
+	return data
+}
+
+// BFAdd adds the items to the Bloom filter stored at key, which is created with the default parameters if it does not exist.
+// It returns for every item whether it was added, false if it may already exist, up to the item which could not be added
+// if an error is returned.
+func (d *DB) BFAdd(key string, items []string) ([]bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	data, ok, err := d.lookupBloom(key)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		data = d.setBloom(key, BloomDefaultErrorRate, BloomDefaultCapacity, BloomDefaultExpansion)
+	}
+	res := make([]bool, 0, len(items))
+	d.updateKey(data, func() {
+		for _, item := range items {
+			var added bool
+			if added, err = data.bloom.add(item); err != nil {
+				break
+			}
+			res = append(res, added)
+		}
+		data.elemsMem = data.bloom.size()
+	})
+	return res, err
+}
+
+// BFExists returns for every item whether it may be in the Bloom filter stored at key.
+func (d *DB) BFExists(key string, items []string) ([]bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	data, ok, err := d.lookupBloom(key)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]bool, len(items))
+	if !ok {
+		return res, nil
+	}
+	for i, item := range items {
+		res[i] = data.bloom.exists(item)
+	}
+	return res, nil
+}
+
+// BFInfo returns the parameters and the usage of the Bloom filter stored at key.
+func (d *DB) BFInfo(key string) (BloomInfo, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	data, ok, err := d.lookupBloom(key)
+	if err != nil {
+		return BloomInfo{}, err
+	}
+	if !ok {
+		return BloomInfo{}, ErrFilterNotFound
+	}
+	f := data.bloom
+	info := BloomInfo{Size: f.size(), Filters: len(f.layers), Items: f.items, Expansion: f.expansion}
+	for _, l := range f.layers {
+		info.Capacity += l.capacity
+	}
+	return info, nil
+}
@@ -0,0 +1,188 @@
+package database
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBloomFilter(t *testing.T) {
+	db := NewDB()
+	require.NoError(t, db.BFReserve("bf", 0.01, 1000, 2, 1<<20))
+	require.ErrorIs(t, db.BFReserve("bf", 0.01, 1000, 2, 1<<20), ErrItemExists)
+
+	items := make([]string, 5000)
+	for i := range items {
+		items[i] = fmt.Sprint("item:", i)
+	}
+	added, err := db.BFAdd("bf", items)
+	require.NoError(t, err)
+	require.Len(t, added, len(items))
+	exists, err := db.BFExists("bf", items)
+	require.NoError(t, err)
+	for _, ok := range exists {
+		require.True(t, ok)
+	}
+
+	// the error rate of the chain stays close to the one requested
+	others := make([]string, 10000)
+	for i := range others {
+		others[i] = fmt.Sprint("other:", i)
+	}
+	exists, err = db.BFExists("bf", others)
+	require.NoError(t, err)
+	falsePositives := 0
+	for _, ok := range exists {
+		if ok {
+			falsePositives++
+		}
+	}
+	require.Less(t, falsePositives, 300)
+
+	info, err := db.BFInfo("bf")
+	require.NoError(t, err)
+	require.Equal(t, 3, info.Filters)
+	require.EqualValues(t, 7000, info.Capacity)
+	require.EqualValues(t, 2, info.Expansion)
+	require.LessOrEqual(t, info.Items, int64(5000))
+
+	_, err = db.BFInfo("missing")
+	require.ErrorIs(t, err, ErrFilterNotFound)
+}
+
+func TestBloomFilterNonScaling(t *testing.T) {
+	db := NewDB()
+	require.NoError(t, db.BFReserve("bf", 0.001, 2, 0, 1<<20))
+	added, err := db.BFAdd("bf", []string{"a", "b", "c"})
+	require.ErrorIs(t, err, ErrNonScalingFull)
+	require.Equal(t, []bool{true, true}, added)
+
+	added, err = db.BFAdd("default", []string{"a", "a"})
+	require.NoError(t, err)
+	require.Equal(t, []bool{true, false}, added)
+	info, err := db.BFInfo("default")
+	require.NoError(t, err)
+	require.EqualValues(t, BloomDefaultCapacity, info.Capacity)
+}
+
+func TestCuckooFilter(t *testing.T) {
+	db := NewDB()
+	require.NoError(t, db.CFReserve("cf", CuckooOptions{Capacity: 100, BucketSize: 4, MaxIterations: 20, Expansion: 2}, 1<<20))
+	for i := 0; i < 1000; i++ {
+		added, err := db.CFAdd("cf", fmt.Sprint("item:", i), false)
+		require.NoError(t, err)
+		require.True(t, added)
+	}
+	info, err := db.CFInfo("cf")
+	require.NoError(t, err)
+	require.Greater(t, info.Filters, 1)
+	require.EqualValues(t, 1000, info.Items)
+	exists, err := db.CFExists("cf", []string{"item:0", "item:999"})
+	require.NoError(t, err)
+	require.Equal(t, []bool{true, true}, exists)
+
+	added, err := db.CFAdd("cf", "item:0", true)
+	require.NoError(t, err)
+	require.False(t, added)
+	_, err = db.CFAdd("cf", "item:0", false)
+	require.NoError(t, err)
+	n, err := db.CFCount("cf", "item:0")
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, n, 2)
+
+	for i := 0; i < 1000; i++ {
+		deleted, err := db.CFDel("cf", fmt.Sprint("item:", i))
+		require.NoError(t, err)
+		require.True(t, deleted)
+	}
+	info, err = db.CFInfo("cf")
+	require.NoError(t, err)
+	require.EqualValues(t, 1, info.Items)
+	require.EqualValues(t, 1000, info.Deletes)
+
+	_, err = db.CFDel("missing", "a")
+	require.ErrorIs(t, err, ErrCuckooNotFound)
+}
+
+func TestCuckooFilterFull(t *testing.T) {
+	db := NewDB()
+	require.NoError(t, db.CFReserve("cf", CuckooOptions{Capacity: 4, BucketSize: 2, MaxIterations: 5, Expansion: 0}, 1<<20))
+	var err error
+	for i := 0; i < 100 && err == nil; i++ {
+		_, err = db.CFAdd("cf", fmt.Sprint("item:", i), false)
+	}
+	require.ErrorIs(t, err, ErrCuckooFull)
+	info, err := db.CFInfo("cf")
+	require.NoError(t, err)
+	require.Equal(t, 1, info.Filters)
+	// the fingerprints moved by the failed insertion are restored
+	require.EqualValues(t, info.Buckets*2, info.Items+int64(countEmpty(t, db, "cf")))
+}
+
+// countEmpty returns the number of empty slots of the Cuckoo filter stored at key.
+func countEmpty(t *testing.T, db *DB, key string) int {
+	data, ok := db.datas.get(key)
+	require.True(t, ok)
+	n := 0
+	for _, filter := range data.cuckoo.filters {
+		for _, fp := range filter {
+			if fp == 0 {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+func TestFilterSizeLimits(t *testing.T) {
+	db := NewDB()
+	require.ErrorIs(t, db.BFReserve("bf", 0.01, 1e15, 2, 512<<20), ErrFilterTooLarge)
+	require.ErrorIs(t, db.BFReserve("bf", 1e-300, math.MaxInt64, 2, math.MaxInt64), ErrFilterTooLarge)
+	require.ErrorIs(t, db.CFReserve("cf", CuckooOptions{Capacity: 1e17, BucketSize: 2, MaxIterations: 20}, 512<<20), ErrFilterTooLarge)
+	require.ErrorIs(t, db.CFReserve("cf", CuckooOptions{Capacity: math.MaxInt64, BucketSize: 255, MaxIterations: 20}, math.MaxInt64), ErrFilterTooLarge)
+
+	// the filters added when scaling are bounded too
+	require.NoError(t, db.BFReserve("bf", 0.01, 1, math.MaxInt32, 1<<20))
+	_, err := db.BFAdd("bf", []string{"a", "b", "c", "d"})
+	require.ErrorIs(t, err, ErrFilterTooLarge)
+	require.NoError(t, db.CFReserve("cf", CuckooOptions{Capacity: 2, BucketSize: 1, MaxIterations: 1, Expansion: 1 << 15}, 1<<20))
+	for i := 0; err == nil; i++ {
+		_, err = db.CFAdd("cf", fmt.Sprint(i), false)
+	}
+	require.ErrorIs(t, err, ErrFilterTooLarge)
+}
+
+func TestModuleValue(t *testing.T) {
+	require.Equal(t, BloomModuleType, ModuleTypeFromID(BloomModuleType.ID()))
+	require.Equal(t, CuckooModuleType, ModuleTypeFromID(CuckooModuleType.ID()))
+
+	db := NewDB()
+	_, err := db.BFAdd("bf", []string{"a", "b"})
+	require.NoError(t, err)
+	_, err = db.CFAdd("cf", "a", false)
+	require.NoError(t, err)
+	for key, data := range db.Snapshot() {
+		typ, fields, ok := data.ModuleValue()
+		require.True(t, ok)
+		loaded, err := NewModuleValue(typ, fields, NO_EXPIRY)
+		require.NoError(t, err)
+		require.Equal(t, data.Type, loaded.Type, key)
+		_, err = NewModuleValue(typ, fields[:len(fields)-1], NO_EXPIRY)
+		require.Error(t, err)
+	}
+	loaded := NewFromLoad(db.Snapshot())
+	exists, err := loaded.BFExists("bf", []string{"a", "b", "c"})
+	require.NoError(t, err)
+	require.Equal(t, []bool{true, true, false}, exists)
+	require.Equal(t, db.UsedMemory(), loaded.UsedMemory())
+
+	// the values of the real RedisBloom and RedisJSON modules have another layout and are not loaded
+	for _, name := range []string{TypeBloom, TypeCuckoo, TypeCMS, TypeTopK, TypeJSON} {
+		for _, encVer := range []int{0, 1, 2, 3, 4} {
+			_, err := NewModuleValue(ModuleType{Name: name, EncVer: encVer}, []any{uint64(1)}, NO_EXPIRY)
+			require.ErrorContains(t, err, "unknown module type "+name)
+		}
+	}
+}
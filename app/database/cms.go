@@ -0,0 +1,232 @@
+package database
+
+import (
+	"errors"
+	"math"
+	"math/bits"
+)
+
+// Count-Min sketches, like CMS.* of RedisBloom: depth rows of width counters, an item increments one counter
+// of every row and its count is the minimum of them, which may only overestimate.
+// ref: https://github.com/RedisBloom/RedisBloom/blob/v2.6.3/src/cms.c
+const TypeCMS = "CMSk-TYPE"
+
+var (
+	ErrCMSKeyExists     = errors.New("CMS: key already exists")
+	ErrCMSKeyNotFound   = errors.New("CMS: key does not exist")
+	ErrCMSDimsMismatch  = errors.New("CMS: width/depth is not equal")
+	ErrCMSOverflow      = errors.New("CMS: INCRBY overflow")
+	ErrCMSMergeOverflow = errors.New("CMS: MERGE overflow")
+	ErrCMSTooLarge      = errors.New("CMS: Insufficient memory to create the sketch")
+)
+
+type countMinSketch struct {
+	counters []uint32 // depth rows of width counters
+	width    uint64
+	depth    uint64
+	count    uint64 // total of the increments
+}
+
+// CMSSize returns the size in bytes of the counters of a sketch, ok is false if it does not fit in an int64.
+func CMSSize(width, depth uint64) (int64, bool) {
+	hi, n := bits.Mul64(width, depth)
+	if hi != 0 || n > math.MaxInt64/4 {
+		return 0, false
+	}
+	return int64(n) * 4, true
+}
+
+// newCountMinSketch creates the sketch, its size must have been checked with CMSSize.
+func newCountMinSketch(width, depth uint64) *countMinSketch {
+	return &countMinSketch{counters: make([]uint32, width*depth), width: width, depth: depth}
+}
+
+// index returns the counter of the item in the row.
+func (s *countMinSketch) index(item string, row uint64) uint64 {
+	return row*s.width + murmurHash64A(item, row)%s.width
+}
+
+func (s *countMinSketch) query(item string) uint32 {
+	res := uint32(math.MaxUint32)
+	for row := uint64(0); row < s.depth; row++ {
+		res = min(res, s.counters[s.index(item, row)])
+	}
+	return res
+}
+
+// incrBy increments the counters of the item, none of them is incremented if one would overflow.
+func (s *countMinSketch) incrBy(item string, incr uint32) (uint32, error) {
+	for row := uint64(0); row < s.depth; row++ {
+		if s.counters[s.index(item, row)] > math.MaxUint32-incr {
+			return 0, ErrCMSOverflow
+		}
+	}
+	for row := uint64(0); row < s.depth; row++ {
+		s.counters[s.index(item, row)] += incr
+	}
+	s.count += uint64(incr)
+	return s.query(item), nil
+}
+
+// decrBy reverts incrBy.
+func (s *countMinSketch) decrBy(item string, incr uint32) {
+	for row := uint64(0); row < s.depth; row++ {
+		s.counters[s.index(item, row)] -= incr
+	}
+	s.count -= uint64(incr)
+}
+
+func (s *countMinSketch) size() int64 {
+	return int64(len(s.counters)) * 4
+}
+
+func (s *countMinSketch) clone() *countMinSketch {
+	c := *s
+	c.counters = append([]uint32(nil), s.counters...)
+	return &c
+}
+
+// CMSInfo is the reply of CMS.INFO.
+type CMSInfo struct {
+	Width uint64
+	Depth uint64
+	Count uint64
+}
+
+// CMSIncr is an increment of CMS.INCRBY.
+type CMSIncr struct {
+	Item  string
+	Value uint32
+}
+
+// lookupCMS returns the Count-Min sketch stored at key, ErrCMSKeyNotFound if the key does not exist.
+// caller should hold the write lock.
+func (d *DB) lookupCMS(key string) (*Data, error) {
+	data, ok := d.lookup(key)
+	if !ok {
+		return nil, ErrCMSKeyNotFound
+	}
+	if data.Type != TypeCMS {
+		return nil, ErrWrongType
+	}
+	return data, nil
+}
+
+// CMSInitByDim creates an empty Count-Min sketch of depth rows of width counters at key.
+// ErrCMSTooLarge is returned if the counters are larger than maxSize bytes.
+func (d *DB) CMSInitByDim(key string, width, depth uint64, maxSize int64) error {
+	if size, ok := CMSSize(width, depth); !ok || size > maxSize {
+		return ErrCMSTooLarge
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.peek(key); ok {
+		return ErrCMSKeyExists
+	}
+	data := newData(TypeCMS)
+	data.cms = newCountMinSketch(width, depth)
+	data.elemsMem = data.cms.size()
+	d.setKey(key, data)
+	return nil
+}
+
+// CMSDimsByProb returns the dimensions of a sketch which overestimates a count by more than errorRate times the total
+// of the increments with a probability lower than prob. The dimensions are limited to uint32 like CMS.INITBYDIM,
+// so that a tiny error rate gives a sketch too large to be created rather than an overflowed width.
+func CMSDimsByProb(errorRate, prob float64) (uint64, uint64) {
+	width := uint64(min(math.Ceil(2/errorRate), math.MaxUint32))
+	depth := uint64(min(math.Ceil(math.Log10(prob)/math.Log10(0.5)), math.MaxUint32))
+	return width, depth
+}
+
+// CMSIncrBy increments the counts of the items of the Count-Min sketch stored at key and returns their new counts.
+// None of the increments is applied if one of them overflows.
+func (d *DB) CMSIncrBy(key string, incrs []CMSIncr) ([]uint32, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	data, err := d.lookupCMS(key)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]uint32, len(incrs))
+	d.updateKey(data, func() {
+		for i, incr := range incrs {
+			if res[i], err = data.cms.incrBy(incr.Item, incr.Value); err != nil {
+				// the increments which succeeded are reverted
+				for j := i - 1; j >= 0; j-- {
+					data.cms.decrBy(incrs[j].Item, incrs[j].Value)
+				}
+				return
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// CMSQuery returns the counts of the items in the Count-Min sketch stored at key.
+func (d *DB) CMSQuery(key string, items []string) ([]uint32, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	data, err := d.lookupCMS(key)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]uint32, len(items))
+	for i, item := range items {
+		res[i] = data.cms.query(item)
+	}
+	return res, nil
+}
+
+// CMSMerge stores in the Count-Min sketch at dst the sum of the sketches at keys multiplied by their weight.
+// All the sketches must exist and have the same dimensions.
+func (d *DB) CMSMerge(dst string, keys []string, weights []int64) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	dstData, err := d.lookupCMS(dst)
+	if err != nil {
+		return err
+	}
+	res := newCountMinSketch(dstData.cms.width, dstData.cms.depth)
+	sums := make([]int64, len(res.counters))
+	var count int64
+	for i, key := range keys {
+		data, err := d.lookupCMS(key)
+		if err != nil {
+			return err
+		}
+		s := data.cms
+		if s.width != res.width || s.depth != res.depth {
+			return ErrCMSDimsMismatch
+		}
+		for j, c := range s.counters {
+			sums[j] += int64(c) * weights[i]
+		}
+		count += int64(s.count) * weights[i]
+	}
+	for j, sum := range sums {
+		if sum < 0 || sum > math.MaxUint32 {
+			return ErrCMSMergeOverflow
+		}
+		res.counters[j] = uint32(sum)
+	}
+	res.count = uint64(max(count, 0))
+	d.updateKey(dstData, func() {
+		dstData.cms = res
+	})
+	return nil
+}
+
+// CMSInfo returns the dimensions and the total count of the Count-Min sketch stored at key.
+func (d *DB) CMSInfo(key string) (CMSInfo, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	data, err := d.lookupCMS(key)
+	if err != nil {
+		return CMSInfo{}, err
+	}
+	return CMSInfo{Width: data.cms.width, Depth: data.cms.depth, Count: data.cms.count}, nil
+}
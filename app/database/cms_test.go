@@ -0,0 +1,79 @@
+package database
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCountMinSketch(t *testing.T) {
+	db := NewDB()
+	require.NoError(t, db.CMSInitByDim("cms", 2000, 5, 1<<20))
+	require.ErrorIs(t, db.CMSInitByDim("cms", 2000, 5, 1<<20), ErrCMSKeyExists)
+
+	counts, err := db.CMSIncrBy("cms", []CMSIncr{{"a", 5}, {"b", 3}, {"a", 2}})
+	require.NoError(t, err)
+	require.Equal(t, []uint32{5, 3, 7}, counts)
+	counts, err = db.CMSQuery("cms", []string{"a", "b", "c"})
+	require.NoError(t, err)
+	require.Equal(t, []uint32{7, 3, 0}, counts)
+
+	// an overflow cancels every increment of the call
+	_, err = db.CMSIncrBy("cms", []CMSIncr{{"a", 1}, {"b", 1 << 31}, {"b", 1 << 31}})
+	require.ErrorIs(t, err, ErrCMSOverflow)
+	counts, err = db.CMSQuery("cms", []string{"a", "b"})
+	require.NoError(t, err)
+	require.Equal(t, []uint32{7, 3}, counts)
+	info, err := db.CMSInfo("cms")
+	require.NoError(t, err)
+	require.Equal(t, CMSInfo{Width: 2000, Depth: 5, Count: 10}, info)
+
+	_, err = db.CMSQuery("missing", []string{"a"})
+	require.ErrorIs(t, err, ErrCMSKeyNotFound)
+	db.Set("s", "v")
+	_, err = db.CMSQuery("s", []string{"a"})
+	require.ErrorIs(t, err, ErrWrongType)
+
+	width, depth := CMSDimsByProb(0.001, 0.01)
+	require.EqualValues(t, 2000, width)
+	require.EqualValues(t, 7, depth)
+
+	width, _ = CMSDimsByProb(1e-300, 0.5)
+	require.EqualValues(t, math.MaxUint32, width)
+	require.ErrorIs(t, db.CMSInitByDim("big", math.MaxUint32, math.MaxUint32, math.MaxInt64), ErrCMSTooLarge)
+	require.ErrorIs(t, db.CMSInitByDim("big", 1<<20, 1<<10, 512<<20), ErrCMSTooLarge)
+	require.ErrorIs(t, db.CMSInitByDim("big", 1<<62, 1, math.MaxInt64), ErrCMSTooLarge)
+	require.Equal(t, "", db.Type("big"))
+}
+
+func TestCountMinSketchMerge(t *testing.T) {
+	db := NewDB()
+	for _, key := range []string{"a", "b", "dst"} {
+		require.NoError(t, db.CMSInitByDim(key, 100, 4, 1<<20))
+	}
+	require.NoError(t, db.CMSInitByDim("small", 10, 4, 1<<20))
+	_, err := db.CMSIncrBy("a", []CMSIncr{{"x", 2}, {"y", 1}})
+	require.NoError(t, err)
+	_, err = db.CMSIncrBy("b", []CMSIncr{{"x", 3}})
+	require.NoError(t, err)
+
+	require.NoError(t, db.CMSMerge("dst", []string{"a", "b"}, []int64{1, 2}))
+	counts, err := db.CMSQuery("dst", []string{"x", "y"})
+	require.NoError(t, err)
+	require.Equal(t, []uint32{8, 1}, counts)
+	info, err := db.CMSInfo("dst")
+	require.NoError(t, err)
+	require.EqualValues(t, 9, info.Count)
+
+	require.ErrorIs(t, db.CMSMerge("dst", []string{"a", "small"}, []int64{1, 1}), ErrCMSDimsMismatch)
+	require.ErrorIs(t, db.CMSMerge("dst", []string{"a", "missing"}, []int64{1, 1}), ErrCMSKeyNotFound)
+	require.ErrorIs(t, db.CMSMerge("missing", []string{"a"}, []int64{1}), ErrCMSKeyNotFound)
+	require.ErrorIs(t, db.CMSMerge("dst", []string{"a"}, []int64{-1}), ErrCMSMergeOverflow)
+
+	// the sources are unchanged by merging into one of them
+	require.NoError(t, db.CMSMerge("a", []string{"a", "a"}, []int64{1, 1}))
+	counts, err = db.CMSQuery("a", []string{"x"})
+	require.NoError(t, err)
+	require.Equal(t, []uint32{4}, counts)
+}
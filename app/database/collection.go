@@ -0,0 +1,116 @@
+package database
+
+// ZMember is a member of a sorted set with its score.
+type ZMember struct {
+	Member string
+	Score  float64
+}
+
+// HSet sets the fields of the hash stored at key, it returns the number of fields that were added.
+func (d *DB) HSet(key string, kvs []KeyValue) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	data, ok := d.lookup(key)
+	if !ok {
+		data = newData(TypeHash)
+		d.setKey(key, data)
+	}
+	if data.Type != TypeHash {
+		return 0, ErrWrongType
+	}
+	added := 0
+	d.updateKey(data, func() {
+		for _, kv := range kvs {
+			if old, ok := data.hash.get(kv.Key); ok {
+				data.elemsMem -= data.elemMem(kv.Key, old)
+			} else {
+				added++
+			}
+			data.hash.set(kv.Key, kv.Value)
+			data.hashAdded(kv.Key, kv.Value)
+		}
+	})
+	return added, nil
+}
+
+// SAdd adds the members to the set stored at key, it returns the number of members that were added.
+func (d *DB) SAdd(key string, members []string) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	data, ok := d.lookup(key)
+	if !ok {
+		data = newData(TypeSet)
+		d.setKey(key, data)
+	}
+	if data.Type != TypeSet {
+		return 0, ErrWrongType
+	}
+	added := 0
+	d.updateKey(data, func() {
+		for _, m := range members {
+			if data.set.set(m, struct{}{}) {
+				added++
+				data.setAdded(m)
+			}
+		}
+	})
+	return added, nil
+}
+
+// ZAdd adds the members to the sorted set stored at key or updates their score, it returns the number of members that were added.
+func (d *DB) ZAdd(key string, members []ZMember) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	data, ok := d.lookup(key)
+	if !ok {
+		data = newData(TypeZSet)
+		d.setKey(key, data)
+	}
+	if data.Type != TypeZSet {
+		return 0, ErrWrongType
+	}
+	added := 0
+	d.updateKey(data, func() {
+		for _, m := range members {
+			if data.zsetSet(m.Member, m.Score) {
+				added++
+				data.zsetAdded(m.Member)
+			}
+		}
+	})
+	return added, nil
+}
+
+// HGetAll returns the fields of the hash stored at key with their values.
+func (d *DB) HGetAll(key string) ([]KeyValue, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	data, ok := d.lookup(key)
+	if !ok {
+		return []KeyValue{}, nil
+	}
+	if data.Type != TypeHash {
+		return nil, ErrWrongType
+	}
+	kvs := make([]KeyValue, 0, data.hash.len())
+	data.hash.each(func(k, v string) bool {
+		kvs = append(kvs, KeyValue{Key: k, Value: v})
+		return true
+	})
+	return kvs, nil
+}
+
+// ZScore returns the score of member in the sorted set stored at key, false if the key or the member does not exist.
+func (d *DB) ZScore(key, member string) (float64, bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	data, ok := d.lookup(key)
+	if !ok {
+		return 0, false, nil
+	}
+	if data.Type != TypeZSet {
+		return 0, false, ErrWrongType
+	}
+	score, ok := data.zset.get(member)
+	return score, ok, nil
+}
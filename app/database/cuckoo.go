@@ -0,0 +1,398 @@
+package database
+
+import (
+	"errors"
+	"math"
+	"math/bits"
+)
+
+// Cuckoo filters, like CF.* of RedisBloom: 8 bits fingerprints stored in one of two buckets, which allows deletions.
+// When the fingerprints cannot be relocated, a new filter Expansion times larger is added.
+// ref: https://github.com/RedisBloom/RedisBloom/blob/v2.6.3/src/cuckoo.c
+const (
+	TypeCuckoo = "MBbloomCF"
+
+	CuckooDefaultCapacity      = 1024
+	CuckooDefaultBucketSize    = 2
+	CuckooDefaultMaxIterations = 20
+	CuckooDefaultExpansion     = 1
+	cuckooAltHashMul           = 0x5bd1e995
+)
+
+var (
+	ErrCuckooFull     = errors.New("Filter is full")
+	ErrCuckooNotFound = errors.New("Not found")
+)
+
+type cuckooFilter struct {
+	filters       [][]uint8 // buckets of bucketSize fingerprints, 0 is an empty slot
+	numBuckets    uint64    // buckets of the first filter, always a power of 2
+	bucketSize    int
+	maxIterations int
+	expansion     int // the filters are always a power of 2 times larger, 0 to never grow
+	items         int64
+	deletes       int64
+}
+
+// nextPow2 returns the smallest power of 2 greater or equal to n, and 1 for 0.
+func nextPow2(n uint64) uint64 {
+	if n <= 1 {
+		return 1
+	}
+	return 1 << bits.Len64(n-1)
+}
+
+// CuckooSize returns the size in bytes of the first filter for capacity items in buckets of bucketSize,
+// ok is false if it does not fit in an int64.
+func CuckooSize(capacity int64, bucketSize int) (int64, bool) {
+	return cuckooFilterSize(nextPow2(uint64(capacity)/uint64(bucketSize)), 1, bucketSize)
+}
+
+// cuckooFilterSize returns the size in bytes of a filter of numBuckets*scale buckets, checking for overflows.
+func cuckooFilterSize(numBuckets, scale uint64, bucketSize int) (int64, bool) {
+	hi, n := bits.Mul64(numBuckets, scale)
+	if hi != 0 {
+		return 0, false
+	}
+	hi, n = bits.Mul64(n, uint64(bucketSize))
+	if hi != 0 || n > math.MaxInt64 {
+		return 0, false
+	}
+	return int64(n), true
+}
+
+// newCuckooFilter creates the filter, its size must have been checked with CuckooSize.
+func newCuckooFilter(capacity int64, bucketSize, maxIterations, expansion int) *cuckooFilter {
+	f := &cuckooFilter{
+		numBuckets:    nextPow2(uint64(capacity) / uint64(bucketSize)),
+		bucketSize:    bucketSize,
+		maxIterations: maxIterations,
+		expansion:     expansion,
+	}
+	if expansion > 0 {
+		f.expansion = int(nextPow2(uint64(expansion)))
+	}
+	f.grow()
+	return f
+}
+
+// grow adds a filter expansion^n times larger than the first one, n being the number of filters.
+// ErrFilterTooLarge is returned if the first filter was already checked and the new one is larger than maxFilterBytes.
+func (f *cuckooFilter) grow() error {
+	scale := uint64(1)
+	for range f.filters {
+		hi, lo := bits.Mul64(scale, uint64(f.expansion))
+		if hi != 0 {
+			return ErrFilterTooLarge
+		}
+		scale = lo
+	}
+	size, ok := cuckooFilterSize(f.numBuckets, scale, f.bucketSize)
+	if !ok || len(f.filters) > 0 && size > maxFilterBytes {
+		return ErrFilterTooLarge
+	}
+	f.filters = append(f.filters, make([]uint8, size))
+	return nil
+}
+
+// cuckooHash is the fingerprint of an item and the hashes of its two buckets.
+type cuckooHash struct {
+	fp     uint8
+	h1, h2 uint64
+}
+
+func newCuckooHash(item string) cuckooHash {
+	h := murmurHash64A(item, 0)
+	fp := uint8(h%255 + 1)
+	return cuckooHash{fp: fp, h1: h, h2: altHash(fp, h)}
+}
+
+// altHash returns the other bucket of a fingerprint, the filters being a power of 2 the result is the same
+// for the hash or the bucket index.
+func altHash(fp uint8, h uint64) uint64 {
+	return h ^ uint64(fp)*cuckooAltHashMul
+}
+
+func (f *cuckooFilter) bucket(filter []uint8, h uint64) []uint8 {
+	n := uint64(len(filter) / f.bucketSize)
+	i := h % n
+	return filter[i*uint64(f.bucketSize) : (i+1)*uint64(f.bucketSize)]
+}
+
+// findSlot returns the index of the first slot of the bucket holding fp, -1 if none.
+func findSlot(bucket []uint8, fp uint8) int {
+	for i, v := range bucket {
+		if v == fp {
+			return i
+		}
+	}
+	return -1
+}
+
+// count returns the number of slots holding the fingerprint of the item, stopping at the first one if first is set.
+func (f *cuckooFilter) count(item string, first bool) int {
+	h := newCuckooHash(item)
+	n := 0
+	for _, filter := range f.filters {
+		b1, b2 := f.bucket(filter, h.h1), f.bucket(filter, h.h2)
+		buckets := [][]uint8{b1}
+		if &b1[0] != &b2[0] {
+			buckets = append(buckets, b2)
+		}
+		for _, b := range buckets {
+			for _, v := range b {
+				if v == h.fp {
+					n++
+					if first {
+						return n
+					}
+				}
+			}
+		}
+	}
+	return n
+}
+
+// insert adds the fingerprint of the item in an empty slot of its buckets, relocating the fingerprints of
+// the last filter if needed, and grows the filter if they cannot be relocated in maxIterations.
+func (f *cuckooFilter) insert(item string) error {
+	h := newCuckooHash(item)
+	for i := len(f.filters) - 1; i >= 0; i-- {
+		for _, hash := range []uint64{h.h1, h.h2} {
+			if slot := findSlot(f.bucket(f.filters[i], hash), 0); slot >= 0 {
+				f.bucket(f.filters[i], hash)[slot] = h.fp
+				f.items++
+				return nil
+			}
+		}
+	}
+	if f.kickOut(f.filters[len(f.filters)-1], h) {
+		f.items++
+		return nil
+	}
+	if f.expansion == 0 {
+		return ErrCuckooFull
+	}
+	if err := f.grow(); err != nil {
+		return err
+	}
+	f.bucket(f.filters[len(f.filters)-1], h.h1)[0] = h.fp
+	f.items++
+	return nil
+}
+
+// kickOut swaps the fingerprint with a victim of its bucket and moves the victim to its other bucket until
+// an empty slot is found. The swaps are rolled back if it fails.
+func (f *cuckooFilter) kickOut(filter []uint8, h cuckooHash) bool {
+	numBuckets := uint64(len(filter) / f.bucketSize)
+	fp := h.fp
+	victim := 0
+	i := h.h1 % numBuckets
+	for n := 0; n < f.maxIterations; n++ {
+		bucket := f.bucket(filter, i)
+		bucket[victim], fp = fp, bucket[victim]
+		i = altHash(fp, i) % numBuckets
+		if slot := findSlot(f.bucket(filter, i), 0); slot >= 0 {
+			f.bucket(filter, i)[slot] = fp
+			return true
+		}
+		victim = (victim + 1) % f.bucketSize
+	}
+	for n := 0; n < f.maxIterations; n++ {
+		victim = (victim + f.bucketSize - 1) % f.bucketSize
+		i = altHash(fp, i) % numBuckets
+		bucket := f.bucket(filter, i)
+		bucket[victim], fp = fp, bucket[victim]
+	}
+	return false
+}
+
+// delete removes one fingerprint of the item, starting with the newest filter.
+func (f *cuckooFilter) delete(item string) bool {
+	h := newCuckooHash(item)
+	for i := len(f.filters) - 1; i >= 0; i-- {
+		for _, hash := range []uint64{h.h1, h.h2} {
+			b := f.bucket(f.filters[i], hash)
+			if slot := findSlot(b, h.fp); slot >= 0 {
+				b[slot] = 0
+				f.items--
+				f.deletes++
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// size is the memory used by the buckets of the filter.
+func (f *cuckooFilter) size() int64 {
+	var size int64
+	for _, filter := range f.filters {
+		size += int64(len(filter))
+	}
+	return size
+}
+
+func (f *cuckooFilter) clone() *cuckooFilter {
+	c := *f
+	c.filters = make([][]uint8, len(f.filters))
+	for i, filter := range f.filters {
+		c.filters[i] = append([]uint8(nil), filter...)
+	}
+	return &c
+}
+
+// CuckooOptions are the parameters of a Cuckoo filter.
+type CuckooOptions struct {
+	Capacity      int64
+	BucketSize    int
+	MaxIterations int
+	Expansion     int
+}
+
+// DefaultCuckooOptions are the parameters of the filters created by CF.ADD.
+var DefaultCuckooOptions = CuckooOptions{
+	Capacity:      CuckooDefaultCapacity,
+	BucketSize:    CuckooDefaultBucketSize,
+	MaxIterations: CuckooDefaultMaxIterations,
+	Expansion:     CuckooDefaultExpansion,
+}
+
+// CuckooInfo is the reply of CF.INFO.
+type CuckooInfo struct {
+	Size          int64
+	Buckets       uint64
+	Filters       int
+	Items         int64
+	Deletes       int64
+	BucketSize    int
+	Expansion     int
+	MaxIterations int
+}
+
+// lookupCuckoo returns the Cuckoo filter stored at key, ok is false if the key does not exist.
+// caller should hold the write lock.
+func (d *DB) lookupCuckoo(key string) (*Data, bool, error) {
+	data, ok := d.lookup(key)
+	if !ok {
+		return nil, false, nil
+	}
+	if data.Type != TypeCuckoo {
+		return nil, false, ErrWrongType
+	}
+	return data, true, nil
+}
+
+// CFReserve creates an empty Cuckoo filter at key.
+// ErrFilterTooLarge is returned if the filter is larger than maxSize bytes.
+func (d *DB) CFReserve(key string, opts CuckooOptions, maxSize int64) error {
+	if size, ok := CuckooSize(opts.Capacity, opts.BucketSize); !ok || size > maxSize {
+		return ErrFilterTooLarge
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.peek(key); ok {
+		return ErrItemExists
+	}
+	d.setCuckoo(key, opts)
+	return nil
+}
+
+// setCuckoo creates an empty Cuckoo filter at key.
+// caller should hold the write lock.
+func (d *DB) setCuckoo(key string, opts CuckooOptions) *Data {
+	data := newData(TypeCuckoo)
+	data.cuckoo = newCuckooFilter(opts.Capacity, opts.BucketSize, opts.MaxIterations, opts.Expansion)
+	data.elemsMem = data.cuckoo.size()
+	d.setKey(key, data)
+	return data
+}
+
+// CFAdd adds the item to the Cuckoo filter stored at key, which is created with the default parameters if it does not exist.
+// With nx, the item is not added if it may already exist and false is returned.
+func (d *DB) CFAdd(key, item string, nx bool) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	data, ok, err := d.lookupCuckoo(key)
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		data = d.setCuckoo(key, DefaultCuckooOptions)
+	}
+	if nx && data.cuckoo.count(item, true) > 0 {
+		return false, nil
+	}
+	d.updateKey(data, func() {
+		err = data.cuckoo.insert(item)
+		data.elemsMem = data.cuckoo.size()
+	})
+	return err == nil, err
+}
+
+// CFExists returns for every item whether it may be in the Cuckoo filter stored at key.
+func (d *DB) CFExists(key string, items []string) ([]bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	data, ok, err := d.lookupCuckoo(key)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]bool, len(items))
+	if !ok {
+		return res, nil
+	}
+	for i, item := range items {
+		res[i] = data.cuckoo.count(item, true) > 0
+	}
+	return res, nil
+}
+
+// CFCount returns an estimation of the number of times the item was added to the Cuckoo filter stored at key.
+func (d *DB) CFCount(key, item string) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	data, ok, err := d.lookupCuckoo(key)
+	if err != nil || !ok {
+		return 0, err
+	}
+	return data.cuckoo.count(item, false), nil
+}
+
+// CFDel removes one occurrence of the item from the Cuckoo filter stored at key, it returns false if it was not found.
+func (d *DB) CFDel(key, item string) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	data, ok, err := d.lookupCuckoo(key)
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		return false, ErrCuckooNotFound
+	}
+	return data.cuckoo.delete(item), nil
+}
+
+// CFInfo returns the parameters and the usage of the Cuckoo filter stored at key.
+func (d *DB) CFInfo(key string) (CuckooInfo, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	data, ok, err := d.lookupCuckoo(key)
+	if err != nil {
+		return CuckooInfo{}, err
+	}
+	if !ok {
+		return CuckooInfo{}, ErrCuckooNotFound
+	}
+	f := data.cuckoo
+	return CuckooInfo{
+		Size:          f.size(),
+		Buckets:       f.numBuckets,
+		Filters:       len(f.filters),
+		Items:         f.items,
+		Deletes:       f.deletes,
+		BucketSize:    f.bucketSize,
+		Expansion:     f.expansion,
+		MaxIterations: f.maxIterations,
+	}, nil
+}
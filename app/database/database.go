@@ -3,12 +3,14 @@ package database
 import (
 	"fmt"
 	"math"
-	"regexp"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/glob"
 )
 
 const (
@@ -16,7 +18,9 @@ const (
 )
 
 type DB struct {
-	datas           map[string]*Data
+	datas           *dict[*Data]
+	expires         *dict[struct{}] // keys with an expire time, sampled by the volatile eviction policies
+	used            atomic.Int64    // estimated memory of the keys, see keyMem
 	mu              sync.RWMutex
 	streamEntrySubs map[string][]subscription
 	subMU           sync.RWMutex
@@ -26,7 +30,23 @@ type Data struct {
 	Type              string
 	Value             string
 	ExpireTimestampMS uint64
+	bits              []byte // bytes of strings modified by the bitmap commands, Value is empty while it is set
 	Entries           []Entry
+	hash              *dict[string]
+	set               *dict[struct{}]
+	zset              *dict[float64]
+	zsl               *skiplist // members of zset ordered by score
+	list              []string
+	bloom             *bloomFilter
+	cuckoo            *cuckooFilter
+	cms               *countMinSketch
+	topk              *topK
+	doc               any    // JSON document
+	encoding          string // encoding of collections, strings are encoded according to their value
+	lastAccessMS      int64  // unix time of the last access, for OBJECT IDLETIME and LRU eviction
+	lfuCounter        uint8  // logarithmic access counter, for OBJECT FREQ and LFU eviction
+	lfuDecrMinutes    int64  // unix time in minutes of the last decrement of lfuCounter
+	elemsMem          int64  // memory of the elements of collections and streams, see mem
 }
 
 type Entry struct {
@@ -41,78 +61,162 @@ type KeyValue struct {
 }
 
 func NewString(value string, expireTimestampMS uint64) *Data {
-	return &Data{
-		Type:              TypeString,
-		Value:             value,
-		ExpireTimestampMS: expireTimestampMS,
-	}
+	data := newData(TypeString)
+	data.Value = value
+	data.ExpireTimestampMS = expireTimestampMS
+	return data
+}
+
+// newData returns an empty data of the type with its access metadata initialized.
+func newData(typ string) *Data {
+	now := time.Now()
+	data := &Data{
+		Type:           typ,
+		lastAccessMS:   now.UnixMilli(),
+		lfuCounter:     lfuInitVal,
+		lfuDecrMinutes: now.Unix() / 60,
+	}
+	switch typ {
+	case TypeHash:
+		data.hash = newDict[string]()
+		data.encoding = EncodingListpack
+	case TypeSet:
+		data.set = newDict[struct{}]()
+		data.encoding = EncodingIntset
+	case TypeZSet:
+		data.zset = newDict[float64]()
+		data.zsl = newSkiplist()
+		data.encoding = EncodingListpack
+	case TypeList:
+		data.encoding = EncodingListpack
+	case TypeStream:
+		data.encoding = EncodingStream
+	case TypeBloom, TypeCuckoo, TypeCMS, TypeTopK, TypeJSON:
+		// like the values of module types in redis
+		data.encoding = EncodingRaw
+	}
+	return data
 }
 
 func NewDB() *DB {
 	return &DB{
-		datas:           make(map[string]*Data),
+		datas:           newDict[*Data](),
+		expires:         newDict[struct{}](),
 		streamEntrySubs: make(map[string][]subscription),
 	}
 }
 
 func NewFromLoad(datas map[string]*Data) *DB {
-	if datas == nil {
-		return NewDB()
-	}
-	return &DB{
-		datas: datas,
+	db := NewDB()
+	for k, v := range datas {
+		if v.Type != TypeString {
+			v.elemsMem = v.computeElemsMem()
+		}
+		db.setKey(k, v)
 	}
+	return db
+}
+
+// Snapshot returns a copy of every key and its data, for saving RDB files.
+func (d *DB) Snapshot() map[string]*Data {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	datas := make(map[string]*Data, d.datas.len())
+	d.datas.each(func(key string, data *Data) bool {
+		if !data.expired(time.Now()) {
+			datas[key] = data.clone()
+		}
+		return true
+	})
+	return datas
 }
 
 func (d *DB) Get(key string) string {
 	return d.get(key).Value
 }
 
+// get returns a copy of the data of key, the write lock is needed since the access is recorded.
 func (d *DB) get(key string) Data {
-	d.mu.RLock()
-	defer d.mu.RUnlock()
-	if data, ok := d.datas[key]; ok {
-		if data.ExpireTimestampMS != NO_EXPIRY && uint64(time.Now().UnixMilli()) > data.ExpireTimestampMS {
-			delete(d.datas, key)
-			return Data{}
-		}
-		return *data
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if data, ok := d.lookup(key); ok {
+		c := *data
+		c.Value, c.bits = data.str(), nil
+		return c
 	}
 	return Data{}
 }
 
+// lookup returns the data of key, lazily deletes it if expired and records the access.
+// caller should hold the write lock.
+func (d *DB) lookup(key string) (*Data, bool) {
+	data, ok := d.peek(key)
+	if ok {
+		data.touch(time.Now())
+	}
+	return data, ok
+}
+
+// peek is like lookup but does not record the access, for commands which do not use the value
+// like TYPE or OBJECT, and for existence checks.
+// caller should hold the write lock.
+func (d *DB) peek(key string) (*Data, bool) {
+	data, ok := d.datas.get(key)
+	if !ok {
+		return nil, false
+	}
+	if data.expired(time.Now()) {
+		d.deleteKey(key)
+		return nil, false
+	}
+	return data, true
+}
+
+func (data *Data) expired(now time.Time) bool {
+	return data.ExpireTimestampMS != NO_EXPIRY && uint64(now.UnixMilli()) > data.ExpireTimestampMS
+}
+
 func (d *DB) Type(key string) string {
-	return d.get(key).Type
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if data, ok := d.peek(key); ok {
+		return data.Type
+	}
+	return ""
 }
 
 func (d *DB) Set(key, value string) {
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	d.datas[key] = NewString(value, NO_EXPIRY)
+	d.setKey(key, NewString(value, NO_EXPIRY))
 }
 
 func (d *DB) SetExp(key, value string, exp int64) {
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	d.datas[key] = NewString(value, uint64(exp))
+	d.setKey(key, NewString(value, uint64(exp)))
 }
 
-func (d *DB) Keys(reg *regexp.Regexp) []string {
+// Keys returns the keys matching the glob-style pattern.
+func (d *DB) Keys(pattern string) []string {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 	keys := make([]string, 0)
-	for key := range d.datas {
-		if reg.MatchString(key) {
+	now := time.Now()
+	allKeys := glob.MatchAll(pattern)
+	d.datas.each(func(key string, data *Data) bool {
+		if !data.expired(now) && (allKeys || glob.Match(pattern, key, false)) {
 			keys = append(keys, key)
 		}
-	}
+		return true
+	})
 	return keys
 }
 
 func (d *DB) XAdd(key, inputEntryID string, kvs []KeyValue) (string, error) {
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	if data, ok := d.datas[key]; ok {
+	if data, ok := d.lookup(key); ok {
 		if data.Type != TypeStream {
 			return "", ErrWrongType
 		}
@@ -125,7 +229,10 @@ func (d *DB) XAdd(key, inputEntryID string, kvs []KeyValue) (string, error) {
 			Seq: seq,
 			KVs: kvs,
 		}
-		data.Entries = append(data.Entries, ent)
+		d.updateKey(data, func() {
+			data.Entries = append(data.Entries, ent)
+			data.elemsMem += entryMem(ent)
+		})
 		d.publishXAdd(key, ent)
 		return StreamEntryID(ts, seq), nil
 	}
@@ -138,10 +245,10 @@ func (d *DB) XAdd(key, inputEntryID string, kvs []KeyValue) (string, error) {
 		Seq: seq,
 		KVs: kvs,
 	}
-	d.datas[key] = &Data{
-		Type:    TypeStream,
-		Entries: []Entry{ent},
-	}
+	data := newData(TypeStream)
+	data.Entries = []Entry{ent}
+	data.elemsMem = entryMem(ent)
+	d.setKey(key, data)
 	d.publishXAdd(key, ent)
 	return StreamEntryID(ts, seq), nil
 }
@@ -209,8 +316,8 @@ func parseEntryIDForXAdd(entryID string) (uint64, uint64, bool, error) {
 }
 
 func (d *DB) Xrange(key, start, end string) ([]Entry, error) {
-	d.mu.RLock()
-	defer d.mu.RUnlock()
+	d.mu.Lock()
+	defer d.mu.Unlock()
 	var sts, sseq, ets, eseq uint64
 	var notSpecified bool
 	var err error
@@ -239,7 +346,7 @@ func (d *DB) Xrange(key, start, end string) ([]Entry, error) {
 		}
 
 	}
-	if data, ok := d.datas[key]; ok {
+	if data, ok := d.lookup(key); ok {
 		if data.Type != TypeStream {
 			return nil, ErrWrongType
 		}
@@ -292,9 +399,10 @@ const (
 	BLOCKING_NO_TIMEOUT time.Duration = 0
 )
 
-func (d *DB) Xread(key, start string, blocking time.Duration) ([]Entry, chan Entry, error) {
-	d.mu.RLock()
-	defer d.mu.RUnlock()
+// Xread reads the entries of the stream after start. A blocking read with a timeout waits for it, or until unblock is closed.
+func (d *DB) Xread(key, start string, blocking time.Duration, unblock <-chan struct{}) ([]Entry, chan Entry, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 	var sts, sseq uint64
 	var err error
 	var useLatest bool // blocking XREAD command signals that we only want new entries. This is similar to passing in the maximum ID we currently have in the stream.
@@ -308,11 +416,16 @@ func (d *DB) Xread(key, start string, blocking time.Duration) ([]Entry, chan Ent
 		}
 	}
 	if blocking > 0 {
-		d.mu.RUnlock()
-		time.Sleep(blocking)
-		d.mu.RLock()
+		d.mu.Unlock()
+		timer := time.NewTimer(blocking)
+		select {
+		case <-timer.C:
+		case <-unblock:
+			timer.Stop()
+		}
+		d.mu.Lock()
 	}
-	if data, ok := d.datas[key]; ok {
+	if data, ok := d.lookup(key); ok {
 		if data.Type != TypeStream {
 			return nil, nil, ErrWrongType
 		}
@@ -353,7 +466,7 @@ func (d *DB) subscribeXAdd(key string, entryTS uint64, entrySeq uint64) (chan En
 	d.subMU.Lock()
 	defer d.subMU.Unlock()
 	sub := subscription{
-		Chan:     make(chan Entry),
+		Chan:     make(chan Entry, 1), // the subscriber may have stopped waiting, so publishing must not block
 		EntryTs:  entryTS,
 		EntrySeq: entrySeq,
 	}
@@ -0,0 +1,306 @@
+package database
+
+import (
+	"hash/maphash"
+	"math/bits"
+	"math/rand"
+)
+
+const dictMinSize = 4
+
+// dictRehashEmptyVisits bounds the empty buckets visited per bucket moved by a rehash step, like dictRehash in redis.
+const dictRehashEmptyVisits = 10
+
+// dict is a chained hash table with a power-of-two number of buckets.
+// Unlike the builtin map, its bucket layout is visible, which lets callers iterate it with a stateless cursor (see scan).
+// The table is resized incrementally like in redis: while rehashing, the entries are split between the old table
+// and the new one, and every lookup, insertion or deletion moves one bucket, so resizing a large table does not
+// hold the DB lock for long.
+// ref: https://github.com/redis/redis/blob/7.2.0/src/dict.c#L283
+// dict is not safe for concurrent use, callers hold the DB lock.
+type dict[V any] struct {
+	// tables[1] is only allocated while rehashing
+	tables [2][]*dictEntry[V]
+	// rehashIdx is the next bucket of tables[0] to move to tables[1], -1 when not rehashing
+	rehashIdx int
+	size      int
+	seed      maphash.Seed
+}
+
+type dictEntry[V any] struct {
+	key  string
+	val  V
+	next *dictEntry[V]
+}
+
+func newDict[V any]() *dict[V] {
+	return &dict[V]{
+		tables:    [2][]*dictEntry[V]{make([]*dictEntry[V], dictMinSize)},
+		rehashIdx: -1,
+		seed:      maphash.MakeSeed(),
+	}
+}
+
+func (d *dict[V]) bucketIdx(key string, mask uint64) uint64 {
+	return maphash.String(d.seed, key) & mask
+}
+
+func (d *dict[V]) len() int {
+	return d.size
+}
+
+// buckets returns the number of buckets of both tables.
+func (d *dict[V]) buckets() int {
+	return len(d.tables[0]) + len(d.tables[1])
+}
+
+func (d *dict[V]) rehashing() bool {
+	return d.rehashIdx >= 0
+}
+
+// liveTables returns the tables that may hold entries, the old one first.
+func (d *dict[V]) liveTables() [][]*dictEntry[V] {
+	if d.rehashing() {
+		return d.tables[:]
+	}
+	return d.tables[:1]
+}
+
+// rehash moves up to n buckets of the old table to the new one, giving up after n*10 empty buckets.
+// The new table replaces the old one once all the buckets are moved.
+// It returns true if there are still buckets to move.
+func (d *dict[V]) rehash(n int) bool {
+	if !d.rehashing() {
+		return false
+	}
+	old := d.tables[0]
+	mask := uint64(len(d.tables[1]) - 1)
+	emptyVisits := n * dictRehashEmptyVisits
+	for n > 0 && emptyVisits > 0 && d.rehashIdx < len(old) {
+		e := old[d.rehashIdx]
+		if e == nil {
+			d.rehashIdx++
+			emptyVisits--
+			continue
+		}
+		for e != nil {
+			next := e.next
+			idx := d.bucketIdx(e.key, mask)
+			e.next = d.tables[1][idx]
+			d.tables[1][idx] = e
+			e = next
+		}
+		old[d.rehashIdx] = nil
+		d.rehashIdx++
+		n--
+	}
+	if d.rehashIdx < len(old) {
+		return true
+	}
+	d.tables[0], d.tables[1] = d.tables[1], nil
+	d.rehashIdx = -1
+	// the size may have changed enough during the rehash to need another one
+	d.resizeIfNeeded()
+	return d.rehashing()
+}
+
+// find returns the entry of key, nil if it does not exist.
+func (d *dict[V]) find(key string) *dictEntry[V] {
+	for _, t := range d.liveTables() {
+		for e := t[d.bucketIdx(key, uint64(len(t)-1))]; e != nil; e = e.next {
+			if e.key == key {
+				return e
+			}
+		}
+	}
+	return nil
+}
+
+func (d *dict[V]) get(key string) (V, bool) {
+	d.rehash(1)
+	if e := d.find(key); e != nil {
+		return e.val, true
+	}
+	var zero V
+	return zero, false
+}
+
+// set inserts or replaces the value of key, it returns true if the key is new.
+func (d *dict[V]) set(key string, val V) bool {
+	d.rehash(1)
+	if e := d.find(key); e != nil {
+		e.val = val
+		return false
+	}
+	// while rehashing the new entries go to the new table, so the old one only empties
+	t := d.tables[0]
+	if d.rehashing() {
+		t = d.tables[1]
+	}
+	idx := d.bucketIdx(key, uint64(len(t)-1))
+	t[idx] = &dictEntry[V]{key: key, val: val, next: t[idx]}
+	d.size++
+	d.resizeIfNeeded()
+	return true
+}
+
+// delete removes the key, it returns true if the key existed.
+func (d *dict[V]) delete(key string) bool {
+	d.rehash(1)
+	for _, t := range d.liveTables() {
+		idx := d.bucketIdx(key, uint64(len(t)-1))
+		var prev *dictEntry[V]
+		for e := t[idx]; e != nil; e = e.next {
+			if e.key != key {
+				prev = e
+				continue
+			}
+			if prev == nil {
+				t[idx] = e.next
+			} else {
+				prev.next = e.next
+			}
+			d.size--
+			d.resizeIfNeeded()
+			return true
+		}
+	}
+	return false
+}
+
+// resizeIfNeeded grows the table to keep a load factor of 1, same as redis when no child process is running,
+// and shrinks it when less than 10% of the buckets are used.
+func (d *dict[V]) resizeIfNeeded() {
+	switch n := len(d.tables[0]); {
+	case d.size > n:
+		d.resize(n * 2)
+	case n > dictMinSize && d.size*10 < n:
+		d.resize(d.size)
+	}
+}
+
+// resize starts rehashing to the smallest table of at least n buckets, the entries are moved by the next operations.
+// It does nothing while a rehash is in progress, like redis.
+func (d *dict[V]) resize(n int) {
+	if d.rehashing() {
+		return
+	}
+	size := dictMinSize
+	for size < n {
+		size *= 2
+	}
+	if size == len(d.tables[0]) {
+		return
+	}
+	d.tables[1] = make([]*dictEntry[V], size)
+	d.rehashIdx = 0
+}
+
+// each calls fn for every entry until fn returns false.
+// fn must not modify the dict.
+func (d *dict[V]) each(fn func(key string, val V) bool) {
+	for _, t := range d.liveTables() {
+		for _, e := range t {
+			for ; e != nil; e = e.next {
+				if !fn(e.key, e.val) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// scan visits every entry of the bucket pointed by cursor and returns the next cursor, 0 when the iteration is complete.
+// The cursor is advanced by incrementing its reversed bits (the high bits first), like dictScan in redis.
+// Since the table size is a power of two, a bucket of a grown or shrunk table maps to the same cursor prefix,
+// so every entry present for the whole iteration is returned at least once even if the dict is resized between calls.
+// While rehashing, the bucket of the smaller table is visited along with all its expansions in the larger one.
+// Entries may be returned more than once when the table shrinks.
+// ref: https://github.com/redis/redis/blob/7.2.0/src/dict.c#L1234
+// fn must not modify the dict.
+func (d *dict[V]) scan(cursor uint64, fn func(key string, val V)) uint64 {
+	visit := func(t []*dictEntry[V], mask uint64) {
+		for e := t[cursor&mask]; e != nil; e = e.next {
+			fn(e.key, e.val)
+		}
+	}
+	// next increments the reversed cursor on the bits of mask only, the unmasked bits are set so the carry ignores them
+	next := func(mask uint64) {
+		cursor |= ^mask
+		cursor = bits.Reverse64(cursor)
+		cursor++
+		cursor = bits.Reverse64(cursor)
+	}
+	if !d.rehashing() {
+		mask := uint64(len(d.tables[0]) - 1)
+		visit(d.tables[0], mask)
+		next(mask)
+		return cursor
+	}
+	small, large := d.tables[0], d.tables[1]
+	if len(small) > len(large) {
+		small, large = large, small
+	}
+	mask0, mask1 := uint64(len(small)-1), uint64(len(large)-1)
+	visit(small, mask0)
+	// the buckets of the larger table whose index ends with the bits of the smaller one
+	for {
+		visit(large, mask1)
+		next(mask1)
+		if cursor&(mask0^mask1) == 0 {
+			return cursor
+		}
+	}
+}
+
+// clone returns a copy of the dict, the values are copied shallowly.
+func (d *dict[V]) clone() *dict[V] {
+	c := newDict[V]()
+	d.each(func(key string, val V) bool {
+		c.set(key, val)
+		return true
+	})
+	return c
+}
+
+// clear unlinks all the buckets, it takes O(buckets) and lets the garbage collector reclaim the entries.
+// A rehash in progress is abandoned.
+func (d *dict[V]) clear() {
+	clear(d.tables[0])
+	d.tables[1] = nil
+	d.rehashIdx = -1
+	d.size = 0
+}
+
+// randomKey returns a random key, false if the dict is empty.
+func (d *dict[V]) randomKey() (string, bool) {
+	if d.size == 0 {
+		return "", false
+	}
+	d.rehash(1)
+	// with a load factor <= 1 most buckets are not empty, except after many deletions before the table shrinks.
+	// While rehashing, the buckets of the old table before rehashIdx are empty and skipped, like dictGetRandomKey.
+	var head *dictEntry[V]
+	for head == nil {
+		if !d.rehashing() {
+			head = d.tables[0][rand.Intn(len(d.tables[0]))]
+			continue
+		}
+		idx := d.rehashIdx + rand.Intn(d.buckets()-d.rehashIdx)
+		if idx < len(d.tables[0]) {
+			head = d.tables[0][idx]
+		} else {
+			head = d.tables[1][idx-len(d.tables[0])]
+		}
+	}
+	// pick a random element of the chain
+	n := 0
+	for e := head; e != nil; e = e.next {
+		n++
+	}
+	e := head
+	for i := rand.Intn(n); i > 0; i-- {
+		e = e.next
+	}
+	return e.key, true
+}
@@ -0,0 +1,155 @@
+package database
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDictSetGetDelete(t *testing.T) {
+	d := newDict[int]()
+	for i := 0; i < 100; i++ {
+		require.True(t, d.set(fmt.Sprintf("key%d", i), i))
+	}
+	require.False(t, d.set("key1", 1000))
+	require.Equal(t, 100, d.len())
+	v, ok := d.get("key1")
+	require.True(t, ok)
+	require.Equal(t, 1000, v)
+	for i := 0; i < 100; i++ {
+		require.True(t, d.delete(fmt.Sprintf("key%d", i)))
+	}
+	require.False(t, d.delete("key1"))
+	require.Equal(t, 0, d.len())
+	for d.rehash(100) {
+	}
+	require.Equal(t, dictMinSize, d.buckets())
+}
+
+func TestDictIncrementalRehash(t *testing.T) {
+	d := newDict[int]()
+	for i := 0; i < 64; i++ {
+		d.set(fmt.Sprintf("key%d", i), i)
+	}
+	for d.rehash(100) {
+	}
+	require.Equal(t, 64, len(d.tables[0]))
+
+	// the 65th key starts the rehash, which only moves one bucket per operation
+	d.set("key64", 64)
+	require.True(t, d.rehashing())
+	require.Len(t, d.tables[1], 128)
+	for i := 0; i <= 64; i++ {
+		v, ok := d.get(fmt.Sprintf("key%d", i))
+		require.True(t, ok)
+		require.Equal(t, i, v)
+	}
+	seen := map[string]bool{}
+	d.each(func(key string, _ int) bool {
+		seen[key] = true
+		return true
+	})
+	require.Len(t, seen, 65)
+	require.True(t, d.delete("key0"))
+	require.False(t, d.delete("key0"))
+	require.Equal(t, 64, d.len())
+
+	for d.rehash(1) {
+	}
+	require.Nil(t, d.tables[1])
+	require.Len(t, d.tables[0], 128)
+	for i := 1; i <= 64; i++ {
+		_, ok := d.get(fmt.Sprintf("key%d", i))
+		require.True(t, ok)
+	}
+}
+
+func scanAll[V any](d *dict[V], between func(step int)) map[string]int {
+	seen := map[string]int{}
+	cursor := uint64(0)
+	step := 0
+	for {
+		cursor = d.scan(cursor, func(key string, _ V) {
+			seen[key]++
+		})
+		if cursor == 0 {
+			return seen
+		}
+		between(step)
+		step++
+	}
+}
+
+func TestDictScanWhileGrowing(t *testing.T) {
+	d := newDict[struct{}]()
+	for i := 0; i < 50; i++ {
+		d.set(fmt.Sprintf("old%d", i), struct{}{})
+	}
+	seen := scanAll(d, func(step int) {
+		// grow the table several times in the middle of the iteration
+		if step >= 5 {
+			return
+		}
+		for i := 0; i < 100; i++ {
+			d.set(fmt.Sprintf("new%d-%d", step, i), struct{}{})
+		}
+	})
+	for i := 0; i < 50; i++ {
+		require.Contains(t, seen, fmt.Sprintf("old%d", i))
+	}
+}
+
+func TestDictScanWhileShrinking(t *testing.T) {
+	d := newDict[struct{}]()
+	for i := 0; i < 1000; i++ {
+		d.set(fmt.Sprintf("tmp%d", i), struct{}{})
+	}
+	for i := 0; i < 10; i++ {
+		d.set(fmt.Sprintf("keep%d", i), struct{}{})
+	}
+	deleted := 0
+	seen := scanAll(d, func(step int) {
+		for ; deleted < 1000 && deleted < (step+1)*100; deleted++ {
+			d.delete(fmt.Sprintf("tmp%d", deleted))
+		}
+	})
+	for i := 0; i < 10; i++ {
+		require.Contains(t, seen, fmt.Sprintf("keep%d", i))
+	}
+}
+
+func TestDictScanWhileRehashing(t *testing.T) {
+	for _, grow := range []bool{true, false} {
+		d := newDict[struct{}]()
+		for i := 0; i < 1000; i++ {
+			d.set(fmt.Sprintf("tmp%d", i), struct{}{})
+		}
+		for i := 0; i < 100; i++ {
+			d.set(fmt.Sprintf("keep%d", i), struct{}{})
+		}
+		for d.rehash(100) {
+		}
+		if grow {
+			for i := 0; !d.rehashing(); i++ {
+				d.set(fmt.Sprintf("new%d", i), struct{}{})
+			}
+		} else {
+			for i := 0; !d.rehashing(); i++ {
+				d.delete(fmt.Sprintf("tmp%d", i))
+			}
+		}
+		rehashing := 0
+		seen := scanAll(d, func(int) {
+			if d.rehashing() {
+				rehashing++
+			}
+			// move a few buckets between the calls, like the commands of other clients would
+			d.rehash(2)
+		})
+		require.Positive(t, rehashing)
+		for i := 0; i < 100; i++ {
+			require.Contains(t, seen, fmt.Sprintf("keep%d", i))
+		}
+	}
+}
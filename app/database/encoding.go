@@ -0,0 +1,115 @@
+package database
+
+import "strconv"
+
+// Encodings reported by OBJECT ENCODING, named after the redis encodings.
+// Values are always stored in Go maps and strings, the encoding tells which representation redis would use,
+// the compact encodings are converted to the full ones once a collection outgrows them and never back.
+const (
+	EncodingInt       = "int"
+	EncodingEmbstr    = "embstr"
+	EncodingRaw       = "raw"
+	EncodingListpack  = "listpack"
+	EncodingIntset    = "intset"
+	EncodingHashtable = "hashtable"
+	EncodingSkiplist  = "skiplist"
+	EncodingQuicklist = "quicklist"
+	EncodingStream    = "stream"
+)
+
+// thresholds of the compact encodings, same as the default configuration of redis.
+const (
+	embstrSizeLimit        = 44
+	hashMaxListpackEntries = 128
+	hashMaxListpackValue   = 64
+	setMaxIntsetEntries    = 512
+	setMaxListpackEntries  = 128
+	setMaxListpackValue    = 64
+	zsetMaxListpackEntries = 128
+	zsetMaxListpackValue   = 64
+	listMaxListpackSize    = 8 << 10 // list-max-listpack-size -2
+	maxInt64StringLen      = 20
+)
+
+// Encoding returns the encoding of the data.
+func (data *Data) Encoding() string {
+	if data.bits != nil {
+		// like redis, strings modified by the bitmap commands are unshared into raw strings
+		return EncodingRaw
+	}
+	if data.Type == TypeString {
+		return stringEncoding(data.Value)
+	}
+	return data.encoding
+}
+
+func stringEncoding(s string) string {
+	if isInt64(s) {
+		return EncodingInt
+	}
+	if len(s) <= embstrSizeLimit {
+		return EncodingEmbstr
+	}
+	return EncodingRaw
+}
+
+// isInt64 reports whether s is the canonical representation of an int64, like string2ll in redis
+// (no leading zeros, spaces or plus sign), so that converting it back gives the same string.
+func isInt64(s string) bool {
+	if len(s) == 0 || len(s) > maxInt64StringLen {
+		return false
+	}
+	i, err := strconv.ParseInt(s, 10, 64)
+	return err == nil && strconv.FormatInt(i, 10) == s
+}
+
+// hashAdded converts the encoding of a hash once a field is set and accounts the memory of the field.
+func (data *Data) hashAdded(field, value string) {
+	if data.encoding == EncodingListpack &&
+		(data.hash.len() > hashMaxListpackEntries || len(field) > hashMaxListpackValue || len(value) > hashMaxListpackValue) {
+		data.convert(EncodingHashtable)
+		return
+	}
+	data.elemsMem += data.elemMem(field, value)
+}
+
+// setAdded converts the encoding of a set once a member is added and accounts the memory of the member.
+func (data *Data) setAdded(member string) {
+	encoding := data.encoding
+	if encoding == EncodingIntset && (!isInt64(member) || data.set.len() > setMaxIntsetEntries) {
+		encoding = EncodingListpack
+	}
+	if encoding == EncodingListpack && (data.set.len() > setMaxListpackEntries || len(member) > setMaxListpackValue) {
+		encoding = EncodingHashtable
+	}
+	if encoding != data.encoding {
+		data.convert(encoding)
+		return
+	}
+	data.elemsMem += data.elemMem(member, "")
+}
+
+// zsetAdded converts the encoding of a sorted set once a member is added and accounts the memory of the member.
+func (data *Data) zsetAdded(member string) {
+	if data.encoding == EncodingListpack && (data.zset.len() > zsetMaxListpackEntries || len(member) > zsetMaxListpackValue) {
+		data.convert(EncodingSkiplist)
+		return
+	}
+	data.elemsMem += data.elemMem(member, "")
+}
+
+// listAdded converts the encoding of a list once elements are pushed and accounts the memory of the elements.
+func (data *Data) listAdded(elems []string) {
+	for _, e := range elems {
+		data.elemsMem += data.elemMem(e, "")
+	}
+	if data.encoding == EncodingListpack && listpackHeader+data.elemsMem > listMaxListpackSize {
+		data.convert(EncodingQuicklist)
+	}
+}
+
+// convert changes the encoding of a collection, the memory of every element is computed again.
+func (data *Data) convert(encoding string) {
+	data.encoding = encoding
+	data.elemsMem = data.computeElemsMem()
+}
@@ -7,4 +7,18 @@ var (
 	ErrInvalidEntryID = errors.New("invalid entry id")
 	ErrIDMinVal       = errors.New("The ID specified in XADD must be greater than 0-0")
 	ErrIDTooSmall     = errors.New("The ID specified in XADD is equal or smaller than the target stream top item")
+	ErrNoSuchKey      = errors.New("no such key")
+	ErrNoSuchKeyHLL   = errors.New("The specified key does not exist")
+	ErrInvalidHLL     = &CodedError{Code: "WRONGTYPE", Msg: "Key is not a valid HyperLogLog string value."}
+	ErrCorruptedHLL   = &CodedError{Code: "INVALIDOBJ", Msg: "Corrupted HLL object detected"}
 )
+
+// CodedError is an error replied with its own code instead of ERR.
+type CodedError struct {
+	Code string
+	Msg  string
+}
+
+func (e *CodedError) Error() string {
+	return e.Msg
+}
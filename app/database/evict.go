@@ -0,0 +1,220 @@
+package database
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Eviction policies of maxmemory-policy, same names as redis.
+const (
+	PolicyNoEviction     = "noeviction"
+	PolicyAllKeysLRU     = "allkeys-lru"
+	PolicyAllKeysLFU     = "allkeys-lfu"
+	PolicyAllKeysRandom  = "allkeys-random"
+	PolicyVolatileLRU    = "volatile-lru"
+	PolicyVolatileLFU    = "volatile-lfu"
+	PolicyVolatileRandom = "volatile-random"
+	PolicyVolatileTTL    = "volatile-ttl"
+)
+
+// Policies lists the valid values of maxmemory-policy.
+var Policies = []string{
+	PolicyVolatileLRU, PolicyVolatileLFU, PolicyVolatileRandom, PolicyVolatileTTL,
+	PolicyAllKeysLRU, PolicyAllKeysLFU, PolicyAllKeysRandom, PolicyNoEviction,
+}
+
+const (
+	DefaultMaxmemorySamples = 5
+	evictionPoolSize        = 16
+)
+
+// evictionCandidate is a key of the eviction pool, idle is the score of the policy, the higher the better to evict.
+type evictionCandidate struct {
+	db   int
+	key  string
+	idle uint64
+}
+
+// Evictor selects the keys to evict when the memory limit is reached.
+// Like redis, the approximated LRU/LFU/TTL policies sample a few keys of every db and keep the best candidates
+// in a pool across calls, so that the quality of the selection gets closer to the exact algorithm.
+// ref: https://github.com/redis/redis/blob/7.2.0/src/evict.c#L148
+type Evictor struct {
+	mu     sync.Mutex
+	pool   []evictionCandidate // sorted by ascending idle
+	nextDB int                 // db to evict from for the random policies, round-robin
+}
+
+func NewEvictor() *Evictor {
+	return &Evictor{pool: make([]evictionCandidate, 0, evictionPoolSize)}
+}
+
+func volatilePolicy(policy string) bool {
+	switch policy {
+	case PolicyVolatileLRU, PolicyVolatileLFU, PolicyVolatileRandom, PolicyVolatileTTL:
+		return true
+	}
+	return false
+}
+
+// LFUPolicy tells if the policy evicts the least frequently used keys, the access frequency of the keys is only
+// meaningful then and their idle time is not.
+func LFUPolicy(policy string) bool {
+	return policy == PolicyAllKeysLFU || policy == PolicyVolatileLFU
+}
+
+// Evict deletes one key according to the policy, it returns the index of its db and the key.
+// false is returned if there is nothing to evict, e.g. under noeviction or when no key has an expire for the volatile policies.
+func (e *Evictor) Evict(dbs []*DB, policy string, samples int) (int, string, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	volatile := volatilePolicy(policy)
+	switch policy {
+	case PolicyNoEviction:
+		return 0, "", false
+	case PolicyAllKeysRandom, PolicyVolatileRandom:
+		for range dbs {
+			idx := e.nextDB % len(dbs)
+			e.nextDB++
+			if key, ok := dbs[idx].evictRandom(volatile); ok {
+				return idx, key, true
+			}
+		}
+		return 0, "", false
+	}
+	if samples <= 0 {
+		samples = DefaultMaxmemorySamples
+	}
+	for {
+		populated := false
+		for idx, db := range dbs {
+			db.sampleCandidates(policy, samples, func(key string, idle uint64) {
+				populated = true
+				e.insert(evictionCandidate{db: idx, key: key, idle: idle})
+			})
+		}
+		if !populated && len(e.pool) == 0 {
+			return 0, "", false
+		}
+		// try the best candidates first, a key may have been deleted or modified since it was sampled
+		for len(e.pool) > 0 {
+			c := e.pool[len(e.pool)-1]
+			e.pool = e.pool[:len(e.pool)-1]
+			if dbs[c.db].evictKey(c.key, volatile) {
+				return c.db, c.key, true
+			}
+		}
+		if !populated {
+			return 0, "", false
+		}
+	}
+}
+
+// insert adds the candidate to the pool if it is better than the worst one, or if the pool is not full.
+func (e *Evictor) insert(c evictionCandidate) {
+	k := 0
+	for k < len(e.pool) && e.pool[k].idle < c.idle {
+		k++
+	}
+	for _, p := range e.pool {
+		if p.db == c.db && p.key == c.key {
+			return
+		}
+	}
+	if len(e.pool) == evictionPoolSize {
+		if k == 0 {
+			return
+		}
+		// drop the candidate with the smallest idle to make room
+		copy(e.pool, e.pool[1:k])
+		e.pool[k-1] = c
+		return
+	}
+	e.pool = append(e.pool, evictionCandidate{})
+	copy(e.pool[k+1:], e.pool[k:])
+	e.pool[k] = c
+}
+
+// sampleCandidates calls fn with up to samples keys and their idle score for the policy.
+func (d *DB) sampleCandidates(policy string, samples int, fn func(key string, idle uint64)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := time.Now()
+	score := func(key string) {
+		data, ok := d.datas.get(key)
+		if !ok {
+			return
+		}
+		switch policy {
+		case PolicyAllKeysLRU, PolicyVolatileLRU:
+			fn(key, uint64(max(now.UnixMilli()-data.lastAccessMS, 0)))
+		case PolicyAllKeysLFU, PolicyVolatileLFU:
+			fn(key, 255-uint64(data.lfuDecr(now)))
+		case PolicyVolatileTTL:
+			// the sooner the expiration, the better
+			fn(key, math.MaxUint64-data.ExpireTimestampMS)
+		}
+	}
+	if volatilePolicy(policy) {
+		d.expires.sampleKeys(samples, score)
+		return
+	}
+	d.datas.sampleKeys(samples, score)
+}
+
+// evictKey deletes key if it still exists, and still has an expire for the volatile policies.
+func (d *DB) evictKey(key string, volatile bool) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if volatile {
+		if _, ok := d.expires.get(key); !ok {
+			return false
+		}
+	}
+	data, ok := d.deleteKey(key)
+	if ok {
+		data.release()
+	}
+	return ok
+}
+
+// evictRandom deletes a random key, with an expire if volatile.
+func (d *DB) evictRandom(volatile bool) (string, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	keys := d.datas.randomKey
+	if volatile {
+		keys = d.expires.randomKey
+	}
+	key, ok := keys()
+	if !ok {
+		return "", false
+	}
+	data, _ := d.deleteKey(key)
+	data.release()
+	return key, true
+}
+
+// sampleKeys calls fn with up to n keys of consecutive buckets starting from a random one, like dictGetSomeKeys in redis.
+// While rehashing, the same index is sampled in both tables.
+func (d *dict[V]) sampleKeys(n int, fn func(key string)) {
+	if d.size == 0 {
+		return
+	}
+	size := max(len(d.tables[0]), len(d.tables[1]))
+	idx := rand.Intn(size)
+	for visited := 0; visited < size && n > 0; visited++ {
+		for _, t := range d.liveTables() {
+			if idx >= len(t) {
+				continue
+			}
+			for e := t[idx]; e != nil && n > 0; e = e.next {
+				fn(e.key)
+				n--
+			}
+		}
+		idx = (idx + 1) & (size - 1)
+	}
+}
@@ -0,0 +1,72 @@
+package database
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvictLRU(t *testing.T) {
+	db := NewDB()
+	for i := 0; i < 10; i++ {
+		db.Set(fmt.Sprintf("k%d", i), "v")
+	}
+	data, _ := db.datas.get("k3")
+	data.lastAccessMS -= 10000
+	e := NewEvictor()
+	idx, key, ok := e.Evict([]*DB{db}, PolicyAllKeysLRU, 20)
+	require.True(t, ok)
+	require.Equal(t, 0, idx)
+	require.Equal(t, "k3", key)
+	require.Equal(t, 9, db.Size())
+}
+
+func TestEvictLFU(t *testing.T) {
+	db := NewDB()
+	for i := 0; i < 10; i++ {
+		db.Set(fmt.Sprintf("k%d", i), "v")
+	}
+	data, _ := db.datas.get("k7")
+	data.lfuCounter = 0
+	_, key, ok := NewEvictor().Evict([]*DB{db}, PolicyAllKeysLFU, 20)
+	require.True(t, ok)
+	require.Equal(t, "k7", key)
+}
+
+func TestEvictVolatile(t *testing.T) {
+	db := NewDB()
+	db.Set("persistent", "v")
+	e := NewEvictor()
+	for _, policy := range []string{PolicyVolatileLRU, PolicyVolatileTTL, PolicyVolatileRandom} {
+		_, _, ok := e.Evict([]*DB{db}, policy, 5)
+		require.False(t, ok, policy)
+	}
+	now := time.Now()
+	db.SetExp("late", "v", now.Add(time.Hour).UnixMilli())
+	db.SetExp("soon", "v", now.Add(time.Minute).UnixMilli())
+	_, key, ok := e.Evict([]*DB{db}, PolicyVolatileTTL, 5)
+	require.True(t, ok)
+	require.Equal(t, "soon", key)
+	_, key, ok = e.Evict([]*DB{db}, PolicyVolatileRandom, 5)
+	require.True(t, ok)
+	require.Equal(t, "late", key)
+	require.Equal(t, []string{"persistent"}, db.Keys("*"))
+
+	_, _, ok = e.Evict([]*DB{db}, PolicyNoEviction, 5)
+	require.False(t, ok)
+}
+
+func TestEvictionPool(t *testing.T) {
+	e := NewEvictor()
+	for i := 0; i < evictionPoolSize*2; i++ {
+		e.insert(evictionCandidate{key: fmt.Sprint(i), idle: uint64(i)})
+	}
+	require.Len(t, e.pool, evictionPoolSize)
+	require.Equal(t, uint64(evictionPoolSize), e.pool[0].idle)
+	require.Equal(t, uint64(evictionPoolSize*2-1), e.pool[evictionPoolSize-1].idle)
+	// worse than every candidate of a full pool
+	e.insert(evictionCandidate{key: "x", idle: 0})
+	require.Equal(t, uint64(evictionPoolSize), e.pool[0].idle)
+}
@@ -0,0 +1,506 @@
+package database
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"strings"
+)
+
+// Geo indexes are sorted sets whose scores are the 52 bits geohash of the coordinates of the members,
+// encoded like redis so that the scores are interchangeable.
+// ref: https://github.com/redis/redis/blob/7.2.0/src/geohash.c
+const (
+	GeoLonMin = -180.0
+	GeoLonMax = 180.0
+	GeoLatMin = -85.05112878
+	GeoLatMax = 85.05112878
+
+	geoStep         = 26 // bits per coordinate
+	earthRadius     = 6372797.560856
+	geoHashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+)
+
+var ErrGeoMemberNotFound = errors.New("could not decode requested zset member")
+
+// GeoPoint is a longitude, latitude pair in degrees.
+type GeoPoint struct {
+	Lon float64
+	Lat float64
+}
+
+// Valid reports whether the point can be indexed, the latitudes are limited by the Web Mercator projection.
+func (p GeoPoint) Valid() bool {
+	return p.Lon >= GeoLonMin && p.Lon <= GeoLonMax && p.Lat >= GeoLatMin && p.Lat <= GeoLatMax
+}
+
+// GeoMember is a member of a geo index.
+type GeoMember struct {
+	Name string
+	GeoPoint
+}
+
+// GeoAddOptions are the options of GEOADD.
+type GeoAddOptions struct {
+	NX bool // only add new members
+	XX bool // only update existing members
+	CH bool // count the updated members as well as the added ones
+}
+
+// GeoQuery is the search area and the options of GEOSEARCH.
+type GeoQuery struct {
+	Member string   // member at the center of the area, empty to use Center
+	Center GeoPoint // center of the area if Member is empty
+	ByBox  bool
+	Radius float64 // radius in meters of the area when searching by radius
+	Width  float64 // width in meters of the area when searching by box
+	Height float64 // height in meters of the area when searching by box
+	Count  int     // maximum number of results, 0 for all
+	Any    bool    // return the first Count members found instead of the nearest ones
+	Asc    bool    // sort by ascending distance
+	Desc   bool    // sort by descending distance
+}
+
+// GeoResult is a member found by GEOSEARCH.
+type GeoResult struct {
+	Name string
+	Dist float64 // distance from the center in meters
+	Hash uint64
+	GeoPoint
+}
+
+// interleave64 interleaves the bits of x in the even positions and the bits of y in the odd positions.
+func interleave64(x, y uint32) uint64 {
+	spread := func(v uint32) uint64 {
+		r := uint64(v)
+		r = (r | r<<16) & 0x0000FFFF0000FFFF
+		r = (r | r<<8) & 0x00FF00FF00FF00FF
+		r = (r | r<<4) & 0x0F0F0F0F0F0F0F0F
+		r = (r | r<<2) & 0x3333333333333333
+		r = (r | r<<1) & 0x5555555555555555
+		return r
+	}
+	return spread(x) | spread(y)<<1
+}
+
+// deinterleave64 is the inverse of interleave64.
+func deinterleave64(v uint64) (uint32, uint32) {
+	squash := func(r uint64) uint32 {
+		r &= 0x5555555555555555
+		r = (r | r>>1) & 0x3333333333333333
+		r = (r | r>>2) & 0x0F0F0F0F0F0F0F0F
+		r = (r | r>>4) & 0x00FF00FF00FF00FF
+		r = (r | r>>8) & 0x0000FFFF0000FFFF
+		r = (r | r>>16) & 0x00000000FFFFFFFF
+		return uint32(r)
+	}
+	return squash(v), squash(v >> 1)
+}
+
+// geohashEncode encodes the point in the given latitude range, the latitudes are in the even bits.
+func geohashEncode(p GeoPoint, latMin, latMax float64) uint64 {
+	latOffset := (p.Lat - latMin) / (latMax - latMin) * (1 << geoStep)
+	lonOffset := (p.Lon - GeoLonMin) / (GeoLonMax - GeoLonMin) * (1 << geoStep)
+	return interleave64(uint32(latOffset), uint32(lonOffset))
+}
+
+// GeoEncode returns the 52 bits geohash of the point used as the score of the member.
+func GeoEncode(p GeoPoint) uint64 {
+	return geohashEncode(p, GeoLatMin, GeoLatMax)
+}
+
+// GeoDecode returns the center of the area of the geohash.
+func GeoDecode(hash uint64) GeoPoint {
+	ilat, ilon := deinterleave64(hash)
+	latScale := GeoLatMax - GeoLatMin
+	lonScale := GeoLonMax - GeoLonMin
+	latMin := GeoLatMin + float64(ilat)/(1<<geoStep)*latScale
+	latMax := GeoLatMin + float64(ilat+1)/(1<<geoStep)*latScale
+	lonMin := GeoLonMin + float64(ilon)/(1<<geoStep)*lonScale
+	lonMax := GeoLonMin + float64(ilon+1)/(1<<geoStep)*lonScale
+	return GeoPoint{
+		Lon: min(max((lonMin+lonMax)/2, GeoLonMin), GeoLonMax),
+		Lat: min(max((latMin+latMax)/2, GeoLatMin), GeoLatMax),
+	}
+}
+
+func degRad(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+// geoLatDistance returns the distance in meters between two latitudes on the same meridian.
+func geoLatDistance(lat1, lat2 float64) float64 {
+	return earthRadius * math.Abs(degRad(lat2)-degRad(lat1))
+}
+
+// GeoDistance returns the haversine distance in meters between two points.
+func GeoDistance(p1, p2 GeoPoint) float64 {
+	lat1r, lon1r := degRad(p1.Lat), degRad(p1.Lon)
+	lat2r, lon2r := degRad(p2.Lat), degRad(p2.Lon)
+	v := math.Sin((lon2r - lon1r) / 2)
+	// same longitude, the distance is the latitude distance
+	if v == 0 {
+		return geoLatDistance(p1.Lat, p2.Lat)
+	}
+	u := math.Sin((lat2r - lat1r) / 2)
+	a := u*u + math.Cos(lat1r)*math.Cos(lat2r)*v*v
+	return 2 * earthRadius * math.Asin(math.Sqrt(a))
+}
+
+// geoHashString returns the standard 11 characters geohash of the point, which uses the full latitude range.
+func geoHashString(p GeoPoint) string {
+	hash := geohashEncode(p, -90, 90)
+	var b strings.Builder
+	for i := 0; i < 11; i++ {
+		idx := 0
+		// the 52 bits hash gives 10 characters, the last one is always 0
+		if i < 10 {
+			idx = int(hash >> (52 - (i+1)*5) & 0x1f)
+		}
+		b.WriteByte(geoHashAlphabet[idx])
+	}
+	return b.String()
+}
+
+// lookupZSet returns the sorted set stored at key, ok is false if the key does not exist.
+// caller should hold the write lock.
+func (d *DB) lookupZSet(key string) (*Data, bool, error) {
+	data, ok := d.lookup(key)
+	if !ok {
+		return nil, false, nil
+	}
+	if data.Type != TypeZSet {
+		return nil, false, ErrWrongType
+	}
+	return data, true, nil
+}
+
+// GeoAdd adds the members to the geo index stored at key or updates their coordinates.
+// It returns the number of members added, and updated if opts.CH is set.
+func (d *DB) GeoAdd(key string, members []GeoMember, opts GeoAddOptions) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	data, ok, err := d.lookupZSet(key)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		if opts.XX {
+			return 0, nil
+		}
+		data = newData(TypeZSet)
+		d.setKey(key, data)
+	}
+	changed := 0
+	d.updateKey(data, func() {
+		for _, m := range members {
+			score := float64(GeoEncode(m.GeoPoint))
+			old, exists := data.zset.get(m.Name)
+			if exists && opts.NX || !exists && opts.XX {
+				continue
+			}
+			if data.zsetSet(m.Name, score) {
+				data.zsetAdded(m.Name)
+				changed++
+			} else if old != score && opts.CH {
+				changed++
+			}
+		}
+	})
+	return changed, nil
+}
+
+// geoPos returns the coordinates of the member of the geo index.
+func geoPos(data *Data, member string) (GeoPoint, bool) {
+	score, ok := data.zset.get(member)
+	if !ok {
+		return GeoPoint{}, false
+	}
+	return GeoDecode(uint64(score)), true
+}
+
+// GeoPos returns the coordinates of the members of the geo index stored at key, nil for the missing members.
+func (d *DB) GeoPos(key string, members []string) ([]*GeoPoint, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	data, ok, err := d.lookupZSet(key)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*GeoPoint, len(members))
+	if !ok {
+		return res, nil
+	}
+	for i, m := range members {
+		if p, ok := geoPos(data, m); ok {
+			res[i] = &p
+		}
+	}
+	return res, nil
+}
+
+// GeoHash returns the standard geohash strings of the members of the geo index stored at key, nil for the missing members.
+func (d *DB) GeoHash(key string, members []string) ([]*string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	data, ok, err := d.lookupZSet(key)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*string, len(members))
+	if !ok {
+		return res, nil
+	}
+	for i, m := range members {
+		if p, ok := geoPos(data, m); ok {
+			h := geoHashString(p)
+			res[i] = &h
+		}
+	}
+	return res, nil
+}
+
+// GeoDist returns the distance in meters between two members of the geo index stored at key,
+// ok is false if one of them does not exist.
+func (d *DB) GeoDist(key, member1, member2 string) (float64, bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	data, ok, err := d.lookupZSet(key)
+	if err != nil || !ok {
+		return 0, false, err
+	}
+	p1, ok1 := geoPos(data, member1)
+	p2, ok2 := geoPos(data, member2)
+	if !ok1 || !ok2 {
+		return 0, false, nil
+	}
+	return GeoDistance(p1, p2), true, nil
+}
+
+// GeoSearch returns the members of the geo index stored at key within the area of the query.
+func (d *DB) GeoSearch(key string, q GeoQuery) ([]GeoResult, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.geoSearch(key, q)
+}
+
+// GeoSearchStore is like GeoSearch but stores the members found as a sorted set at dst, it returns its length.
+// The scores are the geohashes of the members, or their distance from the center if storeDist is set.
+// dst is deleted if nothing is found.
+func (d *DB) GeoSearchStore(dst, key string, q GeoQuery, storeDist bool, unit float64) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	res, err := d.geoSearch(key, q)
+	if err != nil {
+		return 0, err
+	}
+	if len(res) == 0 {
+		d.deleteKey(dst)
+		return 0, nil
+	}
+	data := newData(TypeZSet)
+	for _, r := range res {
+		score := float64(r.Hash)
+		if storeDist {
+			score = r.Dist / unit
+		}
+		data.zsetSet(r.Name, score)
+		data.zsetAdded(r.Name)
+	}
+	d.setKey(dst, data)
+	return len(res), nil
+}
+
+// geoSearch is called with the write lock held.
+// Only the score ranges of the geohash areas covering the search area are scanned, see geoSearchRanges.
+// ref: https://github.com/redis/redis/blob/7.2.0/src/geo.c#L640
+func (d *DB) geoSearch(key string, q GeoQuery) ([]GeoResult, error) {
+	data, ok, err := d.lookupZSet(key)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		if q.Member != "" {
+			return nil, ErrGeoMemberNotFound
+		}
+		return []GeoResult{}, nil
+	}
+	center := q.Center
+	if q.Member != "" {
+		if center, ok = geoPos(data, q.Member); !ok {
+			return nil, ErrGeoMemberNotFound
+		}
+	}
+	res := []GeoResult{}
+	for _, r := range geoSearchRanges(center, q) {
+		data.zsl.rangeByScore(r[0], r[1], func(member string, score float64) bool {
+			hash := uint64(score)
+			p := GeoDecode(hash)
+			if dist, ok := geoWithin(center, p, q); ok {
+				res = append(res, GeoResult{Name: member, Dist: dist, Hash: hash, GeoPoint: p})
+			}
+			return !q.Any || len(res) < q.Count
+		})
+		if q.Any && len(res) == q.Count {
+			break
+		}
+	}
+	// COUNT without ANY returns the nearest members
+	asc, desc := q.Asc, q.Desc
+	if q.Count > 0 && !q.Any && !desc {
+		asc = true
+	}
+	if asc || desc {
+		slices.SortStableFunc(res, func(a, b GeoResult) int {
+			cmp := 0
+			switch {
+			case a.Dist < b.Dist:
+				cmp = -1
+			case a.Dist > b.Dist:
+				cmp = 1
+			}
+			if desc {
+				return -cmp
+			}
+			return cmp
+		})
+	}
+	if q.Count > 0 && len(res) > q.Count {
+		res = res[:q.Count]
+	}
+	return res, nil
+}
+
+// geoArea is the bounds of a geohash of step bits per coordinate.
+type geoArea struct {
+	lonMin, lonMax float64
+	latMin, latMax float64
+}
+
+// geohashEncodeStep returns the coordinates of the geohash of step bits per coordinate containing the point.
+func geohashEncodeStep(p GeoPoint, step uint) (ilat, ilon uint32) {
+	latOffset := (p.Lat - GeoLatMin) / (GeoLatMax - GeoLatMin) * float64(uint64(1)<<step)
+	lonOffset := (p.Lon - GeoLonMin) / (GeoLonMax - GeoLonMin) * float64(uint64(1)<<step)
+	mask := uint32(1<<step - 1)
+	return min(uint32(latOffset), mask), min(uint32(lonOffset), mask)
+}
+
+// geohashDecodeStep returns the bounds of the geohash of step bits per coordinate.
+func geohashDecodeStep(ilat, ilon uint32, step uint) geoArea {
+	cells := float64(uint64(1) << step)
+	latScale := GeoLatMax - GeoLatMin
+	lonScale := GeoLonMax - GeoLonMin
+	return geoArea{
+		lonMin: GeoLonMin + float64(ilon)/cells*lonScale,
+		lonMax: GeoLonMin + float64(ilon+1)/cells*lonScale,
+		latMin: GeoLatMin + float64(ilat)/cells*latScale,
+		latMax: GeoLatMin + float64(ilat+1)/cells*latScale,
+	}
+}
+
+// geohashEstimateSteps returns the precision of the geohash whose areas are about the size of the radius,
+// it is lowered near the poles where the areas get narrower.
+// ref: https://github.com/redis/redis/blob/7.2.0/src/geohash_helper.c#L59
+func geohashEstimateSteps(radius, lat float64) uint {
+	if radius == 0 {
+		return geoStep
+	}
+	const mercatorMax = 20037726.37
+	step := 1
+	for radius < mercatorMax {
+		radius *= 2
+		step++
+	}
+	step -= 2
+	if lat > 66 || lat < -66 {
+		step--
+		if lat > 80 || lat < -80 {
+			step--
+		}
+	}
+	return uint(min(max(step, 1), geoStep))
+}
+
+// geoBoundingBox returns the bounds of the box containing the search area.
+// ref: https://github.com/redis/redis/blob/7.2.0/src/geohash_helper.c#L85
+func geoBoundingBox(center GeoPoint, q GeoQuery) geoArea {
+	width, height := q.Radius, q.Radius
+	if q.ByBox {
+		width, height = q.Width/2, q.Height/2
+	}
+	latDelta := height / earthRadius * 180 / math.Pi
+	lonDeltaTop := width / earthRadius / math.Cos(degRad(center.Lat+latDelta)) * 180 / math.Pi
+	lonDeltaBottom := width / earthRadius / math.Cos(degRad(center.Lat-latDelta)) * 180 / math.Pi
+	lonDelta := lonDeltaTop
+	if center.Lat < 0 {
+		lonDelta = lonDeltaBottom
+	}
+	return geoArea{
+		lonMin: center.Lon - lonDelta,
+		lonMax: center.Lon + lonDelta,
+		latMin: center.Lat - latDelta,
+		latMax: center.Lat + latDelta,
+	}
+}
+
+// geoSearchRanges returns the score ranges [min, max) of the geohash containing the center and of its 8 neighbours,
+// with a precision such that they cover the search area. The neighbours which do not overlap the area are skipped.
+// ref: https://github.com/redis/redis/blob/7.2.0/src/geohash_helper.c#L113
+func geoSearchRanges(center GeoPoint, q GeoQuery) [][2]float64 {
+	radius := q.Radius
+	if q.ByBox {
+		radius = math.Sqrt(q.Width/2*q.Width/2 + q.Height/2*q.Height/2)
+	}
+	bounds := geoBoundingBox(center, q)
+	step := geohashEstimateSteps(radius, center.Lat)
+	ilat, ilon := geohashEncodeStep(center, step)
+	area := geohashDecodeStep(ilat, ilon, step)
+	// the neighbours may still not reach the bounds, use larger areas then
+	north := geohashDecodeStep(ilat+1, ilon, step)
+	south := geohashDecodeStep(ilat-1, ilon, step)
+	east := geohashDecodeStep(ilat, ilon+1, step)
+	west := geohashDecodeStep(ilat, ilon-1, step)
+	if step > 1 && (north.latMax < bounds.latMax || south.latMin > bounds.latMin ||
+		east.lonMax < bounds.lonMax || west.lonMin > bounds.lonMin) {
+		step--
+		ilat, ilon = geohashEncodeStep(center, step)
+		area = geohashDecodeStep(ilat, ilon, step)
+	}
+	// in the order of redis: center, north, south, east, west, north east, north west, south east, south west
+	dlats := []int{0, 1, -1, 0, 0, 1, 1, -1, -1}
+	dlons := []int{0, 0, 0, 1, -1, 1, -1, 1, -1}
+	mask := uint32(1<<step - 1)
+	seen := make(map[uint64]bool, len(dlats))
+	ranges := make([][2]float64, 0, len(dlats))
+	for i := range dlats {
+		if step >= 2 && (dlats[i] < 0 && area.latMin < bounds.latMin || dlats[i] > 0 && area.latMax > bounds.latMax ||
+			dlons[i] < 0 && area.lonMin < bounds.lonMin || dlons[i] > 0 && area.lonMax > bounds.lonMax) {
+			continue
+		}
+		// the neighbours wrap around like in redis, the members found there are filtered by distance anyway
+		hash := interleave64((ilat+uint32(dlats[i]))&mask, (ilon+uint32(dlons[i]))&mask)
+		if seen[hash] {
+			continue
+		}
+		seen[hash] = true
+		shift := 2 * (geoStep - step)
+		ranges = append(ranges, [2]float64{float64(hash << shift), float64((hash + 1) << shift)})
+	}
+	return ranges
+}
+
+// geoWithin returns the distance in meters between the center and the point if the point is within the area of the query.
+func geoWithin(center, p GeoPoint, q GeoQuery) (float64, bool) {
+	if !q.ByBox {
+		dist := GeoDistance(center, p)
+		return dist, dist <= q.Radius
+	}
+	// the latitude distance is cheaper to compute, check it first
+	if geoLatDistance(p.Lat, center.Lat) > q.Height/2 {
+		return 0, false
+	}
+	if GeoDistance(GeoPoint{Lon: p.Lon, Lat: p.Lat}, GeoPoint{Lon: center.Lon, Lat: p.Lat}) > q.Width/2 {
+		return 0, false
+	}
+	return GeoDistance(center, p), true
+}
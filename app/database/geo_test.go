@@ -0,0 +1,160 @@
+package database
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	palermo = GeoMember{Name: "Palermo", GeoPoint: GeoPoint{Lon: 13.361389, Lat: 38.115556}}
+	catania = GeoMember{Name: "Catania", GeoPoint: GeoPoint{Lon: 15.087269, Lat: 37.502669}}
+)
+
+func TestGeoEncode(t *testing.T) {
+	// same score as redis
+	require.EqualValues(t, 3479099956230698, GeoEncode(palermo.GeoPoint))
+	p := GeoDecode(3479099956230698)
+	require.InDelta(t, 13.36138933897018433, p.Lon, 1e-12)
+	require.InDelta(t, 38.11555639549629859, p.Lat, 1e-12)
+	require.Equal(t, "sqc8b49rny0", geoHashString(p))
+	// distances are computed between the decoded coordinates, like redis
+	require.InDelta(t, 166274.1516, GeoDistance(p, GeoDecode(GeoEncode(catania.GeoPoint))), 1e-4)
+}
+
+func TestGeoAdd(t *testing.T) {
+	db := NewDB()
+	n, err := db.GeoAdd("Sicily", []GeoMember{palermo, catania}, GeoAddOptions{})
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	moved := GeoMember{Name: "Palermo", GeoPoint: GeoPoint{Lon: 13, Lat: 38}}
+	n, err = db.GeoAdd("Sicily", []GeoMember{moved}, GeoAddOptions{NX: true, CH: true})
+	require.NoError(t, err)
+	require.Equal(t, 0, n)
+	n, err = db.GeoAdd("Sicily", []GeoMember{moved}, GeoAddOptions{XX: true, CH: true})
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	n, err = db.GeoAdd("other", []GeoMember{moved}, GeoAddOptions{XX: true})
+	require.NoError(t, err)
+	require.Equal(t, 0, n)
+	require.Equal(t, "", db.Type("other"))
+
+	pos, err := db.GeoPos("Sicily", []string{"Palermo", "missing"})
+	require.NoError(t, err)
+	require.InDelta(t, 13, pos[0].Lon, 1e-5)
+	require.Nil(t, pos[1])
+	_, ok, err := db.GeoDist("Sicily", "Palermo", "missing")
+	require.NoError(t, err)
+	require.False(t, ok)
+}
+
+func TestGeoSearch(t *testing.T) {
+	db := NewDB()
+	_, err := db.GeoAdd("Sicily", []GeoMember{palermo, catania,
+		{Name: "edge1", GeoPoint: GeoPoint{Lon: 12.758489, Lat: 38.788135}},
+		{Name: "edge2", GeoPoint: GeoPoint{Lon: 17.241510, Lat: 38.788135}},
+	}, GeoAddOptions{})
+	require.NoError(t, err)
+
+	names := func(res []GeoResult) []string {
+		n := make([]string, len(res))
+		for i, r := range res {
+			n[i] = r.Name
+		}
+		return n
+	}
+	center := GeoPoint{Lon: 15, Lat: 37}
+	res, err := db.GeoSearch("Sicily", GeoQuery{Center: center, Radius: 200000, Asc: true})
+	require.NoError(t, err)
+	require.Equal(t, []string{"Catania", "Palermo"}, names(res))
+	require.InDelta(t, 56.4413, res[0].Dist/1000, 1e-4)
+
+	res, err = db.GeoSearch("Sicily", GeoQuery{Center: center, ByBox: true, Width: 400000, Height: 400000, Asc: true})
+	require.NoError(t, err)
+	require.Equal(t, []string{"Catania", "Palermo", "edge2", "edge1"}, names(res))
+
+	res, err = db.GeoSearch("Sicily", GeoQuery{Member: "Palermo", Radius: 200000, Desc: true, Count: 1})
+	require.NoError(t, err)
+	require.Equal(t, []string{"Catania"}, names(res))
+	res, err = db.GeoSearch("Sicily", GeoQuery{Member: "Palermo", Radius: 200000, Count: 1})
+	require.NoError(t, err)
+	require.Equal(t, []string{"Palermo"}, names(res))
+
+	_, err = db.GeoSearch("Sicily", GeoQuery{Member: "missing", Radius: 1})
+	require.ErrorIs(t, err, ErrGeoMemberNotFound)
+
+	n, err := db.GeoSearchStore("dst", "Sicily", GeoQuery{Center: center, Radius: 200000}, true, 1000)
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	_, members, err := db.ZScan("dst", 0, ScanOptions{Count: 10})
+	require.NoError(t, err)
+	require.Len(t, members, 2)
+	for _, m := range members {
+		if m.Member == "Catania" {
+			require.InDelta(t, 56.4413, m.Score, 1e-4)
+		}
+	}
+	n, err = db.GeoSearchStore("dst", "Sicily", GeoQuery{Center: center, Radius: 1}, false, 1)
+	require.NoError(t, err)
+	require.Equal(t, 0, n)
+	require.Equal(t, "", db.Type("dst"))
+}
+
+func TestGeoSearchRanges(t *testing.T) {
+	db := NewDB()
+	rnd := rand.New(rand.NewSource(1))
+	var members []GeoMember
+	for i := 0; i < 2000; i++ {
+		members = append(members, GeoMember{Name: fmt.Sprint(i), GeoPoint: GeoPoint{
+			Lon: 10 + rnd.Float64()*10,
+			Lat: 35 + rnd.Float64()*10,
+		}})
+	}
+	_, err := db.GeoAdd("points", members, GeoAddOptions{})
+	require.NoError(t, err)
+
+	center := GeoPoint{Lon: 15, Lat: 40}
+	for _, q := range []GeoQuery{
+		{Center: center, Radius: 1000},
+		{Center: center, Radius: 10000},
+		{Center: center, Radius: 50000},
+		{Center: center, Radius: 300000},
+		{Center: center, ByBox: true, Width: 100000, Height: 20000},
+		{Center: center, ByBox: true, Width: 2000000, Height: 2000000},
+	} {
+		// the scanned ranges only hold the members of the geohash areas around the center
+		scanned := 0
+		for _, r := range geoSearchRanges(center, q) {
+			data, _ := db.peek("points")
+			data.zsl.rangeByScore(r[0], r[1], func(string, float64) bool {
+				scanned++
+				return true
+			})
+		}
+		if q.Radius > 0 && q.Radius <= 10000 {
+			require.Less(t, scanned, len(members)/4, q)
+		}
+
+		res, err := db.GeoSearch("points", q)
+		require.NoError(t, err)
+		got := make([]string, len(res))
+		for i, r := range res {
+			got[i] = r.Name
+		}
+		want := []string{}
+		for _, m := range members {
+			if _, ok := geoWithin(center, GeoDecode(GeoEncode(m.GeoPoint)), q); ok {
+				want = append(want, m.Name)
+			}
+		}
+		if q.Radius >= 50000 || q.ByBox {
+			require.NotEmpty(t, want, q)
+		}
+		slices.Sort(got)
+		slices.Sort(want)
+		require.Equal(t, want, got, q)
+	}
+}
@@ -0,0 +1,644 @@
+package database
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// HyperLogLogs are strings with the same layout as redis, so that they can be exchanged with redis through RDB files
+// or DUMP/RESTORE: a 16 bytes header followed by the registers in the sparse or dense representation.
+// ref: https://github.com/redis/redis/blob/7.2.0/src/hyperloglog.c
+const (
+	hllP              = 14 // number of bits of the hash used to select the register
+	hllQ              = 64 - hllP
+	hllRegisters      = 1 << hllP
+	hllPMask          = hllRegisters - 1
+	hllBits           = 6 // bits of a dense register
+	hllRegisterMax    = 1<<hllBits - 1
+	hllHdrSize        = 16
+	hllDenseSize      = hllHdrSize + (hllRegisters*hllBits+7)/8
+	hllEncodingDense  = 0
+	hllEncodingSparse = 1
+	hllMaxEncoding    = 1
+	hllAlphaInf       = 0.721347520444481703680 // 0.5/ln(2)
+
+	hllSparseXZeroBit    = 0x40
+	hllSparseValBit      = 0x80
+	hllSparseValMaxValue = 32
+	hllSparseValMaxLen   = 4
+	hllSparseZeroMaxLen  = 64
+	hllSparseXZeroMaxLen = 16384
+	hllSparseMaxBytes    = 3000 // hll-sparse-max-bytes
+	hllCardInvalidBit    = 1 << 7
+	hllMurmurSeed        = 0xadc83b19
+)
+
+// hll is the string of a HyperLogLog, it is modified in place and grown by the sparse representation.
+type hll []byte
+
+// newHLL returns an empty HyperLogLog in the sparse representation, with a valid cached cardinality of 0.
+func newHLL() hll {
+	h := make(hll, hllHdrSize, hllHdrSize+2)
+	copy(h, "HYLL")
+	h[4] = hllEncodingSparse
+	for aux := hllRegisters; aux > 0; aux -= hllSparseXZeroMaxLen {
+		h = append(h, xzeroOp(min(aux, hllSparseXZeroMaxLen))...)
+	}
+	return h
+}
+
+// isHLL reports whether s has a valid HyperLogLog header.
+func isHLL(s string) bool {
+	if len(s) < hllHdrSize || s[:4] != "HYLL" || s[4] > hllMaxEncoding {
+		return false
+	}
+	return s[4] != hllEncodingDense || len(s) == hllDenseSize
+}
+
+func (h hll) dense() bool {
+	return h[4] == hllEncodingDense
+}
+
+func (h hll) invalidateCache() {
+	h[15] |= hllCardInvalidBit
+}
+
+func (h hll) cachedCard() (uint64, bool) {
+	if h[15]&hllCardInvalidBit != 0 {
+		return 0, false
+	}
+	return binary.LittleEndian.Uint64(h[8:16]), true
+}
+
+func (h hll) setCachedCard(card uint64) {
+	binary.LittleEndian.PutUint64(h[8:16], card)
+}
+
+// murmurHash64A is the hash function of the HyperLogLog registers.
+func murmurHash64A(key string, seed uint64) uint64 {
+	const m = 0xc6a4a7935bd1e995
+	const r = 47
+	h := seed ^ (uint64(len(key)) * m)
+	n := len(key) - len(key)&7
+	for i := 0; i < n; i += 8 {
+		k := binary.LittleEndian.Uint64([]byte(key[i : i+8]))
+		k *= m
+		k ^= k >> r
+		k *= m
+		h ^= k
+		h *= m
+	}
+	if rest := key[n:]; len(rest) > 0 {
+		for i := len(rest) - 1; i >= 0; i-- {
+			h ^= uint64(rest[i]) << (8 * i)
+		}
+		h *= m
+	}
+	h ^= h >> r
+	h *= m
+	h ^= h >> r
+	return h
+}
+
+// hllPatLen returns the register of the element and the length of the run of zeros of its hash plus one.
+func hllPatLen(elem string) (int, uint8) {
+	hash := murmurHash64A(elem, hllMurmurSeed)
+	index := int(hash & hllPMask)
+	hash >>= hllP
+	hash |= 1 << hllQ // make sure the count is <= Q+1
+	count := uint8(1)
+	for bit := uint64(1); hash&bit == 0; bit <<= 1 {
+		count++
+	}
+	return index, count
+}
+
+// denseGet returns a 6 bits register of the dense representation, registers are packed from the least significant bit.
+func denseGet(regs []byte, idx int) uint8 {
+	b := idx * hllBits / 8
+	fb := uint(idx * hllBits & 7)
+	v := uint(regs[b]) >> fb
+	if b+1 < len(regs) {
+		v |= uint(regs[b+1]) << (8 - fb)
+	}
+	return uint8(v & hllRegisterMax)
+}
+
+func denseSet(regs []byte, idx int, val uint8) {
+	b := idx * hllBits / 8
+	fb := uint(idx * hllBits & 7)
+	regs[b] &^= byte(hllRegisterMax << fb)
+	regs[b] |= byte(uint(val) << fb)
+	if b+1 < len(regs) {
+		regs[b+1] &^= byte(hllRegisterMax >> (8 - fb))
+		regs[b+1] |= byte(uint(val) >> (8 - fb))
+	}
+}
+
+// denseUpdate sets the register if count is greater than its value, it returns true if it was updated.
+func denseUpdate(regs []byte, idx int, count uint8) bool {
+	if count > denseGet(regs, idx) {
+		denseSet(regs, idx, count)
+		return true
+	}
+	return false
+}
+
+// Sparse opcodes:
+// ZERO  00xxxxxx           run of xxxxxx+1 registers set to 0
+// XZERO 01xxxxxx yyyyyyyy  run of xxxxxxyyyyyyyy+1 registers set to 0
+// VAL   1vvvvvxx           run of xx+1 registers set to vvvvv+1
+func isZeroOp(b byte) bool  { return b&0xc0 == 0 }
+func isXZeroOp(b byte) bool { return b&0xc0 == hllSparseXZeroBit }
+func isValOp(b byte) bool   { return b&hllSparseValBit != 0 }
+func zeroLen(b byte) int    { return int(b&0x3f) + 1 }
+func xzeroLen(b0, b1 byte) int {
+	return (int(b0&0x3f)<<8 | int(b1)) + 1
+}
+func valValue(b byte) uint8 { return (b>>2)&0x1f + 1 }
+func valLen(b byte) int     { return int(b&0x3) + 1 }
+func valOp(val uint8, n int) byte {
+	return byte(int(val-1)<<2|(n-1)) | hllSparseValBit
+}
+func zeroOp(n int) byte { return byte(n - 1) }
+func xzeroOp(n int) []byte {
+	l := n - 1
+	return []byte{byte(l>>8) | hllSparseXZeroBit, byte(l & 0xff)}
+}
+
+// zerosOp returns the shortest opcode for a run of n zero registers.
+func zerosOp(n int) []byte {
+	if n > hllSparseZeroMaxLen {
+		return xzeroOp(n)
+	}
+	return []byte{zeroOp(n)}
+}
+
+// eachSparse calls fn for every opcode with the first register it covers, its length and value.
+// It returns false if the opcodes do not cover exactly all the registers.
+func eachSparse(ops []byte, fn func(first, n int, val uint8)) bool {
+	idx := 0
+	for p := 0; p < len(ops); {
+		var n int
+		var val uint8
+		switch {
+		case isZeroOp(ops[p]):
+			n = zeroLen(ops[p])
+			p++
+		case isXZeroOp(ops[p]):
+			if p+1 >= len(ops) {
+				return false
+			}
+			n = xzeroLen(ops[p], ops[p+1])
+			p += 2
+		default:
+			n, val = valLen(ops[p]), valValue(ops[p])
+			if idx+n > hllRegisters {
+				return false
+			}
+			p++
+		}
+		fn(idx, n, val)
+		idx += n
+	}
+	return idx == hllRegisters
+}
+
+// toDense converts a sparse HyperLogLog to the dense representation, keeping the cached cardinality.
+func (h hll) toDense() (hll, bool) {
+	if h.dense() {
+		return h, true
+	}
+	d := make(hll, hllDenseSize)
+	copy(d, h[:hllHdrSize])
+	d[4] = hllEncodingDense
+	regs := d[hllHdrSize:]
+	ok := eachSparse(h[hllHdrSize:], func(first, n int, val uint8) {
+		if val == 0 {
+			return
+		}
+		for i := first; i < first+n; i++ {
+			denseSet(regs, i, val)
+		}
+	})
+	return d, ok
+}
+
+// add adds the element, it returns the updated HyperLogLog and whether a register changed.
+func (h hll) add(elem string) (hll, bool, error) {
+	index, count := hllPatLen(elem)
+	return h.set(index, count)
+}
+
+// set sets the register to count if it is greater than its value.
+func (h hll) set(index int, count uint8) (hll, bool, error) {
+	if h.dense() {
+		return h, denseUpdate(h[hllHdrSize:], index, count), nil
+	}
+	return h.sparseSet(index, count)
+}
+
+// sparseSet updates a register of the sparse representation in place, splitting the opcode covering it,
+// and promotes the HyperLogLog to the dense representation if the value or the size does not fit anymore.
+// The opcodes are modified exactly like hllSparseSet in redis so that the strings stay identical.
+func (h hll) sparseSet(index int, count uint8) (hll, bool, error) {
+	if count > hllSparseValMaxValue {
+		return h.promote(index, count)
+	}
+	// step 1: locate the opcode covering the register
+	p, prev, first, span := hllHdrSize, -1, 0, 0
+	for p < len(h) {
+		oplen := 1
+		switch {
+		case isZeroOp(h[p]):
+			span = zeroLen(h[p])
+		case isValOp(h[p]):
+			span = valLen(h[p])
+		default:
+			if p+1 >= len(h) {
+				return h, false, ErrCorruptedHLL
+			}
+			span = xzeroLen(h[p], h[p+1])
+			oplen = 2
+		}
+		if index <= first+span-1 {
+			break
+		}
+		prev = p
+		p += oplen
+		first += span
+	}
+	if span == 0 || p >= len(h) {
+		return h, false, ErrCorruptedHLL
+	}
+	op := h[p]
+	isZero, isXZero, isVal := isZeroOp(op), isXZeroOp(op), isValOp(op)
+
+	// step 2: update in place when trivial, otherwise build the sequence replacing the opcode
+	switch {
+	case isVal && valValue(op) >= count:
+		return h, false, nil
+	case isVal && span == 1, isZero && span == 1:
+		h[p] = valOp(count, 1)
+	default:
+		last := first + span - 1
+		seq := make([]byte, 0, 5)
+		if isZero || isXZero {
+			if index != first {
+				seq = append(seq, zerosOp(index-first)...)
+			}
+			seq = append(seq, valOp(count, 1))
+			if index != last {
+				seq = append(seq, zerosOp(last-index)...)
+			}
+		} else {
+			cur := valValue(op)
+			if index != first {
+				seq = append(seq, valOp(cur, index-first))
+			}
+			seq = append(seq, valOp(count, 1))
+			if index != last {
+				seq = append(seq, valOp(cur, last-index))
+			}
+		}
+		// step 3: replace the opcode with the sequence
+		oldLen := 1
+		if isXZero {
+			oldLen = 2
+		}
+		delta := len(seq) - oldLen
+		if delta > 0 && len(h)+delta > hllSparseMaxBytes {
+			return h.promote(index, count)
+		}
+		h = append(h[:p], append(seq, h[p+oldLen:]...)...)
+	}
+
+	// step 4: merge the adjacent VAL opcodes with the same value, up to 5 opcodes from the previous one
+	if prev < 0 {
+		prev = hllHdrSize
+	}
+	for p, scan := prev, 5; p < len(h) && scan > 0; scan-- {
+		switch {
+		case isXZeroOp(h[p]):
+			p += 2
+			continue
+		case isZeroOp(h[p]):
+			p++
+			continue
+		}
+		if p+1 < len(h) && isValOp(h[p+1]) {
+			v1, v2 := valValue(h[p]), valValue(h[p+1])
+			if n := valLen(h[p]) + valLen(h[p+1]); v1 == v2 && n <= hllSparseValMaxLen {
+				h[p+1] = valOp(v1, n)
+				h = append(h[:p], h[p+1:]...)
+				// try to merge the merged opcode with the next one
+				continue
+			}
+		}
+		p++
+	}
+	h.invalidateCache()
+	return h, true, nil
+}
+
+// promote converts the HyperLogLog to the dense representation and sets the register, which always changes it.
+func (h hll) promote(index int, count uint8) (hll, bool, error) {
+	d, ok := h.toDense()
+	if !ok {
+		return h, false, ErrCorruptedHLL
+	}
+	denseUpdate(d[hllHdrSize:], index, count)
+	return d, true, nil
+}
+
+// histogram counts the registers by value, ok is false if the sparse representation is invalid.
+func (h hll) histogram() ([64]int, bool) {
+	var histo [64]int
+	if h.dense() {
+		regs := h[hllHdrSize:]
+		for i := 0; i < hllRegisters; i++ {
+			histo[denseGet(regs, i)]++
+		}
+		return histo, true
+	}
+	ok := eachSparse(h[hllHdrSize:], func(_, n int, val uint8) {
+		histo[val] += n
+	})
+	return histo, ok
+}
+
+// registers returns the value of every register.
+func (h hll) registers() ([]uint8, bool) {
+	regs := make([]uint8, hllRegisters)
+	if h.dense() {
+		for i := range regs {
+			regs[i] = denseGet(h[hllHdrSize:], i)
+		}
+		return regs, true
+	}
+	ok := eachSparse(h[hllHdrSize:], func(first, n int, val uint8) {
+		for i := first; i < first+n; i++ {
+			regs[i] = val
+		}
+	})
+	return regs, ok
+}
+
+// hllCount estimates the cardinality from the histogram of the registers.
+// See "New cardinality estimation algorithms for HyperLogLog sketches", Otmar Ertl, arXiv:1702.01284.
+func hllCount(histo [64]int) uint64 {
+	m := float64(hllRegisters)
+	z := m * hllTau((m-float64(histo[hllQ+1]))/m)
+	for j := hllQ; j >= 1; j-- {
+		z += float64(histo[j])
+		z *= 0.5
+	}
+	z += m * hllSigma(float64(histo[0])/m)
+	return uint64(math.Round(hllAlphaInf * m * m / z))
+}
+
+func hllSigma(x float64) float64 {
+	if x == 1 {
+		return math.Inf(1)
+	}
+	y, z := 1.0, x
+	for {
+		x *= x
+		zPrime := z
+		z += x * y
+		y += y
+		if zPrime == z {
+			return z
+		}
+	}
+}
+
+func hllTau(x float64) float64 {
+	if x == 0 || x == 1 {
+		return 0
+	}
+	y, z := 1.0, 1-x
+	for {
+		x = math.Sqrt(x)
+		zPrime := z
+		y *= 0.5
+		z -= math.Pow(1-x, 2) * y
+		if zPrime == z {
+			return z / 3
+		}
+	}
+}
+
+// lookupHLL returns the HyperLogLog stored at key, a copy of the string which is stored back by the caller if modified.
+// caller should hold the write lock.
+func (d *DB) lookupHLL(key string) (*Data, hll, error) {
+	data, ok, err := d.lookupString(key)
+	if err != nil || !ok {
+		return nil, nil, err
+	}
+	v := data.str()
+	if !isHLL(v) {
+		return nil, nil, ErrInvalidHLL
+	}
+	return data, hll(v), nil
+}
+
+// storeHLL stores the HyperLogLog at key, creating the key if data is nil.
+// caller should hold the write lock.
+func (d *DB) storeHLL(key string, data *Data, h hll) {
+	if data == nil {
+		d.setKey(key, NewString(string(h), NO_EXPIRY))
+		return
+	}
+	d.updateKey(data, func() {
+		data.Value, data.bits = string(h), nil
+	})
+}
+
+// PFAdd adds the elements to the HyperLogLog stored at key, it returns true if the key was created or a register changed.
+func (d *DB) PFAdd(key string, elems []string) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	data, h, err := d.lookupHLL(key)
+	if err != nil {
+		return false, err
+	}
+	updated := data == nil
+	if data == nil {
+		h = newHLL()
+	}
+	for _, elem := range elems {
+		var changed bool
+		if h, changed, err = h.add(elem); err != nil {
+			return false, err
+		}
+		updated = updated || changed
+	}
+	if updated {
+		h.invalidateCache()
+		d.storeHLL(key, data, h)
+	}
+	return updated, nil
+}
+
+// PFCount returns the estimated cardinality of the union of the HyperLogLogs stored at keys.
+// With one key, the cardinality is cached in the header of the HyperLogLog.
+func (d *DB) PFCount(keys []string) (uint64, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(keys) == 1 {
+		data, h, err := d.lookupHLL(keys[0])
+		if err != nil || data == nil {
+			return 0, err
+		}
+		if card, ok := h.cachedCard(); ok {
+			return card, nil
+		}
+		histo, ok := h.histogram()
+		if !ok {
+			return 0, ErrCorruptedHLL
+		}
+		card := hllCount(histo)
+		h.setCachedCard(card)
+		d.storeHLL(keys[0], data, h)
+		return card, nil
+	}
+	regs, _, err := d.mergeHLLs(keys)
+	if err != nil {
+		return 0, err
+	}
+	var histo [64]int
+	for _, r := range regs {
+		histo[r]++
+	}
+	return hllCount(histo), nil
+}
+
+// mergeHLLs returns the maximum of every register of the HyperLogLogs stored at keys, and whether one of them is dense.
+// caller should hold the write lock.
+func (d *DB) mergeHLLs(keys []string) ([]uint8, bool, error) {
+	regs := make([]uint8, hllRegisters)
+	useDense := false
+	for _, key := range keys {
+		data, h, err := d.lookupHLL(key)
+		if err != nil {
+			return nil, false, err
+		}
+		if data == nil {
+			continue
+		}
+		useDense = useDense || h.dense()
+		hregs, ok := h.registers()
+		if !ok {
+			return nil, false, ErrCorruptedHLL
+		}
+		for i, r := range hregs {
+			regs[i] = max(regs[i], r)
+		}
+	}
+	return regs, useDense, nil
+}
+
+// PFMerge stores the union of the HyperLogLogs stored at dst and keys in dst.
+// The result is dense if one of the HyperLogLogs is dense.
+func (d *DB) PFMerge(dst string, keys []string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	regs, useDense, err := d.mergeHLLs(append([]string{dst}, keys...))
+	if err != nil {
+		return err
+	}
+	data, h, _ := d.lookupHLL(dst)
+	if data == nil {
+		h = newHLL()
+	}
+	if useDense {
+		var ok bool
+		if h, ok = h.toDense(); !ok {
+			return ErrCorruptedHLL
+		}
+	}
+	for i, r := range regs {
+		if r == 0 {
+			continue
+		}
+		if h, _, err = h.set(i, r); err != nil {
+			return err
+		}
+	}
+	h.invalidateCache()
+	d.storeHLL(dst, data, h)
+	return nil
+}
+
+// HLLEncoding is the representation of a HyperLogLog returned by PFDEBUG ENCODING.
+type HLLEncoding string
+
+// PFDebug runs a PFDEBUG subcommand on the HyperLogLog stored at key:
+// GETREG returns the registers (converting to dense like redis), DECODE the sparse opcodes,
+// ENCODING the representation and TODENSE whether it was converted.
+func (d *DB) PFDebug(sub, key string) (any, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	data, h, err := d.lookupHLL(key)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, ErrNoSuchKeyHLL
+	}
+	switch strings.ToUpper(sub) {
+	case "GETREG":
+		dense, ok := h.toDense()
+		if !ok {
+			return nil, ErrCorruptedHLL
+		}
+		if !h.dense() {
+			d.storeHLL(key, data, dense)
+		}
+		regs, _ := dense.registers()
+		res := make([]int, len(regs))
+		for i, r := range regs {
+			res[i] = int(r)
+		}
+		return res, nil
+	case "DECODE":
+		if h.dense() {
+			return nil, fmt.Errorf("HLL encoding is not sparse")
+		}
+		ops := []string{}
+		ops1 := h[hllHdrSize:]
+		for p := 0; p < len(ops1); p++ {
+			switch {
+			case isZeroOp(ops1[p]):
+				ops = append(ops, fmt.Sprintf("z:%d", zeroLen(ops1[p])))
+			case isXZeroOp(ops1[p]) && p+1 < len(ops1):
+				ops = append(ops, fmt.Sprintf("Z:%d", xzeroLen(ops1[p], ops1[p+1])))
+				p++
+			case isValOp(ops1[p]):
+				ops = append(ops, fmt.Sprintf("v:%d,%d", valValue(ops1[p]), valLen(ops1[p])))
+			default:
+				return nil, ErrCorruptedHLL
+			}
+		}
+		return strings.Join(ops, " "), nil
+	case "ENCODING":
+		if h.dense() {
+			return HLLEncoding("dense"), nil
+		}
+		return HLLEncoding("sparse"), nil
+	case "TODENSE":
+		if h.dense() {
+			return false, nil
+		}
+		dense, ok := h.toDense()
+		if !ok {
+			return nil, ErrCorruptedHLL
+		}
+		d.storeHLL(key, data, dense)
+		return true, nil
+	}
+	return nil, fmt.Errorf("Unknown PFDEBUG subcommand '%s'", sub)
+}
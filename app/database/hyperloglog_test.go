@@ -0,0 +1,124 @@
+package database
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPFAdd(t *testing.T) {
+	db := NewDB()
+	updated, err := db.PFAdd("hll", nil)
+	require.NoError(t, err)
+	require.True(t, updated)
+	// same bytes as PFADD hll in redis: sparse, cached cardinality invalidated, one XZERO opcode
+	require.Equal(t, "HYLL\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x80\x7f\xff", db.Get("hll"))
+
+	updated, err = db.PFAdd("hll", []string{"a", "b", "c", "d", "e", "f", "g"})
+	require.NoError(t, err)
+	require.True(t, updated)
+	updated, err = db.PFAdd("hll", []string{"a", "b"})
+	require.NoError(t, err)
+	require.False(t, updated)
+	card, err := db.PFCount([]string{"hll"})
+	require.NoError(t, err)
+	require.EqualValues(t, 7, card)
+
+	db.Set("s", "foo")
+	_, err = db.PFAdd("s", []string{"a"})
+	require.ErrorIs(t, err, ErrInvalidHLL)
+}
+
+func TestPFCountAccuracy(t *testing.T) {
+	db := NewDB()
+	for n := 0; n < 100000; n += 1000 {
+		elems := make([]string, 1000)
+		for i := range elems {
+			elems[i] = fmt.Sprint("ele:", n+i)
+		}
+		_, err := db.PFAdd("hll", elems)
+		require.NoError(t, err)
+		card, err := db.PFCount([]string{"hll"})
+		require.NoError(t, err)
+		require.InEpsilon(t, n+1000, card, 0.05)
+	}
+	enc, err := db.PFDebug("ENCODING", "hll")
+	require.NoError(t, err)
+	require.Equal(t, HLLEncoding("dense"), enc)
+}
+
+func TestHLLSparseToDense(t *testing.T) {
+	db := NewDB()
+	_, err := db.PFAdd("hll", []string{"a", "b", "c"})
+	require.NoError(t, err)
+	sparse := hll(db.Get("hll"))
+	sparseRegs, ok := sparse.registers()
+	require.True(t, ok)
+
+	converted, err := db.PFDebug("TODENSE", "hll")
+	require.NoError(t, err)
+	require.Equal(t, true, converted)
+	dense := hll(db.Get("hll"))
+	require.Len(t, dense, hllDenseSize)
+	denseRegs, ok := dense.registers()
+	require.True(t, ok)
+	require.Equal(t, sparseRegs, denseRegs)
+
+	card, err := db.PFCount([]string{"hll"})
+	require.NoError(t, err)
+	require.EqualValues(t, 3, card)
+	_, err = db.PFDebug("DECODE", "hll")
+	require.EqualError(t, err, "HLL encoding is not sparse")
+}
+
+func TestHLLSparseDecode(t *testing.T) {
+	h := newHLL()
+	h, updated, err := h.set(0, 3)
+	require.NoError(t, err)
+	require.True(t, updated)
+	h, _, err = h.set(1, 3)
+	require.NoError(t, err)
+	h, _, err = h.set(100, 1)
+	require.NoError(t, err)
+
+	db := NewDB()
+	db.Set("hll", string(h))
+	ops, err := db.PFDebug("DECODE", "hll")
+	require.NoError(t, err)
+	require.Equal(t, "v:3,2 Z:98 v:1,1 Z:16283", ops)
+
+	// values greater than 32 cannot be represented by the sparse representation
+	h, updated, err = h.set(5, 33)
+	require.NoError(t, err)
+	require.True(t, updated)
+	require.True(t, h.dense())
+}
+
+func TestPFMerge(t *testing.T) {
+	db := NewDB()
+	_, err := db.PFAdd("h1", []string{"foo", "bar", "zap", "a"})
+	require.NoError(t, err)
+	_, err = db.PFAdd("h2", []string{"a", "b", "c", "foo"})
+	require.NoError(t, err)
+	require.NoError(t, db.PFMerge("h3", []string{"h1", "h2"}))
+	card, err := db.PFCount([]string{"h3"})
+	require.NoError(t, err)
+	require.EqualValues(t, 6, card)
+	card, err = db.PFCount([]string{"h1", "h2", "missing"})
+	require.NoError(t, err)
+	require.EqualValues(t, 6, card)
+	enc, err := db.PFDebug("ENCODING", "h3")
+	require.NoError(t, err)
+	require.Equal(t, HLLEncoding("sparse"), enc)
+
+	_, err = db.PFDebug("TODENSE", "h2")
+	require.NoError(t, err)
+	require.NoError(t, db.PFMerge("h4", []string{"h1", "h2"}))
+	enc, err = db.PFDebug("ENCODING", "h4")
+	require.NoError(t, err)
+	require.Equal(t, HLLEncoding("dense"), enc)
+	card, err = db.PFCount([]string{"h4"})
+	require.NoError(t, err)
+	require.EqualValues(t, 6, card)
+}
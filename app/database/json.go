@@ -0,0 +1,921 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// JSON documents, like JSON.* of RedisJSON. A document is a tree of nil, bool, int64, float64, string,
+// *jsonArray and *jsonObject, integers and floats are kept apart like RedisJSON does.
+// ref: https://github.com/RedisJSON/RedisJSON/blob/v2.6.6/redis_json/src/commands.rs
+const TypeJSON = "ReJSON-RL"
+
+var (
+	ErrJSONInvalid          = errors.New("invalid JSON")
+	ErrJSONInvalidPath      = errors.New("invalid JSONPath")
+	ErrJSONNewAtRoot        = errors.New("new objects must be created at the root")
+	ErrJSONKeyNotFound      = errors.New("could not perform this operation on a key that doesn't exist")
+	ErrJSONIndexOutOfBounds = errors.New("index out of bounds")
+	ErrJSONNotNumber        = errors.New("result is not a number")
+)
+
+// jsonPathNotFound is the error of a legacy path without match.
+func jsonPathNotFound(path string) error {
+	return fmt.Errorf("Path '%s' does not exist", path)
+}
+
+// jsonWrongType is the error of a legacy path matching a value of an unexpected type.
+func jsonWrongType(expected string, v any) error {
+	return &CodedError{Code: "WRONGTYPE", Msg: fmt.Sprintf("wrong type of path value - expected %s but found %s", expected, jsonTypeName(v))}
+}
+
+type jsonObject struct {
+	keys []string // in insertion order
+	vals map[string]any
+}
+
+type jsonArray struct {
+	elems []any
+}
+
+func newJSONObject() *jsonObject {
+	return &jsonObject{vals: map[string]any{}}
+}
+
+func (o *jsonObject) set(key string, v any) {
+	if _, ok := o.vals[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.vals[key] = v
+}
+
+func (o *jsonObject) delete(key string) {
+	delete(o.vals, key)
+	for i, k := range o.keys {
+		if k == key {
+			o.keys = append(o.keys[:i], o.keys[i+1:]...)
+			return
+		}
+	}
+}
+
+// parseJSON parses a JSON value, the numbers without fraction nor exponent which fit in an int64 are integers.
+func parseJSON(s string) (any, error) {
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	v, err := decodeJSON(dec)
+	if err == nil {
+		if _, err = dec.Token(); err == io.EOF {
+			return v, nil
+		}
+		if err == nil {
+			err = fmt.Errorf("trailing characters")
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrJSONInvalid, err.Error())
+}
+
+func decodeJSON(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		if t == '{' {
+			obj := newJSONObject()
+			for dec.More() {
+				key, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				v, err := decodeJSON(dec)
+				if err != nil {
+					return nil, err
+				}
+				obj.set(key.(string), v)
+			}
+			_, err := dec.Token()
+			return obj, err
+		}
+		arr := &jsonArray{elems: []any{}}
+		for dec.More() {
+			v, err := decodeJSON(dec)
+			if err != nil {
+				return nil, err
+			}
+			arr.elems = append(arr.elems, v)
+		}
+		_, err := dec.Token()
+		return arr, err
+	case json.Number:
+		if !strings.ContainsAny(t.String(), ".eE") {
+			if i, err := t.Int64(); err == nil {
+				return i, nil
+			}
+		}
+		return t.Float64()
+	}
+	return tok, nil
+}
+
+// JSONFormat are the separators of JSON.GET, nothing for a compact output.
+type JSONFormat struct {
+	Indent  string
+	Newline string
+	Space   string
+}
+
+// formatJSON serializes a value, the floats always have a fraction or an exponent to stay floats once parsed back.
+func formatJSON(v any, f JSONFormat) string {
+	return string(appendJSON(nil, v, f, 0))
+}
+
+func appendJSON(b []byte, v any, f JSONFormat, depth int) []byte {
+	newline := func(b []byte, depth int) []byte {
+		b = append(b, f.Newline...)
+		for i := 0; i < depth; i++ {
+			b = append(b, f.Indent...)
+		}
+		return b
+	}
+	switch v := v.(type) {
+	case nil:
+		return append(b, "null"...)
+	case bool:
+		return strconv.AppendBool(b, v)
+	case int64:
+		return strconv.AppendInt(b, v, 10)
+	case float64:
+		return append(b, formatJSONFloat(v)...)
+	case string:
+		return appendJSONString(b, v)
+	case *jsonArray:
+		if len(v.elems) == 0 {
+			return append(b, "[]"...)
+		}
+		b = append(b, '[')
+		for i, e := range v.elems {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			b = appendJSON(newline(b, depth+1), e, f, depth+1)
+		}
+		return append(newline(b, depth), ']')
+	case *jsonObject:
+		if len(v.keys) == 0 {
+			return append(b, "{}"...)
+		}
+		b = append(b, '{')
+		for i, k := range v.keys {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			b = append(appendJSONString(newline(b, depth+1), k), ':')
+			b = appendJSON(append(b, f.Space...), v.vals[k], f, depth+1)
+		}
+		return append(newline(b, depth), '}')
+	}
+	panic(fmt.Sprintf("unexpected JSON value %T", v))
+}
+
+func formatJSONFloat(f float64) string {
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if strings.Contains(s, "e") {
+		return strings.Replace(s, "e+", "e", 1)
+	}
+	if !strings.Contains(s, ".") {
+		s += ".0"
+	}
+	return s
+}
+
+// appendJSONString quotes a string, escaping only what JSON requires.
+func appendJSONString(b []byte, s string) []byte {
+	const hex = "0123456789abcdef"
+	b = append(b, '"')
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c >= utf8.RuneSelf {
+			r, size := utf8.DecodeRuneInString(s[i:])
+			if r == utf8.RuneError && size == 1 {
+				b = append(b, "\ufffd"...)
+			} else {
+				b = append(b, s[i:i+size]...)
+			}
+			i += size
+			continue
+		}
+		switch c {
+		case '"', '\\':
+			b = append(b, '\\', c)
+		case '\n':
+			b = append(b, '\\', 'n')
+		case '\r':
+			b = append(b, '\\', 'r')
+		case '\t':
+			b = append(b, '\\', 't')
+		case '\b':
+			b = append(b, '\\', 'b')
+		case '\f':
+			b = append(b, '\\', 'f')
+		default:
+			if c < 0x20 {
+				b = append(b, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xf])
+			} else {
+				b = append(b, c)
+			}
+		}
+		i++
+	}
+	return append(b, '"')
+}
+
+func jsonTypeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case int64:
+		return "integer"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case *jsonArray:
+		return "array"
+	}
+	return "object"
+}
+
+func isJSONNumber(v any) bool {
+	switch v.(type) {
+	case int64, float64:
+		return true
+	}
+	return false
+}
+
+func jsonFloat(v any) float64 {
+	if i, ok := v.(int64); ok {
+		return float64(i)
+	}
+	return v.(float64)
+}
+
+// jsonEqual compares two values deeply, an integer equals a float of the same value.
+func jsonEqual(a, b any) bool {
+	if isJSONNumber(a) && isJSONNumber(b) {
+		return jsonFloat(a) == jsonFloat(b)
+	}
+	switch a := a.(type) {
+	case *jsonArray:
+		b, ok := b.(*jsonArray)
+		if !ok || len(a.elems) != len(b.elems) {
+			return false
+		}
+		for i := range a.elems {
+			if !jsonEqual(a.elems[i], b.elems[i]) {
+				return false
+			}
+		}
+		return true
+	case *jsonObject:
+		b, ok := b.(*jsonObject)
+		if !ok || len(a.keys) != len(b.keys) {
+			return false
+		}
+		for k, v := range a.vals {
+			if bv, ok := b.vals[k]; !ok || !jsonEqual(v, bv) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
+
+func cloneJSON(v any) any {
+	switch v := v.(type) {
+	case *jsonArray:
+		c := &jsonArray{elems: make([]any, len(v.elems))}
+		for i, e := range v.elems {
+			c.elems[i] = cloneJSON(e)
+		}
+		return c
+	case *jsonObject:
+		c := &jsonObject{keys: append([]string(nil), v.keys...), vals: make(map[string]any, len(v.vals))}
+		for k, e := range v.vals {
+			c.vals[k] = cloneJSON(e)
+		}
+		return c
+	}
+	return v
+}
+
+// jsonMem estimates the memory used by a value: a tagged value for every node plus the strings and the keys.
+func jsonMem(v any) int64 {
+	const nodeSize = 16
+	switch v := v.(type) {
+	case string:
+		return nodeSize + int64(len(v))
+	case *jsonArray:
+		size := nodeSize + int64(cap(v.elems))*pointerSize
+		for _, e := range v.elems {
+			size += jsonMem(e)
+		}
+		return size
+	case *jsonObject:
+		size := int64(nodeSize)
+		for k, e := range v.vals {
+			size += dictEntrySize + int64(len(k)) + jsonMem(e)
+		}
+		return size
+	}
+	return nodeSize
+}
+
+// setJSON replaces the document of data and updates its memory.
+// caller should hold the write lock.
+func (d *DB) setJSON(data *Data, fn func()) {
+	d.updateKey(data, func() {
+		fn()
+		data.elemsMem = jsonMem(data.doc)
+	})
+}
+
+// lookupJSON returns the JSON document stored at key and the matches of the path, data is nil if the key does not exist.
+// caller should hold the write lock.
+func (d *DB) lookupJSON(key, path string) (*Data, *jsonPath, []jsonRef, error) {
+	p, err := parseJSONPath(path)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	data, ok := d.lookup(key)
+	if !ok {
+		return nil, p, nil, nil
+	}
+	if data.Type != TypeJSON {
+		return nil, nil, nil, ErrWrongType
+	}
+	return data, p, p.eval(data.doc), nil
+}
+
+// checkLegacy returns an error if the path is legacy and its first match is not of the expected type,
+// the legacy paths replying a single value.
+func (p *jsonPath) checkLegacy(refs []jsonRef, expected string, accept func(v any) bool) error {
+	if !p.legacy {
+		return nil
+	}
+	if len(refs) == 0 {
+		return jsonPathNotFound(p.raw)
+	}
+	if !accept(refs[0].value) {
+		return jsonWrongType(expected, refs[0].value)
+	}
+	return nil
+}
+
+func isJSONArray(v any) bool {
+	_, ok := v.(*jsonArray)
+	return ok
+}
+
+func isJSONObject(v any) bool {
+	_, ok := v.(*jsonObject)
+	return ok
+}
+
+// JSONSet sets the value at the path of the document stored at key. A new key can only be set at the root, and a
+// path which does not match may add a key to the objects matched by its parent. It returns false if nothing was set,
+// or if the NX or XX condition is not met.
+func (d *DB) JSONSet(key, path, value string, nx, xx bool) (bool, error) {
+	v, err := parseJSON(value)
+	if err != nil {
+		return false, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	data, p, refs, err := d.lookupJSON(key, path)
+	if err != nil {
+		return false, err
+	}
+	if data == nil {
+		if !p.isRoot() {
+			return false, ErrJSONNewAtRoot
+		}
+		if xx {
+			return false, nil
+		}
+		data = newData(TypeJSON)
+		data.doc = v
+		data.elemsMem = jsonMem(v)
+		d.setKey(key, data)
+		return true, nil
+	}
+	if len(refs) > 0 {
+		if nx {
+			return false, nil
+		}
+		d.setJSON(data, func() {
+			for i, ref := range refs {
+				if i > 0 {
+					v = cloneJSON(v)
+				}
+				if ref.parent == nil {
+					data.doc = v
+					continue
+				}
+				ref.set(v)
+			}
+		})
+		return true, nil
+	}
+	if xx {
+		return false, nil
+	}
+	parent, name, ok := p.parentAndName()
+	if !ok {
+		return false, nil
+	}
+	var objs []*jsonObject
+	for _, ref := range parent.eval(data.doc) {
+		if obj, ok := ref.value.(*jsonObject); ok {
+			objs = append(objs, obj)
+		}
+	}
+	if len(objs) == 0 {
+		return false, nil
+	}
+	d.setJSON(data, func() {
+		for i, obj := range objs {
+			if i > 0 {
+				v = cloneJSON(v)
+			}
+			obj.set(name, v)
+		}
+	})
+	return true, nil
+}
+
+// set replaces the value of a node which is not the root.
+func (r jsonRef) set(v any) {
+	switch parent := r.parent.(type) {
+	case *jsonObject:
+		parent.vals[r.key] = v
+	case *jsonArray:
+		parent.elems[r.index] = v
+	}
+}
+
+// jsonGetPath returns the matches of a path for JSON.GET and JSON.MGET: an array of the matches, or the first match
+// for a legacy path.
+func jsonGetPath(p *jsonPath, doc any, legacy bool) (any, error) {
+	refs := p.eval(doc)
+	if legacy {
+		if len(refs) == 0 {
+			return nil, jsonPathNotFound(p.raw)
+		}
+		return refs[0].value, nil
+	}
+	arr := &jsonArray{elems: make([]any, len(refs))}
+	for i, ref := range refs {
+		arr.elems[i] = ref.value
+	}
+	return arr, nil
+}
+
+// JSONGet returns the serialized values at the paths of the document stored at key, ok is false if the key does not exist.
+// With several paths, the reply is an object of every path, the values are the ones of legacy paths only if all the
+// paths are legacy.
+func (d *DB) JSONGet(key string, paths []string, f JSONFormat) (string, bool, error) {
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	parsed := make([]*jsonPath, len(paths))
+	legacy := true
+	for i, path := range paths {
+		var err error
+		if parsed[i], err = parseJSONPath(path); err != nil {
+			return "", false, err
+		}
+		legacy = legacy && parsed[i].legacy
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	data, ok := d.lookup(key)
+	if !ok {
+		return "", false, nil
+	}
+	if data.Type != TypeJSON {
+		return "", false, ErrWrongType
+	}
+	if len(parsed) == 1 {
+		v, err := jsonGetPath(parsed[0], data.doc, legacy)
+		if err != nil {
+			return "", false, err
+		}
+		return formatJSON(v, f), true, nil
+	}
+	res := newJSONObject()
+	for _, p := range parsed {
+		v, err := jsonGetPath(p, data.doc, legacy)
+		if err != nil {
+			return "", false, err
+		}
+		res.set(p.raw, v)
+	}
+	return formatJSON(res, f), true, nil
+}
+
+// JSONMGet returns the serialized values at the path of the documents stored at keys, nil for the keys which do not
+// exist, are not documents, or do not match a legacy path.
+func (d *DB) JSONMGet(keys []string, path string) ([]*string, error) {
+	p, err := parseJSONPath(path)
+	if err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	res := make([]*string, len(keys))
+	for i, key := range keys {
+		data, ok := d.lookup(key)
+		if !ok || data.Type != TypeJSON {
+			continue
+		}
+		if v, err := jsonGetPath(p, data.doc, p.legacy); err == nil {
+			s := formatJSON(v, JSONFormat{})
+			res[i] = &s
+		}
+	}
+	return res, nil
+}
+
+// JSONDel deletes the values at the path of the document stored at key and returns how many were deleted,
+// the key is deleted with the root.
+func (d *DB) JSONDel(key, path string) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	data, _, refs, err := d.lookupJSON(key, path)
+	if err != nil || data == nil || len(refs) == 0 {
+		return 0, err
+	}
+	if refs[0].parent == nil {
+		d.deleteKey(key)
+		return 1, nil
+	}
+	// a node matched several times is deleted once, and the elements of an array are removed from the last
+	// so that the indexes of the others stay valid
+	type node struct {
+		parent any
+		key    string
+		index  int
+	}
+	seen := map[node]bool{}
+	unique := refs[:0]
+	for _, ref := range refs {
+		if n := (node{ref.parent, ref.key, ref.index}); !seen[n] {
+			seen[n] = true
+			unique = append(unique, ref)
+		}
+	}
+	sort.SliceStable(unique, func(i, j int) bool {
+		return unique[i].index > unique[j].index
+	})
+	d.setJSON(data, func() {
+		for _, ref := range unique {
+			switch parent := ref.parent.(type) {
+			case *jsonObject:
+				parent.delete(ref.key)
+			case *jsonArray:
+				parent.elems = append(parent.elems[:ref.index], parent.elems[ref.index+1:]...)
+			}
+		}
+	})
+	return len(unique), nil
+}
+
+// JSONType returns the type of the values at the path of the document stored at key, nil if the key does not exist.
+func (d *DB) JSONType(key, path string) ([]any, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	data, _, refs, err := d.lookupJSON(key, path)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	res := make([]any, len(refs))
+	for i, ref := range refs {
+		res[i] = jsonTypeName(ref.value)
+	}
+	return res, nil
+}
+
+// JSONNumIncrBy increments the numbers at the path of the document stored at key and returns the new values serialized,
+// an array with null for the values which are not numbers, or the first value for a legacy path.
+func (d *DB) JSONNumIncrBy(key, path, incr string) (string, error) {
+	by, err := parseJSON(incr)
+	if err != nil {
+		return "", err
+	}
+	if !isJSONNumber(by) {
+		return "", fmt.Errorf("%w: expected a number", ErrJSONInvalid)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	data, p, refs, err := d.lookupJSON(key, path)
+	if err != nil {
+		return "", err
+	}
+	if data == nil {
+		return "", ErrJSONKeyNotFound
+	}
+	if err := p.checkLegacy(refs, "a number", isJSONNumber); err != nil {
+		return "", err
+	}
+	// the sums are checked before any is set
+	res := &jsonArray{elems: make([]any, len(refs))}
+	for i, ref := range refs {
+		if !isJSONNumber(ref.value) {
+			continue
+		}
+		a, aok := ref.value.(int64)
+		b, bok := by.(int64)
+		if sum := a + b; aok && bok && (sum > a) == (b > 0) {
+			res.elems[i] = sum
+			continue
+		}
+		sum := jsonFloat(ref.value) + jsonFloat(by)
+		if math.IsInf(sum, 0) || math.IsNaN(sum) {
+			return "", ErrJSONNotNumber
+		}
+		res.elems[i] = sum
+	}
+	d.setJSON(data, func() {
+		for i, ref := range refs {
+			if res.elems[i] == nil {
+				continue
+			}
+			if ref.parent == nil {
+				data.doc = res.elems[i]
+				continue
+			}
+			ref.set(res.elems[i])
+		}
+	})
+	if p.legacy {
+		return formatJSON(res.elems[0], JSONFormat{}), nil
+	}
+	return formatJSON(res, JSONFormat{}), nil
+}
+
+// JSONStrAppend appends the JSON string to the strings at the path of the document stored at key and returns their
+// new lengths, nil for the values which are not strings.
+func (d *DB) JSONStrAppend(key, path, value string) ([]any, error) {
+	v, err := parseJSON(value)
+	if err != nil {
+		return nil, err
+	}
+	suffix, ok := v.(string)
+	if !ok {
+		return nil, jsonWrongType("string", v)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	data, p, refs, err := d.lookupJSON(key, path)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, ErrJSONKeyNotFound
+	}
+	if err := p.checkLegacy(refs, "string", isString); err != nil {
+		return nil, err
+	}
+	res := make([]any, len(refs))
+	d.setJSON(data, func() {
+		for i, ref := range refs {
+			s, ok := ref.value.(string)
+			if !ok {
+				continue
+			}
+			s += suffix
+			res[i] = int64(len(s))
+			if ref.parent == nil {
+				data.doc = s
+				continue
+			}
+			ref.set(s)
+		}
+	})
+	return res, nil
+}
+
+// jsonArrays applies fn to the arrays at the path of the document stored at key and returns its results, nil for the
+// values which are not arrays. With write, the key must exist and the memory of the document is updated. If check
+// is set, it is called with every array first and nothing is changed if it fails for any.
+func (d *DB) jsonArrays(key, path string, write bool, check func(arr *jsonArray) error, fn func(arr *jsonArray) any) ([]any, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	data, p, refs, err := d.lookupJSON(key, path)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		if write {
+			return nil, ErrJSONKeyNotFound
+		}
+		return nil, nil
+	}
+	if err := p.checkLegacy(refs, "array", isJSONArray); err != nil {
+		return nil, err
+	}
+	for _, ref := range refs {
+		if arr, ok := ref.value.(*jsonArray); ok && check != nil {
+			if err := check(arr); err != nil {
+				return nil, err
+			}
+		}
+	}
+	res := make([]any, len(refs))
+	apply := func() {
+		for i, ref := range refs {
+			if arr, ok := ref.value.(*jsonArray); ok {
+				res[i] = fn(arr)
+			}
+		}
+	}
+	if write {
+		d.setJSON(data, apply)
+	} else {
+		apply()
+	}
+	return res, nil
+}
+
+// parseJSONValues parses the values of the JSON.ARR* commands.
+func parseJSONValues(values []string) ([]any, error) {
+	res := make([]any, len(values))
+	for i, value := range values {
+		var err error
+		if res[i], err = parseJSON(value); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// JSONArrAppend appends the values to the arrays at the path of the document stored at key and returns their new lengths.
+func (d *DB) JSONArrAppend(key, path string, values []string) ([]any, error) {
+	vs, err := parseJSONValues(values)
+	if err != nil {
+		return nil, err
+	}
+	first := true
+	return d.jsonArrays(key, path, true, nil, func(arr *jsonArray) any {
+		for _, v := range vs {
+			if !first {
+				v = cloneJSON(v)
+			}
+			arr.elems = append(arr.elems, v)
+		}
+		first = false
+		return int64(len(arr.elems))
+	})
+}
+
+// JSONArrInsert inserts the values before the index of the arrays at the path of the document stored at key and
+// returns their new lengths. A negative index counts from the end, and it is an error for an index to be out of
+// any of the arrays, in which case none is changed.
+func (d *DB) JSONArrInsert(key, path string, index int, values []string) ([]any, error) {
+	vs, err := parseJSONValues(values)
+	if err != nil {
+		return nil, err
+	}
+	check := func(arr *jsonArray) error {
+		if index > len(arr.elems) || index < 0 && -index > len(arr.elems) {
+			return ErrJSONIndexOutOfBounds
+		}
+		return nil
+	}
+	first := true
+	return d.jsonArrays(key, path, true, check, func(arr *jsonArray) any {
+		i := index
+		if i < 0 {
+			i += len(arr.elems)
+		}
+		ins := vs
+		if !first {
+			ins = make([]any, len(vs))
+			for j, v := range vs {
+				ins[j] = cloneJSON(v)
+			}
+		}
+		first = false
+		arr.elems = append(arr.elems[:i], append(ins, arr.elems[i:]...)...)
+		return int64(len(arr.elems))
+	})
+}
+
+// JSONArrIndex returns the first index of the value in the arrays at the path of the document stored at key, -1 if it
+// is not found, nil if the key does not exist. The search is limited to [start, stop), negative bounds count from the end and a stop of 0 is the end.
+func (d *DB) JSONArrIndex(key, path, value string, start, stop int) ([]any, error) {
+	v, err := parseJSON(value)
+	if err != nil {
+		return nil, err
+	}
+	return d.jsonArrays(key, path, false, nil, func(arr *jsonArray) any {
+		n := len(arr.elems)
+		from, to := start, stop
+		if from < 0 {
+			from = max(from+n, 0)
+		}
+		if to <= 0 {
+			to += n
+		}
+		for i := from; i < min(to, n); i++ {
+			if jsonEqual(arr.elems[i], v) {
+				return int64(i)
+			}
+		}
+		return int64(-1)
+	})
+}
+
+// JSONArrLen returns the lengths of the arrays at the path of the document stored at key, nil if the key does not exist.
+func (d *DB) JSONArrLen(key, path string) ([]any, error) {
+	return d.jsonArrays(key, path, false, nil, func(arr *jsonArray) any {
+		return int64(len(arr.elems))
+	})
+}
+
+// JSONArrPop removes the element at the index of the arrays at the path of the document stored at key and returns
+// them serialized, nil for the empty arrays. The index is clamped to the array and a negative index counts from the end.
+func (d *DB) JSONArrPop(key, path string, index int) ([]any, error) {
+	return d.jsonArrays(key, path, true, nil, func(arr *jsonArray) any {
+		n := len(arr.elems)
+		if n == 0 {
+			return nil
+		}
+		i := index
+		if i < 0 {
+			i += n
+		}
+		i = min(max(i, 0), n-1)
+		v := arr.elems[i]
+		arr.elems = append(arr.elems[:i], arr.elems[i+1:]...)
+		return formatJSON(v, JSONFormat{})
+	})
+}
+
+// JSONArrTrim keeps the elements of the arrays at the path of the document stored at key between start and stop
+// included, like LTRIM, and returns their new lengths.
+func (d *DB) JSONArrTrim(key, path string, start, stop int) ([]any, error) {
+	return d.jsonArrays(key, path, true, nil, func(arr *jsonArray) any {
+		n := len(arr.elems)
+		from, to := start, stop
+		if from < 0 {
+			from = max(from+n, 0)
+		}
+		if to < 0 {
+			to += n
+		}
+		to = min(to, n-1)
+		if from > to {
+			arr.elems = arr.elems[:0]
+		} else {
+			arr.elems = append(arr.elems[:0], arr.elems[from:to+1]...)
+		}
+		return int64(len(arr.elems))
+	})
+}
+
+// JSONObjKeys returns the keys of the objects at the path of the document stored at key, nil if the key does not exist.
+func (d *DB) JSONObjKeys(key, path string) ([]any, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	data, p, refs, err := d.lookupJSON(key, path)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	if err := p.checkLegacy(refs, "object", isJSONObject); err != nil {
+		return nil, err
+	}
+	res := make([]any, len(refs))
+	for i, ref := range refs {
+		if obj, ok := ref.value.(*jsonObject); ok {
+			res[i] = append([]string{}, obj.keys...)
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,207 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONSetGet(t *testing.T) {
+	db := NewDB()
+	_, err := db.JSONSet("doc", "$.a", `1`, false, false)
+	require.ErrorIs(t, err, ErrJSONNewAtRoot)
+	ok, err := db.JSONSet("doc", "$", `{"a":1,"b":{"c":[1,2.5,"x",true,null]}}`, false, true)
+	require.NoError(t, err)
+	require.False(t, ok)
+	ok, err = db.JSONSet("doc", "$", `{"a":1,"b":{"c":[1,2.5,"x",true,null]}}`, false, false)
+	require.NoError(t, err)
+	require.True(t, ok)
+	_, err = db.JSONSet("doc", "$", `{"a":`, false, false)
+	require.ErrorIs(t, err, ErrJSONInvalid)
+
+	got, ok, err := db.JSONGet("doc", nil, JSONFormat{})
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, `{"a":1,"b":{"c":[1,2.5,"x",true,null]}}`, got)
+	got, _, err = db.JSONGet("doc", []string{"$..c[1]"}, JSONFormat{})
+	require.NoError(t, err)
+	require.Equal(t, `[2.5]`, got)
+	got, _, err = db.JSONGet("doc", []string{".a", "b.c[0]"}, JSONFormat{})
+	require.NoError(t, err)
+	require.Equal(t, `{".a":1,"b.c[0]":1}`, got)
+	got, _, err = db.JSONGet("doc", []string{".a", "$.missing"}, JSONFormat{})
+	require.NoError(t, err)
+	require.Equal(t, `{".a":[1],"$.missing":[]}`, got)
+	_, _, err = db.JSONGet("doc", []string{".missing"}, JSONFormat{})
+	require.EqualError(t, err, "Path '.missing' does not exist")
+	got, _, err = db.JSONGet("doc", []string{"$.b"}, JSONFormat{Indent: "  ", Newline: "\n", Space: " "})
+	require.NoError(t, err)
+	require.Equal(t, "[\n  {\n    \"c\": [\n      1,\n      2.5,\n      \"x\",\n      true,\n      null\n    ]\n  }\n]", got)
+	_, ok, err = db.JSONGet("missing", nil, JSONFormat{})
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	// NX and XX on paths, and a missing key created in the parent objects
+	ok, err = db.JSONSet("doc", "$.a", `2`, true, false)
+	require.NoError(t, err)
+	require.False(t, ok)
+	ok, err = db.JSONSet("doc", "$.d", `{"e":"f"}`, false, true)
+	require.NoError(t, err)
+	require.False(t, ok)
+	ok, err = db.JSONSet("doc", "$.d", `{"e":"f"}`, true, false)
+	require.NoError(t, err)
+	require.True(t, ok)
+	ok, err = db.JSONSet("doc", "$..x.y", `1`, false, false)
+	require.NoError(t, err)
+	require.False(t, ok)
+	ok, err = db.JSONSet("doc", "$..e", `"g"`, false, true)
+	require.NoError(t, err)
+	require.True(t, ok)
+	got, _, err = db.JSONGet("doc", []string{"$"}, JSONFormat{})
+	require.NoError(t, err)
+	require.Equal(t, `[{"a":1,"b":{"c":[1,2.5,"x",true,null]},"d":{"e":"g"}}]`, got)
+
+	db.Set("str", "v")
+	_, _, err = db.JSONGet("str", nil, JSONFormat{})
+	require.ErrorIs(t, err, ErrWrongType)
+	mget, err := db.JSONMGet([]string{"doc", "str", "missing"}, "$.a")
+	require.NoError(t, err)
+	require.Equal(t, "[1]", *mget[0])
+	require.Nil(t, mget[1])
+	require.Nil(t, mget[2])
+	require.Equal(t, TypeJSON, db.Type("doc"))
+}
+
+func TestJSONDelType(t *testing.T) {
+	db := NewDB()
+	_, err := db.JSONSet("doc", ".", `{"a":[1,2,3,4],"b":{"a":"x"},"c":1.5}`, false, false)
+	require.NoError(t, err)
+
+	types, err := db.JSONType("doc", "$..a")
+	require.NoError(t, err)
+	require.Equal(t, []any{"array", "string"}, types)
+	types, err = db.JSONType("doc", "$.*")
+	require.NoError(t, err)
+	require.Equal(t, []any{"array", "object", "number"}, types)
+	types, err = db.JSONType("missing", "$")
+	require.NoError(t, err)
+	require.Nil(t, types)
+
+	n, err := db.JSONDel("doc", "$.a[0,2,0]")
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	n, err = db.JSONDel("doc", "$..a")
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	got, _, err := db.JSONGet("doc", nil, JSONFormat{})
+	require.NoError(t, err)
+	require.Equal(t, `{"b":{},"c":1.5}`, got)
+	n, err = db.JSONDel("doc", "$")
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	require.Empty(t, db.Type("doc"))
+	require.Zero(t, db.UsedMemory())
+}
+
+func TestJSONNumStr(t *testing.T) {
+	db := NewDB()
+	_, err := db.JSONSet("doc", "$", `{"a":1,"b":{"a":2.5},"c":"x","d":9223372036854775807}`, false, false)
+	require.NoError(t, err)
+
+	got, err := db.JSONNumIncrBy("doc", "$..a", "2")
+	require.NoError(t, err)
+	require.Equal(t, "[3,4.5]", got)
+	got, err = db.JSONNumIncrBy("doc", "$.*", "0.5")
+	require.NoError(t, err)
+	require.Equal(t, "[3.5,null,null,9.223372036854776e18]", got)
+	got, err = db.JSONNumIncrBy("doc", ".b.a", "-4.5")
+	require.NoError(t, err)
+	require.Equal(t, "0.0", got)
+	_, err = db.JSONNumIncrBy("doc", ".c", "1")
+	require.EqualError(t, err, "wrong type of path value - expected a number but found string")
+	_, err = db.JSONNumIncrBy("doc", "$.a", "x")
+	require.ErrorIs(t, err, ErrJSONInvalid)
+	_, err = db.JSONNumIncrBy("missing", "$.a", "1")
+	require.ErrorIs(t, err, ErrJSONKeyNotFound)
+
+	lens, err := db.JSONStrAppend("doc", "$.*", `"yz"`)
+	require.NoError(t, err)
+	require.Equal(t, []any{nil, nil, int64(3), nil}, lens)
+	_, err = db.JSONStrAppend("doc", "$.c", `1`)
+	require.Error(t, err)
+	got, _, err = db.JSONGet("doc", []string{".c"}, JSONFormat{})
+	require.NoError(t, err)
+	require.Equal(t, `"xyz"`, got)
+}
+
+func TestJSONArrObj(t *testing.T) {
+	db := NewDB()
+	_, err := db.JSONSet("doc", "$", `{"a":[1,2],"b":{"a":"x","c":[]}}`, false, false)
+	require.NoError(t, err)
+	get := func() string {
+		got, _, err := db.JSONGet("doc", nil, JSONFormat{})
+		require.NoError(t, err)
+		return got
+	}
+
+	res, err := db.JSONArrAppend("doc", "$..a", []string{`3`, `{"x":1}`})
+	require.NoError(t, err)
+	require.Equal(t, []any{int64(4), nil}, res)
+	res, err = db.JSONArrLen("doc", "$..*")
+	require.NoError(t, err)
+	require.Equal(t, []any{int64(4), nil, nil, nil, nil, nil, nil, nil, int64(0)}, res)
+	res, err = db.JSONArrIndex("doc", "$.a", `{"x":1}`, 0, 0)
+	require.NoError(t, err)
+	require.Equal(t, []any{int64(3)}, res)
+	res, err = db.JSONArrIndex("doc", "$.a", `1`, 1, 0)
+	require.NoError(t, err)
+	require.Equal(t, []any{int64(-1)}, res)
+
+	res, err = db.JSONArrInsert("doc", "$.a", -1, []string{`"i"`})
+	require.NoError(t, err)
+	require.Equal(t, []any{int64(5)}, res)
+	_, err = db.JSONArrInsert("doc", "$..[?(@ == @)]", 6, []string{`0`})
+	require.Error(t, err)
+	_, err = db.JSONArrInsert("doc", "$..*", 1, []string{`0`})
+	require.ErrorIs(t, err, ErrJSONIndexOutOfBounds)
+	require.Equal(t, `{"a":[1,2,3,"i",{"x":1}],"b":{"a":"x","c":[]}}`, get())
+
+	res, err = db.JSONArrPop("doc", "$..[?(@ != 1)]", -1)
+	require.NoError(t, err)
+	require.Equal(t, []any{`{"x":1}`, nil, nil}, res[:3])
+	res, err = db.JSONArrPop("doc", "$.a", 0)
+	require.NoError(t, err)
+	require.Equal(t, []any{"1"}, res)
+	res, err = db.JSONArrTrim("doc", "$.a", 1, -1)
+	require.NoError(t, err)
+	require.Equal(t, []any{int64(2)}, res)
+	require.Equal(t, `{"a":[3,"i"],"b":{"a":"x","c":[]}}`, get())
+	res, err = db.JSONArrTrim("doc", "$.a", 5, 10)
+	require.NoError(t, err)
+	require.Equal(t, []any{int64(0)}, res)
+	_, err = db.JSONArrLen("doc", ".b")
+	require.EqualError(t, err, "wrong type of path value - expected array but found object")
+
+	res, err = db.JSONObjKeys("doc", "$..*")
+	require.NoError(t, err)
+	require.Equal(t, []any{nil, []string{"a", "c"}, nil, nil}, res)
+	_, err = db.JSONObjKeys("doc", ".missing")
+	require.EqualError(t, err, "Path '.missing' does not exist")
+}
+
+func TestJSONModuleValue(t *testing.T) {
+	require.Equal(t, JSONModuleType, ModuleTypeFromID(JSONModuleType.ID()))
+	db := NewDB()
+	_, err := db.JSONSet("doc", "$", `{"b":[1,2.0,"s"],"a":{"x":null}}`, false, false)
+	require.NoError(t, err)
+	typ, fields, ok := db.Snapshot()["doc"].ModuleValue()
+	require.True(t, ok)
+	require.Equal(t, []any{`{"b":[1,2.0,"s"],"a":{"x":null}}`}, fields)
+	data, err := NewModuleValue(typ, fields, NO_EXPIRY)
+	require.NoError(t, err)
+	loaded := NewFromLoad(map[string]*Data{"doc": data})
+	got, _, err := loaded.JSONGet("doc", nil, JSONFormat{})
+	require.NoError(t, err)
+	require.Equal(t, `{"b":[1,2.0,"s"],"a":{"x":null}}`, got)
+	require.Equal(t, db.UsedMemory(), loaded.UsedMemory())
+}
@@ -0,0 +1,629 @@
+package database
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// JSONPath as RFC 9535 and RedisJSON: $ followed by segments of selectors, .name, ['name'], [index], [start:end:step],
+// [*] or .*, [?filter], and .. to select the descendants. Paths which do not start with $ are the legacy paths of
+// RedisJSON v1, they reply the first match instead of an array of all the matches.
+// ref: https://www.rfc-editor.org/rfc/rfc9535
+type jsonPath struct {
+	raw      string
+	legacy   bool
+	segments []jsonSegment
+}
+
+type jsonSegment struct {
+	descendant bool
+	selectors  []jsonSelector
+}
+
+// jsonRef is a node matched by a path and where it is in the document, the parent is nil for the root.
+type jsonRef struct {
+	parent any // *jsonObject or *jsonArray
+	key    string
+	index  int
+	value  any
+}
+
+type jsonSelector interface {
+	// appendMatches appends the children of node selected, root is the document for the filters.
+	appendMatches(res []jsonRef, node any, root any) []jsonRef
+}
+
+type (
+	jsonNameSelector     string
+	jsonWildcardSelector struct{}
+	jsonIndexSelector    int
+	jsonSliceSelector    struct{ start, end, step *int }
+	jsonFilterSelector   struct{ expr jsonExpr }
+)
+
+// JSONPathIsLegacy reports whether the path is a legacy path, which replies a single value.
+func JSONPathIsLegacy(path string) bool {
+	return !strings.HasPrefix(path, "$")
+}
+
+func parseJSONPath(path string) (*jsonPath, error) {
+	p := &jsonPath{raw: path, legacy: JSONPathIsLegacy(path)}
+	s := path
+	if p.legacy {
+		switch {
+		case s == ".":
+			s = "$"
+		case strings.HasPrefix(s, ".") || strings.HasPrefix(s, "["):
+			s = "$" + s
+		default:
+			s = "$." + s
+		}
+	}
+	parser := &jsonPathParser{s: s, pos: 1}
+	segments, err := parser.segments()
+	if err == nil && parser.pos < len(s) {
+		err = parser.errorf("unexpected character")
+	}
+	if err != nil {
+		return nil, err
+	}
+	p.segments = segments
+	return p, nil
+}
+
+// isRoot reports whether the path only matches the root.
+func (p *jsonPath) isRoot() bool {
+	return len(p.segments) == 0
+}
+
+// parentAndName splits a path ending with a single name, which may be created in the objects matched by its parent.
+func (p *jsonPath) parentAndName() (*jsonPath, string, bool) {
+	if len(p.segments) == 0 {
+		return nil, "", false
+	}
+	last := p.segments[len(p.segments)-1]
+	name, ok := last.selectors[0].(jsonNameSelector)
+	if last.descendant || len(last.selectors) != 1 || !ok {
+		return nil, "", false
+	}
+	return &jsonPath{raw: p.raw, legacy: p.legacy, segments: p.segments[:len(p.segments)-1]}, string(name), true
+}
+
+// eval returns the nodes matched by the path in the document.
+func (p *jsonPath) eval(root any) []jsonRef {
+	return evalJSONSegments(p.segments, jsonRef{index: -1, value: root}, root)
+}
+
+func evalJSONSegments(segments []jsonSegment, start jsonRef, root any) []jsonRef {
+	refs := []jsonRef{start}
+	for _, seg := range segments {
+		var next []jsonRef
+		for _, ref := range refs {
+			nodes := []any{ref.value}
+			if seg.descendant {
+				nodes = appendJSONDescendants(nodes[:0], ref.value)
+			}
+			for _, node := range nodes {
+				for _, sel := range seg.selectors {
+					next = sel.appendMatches(next, node, root)
+				}
+			}
+		}
+		refs = next
+	}
+	return refs
+}
+
+// appendJSONDescendants appends the node and all its descendants, parents first.
+func appendJSONDescendants(res []any, node any) []any {
+	res = append(res, node)
+	switch v := node.(type) {
+	case *jsonObject:
+		for _, k := range v.keys {
+			res = appendJSONDescendants(res, v.vals[k])
+		}
+	case *jsonArray:
+		for _, e := range v.elems {
+			res = appendJSONDescendants(res, e)
+		}
+	}
+	return res
+}
+
+// appendJSONChildren appends every child of an object or an array.
+func appendJSONChildren(res []jsonRef, node any) []jsonRef {
+	switch v := node.(type) {
+	case *jsonObject:
+		for _, k := range v.keys {
+			res = append(res, jsonRef{parent: v, key: k, index: -1, value: v.vals[k]})
+		}
+	case *jsonArray:
+		for i, e := range v.elems {
+			res = append(res, jsonRef{parent: v, index: i, value: e})
+		}
+	}
+	return res
+}
+
+func (s jsonNameSelector) appendMatches(res []jsonRef, node any, _ any) []jsonRef {
+	if obj, ok := node.(*jsonObject); ok {
+		if v, ok := obj.vals[string(s)]; ok {
+			res = append(res, jsonRef{parent: obj, key: string(s), index: -1, value: v})
+		}
+	}
+	return res
+}
+
+func (jsonWildcardSelector) appendMatches(res []jsonRef, node any, _ any) []jsonRef {
+	return appendJSONChildren(res, node)
+}
+
+func (s jsonIndexSelector) appendMatches(res []jsonRef, node any, _ any) []jsonRef {
+	arr, ok := node.(*jsonArray)
+	if !ok {
+		return res
+	}
+	i := int(s)
+	if i < 0 {
+		i += len(arr.elems)
+	}
+	if i >= 0 && i < len(arr.elems) {
+		res = append(res, jsonRef{parent: arr, index: i, value: arr.elems[i]})
+	}
+	return res
+}
+
+// appendMatches selects like the slices of python, the bounds are clamped and a negative step goes backward.
+func (s jsonSliceSelector) appendMatches(res []jsonRef, node any, _ any) []jsonRef {
+	arr, ok := node.(*jsonArray)
+	if !ok {
+		return res
+	}
+	n := len(arr.elems)
+	step := 1
+	if s.step != nil {
+		step = *s.step
+	}
+	if step == 0 {
+		return res
+	}
+	bound := func(i *int, def int) int {
+		if i == nil {
+			return def
+		}
+		v := *i
+		if v < 0 {
+			v += n
+		}
+		if step > 0 {
+			return min(max(v, 0), n)
+		}
+		return min(max(v, -1), n-1)
+	}
+	if step > 0 {
+		for i := bound(s.start, 0); i < bound(s.end, n); i += step {
+			res = append(res, jsonRef{parent: arr, index: i, value: arr.elems[i]})
+		}
+		return res
+	}
+	for i := bound(s.start, n-1); i > bound(s.end, -1); i += step {
+		res = append(res, jsonRef{parent: arr, index: i, value: arr.elems[i]})
+	}
+	return res
+}
+
+func (s jsonFilterSelector) appendMatches(res []jsonRef, node any, root any) []jsonRef {
+	for _, child := range appendJSONChildren(nil, node) {
+		if s.expr.eval(child.value, root) {
+			res = append(res, child)
+		}
+	}
+	return res
+}
+
+// jsonExpr is a filter expression, evaluated with @ being the current node.
+type jsonExpr interface {
+	eval(cur, root any) bool
+}
+
+type (
+	jsonOrExpr  []jsonExpr
+	jsonAndExpr []jsonExpr
+	jsonNotExpr struct{ expr jsonExpr }
+	// jsonExistsExpr is true if the query matches any node.
+	jsonExistsExpr  struct{ query jsonOperand }
+	jsonCompareExpr struct {
+		op          string
+		left, right jsonOperand
+	}
+)
+
+// jsonOperand is either a literal or a query relative to the current node (@) or to the root ($).
+type jsonOperand struct {
+	literal  any
+	segments []jsonSegment
+	relative bool
+	isQuery  bool
+}
+
+// value returns the literal or the first node matched by the query, ok is false if there is none.
+func (o jsonOperand) value(cur, root any) (any, bool) {
+	if !o.isQuery {
+		return o.literal, true
+	}
+	start := root
+	if o.relative {
+		start = cur
+	}
+	refs := evalJSONSegments(o.segments, jsonRef{index: -1, value: start}, root)
+	if len(refs) == 0 {
+		return nil, false
+	}
+	return refs[0].value, true
+}
+
+func (e jsonOrExpr) eval(cur, root any) bool {
+	for _, sub := range e {
+		if sub.eval(cur, root) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e jsonAndExpr) eval(cur, root any) bool {
+	for _, sub := range e {
+		if !sub.eval(cur, root) {
+			return false
+		}
+	}
+	return true
+}
+
+func (e jsonNotExpr) eval(cur, root any) bool {
+	return !e.expr.eval(cur, root)
+}
+
+func (e jsonExistsExpr) eval(cur, root any) bool {
+	_, ok := e.query.value(cur, root)
+	return ok
+}
+
+// eval compares like RFC 9535: a missing value only equals another missing value, and only numbers or strings
+// are ordered.
+func (e jsonCompareExpr) eval(cur, root any) bool {
+	l, lok := e.left.value(cur, root)
+	r, rok := e.right.value(cur, root)
+	equal := lok == rok && (!lok || jsonEqual(l, r))
+	switch e.op {
+	case "==":
+		return equal
+	case "!=":
+		return !equal
+	}
+	if !lok || !rok {
+		return false
+	}
+	less := false
+	switch {
+	case isJSONNumber(l) && isJSONNumber(r):
+		less = jsonFloat(l) < jsonFloat(r)
+	case isString(l) && isString(r):
+		less = l.(string) < r.(string)
+	default:
+		return false
+	}
+	switch e.op {
+	case "<":
+		return less
+	case "<=":
+		return less || equal
+	case ">":
+		return !less && !equal
+	case ">=":
+		return !less
+	}
+	return false
+}
+
+func isString(v any) bool {
+	_, ok := v.(string)
+	return ok
+}
+
+type jsonPathParser struct {
+	s   string
+	pos int
+}
+
+func (p *jsonPathParser) errorf(msg string) error {
+	return fmt.Errorf("%w: %s at position %d", ErrJSONInvalidPath, msg, p.pos)
+}
+
+func (p *jsonPathParser) peek() byte {
+	if p.pos >= len(p.s) {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+func (p *jsonPathParser) skipSpaces() {
+	for p.pos < len(p.s) && p.s[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+// consume skips the spaces and the token if it is next.
+func (p *jsonPathParser) consume(token string) bool {
+	p.skipSpaces()
+	if strings.HasPrefix(p.s[p.pos:], token) {
+		p.pos += len(token)
+		return true
+	}
+	return false
+}
+
+// segments parses the segments up to the first character which does not start a segment.
+func (p *jsonPathParser) segments() ([]jsonSegment, error) {
+	var segments []jsonSegment
+	for {
+		var seg jsonSegment
+		switch {
+		case strings.HasPrefix(p.s[p.pos:], ".."):
+			p.pos += 2
+			seg.descendant = true
+			if p.peek() == '[' {
+				p.pos++
+				sels, err := p.brackets()
+				if err != nil {
+					return nil, err
+				}
+				seg.selectors = sels
+				break
+			}
+			sel, err := p.dotSelector()
+			if err != nil {
+				return nil, err
+			}
+			seg.selectors = []jsonSelector{sel}
+		case p.peek() == '.':
+			p.pos++
+			sel, err := p.dotSelector()
+			if err != nil {
+				return nil, err
+			}
+			seg.selectors = []jsonSelector{sel}
+		case p.peek() == '[':
+			p.pos++
+			sels, err := p.brackets()
+			if err != nil {
+				return nil, err
+			}
+			seg.selectors = sels
+		default:
+			return segments, nil
+		}
+		segments = append(segments, seg)
+	}
+}
+
+// dotSelector parses the name or the wildcard following a dot.
+func (p *jsonPathParser) dotSelector() (jsonSelector, error) {
+	if p.peek() == '*' {
+		p.pos++
+		return jsonWildcardSelector{}, nil
+	}
+	start := p.pos
+	for p.pos < len(p.s) && !strings.ContainsRune(".[]()<>=!&|,'\" ", rune(p.s[p.pos])) {
+		p.pos++
+	}
+	if p.pos == start {
+		return nil, p.errorf("expected a name")
+	}
+	return jsonNameSelector(p.s[start:p.pos]), nil
+}
+
+// brackets parses the comma separated selectors up to the closing bracket.
+func (p *jsonPathParser) brackets() ([]jsonSelector, error) {
+	var sels []jsonSelector
+	for {
+		p.skipSpaces()
+		var sel jsonSelector
+		switch c := p.peek(); {
+		case c == '*':
+			p.pos++
+			sel = jsonWildcardSelector{}
+		case c == '\'' || c == '"':
+			name, err := p.quoted()
+			if err != nil {
+				return nil, err
+			}
+			sel = jsonNameSelector(name)
+		case c == '?':
+			p.pos++
+			expr, err := p.or()
+			if err != nil {
+				return nil, err
+			}
+			sel = jsonFilterSelector{expr: expr}
+		case c == '-' || c == ':' || c >= '0' && c <= '9':
+			var err error
+			if sel, err = p.indexOrSlice(); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, p.errorf("expected a selector")
+		}
+		sels = append(sels, sel)
+		if p.consume("]") {
+			return sels, nil
+		}
+		if !p.consume(",") {
+			return nil, p.errorf("expected ',' or ']'")
+		}
+	}
+}
+
+// int parses an optional integer, nil if there is none.
+func (p *jsonPathParser) int() (*int, error) {
+	p.skipSpaces()
+	start := p.pos
+	if p.peek() == '-' {
+		p.pos++
+	}
+	for p.peek() >= '0' && p.peek() <= '9' {
+		p.pos++
+	}
+	if p.pos == start {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(p.s[start:p.pos])
+	if err != nil {
+		return nil, p.errorf("invalid integer")
+	}
+	return &n, nil
+}
+
+func (p *jsonPathParser) indexOrSlice() (jsonSelector, error) {
+	start, err := p.int()
+	if err != nil {
+		return nil, err
+	}
+	if !p.consume(":") {
+		if start == nil {
+			return nil, p.errorf("expected an index")
+		}
+		return jsonIndexSelector(*start), nil
+	}
+	var s jsonSliceSelector
+	s.start = start
+	if s.end, err = p.int(); err != nil {
+		return nil, err
+	}
+	if p.consume(":") {
+		if s.step, err = p.int(); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// quoted parses a string between single or double quotes, with backslash escapes.
+func (p *jsonPathParser) quoted() (string, error) {
+	quote := p.s[p.pos]
+	p.pos++
+	var b strings.Builder
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		p.pos++
+		switch {
+		case c == quote:
+			return b.String(), nil
+		case c == '\\' && p.pos < len(p.s):
+			b.WriteByte(p.s[p.pos])
+			p.pos++
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+func (p *jsonPathParser) or() (jsonExpr, error) {
+	expr, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	or := jsonOrExpr{expr}
+	for p.consume("||") {
+		if expr, err = p.and(); err != nil {
+			return nil, err
+		}
+		or = append(or, expr)
+	}
+	if len(or) == 1 {
+		return or[0], nil
+	}
+	return or, nil
+}
+
+func (p *jsonPathParser) and() (jsonExpr, error) {
+	expr, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	and := jsonAndExpr{expr}
+	for p.consume("&&") {
+		if expr, err = p.unary(); err != nil {
+			return nil, err
+		}
+		and = append(and, expr)
+	}
+	if len(and) == 1 {
+		return and[0], nil
+	}
+	return and, nil
+}
+
+func (p *jsonPathParser) unary() (jsonExpr, error) {
+	switch {
+	case p.consume("!"):
+		expr, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return jsonNotExpr{expr: expr}, nil
+	case p.consume("("):
+		expr, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if !p.consume(")") {
+			return nil, p.errorf("expected ')'")
+		}
+		return expr, nil
+	}
+	left, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.consume(op) {
+			right, err := p.operand()
+			if err != nil {
+				return nil, err
+			}
+			return jsonCompareExpr{op: op, left: left, right: right}, nil
+		}
+	}
+	if !left.isQuery {
+		return nil, p.errorf("expected a comparison")
+	}
+	return jsonExistsExpr{query: left}, nil
+}
+
+func (p *jsonPathParser) operand() (jsonOperand, error) {
+	p.skipSpaces()
+	switch c := p.peek(); {
+	case c == '@' || c == '$':
+		p.pos++
+		segments, err := p.segments()
+		if err != nil {
+			return jsonOperand{}, err
+		}
+		return jsonOperand{segments: segments, relative: c == '@', isQuery: true}, nil
+	case c == '\'' || c == '"':
+		s, err := p.quoted()
+		return jsonOperand{literal: s}, err
+	}
+	start := p.pos
+	for p.pos < len(p.s) && strings.ContainsRune("abcdefghijklmnopqrstuvwxyz0123456789.+-E", rune(p.s[p.pos])) {
+		p.pos++
+	}
+	v, err := parseJSON(p.s[start:p.pos])
+	if err != nil || start == p.pos {
+		return jsonOperand{}, p.errorf("expected a value")
+	}
+	return jsonOperand{literal: v}, nil
+}
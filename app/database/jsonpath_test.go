@@ -0,0 +1,74 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONPath(t *testing.T) {
+	doc, err := parseJSON(`{"store":{"book":[` +
+		`{"category":"reference","author":"Nigel Rees","title":"Sayings of the Century","price":8.95},` +
+		`{"category":"fiction","author":"Evelyn Waugh","title":"Sword of Honour","price":12.99},` +
+		`{"category":"fiction","author":"Herman Melville","title":"Moby Dick","isbn":"0-553-21311-3","price":8.99},` +
+		`{"category":"fiction","author":"J. R. R. Tolkien","title":"The Lord of the Rings","isbn":"0-395-19395-8","price":22.99}],` +
+		`"bicycle":{"color":"red","price":399}},"a.b":1}`)
+	require.NoError(t, err)
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"$", ""},
+		{"$.store.book[*].author", `["Nigel Rees","Evelyn Waugh","Herman Melville","J. R. R. Tolkien"]`},
+		{"$..author", `["Nigel Rees","Evelyn Waugh","Herman Melville","J. R. R. Tolkien"]`},
+		{"$.store.*", ""},
+		{"$.store..price", `[8.95,12.99,8.99,22.99,399]`},
+		{"$..book[2].title", `["Moby Dick"]`},
+		{"$..book[-1].title", `["The Lord of the Rings"]`},
+		{"$..book[0,1].title", `["Sayings of the Century","Sword of Honour"]`},
+		{"$..book[:2].title", `["Sayings of the Century","Sword of Honour"]`},
+		{"$..book[1:4:2].title", `["Sword of Honour","The Lord of the Rings"]`},
+		{"$..book[::-1].price", `[22.99,8.99,12.99,8.95]`},
+		{"$..book[?(@.isbn)].title", `["Moby Dick","The Lord of the Rings"]`},
+		{"$..book[?(@.price<10)].title", `["Sayings of the Century","Moby Dick"]`},
+		{`$..book[?@.category=="fiction" && @.price > 20].title`, `["The Lord of the Rings"]`},
+		{`$..book[?(@.price > $.store.bicycle.price || !(@.author != 'Nigel Rees'))].title`, `["Sayings of the Century"]`},
+		{"$['store']['bicycle'][\"color\"]", `["red"]`},
+		{"$['a.b']", `[1]`},
+		{"$.missing", `[]`},
+		{"$.store.book[10]", `[]`},
+	}
+	for _, tt := range tests {
+		p, err := parseJSONPath(tt.path)
+		require.NoError(t, err, tt.path)
+		refs := p.eval(doc)
+		if tt.want == "" {
+			require.NotEmpty(t, refs, tt.path)
+			continue
+		}
+		arr := &jsonArray{elems: []any{}}
+		for _, ref := range refs {
+			arr.elems = append(arr.elems, ref.value)
+		}
+		require.Equal(t, tt.want, formatJSON(arr, JSONFormat{}), tt.path)
+	}
+
+	for _, path := range []string{"$.", "$[", "$[1", "$..", "$[?(@.a ==)]", "$['a]", "$a"} {
+		_, err := parseJSONPath(path)
+		require.ErrorIs(t, err, ErrJSONInvalidPath, path)
+	}
+}
+
+func TestJSONLegacyPath(t *testing.T) {
+	doc, err := parseJSON(`{"a":{"b":[1,2]}}`)
+	require.NoError(t, err)
+	for path, want := range map[string]string{".": `{"a":{"b":[1,2]}}`, ".a.b": "[1,2]", "a.b[1]": "2", "a[\"b\"][0]": "1"} {
+		p, err := parseJSONPath(path)
+		require.NoError(t, err)
+		require.True(t, p.legacy)
+		refs := p.eval(doc)
+		require.Len(t, refs, 1, path)
+		require.Equal(t, want, formatJSON(refs[0].value, JSONFormat{}), path)
+	}
+}
@@ -0,0 +1,178 @@
+package database
+
+import "sync"
+
+// crossDBMu is held by operations locking two databases, so that they always lock the databases
+// while no other goroutine can be waiting for the second lock in the opposite order.
+var crossDBMu sync.Mutex
+
+func lockPair(src, dst *DB) func() {
+	if src == dst {
+		src.mu.Lock()
+		return src.mu.Unlock
+	}
+	crossDBMu.Lock()
+	src.mu.Lock()
+	dst.mu.Lock()
+	return func() {
+		dst.mu.Unlock()
+		src.mu.Unlock()
+		crossDBMu.Unlock()
+	}
+}
+
+// clone returns a deep copy of the data, so that modifying the copy does not affect the original.
+func (data *Data) clone() *Data {
+	c := *data
+	c.Value, c.bits = data.str(), nil
+	c.Entries = append([]Entry(nil), data.Entries...)
+	c.list = append([]string(nil), data.list...)
+	if data.hash != nil {
+		c.hash = data.hash.clone()
+	}
+	if data.set != nil {
+		c.set = data.set.clone()
+	}
+	if data.zset != nil {
+		c.zset = data.zset.clone()
+		c.zsl = newSkiplist()
+		data.zsl.each(func(member string, score float64) bool {
+			c.zsl.insert(score, member)
+			return true
+		})
+	}
+	if data.bloom != nil {
+		c.bloom = data.bloom.clone()
+	}
+	if data.cuckoo != nil {
+		c.cuckoo = data.cuckoo.clone()
+	}
+	if data.cms != nil {
+		c.cms = data.cms.clone()
+	}
+	if data.topk != nil {
+		c.topk = data.topk.clone()
+	}
+	if data.doc != nil {
+		c.doc = cloneJSON(data.doc)
+	}
+	return &c
+}
+
+// Rename renames key to newKey, keeping its expire time. An existing newKey is overwritten unless nx is set,
+// in which case nothing is done and false is returned.
+func (d *DB) Rename(key, newKey string, nx bool) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	data, ok := d.lookup(key)
+	if !ok {
+		return false, ErrNoSuchKey
+	}
+	if key == newKey {
+		return !nx, nil
+	}
+	if _, exists := d.peek(newKey); exists && nx {
+		return false, nil
+	}
+	d.deleteKey(key)
+	d.setKey(newKey, data)
+	return true, nil
+}
+
+// Move moves key from src to dst, it returns false if the key does not exist in src or already exists in dst.
+func Move(src, dst *DB, key string) bool {
+	unlock := lockPair(src, dst)
+	defer unlock()
+	data, ok := src.lookup(key)
+	if !ok {
+		return false
+	}
+	if _, exists := dst.peek(key); exists {
+		return false
+	}
+	src.deleteKey(key)
+	dst.setKey(key, data)
+	return true
+}
+
+// Copy copies the value of key in src to dstKey in dst, keeping its expire time.
+// It returns false if the key does not exist in src or dstKey already exists in dst and replace is not set.
+func Copy(src, dst *DB, key, dstKey string, replace bool) bool {
+	unlock := lockPair(src, dst)
+	defer unlock()
+	data, ok := src.lookup(key)
+	if !ok {
+		return false
+	}
+	if _, exists := dst.peek(dstKey); exists && !replace {
+		return false
+	}
+	dst.setKey(dstKey, data.clone())
+	return true
+}
+
+// Swap swaps the keyspace of two databases, clients connected to a database see the data of the other one.
+func Swap(a, b *DB) {
+	unlock := lockPair(a, b)
+	defer unlock()
+	a.datas, b.datas = b.datas, a.datas
+	a.expires, b.expires = b.expires, a.expires
+	used := a.used.Load()
+	a.used.Store(b.used.Swap(used))
+}
+
+// Del deletes the keys and returns the number of keys that existed.
+// If async, the values of large keys are released in the background (UNLINK).
+func (d *DB) Del(keys []string, async bool) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	deleted := 0
+	for _, key := range keys {
+		data, ok := d.peek(key)
+		if !ok {
+			continue
+		}
+		d.deleteKey(key)
+		freeData(data, async)
+		deleted++
+	}
+	return deleted
+}
+
+// Flush removes all the keys. The keyspace is swapped with an empty one,
+// if async the old one is released in the background so that flushing a huge db does not block the caller.
+func (d *DB) Flush(async bool) {
+	d.mu.Lock()
+	old := d.datas
+	d.datas = newDict[*Data]()
+	d.expires = newDict[struct{}]()
+	d.used.Store(0)
+	d.mu.Unlock()
+	freeKeyspace(old, async)
+}
+
+// Size returns the number of keys, including the expired keys which are not deleted yet.
+func (d *DB) Size() int {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.datas.len()
+}
+
+// maxRandomKeyTries bounds the number of expired keys RandomKey deletes before giving up.
+const maxRandomKeyTries = 100
+
+// RandomKey returns a random key which is not expired, false if the db is empty.
+func (d *DB) RandomKey() (string, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i := 0; i < maxRandomKeyTries; i++ {
+		key, ok := d.datas.randomKey()
+		if !ok {
+			return "", false
+		}
+		if _, ok := d.peek(key); ok {
+			return key, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,49 @@
+package database
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlushAsync(t *testing.T) {
+	db := NewDB()
+	for i := 0; i < 1000; i++ {
+		db.Set(fmt.Sprintf("key%d", i), "v")
+	}
+	members := make([]string, 1000)
+	for i := range members {
+		members[i] = fmt.Sprintf("m%d", i)
+	}
+	_, err := db.SAdd("set", members)
+	require.NoError(t, err)
+	db.Flush(true)
+	require.Equal(t, 0, db.Size())
+	db.Set("key", "v")
+	require.Equal(t, "v", db.Get("key"))
+}
+
+func TestDelLargeCollectionAsync(t *testing.T) {
+	db := NewDB()
+	members := make([]string, lazyfreeThreshold*2)
+	for i := range members {
+		members[i] = fmt.Sprintf("m%d", i)
+	}
+	_, err := db.SAdd("set", members)
+	require.NoError(t, err)
+	require.Equal(t, 1, db.Del([]string{"set", "missing"}, true))
+	require.Equal(t, "", db.Type("set"))
+}
+
+func TestRandomKeySkipsExpired(t *testing.T) {
+	db := NewDB()
+	db.SetExp("expired", "v", 1)
+	_, ok := db.RandomKey()
+	require.False(t, ok)
+	require.Equal(t, 0, db.Size())
+	db.Set("k", "v")
+	key, ok := db.RandomKey()
+	require.True(t, ok)
+	require.Equal(t, "k", key)
+}
@@ -0,0 +1,68 @@
+package database
+
+// lazyfreeThreshold is the number of elements above which an asynchronous free is worth a goroutine,
+// smaller values are released right away. Same as LAZYFREE_THRESHOLD in redis.
+const lazyfreeThreshold = 64
+
+// freeEffort returns the number of elements held by the data, which is the work needed to release it.
+func (data *Data) freeEffort() int {
+	switch {
+	case data.hash != nil:
+		return data.hash.len()
+	case data.set != nil:
+		return data.set.len()
+	case data.zset != nil:
+		return data.zset.len()
+	case data.list != nil:
+		return len(data.list)
+	}
+	return len(data.Entries)
+}
+
+// release unlinks the elements of a data that is no longer referenced by the keyspace.
+func (data *Data) release() {
+	if data.hash != nil {
+		data.hash.clear()
+	}
+	if data.set != nil {
+		data.set.clear()
+	}
+	if data.zset != nil {
+		data.zset.clear()
+		data.zsl = nil
+	}
+	data.list = nil
+	data.Entries = nil
+	data.bloom = nil
+	data.cuckoo = nil
+	data.cms = nil
+	data.topk = nil
+	data.doc = nil
+}
+
+// releaseKeyspace unlinks every data of a keyspace detached from its DB.
+func releaseKeyspace(datas *dict[*Data]) {
+	datas.each(func(_ string, data *Data) bool {
+		data.release()
+		return true
+	})
+	datas.clear()
+}
+
+// freeData releases a data deleted from the keyspace, in the background if async and the data is large enough.
+func freeData(data *Data, async bool) {
+	if async && data.freeEffort() > lazyfreeThreshold {
+		go data.release()
+		return
+	}
+	data.release()
+}
+
+// freeKeyspace releases a detached keyspace, in the background if async.
+func freeKeyspace(datas *dict[*Data], async bool) {
+	if async {
+		go releaseKeyspace(datas)
+		return
+	}
+	releaseKeyspace(datas)
+}
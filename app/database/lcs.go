@@ -0,0 +1,140 @@
+package database
+
+import "errors"
+
+var (
+	ErrLCSNotString = errors.New("The specified keys must contain string values")
+	ErrLCSMemory    = errors.New("Insufficient memory, transient memory for LCS exceeds proto-max-bulk-len")
+)
+
+// LCSOptions are the options of LCS.
+type LCSOptions struct {
+	Idx         bool  // compute the matching ranges instead of the common string
+	MinMatchLen int   // ignore the ranges shorter than this
+	MaxMemory   int64 // maximum size in bytes of the dynamic programming table
+}
+
+// LCSMatch is a range of the common subsequence, the indexes are inclusive.
+type LCSMatch struct {
+	A   [2]int
+	B   [2]int
+	Len int
+}
+
+// LCSResult is the longest common subsequence of two strings, Str is only set without LCSOptions.Idx
+// and Matches are ordered from the end of the strings.
+type LCSResult struct {
+	Str     string
+	Len     int
+	Matches []LCSMatch
+}
+
+// LCS returns the longest common subsequence of the strings stored at key1 and key2, missing keys are empty strings.
+// ref: https://github.com/redis/redis/blob/7.2.0/src/t_string.c#L742
+func (d *DB) LCS(key1, key2 string, opts LCSOptions) (LCSResult, error) {
+	d.mu.Lock()
+	a, err := d.lcsString(key1)
+	if err != nil {
+		d.mu.Unlock()
+		return LCSResult{}, err
+	}
+	b, err := d.lcsString(key2)
+	// strings are immutable, the table is computed without holding the lock
+	d.mu.Unlock()
+	if err != nil {
+		return LCSResult{}, err
+	}
+	return lcs(a, b, opts)
+}
+
+// lcsString returns the string stored at key, or an empty string if the key does not exist.
+// caller should hold the write lock.
+func (d *DB) lcsString(key string) (string, error) {
+	data, ok, err := d.lookupString(key)
+	if errors.Is(err, ErrWrongType) {
+		return "", ErrLCSNotString
+	}
+	if !ok {
+		return "", nil
+	}
+	return data.str(), nil
+}
+
+func lcs(a, b string, opts LCSOptions) (LCSResult, error) {
+	alen, blen := len(a), len(b)
+	// the table holds the length of the LCS of every pair of prefixes
+	cells := int64(alen+1) * int64(blen+1)
+	if opts.MaxMemory > 0 && cells > opts.MaxMemory/4 {
+		return LCSResult{}, ErrLCSMemory
+	}
+	dp := make([]uint32, cells)
+	at := func(i, j int) uint32 { return dp[j*(alen+1)+i] }
+	for i := 1; i <= alen; i++ {
+		for j := 1; j <= blen; j++ {
+			if a[i-1] == b[j-1] {
+				dp[j*(alen+1)+i] = at(i-1, j-1) + 1
+			} else {
+				dp[j*(alen+1)+i] = max(at(i-1, j), at(i, j-1))
+			}
+		}
+	}
+
+	idx := int(at(alen, blen))
+	res := LCSResult{Len: idx}
+	var str []byte
+	if !opts.Idx {
+		str = make([]byte, idx)
+	}
+	// walk the table back from the end of the strings, collecting the common bytes and the ranges
+	arangeStart, arangeEnd, brangeStart, brangeEnd := alen, 0, 0, 0
+	for i, j := alen, blen; i > 0 && j > 0; {
+		emit := false
+		if a[i-1] == b[j-1] {
+			if str != nil {
+				str[idx-1] = a[i-1]
+			}
+			if arangeStart == alen {
+				arangeStart, arangeEnd = i-1, i-1
+				brangeStart, brangeEnd = j-1, j-1
+			} else if arangeStart == i && brangeStart == j {
+				// the range is contiguous, extend it backward
+				arangeStart--
+				brangeStart--
+			} else {
+				emit = true
+			}
+			// stop at the first byte of one of the strings
+			if arangeStart == 0 || brangeStart == 0 {
+				emit = true
+			}
+			idx--
+			i--
+			j--
+		} else {
+			if at(i-1, j) > at(i, j-1) {
+				i--
+			} else {
+				j--
+			}
+			if arangeStart != alen {
+				emit = true
+			}
+		}
+		if emit && opts.Idx {
+			n := arangeEnd - arangeStart + 1
+			if opts.MinMatchLen == 0 || n >= opts.MinMatchLen {
+				res.Matches = append(res.Matches, LCSMatch{A: [2]int{arangeStart, arangeEnd}, B: [2]int{brangeStart, brangeEnd}, Len: n})
+			}
+		}
+		if emit {
+			arangeStart = alen
+		}
+	}
+	if str != nil {
+		res.Str = string(str)
+	}
+	if opts.Idx && res.Matches == nil {
+		res.Matches = []LCSMatch{}
+	}
+	return res, nil
+}
@@ -0,0 +1,40 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLCS(t *testing.T) {
+	db := NewDB()
+	db.Set("key1", "ohmytext")
+	db.Set("key2", "mynewtext")
+
+	res, err := db.LCS("key1", "key2", LCSOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "mytext", res.Str)
+	require.Equal(t, 6, res.Len)
+
+	res, err = db.LCS("key1", "key2", LCSOptions{Idx: true})
+	require.NoError(t, err)
+	require.Equal(t, []LCSMatch{
+		{A: [2]int{4, 7}, B: [2]int{5, 8}, Len: 4},
+		{A: [2]int{2, 3}, B: [2]int{0, 1}, Len: 2},
+	}, res.Matches)
+	res, err = db.LCS("key1", "key2", LCSOptions{Idx: true, MinMatchLen: 4})
+	require.NoError(t, err)
+	require.Len(t, res.Matches, 1)
+
+	res, err = db.LCS("key1", "missing", LCSOptions{Idx: true})
+	require.NoError(t, err)
+	require.Equal(t, LCSResult{Matches: []LCSMatch{}}, res)
+
+	_, err = db.LCS("key1", "key2", LCSOptions{MaxMemory: 100})
+	require.ErrorIs(t, err, ErrLCSMemory)
+
+	_, err = db.HSet("h", []KeyValue{{Key: "f", Value: "v"}})
+	require.NoError(t, err)
+	_, err = db.LCS("key1", "h", LCSOptions{})
+	require.ErrorIs(t, err, ErrLCSNotString)
+}
@@ -0,0 +1,65 @@
+package database
+
+import "slices"
+
+// push inserts the elements at the head or the tail of the list stored at key, it returns the length of the list.
+func (d *DB) push(key string, elems []string, head bool) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	data, ok := d.lookup(key)
+	if !ok {
+		data = newData(TypeList)
+		d.setKey(key, data)
+	}
+	if data.Type != TypeList {
+		return 0, ErrWrongType
+	}
+	d.updateKey(data, func() {
+		if head {
+			// LPUSH a b c results in c b a
+			reversed := slices.Clone(elems)
+			slices.Reverse(reversed)
+			data.list = append(reversed, data.list...)
+		} else {
+			data.list = append(data.list, elems...)
+		}
+		data.listAdded(elems)
+	})
+	return len(data.list), nil
+}
+
+// LPush inserts the elements at the head of the list stored at key, it returns the length of the list.
+func (d *DB) LPush(key string, elems []string) (int, error) {
+	return d.push(key, elems, true)
+}
+
+// RPush inserts the elements at the tail of the list stored at key, it returns the length of the list.
+func (d *DB) RPush(key string, elems []string) (int, error) {
+	return d.push(key, elems, false)
+}
+
+// LRange returns the elements of the list stored at key between start and stop, both inclusive.
+// Negative indexes are offsets from the end of the list.
+func (d *DB) LRange(key string, start, stop int) ([]string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	data, ok := d.lookup(key)
+	if !ok {
+		return []string{}, nil
+	}
+	if data.Type != TypeList {
+		return nil, ErrWrongType
+	}
+	n := len(data.list)
+	if start < 0 {
+		start = max(n+start, 0)
+	}
+	if stop < 0 {
+		stop = n + stop
+	}
+	stop = min(stop, n-1)
+	if start > stop {
+		return []string{}, nil
+	}
+	return slices.Clone(data.list[start : stop+1]), nil
+}
@@ -0,0 +1,337 @@
+package database
+
+// Memory is estimated with the allocation sizes redis would use on a 64-bit system rather than the Go heap,
+// so that maxmemory and MEMORY USAGE behave like redis for the same dataset.
+// ref: https://github.com/redis/redis/blob/7.2.0/src/object.c#L1037
+const (
+	pointerSize       = 8
+	robjSize          = 16 // type, encoding, lru, refcount and ptr
+	dictEntrySize     = 24 // key, value and next pointers
+	dictSize          = 56
+	listpackHeader    = 7 // total bytes, number of elements and end byte
+	intsetHeader      = 8
+	intsetEntrySize   = 8
+	skiplistNodeSize  = 48 // ele, score, backward and about 1.33 levels
+	skiplistSize      = 32
+	streamSize        = 96
+	quicklistSize     = 40
+	quicklistNodeSize = 32 // one node for every listpack of list-max-listpack-size
+	streamEntryHeader = 24 // id, flags and counts of the entry in its listpack
+	moduleValueSize   = 16 // module type and value pointers
+)
+
+// sdsSize is the allocation of a sds string with the smallest header for its length and the null terminator.
+func sdsSize(n int) int64 {
+	switch {
+	case n < 1<<8:
+		return int64(n) + 3 + 1
+	case n < 1<<16:
+		return int64(n) + 5 + 1
+	case n < 1<<32:
+		return int64(n) + 9 + 1
+	}
+	return int64(n) + 17 + 1
+}
+
+// listpackEntrySize is the size of a string element in a listpack: encoding, content and backlen.
+func listpackEntrySize(s string) int64 {
+	if isInt64(s) {
+		return 9 // integers take 2 to 9 bytes, the largest is used for simplicity
+	}
+	n := len(s)
+	switch {
+	case n < 64:
+		return int64(n) + 2
+	case n < 4096:
+		return int64(n) + 4
+	}
+	return int64(n) + 10
+}
+
+// keyMem is the memory used by key in the keyspace: its entry, the key string, the value and the expire entry.
+func keyMem(key string, data *Data) int64 {
+	size := dictEntrySize + sdsSize(len(key)) + data.mem()
+	if data.ExpireTimestampMS != NO_EXPIRY {
+		size += dictEntrySize
+	}
+	return size
+}
+
+// mem is the memory used by the value, elemsMem is maintained as elements are added so that it is O(1).
+func (data *Data) mem() int64 {
+	return data.overhead() + data.elemsMem
+}
+
+// overhead is the memory used by the value besides the elements of collections and streams.
+func (data *Data) overhead() int64 {
+	size := int64(robjSize)
+	switch data.Type {
+	case TypeString:
+		if data.Encoding() != EncodingInt {
+			size += sdsSize(data.strLen())
+		}
+		return size
+	case TypeStream:
+		return size + streamSize
+	case TypeBloom:
+		return size + moduleValueSize + int64(len(data.bloom.layers))*pointerSize
+	case TypeCuckoo:
+		return size + moduleValueSize + int64(len(data.cuckoo.filters))*pointerSize
+	case TypeCMS, TypeTopK, TypeJSON:
+		return size + moduleValueSize
+	}
+	switch data.encoding {
+	case EncodingListpack:
+		size += listpackHeader
+	case EncodingIntset:
+		size += intsetHeader
+	case EncodingHashtable:
+		size += dictSize + int64(data.buckets())*pointerSize
+	case EncodingSkiplist:
+		size += dictSize + int64(data.buckets())*pointerSize + skiplistSize + skiplistNodeSize
+	case EncodingQuicklist:
+		nodes := data.elemsMem/listMaxListpackSize + 1
+		size += quicklistSize + nodes*(quicklistNodeSize+listpackHeader)
+	}
+	return size
+}
+
+// sampledMem estimates the memory used by the value from the average size of the first samples elements,
+// like MEMORY USAGE in redis. The compact encodings are contiguous in redis so their size is always exact,
+// samples <= 0 visits all the elements.
+func (data *Data) sampledMem(samples int) int64 {
+	n := data.freeEffort()
+	if data.Type == TypeString || samples <= 0 || samples >= n ||
+		data.encoding == EncodingListpack || data.encoding == EncodingIntset {
+		return data.mem()
+	}
+	var sum int64
+	visited := 0
+	visit := func(member, value string) bool {
+		sum += data.elemMem(member, value)
+		visited++
+		return visited < samples
+	}
+	switch data.Type {
+	case TypeHash:
+		data.hash.each(visit)
+	case TypeSet:
+		data.set.each(func(member string, _ struct{}) bool { return visit(member, "") })
+	case TypeZSet:
+		data.zset.each(func(member string, _ float64) bool { return visit(member, "") })
+	case TypeList:
+		for _, e := range data.list[:samples] {
+			visit(e, "")
+		}
+	case TypeStream:
+		for _, ent := range data.Entries[:samples] {
+			sum += entryMem(ent)
+		}
+		visited = samples
+	}
+	return data.overhead() + sum*int64(n)/int64(visited)
+}
+
+func (data *Data) buckets() int {
+	switch {
+	case data.hash != nil:
+		return data.hash.buckets()
+	case data.set != nil:
+		return data.set.buckets()
+	case data.zset != nil:
+		return data.zset.buckets()
+	}
+	return 0
+}
+
+// elemMem is the memory used by one element of a collection in its current encoding,
+// value is the value of a hash field and unused for sets and sorted sets.
+func (data *Data) elemMem(member, value string) int64 {
+	switch data.Type {
+	case TypeHash:
+		if data.encoding == EncodingListpack {
+			return listpackEntrySize(member) + listpackEntrySize(value)
+		}
+		return dictEntrySize + sdsSize(len(member)) + sdsSize(len(value))
+	case TypeSet:
+		switch data.encoding {
+		case EncodingIntset:
+			return intsetEntrySize
+		case EncodingListpack:
+			return listpackEntrySize(member)
+		}
+		return dictEntrySize + sdsSize(len(member))
+	case TypeZSet:
+		if data.encoding == EncodingListpack {
+			// the score is stored as a number after the member
+			return listpackEntrySize(member) + 9
+		}
+		return dictEntrySize + sdsSize(len(member)) + skiplistNodeSize
+	case TypeList:
+		// quicklist nodes are listpacks too
+		return listpackEntrySize(member)
+	}
+	return 0
+}
+
+// entryMem is the memory used by one stream entry.
+func entryMem(ent Entry) int64 {
+	size := int64(streamEntryHeader)
+	for _, kv := range ent.KVs {
+		size += listpackEntrySize(kv.Key) + listpackEntrySize(kv.Value)
+	}
+	return size
+}
+
+// computeElemsMem sums the memory of every element, used when the encoding changes.
+func (data *Data) computeElemsMem() int64 {
+	var size int64
+	switch data.Type {
+	case TypeHash:
+		data.hash.each(func(field, value string) bool {
+			size += data.elemMem(field, value)
+			return true
+		})
+	case TypeSet:
+		data.set.each(func(member string, _ struct{}) bool {
+			size += data.elemMem(member, "")
+			return true
+		})
+	case TypeZSet:
+		data.zset.each(func(member string, _ float64) bool {
+			size += data.elemMem(member, "")
+			return true
+		})
+	case TypeList:
+		for _, e := range data.list {
+			size += data.elemMem(e, "")
+		}
+	case TypeStream:
+		for _, ent := range data.Entries {
+			size += entryMem(ent)
+		}
+	case TypeBloom:
+		size = data.bloom.size()
+	case TypeCuckoo:
+		size = data.cuckoo.size()
+	case TypeCMS:
+		size = data.cms.size()
+	case TypeTopK:
+		size = data.topk.size()
+	case TypeJSON:
+		size = jsonMem(data.doc)
+	}
+	return size
+}
+
+// setKey stores data at key, replacing the previous value, and updates the memory accounting and the expires index.
+// caller should hold the write lock.
+func (d *DB) setKey(key string, data *Data) {
+	if old, ok := d.datas.get(key); ok {
+		d.used.Add(-keyMem(key, old))
+	}
+	d.datas.set(key, data)
+	d.used.Add(keyMem(key, data))
+	if data.ExpireTimestampMS != NO_EXPIRY {
+		d.expires.set(key, struct{}{})
+	} else {
+		d.expires.delete(key)
+	}
+}
+
+// deleteKey removes key and returns its data, false if the key does not exist.
+// caller should hold the write lock.
+func (d *DB) deleteKey(key string) (*Data, bool) {
+	data, ok := d.datas.get(key)
+	if !ok {
+		return nil, false
+	}
+	d.datas.delete(key)
+	d.expires.delete(key)
+	d.used.Add(-keyMem(key, data))
+	return data, true
+}
+
+// updateKey runs fn which modifies data in place and accounts the memory difference.
+// caller should hold the write lock.
+func (d *DB) updateKey(data *Data, fn func()) {
+	before := data.mem()
+	fn()
+	d.used.Add(data.mem() - before)
+}
+
+// UsedMemory returns the estimated memory used by the keys of the db.
+func (d *DB) UsedMemory() int64 {
+	return d.used.Load()
+}
+
+// DefaultMemorySamples is the number of elements sampled by MEMORY USAGE, same as redis.
+const DefaultMemorySamples = 5
+
+// MemoryUsage returns the memory used by key and its value, the elements of large collections are sampled.
+func (d *DB) MemoryUsage(key string, samples int) (int64, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	data, ok := d.peek(key)
+	if !ok {
+		return 0, false
+	}
+	return dictEntrySize + sdsSize(len(key)) + data.sampledMem(samples), true
+}
+
+// Len returns the type of key and its length: the number of bytes of strings, of elements of collections,
+// of entries of streams, of items added to Bloom and Cuckoo filters, of counters of Count-Min sketches,
+// of items kept by Top-K, and the memory of JSON documents.
+func (d *DB) Len(key string) (string, int, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	data, ok := d.peek(key)
+	if !ok {
+		return "", 0, false
+	}
+	switch data.Type {
+	case TypeString:
+		return data.Type, data.strLen(), true
+	case TypeBloom:
+		return data.Type, int(data.bloom.items), true
+	case TypeCuckoo:
+		return data.Type, int(data.cuckoo.items), true
+	case TypeCMS:
+		return data.Type, len(data.cms.counters), true
+	case TypeTopK:
+		return data.Type, len(data.topk.list()), true
+	case TypeJSON:
+		return data.Type, int(jsonMem(data.doc)), true
+	}
+	return data.Type, data.freeEffort(), true
+}
+
+// MemoryStats is the memory breakdown of a db reported by MEMORY STATS.
+type MemoryStats struct {
+	Keys            int
+	Expires         int
+	Used            int64 // memory of the keys, including the entries of the hash tables
+	OverheadMain    int64 // hash table of the keyspace
+	OverheadExpires int64 // hash table of the keys with an expire
+}
+
+// Dataset is the memory of the keys and values without the hash tables of the db.
+func (st MemoryStats) Dataset() int64 {
+	return st.Used - int64(st.Keys+st.Expires)*dictEntrySize
+}
+
+func dictOverhead[V any](dt *dict[V]) int64 {
+	return dictSize + int64(dt.buckets())*pointerSize + int64(dt.len())*dictEntrySize
+}
+
+func (d *DB) MemoryStats() MemoryStats {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return MemoryStats{
+		Keys:            d.datas.len(),
+		Expires:         d.expires.len(),
+		Used:            d.used.Load(),
+		OverheadMain:    dictOverhead(d.datas),
+		OverheadExpires: dictOverhead(d.expires),
+	}
+}
@@ -0,0 +1,80 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsedMemory(t *testing.T) {
+	db := NewDB()
+	require.Zero(t, db.UsedMemory())
+	db.Set("k", strings.Repeat("a", 100))
+	withString := db.UsedMemory()
+	require.Greater(t, withString, int64(100))
+
+	_, err := db.HSet("h", []KeyValue{{Key: "f", Value: "v"}})
+	require.NoError(t, err)
+	withHash := db.UsedMemory()
+	require.Greater(t, withHash, withString)
+	// converting to a hashtable costs more than the added field
+	kvs := []KeyValue{}
+	for i := 0; i < hashMaxListpackEntries; i++ {
+		kvs = append(kvs, KeyValue{Key: fmt.Sprintf("f%d", i), Value: "v"})
+	}
+	_, err = db.HSet("h", kvs)
+	require.NoError(t, err)
+	require.Greater(t, db.UsedMemory(), withHash)
+
+	// overwriting and deleting give back the memory
+	before := db.UsedMemory()
+	db.Set("k", "1")
+	require.Less(t, db.UsedMemory(), before)
+	db.Del([]string{"k", "h"}, false)
+	require.Zero(t, db.UsedMemory())
+
+	db.SetExp("e", "v", time.Now().Add(time.Hour).UnixMilli())
+	require.Equal(t, 1, db.expires.len())
+	db.Flush(false)
+	require.Zero(t, db.UsedMemory())
+	require.Equal(t, 0, db.expires.len())
+}
+
+func TestMemoryUsage(t *testing.T) {
+	db := NewDB()
+	_, ok := db.MemoryUsage("missing", DefaultMemorySamples)
+	require.False(t, ok)
+
+	db.Set("int", "12345")
+	db.Set("str", strings.Repeat("a", 100))
+	intUsage, _ := db.MemoryUsage("int", DefaultMemorySamples)
+	strUsage, _ := db.MemoryUsage("str", DefaultMemorySamples)
+	require.Greater(t, strUsage-intUsage, int64(100))
+
+	members := []string{}
+	for i := 0; i < 1000; i++ {
+		members = append(members, fmt.Sprintf("member:%d", i))
+	}
+	_, err := db.SAdd("set", members)
+	require.NoError(t, err)
+	exact, _ := db.MemoryUsage("set", 0)
+	sampled, _ := db.MemoryUsage("set", DefaultMemorySamples)
+	// the members have almost the same size so the estimation is close
+	require.InDelta(t, exact, sampled, float64(exact)/20)
+	// without sampling, the usage is the accounted memory of the key
+	require.Equal(t, db.UsedMemory()-intUsage-strUsage, exact)
+}
+
+func TestMemoryStats(t *testing.T) {
+	db := NewDB()
+	db.Set("k", "v")
+	db.SetExp("e", "v", time.Now().Add(time.Hour).UnixMilli())
+	st := db.MemoryStats()
+	require.Equal(t, 2, st.Keys)
+	require.Equal(t, 1, st.Expires)
+	require.Equal(t, st.Used-3*dictEntrySize, st.Dataset())
+	require.Greater(t, st.OverheadMain, st.OverheadExpires)
+}
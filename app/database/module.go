@@ -0,0 +1,221 @@
+package database
+
+import (
+	"container/heap"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// The types which are not native to redis are saved in RDB files like the values of redis modules:
+// a 64 bits id made of a 9 characters type name and an encoding version, followed by typed fields.
+// TYPE replies the names of RedisBloom and RedisJSON, but the fields are our own so the ids use distinct names:
+// a dump of the real modules is rejected as an unknown module type instead of being misparsed, and the other way around.
+// ref: https://github.com/redis/redis/blob/7.2.0/src/module.c#L6604
+const moduleTypeCharset = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_"
+
+// ModuleType identifies the encoding of a module value.
+type ModuleType struct {
+	Name   string
+	EncVer int
+}
+
+var (
+	BloomModuleType  = ModuleType{Name: "CCbloomBF", EncVer: 1}
+	CuckooModuleType = ModuleType{Name: "CCbloomCF", EncVer: 1}
+	CMSModuleType    = ModuleType{Name: "CCbloomCM", EncVer: 1}
+	TopKModuleType   = ModuleType{Name: "CCbloomTK", EncVer: 1}
+	JSONModuleType   = ModuleType{Name: "CCjson-JS", EncVer: 1}
+)
+
+// ID returns the id of the type saved in RDB files, 6 bits per character of the name then 10 bits of version.
+func (t ModuleType) ID() uint64 {
+	var id uint64
+	for i := 0; i < len(t.Name); i++ {
+		id = id<<6 | uint64(strings.IndexByte(moduleTypeCharset, t.Name[i]))
+	}
+	return id<<10 | uint64(t.EncVer&1023)
+}
+
+// ModuleTypeFromID is the inverse of ModuleType.ID.
+func ModuleTypeFromID(id uint64) ModuleType {
+	t := ModuleType{EncVer: int(id & 1023)}
+	id >>= 10
+	name := make([]byte, 9)
+	for i := len(name) - 1; i >= 0; i-- {
+		name[i] = moduleTypeCharset[id&63]
+		id >>= 6
+	}
+	t.Name = string(name)
+	return t
+}
+
+// ModuleValue returns the type of a module value and its fields, which are uint64, float64 or string,
+// ok is false for the native types.
+func (data *Data) ModuleValue() (ModuleType, []any, bool) {
+	switch data.Type {
+	case TypeBloom:
+		f := data.bloom
+		fields := []any{uint64(f.expansion), uint64(f.items), uint64(len(f.layers))}
+		for _, l := range f.layers {
+			fields = append(fields, uint64(l.capacity), uint64(l.items), l.errorRate, uint64(l.hashes), string(l.bits))
+		}
+		return BloomModuleType, fields, true
+	case TypeCuckoo:
+		f := data.cuckoo
+		fields := []any{f.numBuckets, uint64(f.bucketSize), uint64(f.maxIterations), uint64(f.expansion),
+			uint64(f.items), uint64(f.deletes), uint64(len(f.filters))}
+		for _, filter := range f.filters {
+			fields = append(fields, string(filter))
+		}
+		return CuckooModuleType, fields, true
+	case TypeCMS:
+		s := data.cms
+		counters := make([]byte, 0, len(s.counters)*4)
+		for _, c := range s.counters {
+			counters = binary.LittleEndian.AppendUint32(counters, c)
+		}
+		return CMSModuleType, []any{s.width, s.depth, s.count, string(counters)}, true
+	case TypeTopK:
+		t := data.topk
+		buckets := make([]byte, 0, len(t.buckets)*8)
+		for _, b := range t.buckets {
+			buckets = binary.LittleEndian.AppendUint32(binary.LittleEndian.AppendUint32(buckets, b.fp), b.count)
+		}
+		fields := []any{t.width, t.depth, t.decay, t.rand, string(buckets), uint64(len(t.heap))}
+		for _, e := range t.heap {
+			fields = append(fields, e.Item, uint64(e.Count))
+		}
+		return TopKModuleType, fields, true
+	case TypeJSON:
+		return JSONModuleType, []any{formatJSON(data.doc, JSONFormat{})}, true
+	}
+	return ModuleType{}, nil, false
+}
+
+// moduleFields reads the fields of a module value in order, the first error is kept.
+type moduleFields struct {
+	fields []any
+	err    error
+}
+
+func (r *moduleFields) next() any {
+	if r.err != nil {
+		return nil
+	}
+	if len(r.fields) == 0 {
+		r.err = fmt.Errorf("missing module value field")
+		return nil
+	}
+	v := r.fields[0]
+	r.fields = r.fields[1:]
+	return v
+}
+
+func (r *moduleFields) uint() uint64 {
+	v, ok := r.next().(uint64)
+	if !ok && r.err == nil {
+		r.err = fmt.Errorf("module value field is not an unsigned integer")
+	}
+	return v
+}
+
+func (r *moduleFields) double() float64 {
+	v, ok := r.next().(float64)
+	if !ok && r.err == nil {
+		r.err = fmt.Errorf("module value field is not a double")
+	}
+	return v
+}
+
+func (r *moduleFields) string() string {
+	v, ok := r.next().(string)
+	if !ok && r.err == nil {
+		r.err = fmt.Errorf("module value field is not a string")
+	}
+	return v
+}
+
+// NewModuleValue is the inverse of Data.ModuleValue, for loading RDB files.
+func NewModuleValue(t ModuleType, fields []any, expireTimestampMS uint64) (*Data, error) {
+	r := &moduleFields{fields: fields}
+	var data *Data
+	switch t {
+	case BloomModuleType:
+		data = newData(TypeBloom)
+		f := &bloomFilter{expansion: int(r.uint()), items: int64(r.uint())}
+		for n := r.uint(); n > 0 && r.err == nil; n-- {
+			l := &bloomLayer{capacity: int64(r.uint()), items: int64(r.uint()), errorRate: r.double(), hashes: int(r.uint())}
+			l.bits = []byte(r.string())
+			l.nbits = uint64(len(l.bits)) * 8
+			if l.nbits == 0 && r.err == nil {
+				r.err = fmt.Errorf("empty bloom filter")
+			}
+			f.layers = append(f.layers, l)
+		}
+		if len(f.layers) == 0 && r.err == nil {
+			r.err = fmt.Errorf("no bloom filter")
+		}
+		data.bloom = f
+	case CuckooModuleType:
+		data = newData(TypeCuckoo)
+		f := &cuckooFilter{numBuckets: r.uint(), bucketSize: int(r.uint()), maxIterations: int(r.uint()), expansion: int(r.uint()),
+			items: int64(r.uint()), deletes: int64(r.uint())}
+		for n := r.uint(); n > 0 && r.err == nil; n-- {
+			filter := []uint8(r.string())
+			if f.bucketSize == 0 || len(filter) == 0 || len(filter)%f.bucketSize != 0 {
+				r.err = fmt.Errorf("invalid cuckoo filter size")
+			}
+			f.filters = append(f.filters, filter)
+		}
+		if len(f.filters) == 0 && r.err == nil {
+			r.err = fmt.Errorf("no cuckoo filter")
+		}
+		data.cuckoo = f
+	case CMSModuleType:
+		data = newData(TypeCMS)
+		s := &countMinSketch{width: r.uint(), depth: r.uint(), count: r.uint()}
+		counters := r.string()
+		if size, ok := CMSSize(s.width, s.depth); r.err == nil && (s.width == 0 || s.depth == 0 || !ok || int64(len(counters)) != size) {
+			r.err = fmt.Errorf("invalid count-min sketch size")
+		}
+		for i := 0; i+4 <= len(counters) && r.err == nil; i += 4 {
+			s.counters = append(s.counters, binary.LittleEndian.Uint32([]byte(counters[i:i+4])))
+		}
+		data.cms = s
+	case TopKModuleType:
+		data = newData(TypeTopK)
+		t := &topK{width: r.uint(), depth: r.uint(), decay: r.double(), rand: r.uint()}
+		buckets := r.string()
+		if size, ok := TopKSize(0, t.width, t.depth); r.err == nil && (t.width == 0 || t.depth == 0 || !ok || int64(len(buckets)) != size) {
+			r.err = fmt.Errorf("invalid top-k size")
+		}
+		for i := 0; i+8 <= len(buckets) && r.err == nil; i += 8 {
+			b := []byte(buckets[i : i+8])
+			t.buckets = append(t.buckets, topKBucket{fp: binary.LittleEndian.Uint32(b), count: binary.LittleEndian.Uint32(b[4:])})
+		}
+		for n := r.uint(); n > 0 && r.err == nil; n-- {
+			t.heap = append(t.heap, TopKItem{Item: r.string(), Count: uint32(r.uint())})
+		}
+		if len(t.heap) == 0 && r.err == nil {
+			r.err = fmt.Errorf("empty top-k heap")
+		}
+		heap.Init(&t.heap)
+		data.topk = t
+	case JSONModuleType:
+		data = newData(TypeJSON)
+		if doc := r.string(); r.err == nil {
+			data.doc, r.err = parseJSON(doc)
+		}
+	default:
+		return nil, fmt.Errorf("unknown module type %s version %d", t.Name, t.EncVer)
+	}
+	if r.err == nil && len(r.fields) > 0 {
+		r.err = fmt.Errorf("unexpected module value fields")
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("invalid %s value: %w", t.Name, r.err)
+	}
+	data.ExpireTimestampMS = expireTimestampMS
+	return data, nil
+}
@@ -0,0 +1,72 @@
+package database
+
+import (
+	"math/rand"
+	"time"
+)
+
+// LFU counter parameters, same as the default configuration of redis.
+// ref: https://github.com/redis/redis/blob/7.2.0/src/evict.c#L260
+const (
+	lfuInitVal      = 5
+	lfuLogFactor    = 10
+	lfuDecayMinutes = 1
+)
+
+// touch records an access to the data.
+func (data *Data) touch(now time.Time) {
+	data.lastAccessMS = now.UnixMilli()
+	data.lfuCounter = lfuLogIncr(data.lfuDecr(now))
+	data.lfuDecrMinutes = now.Unix() / 60
+}
+
+// lfuLogIncr increments the counter with a probability decreasing with the counter,
+// so that 255 is only reached after about a million accesses.
+func lfuLogIncr(counter uint8) uint8 {
+	if counter == 255 {
+		return counter
+	}
+	baseval := max(float64(counter)-lfuInitVal, 0)
+	if rand.Float64() < 1.0/(baseval*lfuLogFactor+1) {
+		counter++
+	}
+	return counter
+}
+
+// lfuDecr returns the counter decremented by one for every decay period elapsed since the last decrement,
+// so that keys which were accessed a lot in the past but not anymore can be evicted.
+func (data *Data) lfuDecr(now time.Time) uint8 {
+	periods := (now.Unix()/60 - data.lfuDecrMinutes) / lfuDecayMinutes
+	if periods <= 0 {
+		return data.lfuCounter
+	}
+	if periods >= int64(data.lfuCounter) {
+		return 0
+	}
+	return data.lfuCounter - uint8(periods)
+}
+
+// ObjectInfo is the metadata of a key reported by OBJECT and DEBUG OBJECT.
+type ObjectInfo struct {
+	Encoding     string
+	Idle         time.Duration // time since the last access
+	LastAccessMS int64
+	Freq         int // LFU counter
+}
+
+// Object returns the metadata of key without recording an access.
+func (d *DB) Object(key string) (ObjectInfo, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	data, ok := d.peek(key)
+	if !ok {
+		return ObjectInfo{}, false
+	}
+	now := time.Now()
+	return ObjectInfo{
+		Encoding:     data.Encoding(),
+		Idle:         now.Sub(time.UnixMilli(data.lastAccessMS)),
+		LastAccessMS: data.lastAccessMS,
+		Freq:         int(data.lfuDecr(now)),
+	}, true
+}
@@ -0,0 +1,77 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func encodingOf(t *testing.T, db *DB, key string) string {
+	info, ok := db.Object(key)
+	require.True(t, ok)
+	return info.Encoding
+}
+
+func TestEncoding(t *testing.T) {
+	db := NewDB()
+	db.Set("int", "12345")
+	db.Set("zeros", "012")
+	db.Set("embstr", "hello")
+	db.Set("raw", strings.Repeat("a", embstrSizeLimit+1))
+	require.Equal(t, EncodingInt, encodingOf(t, db, "int"))
+	require.Equal(t, EncodingEmbstr, encodingOf(t, db, "zeros"))
+	require.Equal(t, EncodingEmbstr, encodingOf(t, db, "embstr"))
+	require.Equal(t, EncodingRaw, encodingOf(t, db, "raw"))
+
+	_, err := db.SAdd("set", []string{"1", "2"})
+	require.NoError(t, err)
+	require.Equal(t, EncodingIntset, encodingOf(t, db, "set"))
+	_, err = db.SAdd("set", []string{"a"})
+	require.NoError(t, err)
+	require.Equal(t, EncodingListpack, encodingOf(t, db, "set"))
+	_, err = db.SAdd("set", []string{strings.Repeat("a", setMaxListpackValue+1)})
+	require.NoError(t, err)
+	require.Equal(t, EncodingHashtable, encodingOf(t, db, "set"))
+
+	_, err = db.HSet("hash", []KeyValue{{Key: "f", Value: "v"}})
+	require.NoError(t, err)
+	require.Equal(t, EncodingListpack, encodingOf(t, db, "hash"))
+	for i := 0; i <= hashMaxListpackEntries; i++ {
+		_, err = db.HSet("hash", []KeyValue{{Key: fmt.Sprintf("f%d", i), Value: "v"}})
+		require.NoError(t, err)
+	}
+	require.Equal(t, EncodingHashtable, encodingOf(t, db, "hash"))
+
+	_, err = db.ZAdd("zset", []ZMember{{Member: strings.Repeat("a", zsetMaxListpackValue+1), Score: 1}})
+	require.NoError(t, err)
+	require.Equal(t, EncodingSkiplist, encodingOf(t, db, "zset"))
+}
+
+func TestAccessMetadata(t *testing.T) {
+	db := NewDB()
+	db.Set("k", "v")
+	data, _ := db.datas.get("k")
+	data.lastAccessMS = time.Now().Add(-10 * time.Second).UnixMilli()
+	data.lfuDecrMinutes -= 3
+
+	info, ok := db.Object("k")
+	require.True(t, ok)
+	require.GreaterOrEqual(t, info.Idle, 10*time.Second)
+	require.Equal(t, lfuInitVal-3, info.Freq, "decayed once per minute")
+
+	// OBJECT does not count as an access
+	info, _ = db.Object("k")
+	require.GreaterOrEqual(t, info.Idle, 10*time.Second)
+
+	db.Get("k")
+	info, _ = db.Object("k")
+	require.Less(t, info.Idle, time.Second)
+	for i := 0; i < 1000; i++ {
+		db.Get("k")
+	}
+	info, _ = db.Object("k")
+	require.Greater(t, info.Freq, lfuInitVal)
+}
@@ -0,0 +1,127 @@
+package database
+
+import (
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/glob"
+)
+
+const DefaultScanCount = 10
+
+// ScanOptions are the filters of SCAN family commands.
+type ScanOptions struct {
+	Count int    // hint of the number of elements to visit in one call
+	Match string // glob-style pattern, empty to match all
+	Type  string // only used by SCAN, empty to match all types
+}
+
+func (o ScanOptions) match(key string) bool {
+	return o.Match == "" || glob.MatchAll(o.Match) || glob.Match(o.Match, key, false)
+}
+
+// scanDict calls dict.scan until roughly count entries are visited or the iteration is complete.
+// Like redis, it gives up after count*10 buckets so that a sparse table does not hold the lock for too long.
+// ref: https://github.com/redis/redis/blob/7.2.0/src/db.c#L1009
+func scanDict[V any](dt *dict[V], cursor uint64, count int, fn func(key string, val V)) uint64 {
+	if count <= 0 {
+		count = DefaultScanCount
+	}
+	visited := 0
+	maxIterations := count * 10
+	for {
+		cursor = dt.scan(cursor, func(key string, val V) {
+			fn(key, val)
+			visited++
+		})
+		maxIterations--
+		if cursor == 0 || visited >= count || maxIterations <= 0 {
+			return cursor
+		}
+	}
+}
+
+// Scan iterates the keyspace starting at cursor, it returns the next cursor and the matching keys.
+// The lock is only held for one call, so iterating a large keyspace does not block other clients.
+func (d *DB) Scan(cursor uint64, opts ScanOptions) (uint64, []string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := time.Now()
+	keys := []string{}
+	expired := []string{}
+	next := scanDict(d.datas, cursor, opts.Count, func(key string, data *Data) {
+		if data.expired(now) {
+			expired = append(expired, key)
+			return
+		}
+		if opts.Type != "" && data.Type != opts.Type {
+			return
+		}
+		if opts.match(key) {
+			keys = append(keys, key)
+		}
+	})
+	for _, key := range expired {
+		d.deleteKey(key)
+	}
+	return next, keys
+}
+
+// HScan iterates the fields of the hash stored at key.
+func (d *DB) HScan(key string, cursor uint64, opts ScanOptions) (uint64, []KeyValue, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	data, ok := d.lookup(key)
+	if !ok {
+		return 0, nil, nil
+	}
+	if data.Type != TypeHash {
+		return 0, nil, ErrWrongType
+	}
+	kvs := []KeyValue{}
+	next := scanDict(data.hash, cursor, opts.Count, func(field string, val string) {
+		if opts.match(field) {
+			kvs = append(kvs, KeyValue{Key: field, Value: val})
+		}
+	})
+	return next, kvs, nil
+}
+
+// SScan iterates the members of the set stored at key.
+func (d *DB) SScan(key string, cursor uint64, opts ScanOptions) (uint64, []string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	data, ok := d.lookup(key)
+	if !ok {
+		return 0, nil, nil
+	}
+	if data.Type != TypeSet {
+		return 0, nil, ErrWrongType
+	}
+	members := []string{}
+	next := scanDict(data.set, cursor, opts.Count, func(member string, _ struct{}) {
+		if opts.match(member) {
+			members = append(members, member)
+		}
+	})
+	return next, members, nil
+}
+
+// ZScan iterates the members of the sorted set stored at key.
+func (d *DB) ZScan(key string, cursor uint64, opts ScanOptions) (uint64, []ZMember, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	data, ok := d.lookup(key)
+	if !ok {
+		return 0, nil, nil
+	}
+	if data.Type != TypeZSet {
+		return 0, nil, ErrWrongType
+	}
+	members := []ZMember{}
+	next := scanDict(data.zset, cursor, opts.Count, func(member string, score float64) {
+		if opts.match(member) {
+			members = append(members, ZMember{Member: member, Score: score})
+		}
+	})
+	return next, members, nil
+}
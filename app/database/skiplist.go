@@ -0,0 +1,129 @@
+package database
+
+import (
+	"math/rand"
+	"strings"
+)
+
+const (
+	skiplistMaxLevel = 32
+	skiplistP        = 0.25
+)
+
+// skiplist orders the members of a sorted set by score, then lexicographically, so that score ranges can be
+// read without scanning the whole set. The dict of the sorted set stays the index by member.
+// ref: https://github.com/redis/redis/blob/7.2.0/src/t_zset.c
+type skiplist struct {
+	head   *skiplistNode
+	level  int
+	length int
+}
+
+type skiplistNode struct {
+	member string
+	score  float64
+	next   []*skiplistNode
+}
+
+func newSkiplist() *skiplist {
+	return &skiplist{head: &skiplistNode{next: make([]*skiplistNode, skiplistMaxLevel)}, level: 1}
+}
+
+func randomSkiplistLevel() int {
+	level := 1
+	for level < skiplistMaxLevel && rand.Float64() < skiplistP {
+		level++
+	}
+	return level
+}
+
+// before reports whether the node is ordered before the score and member.
+func (n *skiplistNode) before(score float64, member string) bool {
+	return n.score < score || n.score == score && strings.Compare(n.member, member) < 0
+}
+
+// insert adds the member, which must not be in the skiplist.
+func (sl *skiplist) insert(score float64, member string) {
+	var update [skiplistMaxLevel]*skiplistNode
+	x := sl.head
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.next[i] != nil && x.next[i].before(score, member) {
+			x = x.next[i]
+		}
+		update[i] = x
+	}
+	level := randomSkiplistLevel()
+	for i := sl.level; i < level; i++ {
+		update[i] = sl.head
+	}
+	sl.level = max(sl.level, level)
+	n := &skiplistNode{member: member, score: score, next: make([]*skiplistNode, level)}
+	for i := 0; i < level; i++ {
+		n.next[i] = update[i].next[i]
+		update[i].next[i] = n
+	}
+	sl.length++
+}
+
+// delete removes the member with the score, it returns false if it is not found.
+func (sl *skiplist) delete(score float64, member string) bool {
+	var update [skiplistMaxLevel]*skiplistNode
+	x := sl.head
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.next[i] != nil && x.next[i].before(score, member) {
+			x = x.next[i]
+		}
+		update[i] = x
+	}
+	x = x.next[0]
+	if x == nil || x.score != score || x.member != member {
+		return false
+	}
+	for i := 0; i < sl.level && update[i].next[i] == x; i++ {
+		update[i].next[i] = x.next[i]
+	}
+	for sl.level > 1 && sl.head.next[sl.level-1] == nil {
+		sl.level--
+	}
+	sl.length--
+	return true
+}
+
+// rangeByScore calls fn with the members whose score is in [min, max) by ascending order until fn returns false.
+func (sl *skiplist) rangeByScore(min, max float64, fn func(member string, score float64) bool) {
+	x := sl.head
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.next[i] != nil && x.next[i].score < min {
+			x = x.next[i]
+		}
+	}
+	for x = x.next[0]; x != nil && x.score < max; x = x.next[0] {
+		if !fn(x.member, x.score) {
+			return
+		}
+	}
+}
+
+// each calls fn with every member by ascending order until fn returns false.
+func (sl *skiplist) each(fn func(member string, score float64) bool) {
+	for x := sl.head.next[0]; x != nil; x = x.next[0] {
+		if !fn(x.member, x.score) {
+			return
+		}
+	}
+}
+
+// zsetSet sets the score of the member of the sorted set in both its dict and its skiplist,
+// it returns true if the member was added.
+func (data *Data) zsetSet(member string, score float64) bool {
+	old, exists := data.zset.get(member)
+	if exists {
+		if old == score {
+			return false
+		}
+		data.zsl.delete(old, member)
+	}
+	data.zset.set(member, score)
+	data.zsl.insert(score, member)
+	return !exists
+}
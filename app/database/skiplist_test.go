@@ -0,0 +1,47 @@
+package database
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSkiplist(t *testing.T) {
+	sl := newSkiplist()
+	for i := 0; i < 100; i++ {
+		sl.insert(float64(i%10), fmt.Sprintf("m%02d", i))
+	}
+	require.Equal(t, 100, sl.length)
+	require.True(t, sl.delete(3, "m13"))
+	require.False(t, sl.delete(4, "m13"))
+	require.Equal(t, 99, sl.length)
+
+	var got []string
+	sl.rangeByScore(3, 4, func(member string, score float64) bool {
+		got = append(got, member)
+		return true
+	})
+	require.Equal(t, []string{"m03", "m23", "m33", "m43", "m53", "m63", "m73", "m83", "m93"}, got)
+
+	prev := ZMember{Score: -1}
+	sl.each(func(member string, score float64) bool {
+		require.True(t, prev.Score < score || prev.Score == score && prev.Member < member)
+		prev = ZMember{Member: member, Score: score}
+		return true
+	})
+}
+
+func TestZSetSet(t *testing.T) {
+	data := newData(TypeZSet)
+	require.True(t, data.zsetSet("a", 2))
+	require.True(t, data.zsetSet("b", 1))
+	require.False(t, data.zsetSet("a", 0))
+	var got []string
+	data.zsl.each(func(member string, _ float64) bool {
+		got = append(got, member)
+		return true
+	})
+	require.Equal(t, []string{"a", "b"}, got)
+	require.Equal(t, 2, data.zsl.length)
+}
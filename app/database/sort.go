@@ -0,0 +1,235 @@
+package database
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var ErrSortNotDouble = errors.New("One or more scores can't be converted into double")
+
+// SortOptions are the options of SORT.
+type SortOptions struct {
+	By     string   // pattern of the keys holding the weights, empty to sort by the elements
+	Get    []string // patterns of the keys holding the values returned instead of the elements, "#" for the element itself
+	Desc   bool
+	Alpha  bool // sort lexicographically instead of numerically
+	Offset int
+	Count  int // number of elements to return, negative for all
+}
+
+// noSort reports whether the BY pattern does not reference the element, in which case sorting is skipped.
+func (o SortOptions) noSort() bool {
+	return o.By != "" && !strings.Contains(o.By, "*")
+}
+
+type sortItem struct {
+	elem   string
+	score  float64
+	cmpObj *string // weight for ALPHA sorting by pattern, nil if the key does not exist
+}
+
+// Sort returns the elements of the list, set or sorted set stored at key sorted, or the values of the GET patterns.
+// A nil value means the key of a GET pattern does not exist.
+// ref: https://github.com/redis/redis/blob/7.2.0/src/sort.c#L190
+func (d *DB) Sort(key string, opts SortOptions) ([]*string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.sort(key, opts, false)
+}
+
+// SortStore is like Sort but stores the result as a list at dst, it returns the length of the list.
+// dst is deleted if the result is empty.
+func (d *DB) SortStore(key, dst string, opts SortOptions) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	res, err := d.sort(key, opts, true)
+	if err != nil {
+		return 0, err
+	}
+	if len(res) == 0 {
+		d.deleteKey(dst)
+		return 0, nil
+	}
+	data := newData(TypeList)
+	data.list = make([]string, len(res))
+	for i, v := range res {
+		if v != nil {
+			data.list[i] = *v
+		}
+	}
+	data.listAdded(data.list)
+	d.setKey(dst, data)
+	return len(res), nil
+}
+
+// sort is called with the write lock held, store makes the result deterministic since it is replicated.
+func (d *DB) sort(key string, opts SortOptions, store bool) ([]*string, error) {
+	elems, typ, err := d.sortElements(key)
+	if err != nil {
+		return nil, err
+	}
+	dontSort := opts.noSort()
+	alpha := opts.Alpha
+	by := opts.By
+	// the order of a set is random, sort it anyway when the result is stored so that the replicas get the same list
+	if dontSort && typ == TypeSet && store {
+		dontSort, alpha, by = false, true, ""
+	}
+	// lists and sorted sets keep their order when not sorted, DESC reverses it
+	if dontSort && opts.Desc && typ != TypeSet {
+		slices.Reverse(elems)
+	}
+
+	start := max(opts.Offset, 0)
+	end := len(elems) - 1
+	if opts.Count >= 0 {
+		end = start + opts.Count - 1
+	}
+	if start >= len(elems) {
+		start, end = len(elems)-1, len(elems)-2
+	}
+	end = min(end, len(elems)-1)
+
+	if !dontSort {
+		items := make([]sortItem, len(elems))
+		for i, elem := range elems {
+			items[i].elem = elem
+			weight := &items[i].elem
+			if by != "" {
+				weight = d.lookupByPattern(by, elem)
+			}
+			if alpha {
+				if by != "" {
+					items[i].cmpObj = weight
+				}
+				continue
+			}
+			if weight == nil {
+				continue
+			}
+			score, err := strconv.ParseFloat(strings.TrimLeft(*weight, " \t\n\v\f\r"), 64)
+			if err != nil || math.IsNaN(score) {
+				return nil, ErrSortNotDouble
+			}
+			items[i].score = score
+		}
+		slices.SortStableFunc(items, func(a, b sortItem) int {
+			cmp := compareSortItems(a, b, alpha, by != "")
+			if opts.Desc {
+				return -cmp
+			}
+			return cmp
+		})
+		for i := range items {
+			elems[i] = items[i].elem
+		}
+	}
+
+	res := make([]*string, 0, max(end-start+1, 0)*max(len(opts.Get), 1))
+	for i := start; i <= end; i++ {
+		elem := elems[i]
+		if len(opts.Get) == 0 {
+			res = append(res, &elem)
+			continue
+		}
+		for _, pattern := range opts.Get {
+			res = append(res, d.lookupByPattern(pattern, elem))
+		}
+	}
+	return res, nil
+}
+
+func compareSortItems(a, b sortItem, alpha, byPattern bool) int {
+	if !alpha {
+		if a.score != b.score {
+			if a.score < b.score {
+				return -1
+			}
+			return 1
+		}
+		// same score, compare the elements so that the result is deterministic
+		return strings.Compare(a.elem, b.elem)
+	}
+	if !byPattern {
+		return strings.Compare(a.elem, b.elem)
+	}
+	switch {
+	case a.cmpObj == nil && b.cmpObj == nil:
+		return 0
+	case a.cmpObj == nil:
+		return -1
+	case b.cmpObj == nil:
+		return 1
+	}
+	return strings.Compare(*a.cmpObj, *b.cmpObj)
+}
+
+// sortElements returns a copy of the elements of the list, set or sorted set stored at key,
+// sorted sets are returned by ascending score.
+func (d *DB) sortElements(key string) ([]string, string, error) {
+	data, ok := d.lookup(key)
+	if !ok {
+		return []string{}, "", nil
+	}
+	switch data.Type {
+	case TypeList:
+		return slices.Clone(data.list), data.Type, nil
+	case TypeSet:
+		elems := make([]string, 0, data.set.len())
+		data.set.each(func(member string, _ struct{}) bool {
+			elems = append(elems, member)
+			return true
+		})
+		return elems, data.Type, nil
+	case TypeZSet:
+		elems := make([]string, 0, data.zset.len())
+		data.zsl.each(func(member string, _ float64) bool {
+			elems = append(elems, member)
+			return true
+		})
+		return elems, data.Type, nil
+	}
+	return nil, "", ErrWrongType
+}
+
+// lookupByPattern returns the value of the key obtained by replacing the first '*' of pattern with subst.
+// "key->field" patterns return a field of a hash, "#" returns subst itself.
+// nil is returned if the pattern has no '*', or the key does not exist or has the wrong type.
+// ref: https://github.com/redis/redis/blob/7.2.0/src/sort.c#L59
+func (d *DB) lookupByPattern(pattern, subst string) *string {
+	if pattern == "#" {
+		return &subst
+	}
+	star := strings.IndexByte(pattern, '*')
+	if star < 0 {
+		return nil
+	}
+	keyPattern, field := pattern, ""
+	if arrow := strings.Index(pattern[star+1:], "->"); arrow >= 0 && star+1+arrow+2 < len(pattern) {
+		keyPattern = pattern[:star+1+arrow]
+		field = pattern[star+1+arrow+2:]
+	}
+	key := keyPattern[:star] + subst + keyPattern[star+1:]
+	data, ok := d.lookup(key)
+	if !ok {
+		return nil
+	}
+	if field != "" {
+		if data.Type != TypeHash {
+			return nil
+		}
+		v, ok := data.hash.get(field)
+		if !ok {
+			return nil
+		}
+		return &v
+	}
+	if data.Type != TypeString {
+		return nil
+	}
+	v := data.str()
+	return &v
+}
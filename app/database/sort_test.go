@@ -0,0 +1,116 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func sortValues(t *testing.T, db *DB, key string, opts SortOptions) []any {
+	vals, err := db.Sort(key, opts)
+	require.NoError(t, err)
+	res := make([]any, len(vals))
+	for i, v := range vals {
+		if v != nil {
+			res[i] = *v
+		}
+	}
+	return res
+}
+
+func TestSort(t *testing.T) {
+	db := NewDB()
+	_, err := db.RPush("list", []string{"3", "10", "1", "2"})
+	require.NoError(t, err)
+	all := SortOptions{Count: -1}
+	require.Equal(t, []any{"1", "2", "3", "10"}, sortValues(t, db, "list", all))
+	require.Equal(t, []any{"10", "3", "2", "1"}, sortValues(t, db, "list", SortOptions{Count: -1, Desc: true}))
+	require.Equal(t, []any{"1", "10", "2", "3"}, sortValues(t, db, "list", SortOptions{Count: -1, Alpha: true}))
+	require.Equal(t, []any{"2", "3"}, sortValues(t, db, "list", SortOptions{Offset: 1, Count: 2}))
+	require.Equal(t, []any{}, sortValues(t, db, "list", SortOptions{Offset: 10, Count: 2}))
+	require.Equal(t, []any{}, sortValues(t, db, "missing", all))
+
+	// BY without '*' keeps the order of the list
+	require.Equal(t, []any{"3", "10", "1", "2"}, sortValues(t, db, "list", SortOptions{Count: -1, By: "nosort"}))
+	require.Equal(t, []any{"2", "1", "10", "3"}, sortValues(t, db, "list", SortOptions{Count: -1, By: "nosort", Desc: true}))
+
+	_, err = db.RPush("alpha", []string{"b", "a"})
+	require.NoError(t, err)
+	_, err = db.Sort("alpha", all)
+	require.ErrorIs(t, err, ErrSortNotDouble)
+	db.Set("h", "v")
+	_, err = db.Sort("h", all)
+	require.ErrorIs(t, err, ErrWrongType)
+}
+
+func TestSortByAndGet(t *testing.T) {
+	db := NewDB()
+	_, err := db.SAdd("users", []string{"1", "2", "3"})
+	require.NoError(t, err)
+	db.Set("weight_1", "30")
+	db.Set("weight_2", "10")
+	// weight_3 is missing and sorts as 0
+	db.Set("name_1", "alice")
+	db.Set("name_2", "bob")
+	_, err = db.HSet("user_3", []KeyValue{{Key: "age", Value: "5"}, {Key: "name", Value: "carol"}})
+	require.NoError(t, err)
+	_, err = db.HSet("user_1", []KeyValue{{Key: "age", Value: "50"}})
+	require.NoError(t, err)
+
+	require.Equal(t, []any{"3", "2", "1"}, sortValues(t, db, "users", SortOptions{Count: -1, By: "weight_*"}))
+	require.Equal(t, []any{"3", nil, "2", "bob", "1", "alice"},
+		sortValues(t, db, "users", SortOptions{Count: -1, By: "weight_*", Get: []string{"#", "name_*"}}))
+	// hash fields, the missing user_2 sorts first
+	require.Equal(t, []any{nil, "carol", nil}, sortValues(t, db, "users", SortOptions{Count: -1, By: "user_*->age", Get: []string{"user_*->name"}}))
+	require.Equal(t, []any{"1", "3", "2"}, sortValues(t, db, "users", SortOptions{Count: -1, By: "user_*->age", Desc: true}))
+	// missing keys sort first with ALPHA
+	require.Equal(t, []any{"3", "1", "2"}, sortValues(t, db, "users", SortOptions{Count: -1, By: "name_*", Alpha: true}))
+}
+
+func TestSortStore(t *testing.T) {
+	db := NewDB()
+	_, err := db.ZAdd("z", []ZMember{{Member: "c", Score: 1}, {Member: "a", Score: 3}, {Member: "b", Score: 2}})
+	require.NoError(t, err)
+	// sorted sets are in score order when not sorted
+	require.Equal(t, []any{"c", "b", "a"}, sortValues(t, db, "z", SortOptions{Count: -1, By: "nosort"}))
+
+	_, err = db.SAdd("s", []string{"b", "c", "a"})
+	require.NoError(t, err)
+	// sets are sorted anyway when stored
+	n, err := db.SortStore("s", "dst", SortOptions{Count: -1, By: "nosort"})
+	require.NoError(t, err)
+	require.Equal(t, 3, n)
+	elems, err := db.LRange("dst", 0, -1)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b", "c"}, elems)
+
+	n, err = db.SortStore("missing", "dst", SortOptions{Count: -1})
+	require.NoError(t, err)
+	require.Equal(t, 0, n)
+	require.Equal(t, "", db.Type("dst"))
+}
+
+func TestList(t *testing.T) {
+	db := NewDB()
+	n, err := db.RPush("l", []string{"b", "c"})
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	n, err = db.LPush("l", []string{"a", "0"})
+	require.NoError(t, err)
+	require.Equal(t, 4, n)
+	elems, err := db.LRange("l", 1, -2)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b"}, elems)
+	elems, err = db.LRange("l", -100, 100)
+	require.NoError(t, err)
+	require.Equal(t, []string{"0", "a", "b", "c"}, elems)
+	require.Equal(t, EncodingListpack, encodingOf(t, db, "l"))
+
+	big := make([]string, 100)
+	for i := range big {
+		big[i] = string(make([]byte, 100))
+	}
+	_, err = db.RPush("l", big)
+	require.NoError(t, err)
+	require.Equal(t, EncodingQuicklist, encodingOf(t, db, "l"))
+}
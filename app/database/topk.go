@@ -0,0 +1,294 @@
+package database
+
+import (
+	"container/heap"
+	"errors"
+	"math"
+	"math/bits"
+	"sort"
+)
+
+// Top-K, like TOPK.* of RedisBloom: the HeavyKeeper algorithm, depth rows of width buckets holding a fingerprint
+// and its count, where the count of another fingerprint decays with probability decay^count, and a min heap of
+// the k items with the largest counts.
+// ref: https://github.com/RedisBloom/RedisBloom/blob/v2.6.3/src/topk.c
+const (
+	TypeTopK = "TopK-TYPE"
+
+	TopKDefaultWidth    = 8
+	TopKDefaultDepth    = 7
+	TopKDefaultDecay    = 0.9
+	topKFingerprintSeed = 1919 // same as the GA constant of RedisBloom
+	topKRandSeed        = 0x9e3779b97f4a7c15
+)
+
+var (
+	ErrTopKKeyExists   = errors.New("TopK: key already exists")
+	ErrTopKKeyNotFound = errors.New("TopK: key does not exist")
+	ErrTopKTooLarge    = errors.New("TopK: Insufficient memory to create the filter")
+)
+
+type topKBucket struct {
+	fp    uint32
+	count uint32
+}
+
+// TopKItem is an item of the heap and its estimated count.
+type TopKItem struct {
+	Item  string
+	Count uint32
+}
+
+// topKHeap is a min heap of the k items with the largest counts, the slots not used yet have an empty item.
+type topKHeap []TopKItem
+
+func (h topKHeap) Len() int           { return len(h) }
+func (h topKHeap) Less(i, j int) bool { return h[i].Count < h[j].Count }
+func (h topKHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *topKHeap) Push(x any)        { *h = append(*h, x.(TopKItem)) }
+func (h *topKHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+type topK struct {
+	buckets []topKBucket // depth rows of width buckets
+	heap    topKHeap
+	width   uint64
+	depth   uint64
+	decay   float64
+	// rand is the state of the generator deciding the decays. It is part of the value, rather than a global
+	// source, so that a replica applying the same commands ends with the same items.
+	rand uint64
+}
+
+// topKItemSize is the size of an empty slot of the heap.
+const topKItemSize = pointerSize + 16
+
+// TopKSize returns the size in bytes of the buckets and of the heap of an empty Top-K,
+// ok is false if it does not fit in an int64.
+func TopKSize(k, width, depth uint64) (int64, bool) {
+	hi, n := bits.Mul64(width, depth)
+	if hi != 0 || n > math.MaxInt64/8 || k > math.MaxInt64/topKItemSize {
+		return 0, false
+	}
+	buckets, items := int64(n)*8, int64(k)*topKItemSize
+	if buckets > math.MaxInt64-items {
+		return 0, false
+	}
+	return buckets + items, true
+}
+
+// newTopK creates the Top-K, its size must have been checked with TopKSize.
+func newTopK(k, width, depth uint64, decay float64) *topK {
+	return &topK{
+		buckets: make([]topKBucket, width*depth),
+		heap:    make(topKHeap, k),
+		width:   width,
+		depth:   depth,
+		decay:   decay,
+		rand:    topKRandSeed,
+	}
+}
+
+// random returns a float in [0, 1) with xorshift64*.
+func (t *topK) random() float64 {
+	t.rand ^= t.rand >> 12
+	t.rand ^= t.rand << 25
+	t.rand ^= t.rand >> 27
+	return float64((t.rand*0x2545f4914f6cdd1d)>>11) / (1 << 53)
+}
+
+func topKFingerprint(item string) uint32 {
+	return uint32(murmurHash64A(item, topKFingerprintSeed))
+}
+
+// find returns the index of the item in the heap, -1 if it is not in the heap.
+func (t *topK) find(item string) int {
+	for i, e := range t.heap {
+		if e.Count > 0 && e.Item == item {
+			return i
+		}
+	}
+	return -1
+}
+
+// incrBy adds incr occurrences of the item and returns the item expelled from the heap, nil if none.
+func (t *topK) incrBy(item string, incr uint32) *string {
+	fp := topKFingerprint(item)
+	var maxCount uint32
+	for row := uint64(0); row < t.depth; row++ {
+		b := &t.buckets[row*t.width+murmurHash64A(item, row)%t.width]
+		switch {
+		case b.count == 0:
+			b.fp, b.count = fp, incr
+		case b.fp == fp:
+			b.count += min(incr, math.MaxUint32-b.count)
+		default:
+			for i := uint32(0); i < incr; i++ {
+				if t.random() < math.Pow(t.decay, float64(b.count)) {
+					b.count--
+					if b.count == 0 {
+						b.fp, b.count = fp, incr-i
+						break
+					}
+				}
+			}
+		}
+		if b.fp == fp {
+			maxCount = max(maxCount, b.count)
+		}
+	}
+
+	if i := t.find(item); i >= 0 {
+		t.heap[i].Count = maxCount
+		heap.Fix(&t.heap, i)
+		return nil
+	}
+	if len(t.heap) == 0 || maxCount <= t.heap[0].Count {
+		return nil
+	}
+	expelled := t.heap[0]
+	t.heap[0] = TopKItem{Item: item, Count: maxCount}
+	heap.Fix(&t.heap, 0)
+	if expelled.Count == 0 {
+		return nil
+	}
+	return &expelled.Item
+}
+
+// list returns the items of the heap by decreasing count.
+func (t *topK) list() []TopKItem {
+	res := make([]TopKItem, 0, len(t.heap))
+	for _, e := range t.heap {
+		if e.Count > 0 {
+			res = append(res, e)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Count != res[j].Count {
+			return res[i].Count > res[j].Count
+		}
+		return res[i].Item < res[j].Item
+	})
+	return res
+}
+
+func (t *topK) size() int64 {
+	size := int64(len(t.buckets))*8 + int64(len(t.heap))*topKItemSize
+	for _, e := range t.heap {
+		size += int64(len(e.Item))
+	}
+	return size
+}
+
+func (t *topK) clone() *topK {
+	c := *t
+	c.buckets = append([]topKBucket(nil), t.buckets...)
+	c.heap = append(topKHeap(nil), t.heap...)
+	return &c
+}
+
+// TopKIncr is an increment of TOPK.INCRBY.
+type TopKIncr struct {
+	Item  string
+	Value uint32
+}
+
+// TopKInfo is the reply of TOPK.INFO.
+type TopKInfo struct {
+	K     int
+	Width uint64
+	Depth uint64
+	Decay float64
+}
+
+// lookupTopK returns the Top-K stored at key, ErrTopKKeyNotFound if the key does not exist.
+// caller should hold the write lock.
+func (d *DB) lookupTopK(key string) (*Data, error) {
+	data, ok := d.lookup(key)
+	if !ok {
+		return nil, ErrTopKKeyNotFound
+	}
+	if data.Type != TypeTopK {
+		return nil, ErrWrongType
+	}
+	return data, nil
+}
+
+// TopKReserve creates an empty Top-K of k items at key.
+// ErrTopKTooLarge is returned if it is larger than maxSize bytes.
+func (d *DB) TopKReserve(key string, k, width, depth uint64, decay float64, maxSize int64) error {
+	if size, ok := TopKSize(k, width, depth); !ok || size > maxSize {
+		return ErrTopKTooLarge
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.peek(key); ok {
+		return ErrTopKKeyExists
+	}
+	data := newData(TypeTopK)
+	data.topk = newTopK(k, width, depth, decay)
+	data.elemsMem = data.topk.size()
+	d.setKey(key, data)
+	return nil
+}
+
+// TopKIncrBy increments the counts of the items of the Top-K stored at key, and returns for every increment
+// the item expelled from the top k, nil if none.
+func (d *DB) TopKIncrBy(key string, incrs []TopKIncr) ([]*string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	data, err := d.lookupTopK(key)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*string, len(incrs))
+	d.updateKey(data, func() {
+		for i, incr := range incrs {
+			res[i] = data.topk.incrBy(incr.Item, incr.Value)
+		}
+		data.elemsMem = data.topk.size()
+	})
+	return res, nil
+}
+
+// TopKQuery returns for every item whether it is in the Top-K stored at key.
+func (d *DB) TopKQuery(key string, items []string) ([]bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	data, err := d.lookupTopK(key)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]bool, len(items))
+	for i, item := range items {
+		res[i] = data.topk.find(item) >= 0
+	}
+	return res, nil
+}
+
+// TopKList returns the items of the Top-K stored at key by decreasing count.
+func (d *DB) TopKList(key string) ([]TopKItem, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	data, err := d.lookupTopK(key)
+	if err != nil {
+		return nil, err
+	}
+	return data.topk.list(), nil
+}
+
+// TopKInfo returns the parameters of the Top-K stored at key.
+func (d *DB) TopKInfo(key string) (TopKInfo, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	data, err := d.lookupTopK(key)
+	if err != nil {
+		return TopKInfo{}, err
+	}
+	t := data.topk
+	return TopKInfo{K: len(t.heap), Width: t.width, Depth: t.depth, Decay: t.decay}, nil
+}
@@ -0,0 +1,115 @@
+package database
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTopK(t *testing.T) {
+	db := NewDB()
+	require.NoError(t, db.TopKReserve("topk", 3, 50, 4, 0.9, 1<<20))
+	require.ErrorIs(t, db.TopKReserve("topk", 3, 50, 4, 0.9, 1<<20), ErrTopKKeyExists)
+
+	// heavy items come back much more often than the noise
+	var incrs []TopKIncr
+	for i := 0; i < 200; i++ {
+		incrs = append(incrs, TopKIncr{"heavy1", 1}, TopKIncr{fmt.Sprint("noise:", i), 1})
+		if i%2 == 0 {
+			incrs = append(incrs, TopKIncr{"heavy2", 1})
+		}
+	}
+	incrs = append(incrs, TopKIncr{"heavy3", 500})
+	_, err := db.TopKIncrBy("topk", incrs)
+	require.NoError(t, err)
+
+	list, err := db.TopKList("topk")
+	require.NoError(t, err)
+	require.Len(t, list, 3)
+	require.Equal(t, []string{"heavy3", "heavy1", "heavy2"}, []string{list[0].Item, list[1].Item, list[2].Item})
+	require.EqualValues(t, 500, list[0].Count)
+	exists, err := db.TopKQuery("topk", []string{"heavy1", "noise:1"})
+	require.NoError(t, err)
+	require.Equal(t, []bool{true, false}, exists)
+
+	// a new heavy item expels the smallest one
+	expelled, err := db.TopKIncrBy("topk", []TopKIncr{{"heavy4", 1000}})
+	require.NoError(t, err)
+	require.Equal(t, "heavy2", *expelled[0])
+	expelled, err = db.TopKIncrBy("topk", []TopKIncr{{"heavy4", 1}})
+	require.NoError(t, err)
+	require.Nil(t, expelled[0])
+
+	info, err := db.TopKInfo("topk")
+	require.NoError(t, err)
+	require.Equal(t, TopKInfo{K: 3, Width: 50, Depth: 4, Decay: 0.9}, info)
+	_, err = db.TopKList("missing")
+	require.ErrorIs(t, err, ErrTopKKeyNotFound)
+}
+
+func TestTopKSizeLimit(t *testing.T) {
+	db := NewDB()
+	require.ErrorIs(t, db.TopKReserve("topk", math.MaxUint32, 8, 7, 0.9, 512<<20), ErrTopKTooLarge)
+	require.ErrorIs(t, db.TopKReserve("topk", 1, math.MaxUint32, math.MaxUint32, 0.9, 512<<20), ErrTopKTooLarge)
+	require.ErrorIs(t, db.TopKReserve("topk", math.MaxUint64, 1<<62, 4, 0.9, math.MaxInt64), ErrTopKTooLarge)
+	require.Equal(t, "", db.Type("topk"))
+}
+
+func TestTopKDeterministic(t *testing.T) {
+	// replicas applying the same commands end with the same items
+	var lists [2][]TopKItem
+	for i := range lists {
+		db := NewDB()
+		require.NoError(t, db.TopKReserve("topk", 5, 8, 3, 0.9, 1<<20))
+		for j := 0; j < 1000; j++ {
+			_, err := db.TopKIncrBy("topk", []TopKIncr{{fmt.Sprint("item:", j%37), uint32(j%5 + 1)}})
+			require.NoError(t, err)
+		}
+		var err error
+		lists[i], err = db.TopKList("topk")
+		require.NoError(t, err)
+	}
+	require.Equal(t, lists[0], lists[1])
+}
+
+func TestSketchModuleValues(t *testing.T) {
+	require.Equal(t, CMSModuleType, ModuleTypeFromID(CMSModuleType.ID()))
+	require.Equal(t, TopKModuleType, ModuleTypeFromID(TopKModuleType.ID()))
+
+	db := NewDB()
+	require.NoError(t, db.CMSInitByDim("cms", 100, 3, 1<<20))
+	_, err := db.CMSIncrBy("cms", []CMSIncr{{"a", 4}})
+	require.NoError(t, err)
+	require.NoError(t, db.TopKReserve("topk", 2, 8, 3, 0.9, 1<<20))
+	_, err = db.TopKIncrBy("topk", []TopKIncr{{"a", 3}, {"b", 1}, {"c", 2}})
+	require.NoError(t, err)
+	for key, data := range db.Snapshot() {
+		typ, fields, ok := data.ModuleValue()
+		require.True(t, ok)
+		loaded, err := NewModuleValue(typ, fields, NO_EXPIRY)
+		require.NoError(t, err)
+		require.Equal(t, data.Type, loaded.Type, key)
+		_, err = NewModuleValue(typ, fields[:len(fields)-1], NO_EXPIRY)
+		require.Error(t, err)
+	}
+
+	loaded := NewFromLoad(db.Snapshot())
+	counts, err := loaded.CMSQuery("cms", []string{"a"})
+	require.NoError(t, err)
+	require.Equal(t, []uint32{4}, counts)
+	list, err := loaded.TopKList("topk")
+	require.NoError(t, err)
+	require.Equal(t, []TopKItem{{"a", 3}, {"c", 2}}, list)
+	require.Equal(t, db.UsedMemory(), loaded.UsedMemory())
+
+	// the loaded Top-K goes on with the same decays
+	_, err = db.TopKIncrBy("topk", []TopKIncr{{"d", 10}})
+	require.NoError(t, err)
+	_, err = loaded.TopKIncrBy("topk", []TopKIncr{{"d", 10}})
+	require.NoError(t, err)
+	want, _ := db.TopKList("topk")
+	got, _ := loaded.TopKList("topk")
+	require.Equal(t, want, got)
+}
@@ -5,8 +5,10 @@ import "fmt"
 const (
 	TypeString = "string"
 	TypeStream = "stream"
-	// TypeList   = "list"
-	// ...
+	TypeHash   = "hash"
+	TypeSet    = "set"
+	TypeZSet   = "zset"
+	TypeList   = "list"
 )
 
 func StreamEntryID(ts, seq uint64) string {
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/database"
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+var (
+	errGeoUnit            = errors.New("unsupported unit provided. please use M, KM, FT, MI")
+	errGeoRadius          = errors.New("radius cannot be negative")
+	errGeoBox             = errors.New("height or width cannot be negative")
+	errGeoCount           = errors.New("COUNT must be > 0")
+	errGeoAnyWithoutCount = errors.New("the ANY argument requires COUNT argument")
+	errNotFloat           = errors.New("value is not a valid float")
+)
+
+// geoUnits are the distance units in meters.
+var geoUnits = map[string]float64{
+	"m":  1,
+	"km": 1000,
+	"ft": 0.3048,
+	"mi": 1609.34,
+}
+
+func parseGeoUnit(s string) (float64, error) {
+	unit, ok := geoUnits[strings.ToLower(s)]
+	if !ok {
+		return 0, errGeoUnit
+	}
+	return unit, nil
+}
+
+// parseGeoPoint parses a longitude, latitude pair, which must be within the range indexed by geohashes.
+func parseGeoPoint(lon, lat string) (database.GeoPoint, error) {
+	var p database.GeoPoint
+	var err error
+	if p.Lon, err = strconv.ParseFloat(lon, 64); err != nil {
+		return p, errNotFloat
+	}
+	if p.Lat, err = strconv.ParseFloat(lat, 64); err != nil {
+		return p, errNotFloat
+	}
+	if !p.Valid() {
+		return p, fmt.Errorf("invalid longitude,latitude pair %f,%f", p.Lon, p.Lat)
+	}
+	return p, nil
+}
+
+// parseGeoDistance parses a distance followed by its unit and returns it in meters.
+func parseGeoDistance(dist, unit, what string) (float64, float64, error) {
+	d, err := strconv.ParseFloat(dist, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("need numeric %s", what)
+	}
+	u, err := parseGeoUnit(unit)
+	if err != nil {
+		return 0, 0, err
+	}
+	return d * u, u, nil
+}
+
+// formatGeoDistance formats a distance like redis, with 4 decimals.
+func formatGeoDistance(d float64) []byte {
+	return resp.NewBulkString(strconv.FormatFloat(d, 'f', 4, 64))
+}
+
+// formatGeoCoord formats a coordinate like redis, as a double in RESP3 or a bulk string
+// with up to 17 decimals in RESP2.
+func formatGeoCoord(w io.Writer, f float64) []byte {
+	if protocol(w) == resp.RESP3 {
+		return resp.NewDouble(f)
+	}
+	s := strings.TrimRight(strconv.FormatFloat(f, 'f', 17, 64), "0")
+	return resp.NewBulkString(strings.TrimSuffix(s, "."))
+}
+
+func handleGeoAdd(conn io.Writer, arr []string, db *database.DB) error {
+	if len(arr) < 5 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	var opts database.GeoAddOptions
+	i := 2
+	for ; i < len(arr); i++ {
+		switch strings.ToUpper(arr[i]) {
+		case "NX":
+			opts.NX = true
+			continue
+		case "XX":
+			opts.XX = true
+			continue
+		case "CH":
+			opts.CH = true
+			continue
+		}
+		break
+	}
+	if (len(arr)-i)%3 != 0 || len(arr) == i || opts.NX && opts.XX {
+		return writeError(conn, errSyntax)
+	}
+	members := make([]database.GeoMember, 0, (len(arr)-i)/3)
+	for ; i < len(arr); i += 3 {
+		p, err := parseGeoPoint(arr[i], arr[i+1])
+		if err != nil {
+			return writeError(conn, err)
+		}
+		members = append(members, database.GeoMember{Name: arr[i+2], GeoPoint: p})
+	}
+	n, err := db.GeoAdd(arr[1], members, opts)
+	if err != nil {
+		return writeError(conn, err)
+	}
+	if _, err := conn.Write(resp.NewInt(n)); err != nil {
+		return fmt.Errorf("error writing to connection: %s", err.Error())
+	}
+	return nil
+}
+
+func handleGeoDist(conn io.Writer, arr []string, db *database.DB) error {
+	if len(arr) < 4 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	if len(arr) > 5 {
+		return writeError(conn, errSyntax)
+	}
+	unit := 1.0
+	if len(arr) == 5 {
+		var err error
+		if unit, err = parseGeoUnit(arr[4]); err != nil {
+			return writeError(conn, err)
+		}
+	}
+	dist, ok, err := db.GeoDist(arr[1], arr[2], arr[3])
+	if err != nil {
+		return writeError(conn, err)
+	}
+	msg := nullBulkString(conn)
+	if ok {
+		msg = formatGeoDistance(dist / unit)
+	}
+	if _, err := conn.Write(msg); err != nil {
+		return fmt.Errorf("error writing to connection: %s", err.Error())
+	}
+	return nil
+}
+
+func handleGeoPos(conn io.Writer, arr []string, db *database.DB) error {
+	if len(arr) < 2 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	points, err := db.GeoPos(arr[1], arr[2:])
+	if err != nil {
+		return writeError(conn, err)
+	}
+	res := make([][]byte, len(points))
+	for i, p := range points {
+		if p == nil {
+			res[i] = nullArray(conn)
+			continue
+		}
+		res[i] = resp.NewArray([][]byte{formatGeoCoord(conn, p.Lon), formatGeoCoord(conn, p.Lat)})
+	}
+	if _, err := conn.Write(resp.NewArray(res)); err != nil {
+		return fmt.Errorf("error writing to connection: %s", err.Error())
+	}
+	return nil
+}
+
+func handleGeoHash(conn io.Writer, arr []string, db *database.DB) error {
+	if len(arr) < 2 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	hashes, err := db.GeoHash(arr[1], arr[2:])
+	if err != nil {
+		return writeError(conn, err)
+	}
+	res := make([][]byte, len(hashes))
+	for i, h := range hashes {
+		if h == nil {
+			res[i] = nullBulkString(conn)
+			continue
+		}
+		res[i] = resp.NewBulkString(*h)
+	}
+	if _, err := conn.Write(resp.NewArray(res)); err != nil {
+		return fmt.Errorf("error writing to connection: %s", err.Error())
+	}
+	return nil
+}
+
+// geoSearchOptions are the arguments of GEOSEARCH and GEOSEARCHSTORE.
+type geoSearchOptions struct {
+	query     database.GeoQuery
+	unit      float64 // unit of the replied or stored distances
+	withDist  bool
+	withHash  bool
+	withCoord bool
+	storeDist bool
+}
+
+// parseGeoSearch parses the arguments following the key of GEOSEARCH or GEOSEARCHSTORE.
+// ref: https://github.com/redis/redis/blob/7.2.0/src/geo.c#L520
+func parseGeoSearch(cmd string, args []string, store bool) (geoSearchOptions, error) {
+	opts := geoSearchOptions{unit: 1}
+	q := &opts.query
+	fromMember, fromLonLat, byRadius, byBox := false, false, false, false
+	for i := 0; i < len(args); i++ {
+		remaining := len(args) - i - 1
+		var err error
+		switch opt := strings.ToUpper(args[i]); {
+		case opt == "WITHDIST":
+			opts.withDist = true
+		case opt == "WITHHASH":
+			opts.withHash = true
+		case opt == "WITHCOORD":
+			opts.withCoord = true
+		case opt == "ANY":
+			q.Any = true
+		case opt == "ASC":
+			q.Asc = true
+		case opt == "DESC":
+			q.Desc = true
+		case opt == "STOREDIST" && store:
+			opts.storeDist = true
+		case opt == "COUNT" && remaining >= 1:
+			count, err := strconv.Atoi(args[i+1])
+			if err != nil {
+				return opts, errNotInteger
+			}
+			if count <= 0 {
+				return opts, errGeoCount
+			}
+			q.Count = count
+			i++
+		case opt == "FROMMEMBER" && remaining >= 1:
+			if fromLonLat {
+				return opts, fmt.Errorf("exactly one of FROMMEMBER or FROMLONLAT can be specified for %s", cmd)
+			}
+			q.Member = args[i+1]
+			fromMember = true
+			i++
+		case opt == "FROMLONLAT" && remaining >= 2:
+			if fromMember {
+				return opts, fmt.Errorf("exactly one of FROMMEMBER or FROMLONLAT can be specified for %s", cmd)
+			}
+			if q.Center, err = parseGeoPoint(args[i+1], args[i+2]); err != nil {
+				return opts, err
+			}
+			fromLonLat = true
+			i += 2
+		case opt == "BYRADIUS" && remaining >= 2:
+			if byBox {
+				return opts, fmt.Errorf("exactly one of BYRADIUS and BYBOX can be specified for %s", cmd)
+			}
+			if q.Radius, opts.unit, err = parseGeoDistance(args[i+1], args[i+2], "radius"); err != nil {
+				return opts, err
+			}
+			if q.Radius < 0 {
+				return opts, errGeoRadius
+			}
+			byRadius = true
+			i += 2
+		case opt == "BYBOX" && remaining >= 3:
+			if byRadius {
+				return opts, fmt.Errorf("exactly one of BYRADIUS and BYBOX can be specified for %s", cmd)
+			}
+			if q.Width, _, err = parseGeoDistance(args[i+1], args[i+3], "width"); err != nil {
+				return opts, err
+			}
+			if q.Height, opts.unit, err = parseGeoDistance(args[i+2], args[i+3], "height"); err != nil {
+				return opts, err
+			}
+			if q.Width < 0 || q.Height < 0 {
+				return opts, errGeoBox
+			}
+			q.ByBox = true
+			byBox = true
+			i += 3
+		default:
+			return opts, errSyntax
+		}
+	}
+	if !fromMember && !fromLonLat {
+		return opts, fmt.Errorf("exactly one of FROMMEMBER or FROMLONLAT can be specified for %s", cmd)
+	}
+	if !byRadius && !byBox {
+		return opts, fmt.Errorf("exactly one of BYRADIUS and BYBOX can be specified for %s", cmd)
+	}
+	if q.Any && q.Count == 0 {
+		return opts, errGeoAnyWithoutCount
+	}
+	if store && (opts.withDist || opts.withHash || opts.withCoord) {
+		return opts, fmt.Errorf("%s is not compatible with WITHDIST, WITHHASH and WITHCOORD options", strings.ToUpper(cmd))
+	}
+	return opts, nil
+}
+
+func handleGeoSearch(conn io.Writer, arr []string, db *database.DB) error {
+	if len(arr) < 7 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	opts, err := parseGeoSearch(strings.ToLower(arr[0]), arr[2:], false)
+	if err != nil {
+		return writeError(conn, err)
+	}
+	results, err := db.GeoSearch(arr[1], opts.query)
+	if err != nil {
+		return writeError(conn, err)
+	}
+	res := make([][]byte, len(results))
+	for i, r := range results {
+		if !opts.withDist && !opts.withHash && !opts.withCoord {
+			res[i] = resp.NewBulkString(r.Name)
+			continue
+		}
+		item := [][]byte{resp.NewBulkString(r.Name)}
+		if opts.withDist {
+			item = append(item, formatGeoDistance(r.Dist/opts.unit))
+		}
+		if opts.withHash {
+			item = append(item, resp.NewInt(int(r.Hash)))
+		}
+		if opts.withCoord {
+			item = append(item, resp.NewArray([][]byte{formatGeoCoord(conn, r.Lon), formatGeoCoord(conn, r.Lat)}))
+		}
+		res[i] = resp.NewArray(item)
+	}
+	if _, err := conn.Write(resp.NewArray(res)); err != nil {
+		return fmt.Errorf("error writing to connection: %s", err.Error())
+	}
+	return nil
+}
+
+func handleGeoSearchStore(conn io.Writer, arr []string, db *database.DB) error {
+	if len(arr) < 8 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	opts, err := parseGeoSearch(strings.ToLower(arr[0]), arr[3:], true)
+	if err != nil {
+		return writeError(conn, err)
+	}
+	n, err := db.GeoSearchStore(arr[1], arr[2], opts.query, opts.storeDist, opts.unit)
+	if err != nil {
+		return writeError(conn, err)
+	}
+	if _, err := conn.Write(resp.NewInt(n)); err != nil {
+		return fmt.Errorf("error writing to connection: %s", err.Error())
+	}
+	return nil
+}
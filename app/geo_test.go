@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/codecrafters-io/redis-starter-go/app/database"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGeoCommands(t *testing.T) {
+	s := newServer(host, "0", []*database.DB{database.NewDB()}, RoleMaster, testCfg)
+	conn, r := pipeClient(t, s)
+
+	// the replies of redis for the examples of the documentation
+	require.Equal(t, 2, do(t, conn, r, "GEOADD", "Sicily", "13.361389", "38.115556", "Palermo", "15.087269", "37.502669", "Catania"))
+	require.Equal(t, "166274.1516", do(t, conn, r, "GEODIST", "Sicily", "Palermo", "Catania"))
+	require.Equal(t, "166.2742", do(t, conn, r, "GEODIST", "Sicily", "Palermo", "Catania", "km"))
+	require.Equal(t, "103.3182", do(t, conn, r, "GEODIST", "Sicily", "Palermo", "Catania", "mi"))
+	require.Nil(t, do(t, conn, r, "GEODIST", "Sicily", "Palermo", "missing"))
+	require.Equal(t, []any{[]any{"13.36138933897018433", "38.11555639549629859"}, []any{"15.08726745843887329", "37.50266842333162032"}, nil},
+		do(t, conn, r, "GEOPOS", "Sicily", "Palermo", "Catania", "NonExisting"))
+	require.Equal(t, []any{"sqc8b49rny0", "sqdtr74hyu0"}, do(t, conn, r, "GEOHASH", "Sicily", "Palermo", "Catania"))
+
+	require.Equal(t, 2, do(t, conn, r, "GEOADD", "Sicily", "12.758489", "38.788135", "edge1", "17.241510", "38.788135", "edge2"))
+	require.Equal(t, []any{"Catania", "Palermo"}, do(t, conn, r, "GEOSEARCH", "Sicily", "FROMLONLAT", "15", "37", "BYRADIUS", "200", "km", "ASC"))
+	require.Equal(t, []any{
+		[]any{"Catania", "56.4413", []any{"15.08726745843887329", "37.50266842333162032"}},
+		[]any{"Palermo", "190.4424", []any{"13.36138933897018433", "38.11555639549629859"}},
+		[]any{"edge2", "279.7403", []any{"17.24151045083999634", "38.78813451624225195"}},
+		[]any{"edge1", "279.7405", []any{"12.7584877610206604", "38.78813451624225195"}},
+	}, do(t, conn, r, "GEOSEARCH", "Sicily", "FROMLONLAT", "15", "37", "BYBOX", "400", "400", "km", "ASC", "WITHCOORD", "WITHDIST"))
+	require.Equal(t, []any{[]any{"Palermo", 3479099956230698}}, do(t, conn, r, "GEOSEARCH", "Sicily", "FROMMEMBER", "Palermo", "BYRADIUS", "10", "km", "WITHHASH"))
+	require.Equal(t, []any{"Palermo"}, do(t, conn, r, "GEOSEARCH", "Sicily", "FROMMEMBER", "Palermo", "BYRADIUS", "500", "km", "COUNT", "1"))
+	require.Equal(t, []any{"edge2"}, do(t, conn, r, "GEOSEARCH", "Sicily", "FROMMEMBER", "Palermo", "BYRADIUS", "500", "km", "COUNT", "1", "DESC"))
+
+	require.Equal(t, 2, do(t, conn, r, "GEOSEARCHSTORE", "dst", "Sicily", "FROMLONLAT", "15", "37", "BYRADIUS", "200", "km", "STOREDIST"))
+
+	require.Equal(t, "ERR invalid longitude,latitude pair 181.000000,10.000000", do(t, conn, r, "GEOADD", "Sicily", "181", "10", "foo").(error).Error())
+	require.Equal(t, "ERR syntax error", do(t, conn, r, "GEOADD", "Sicily", "NX", "XX", "13", "38", "foo").(error).Error())
+	require.Equal(t, "ERR unsupported unit provided. please use M, KM, FT, MI", do(t, conn, r, "GEODIST", "Sicily", "Palermo", "Catania", "yd").(error).Error())
+	require.Equal(t, "ERR exactly one of BYRADIUS and BYBOX can be specified for geosearch",
+		do(t, conn, r, "GEOSEARCH", "Sicily", "FROMLONLAT", "15", "37", "COUNT", "1", "ASC").(error).Error())
+	require.Equal(t, "ERR exactly one of FROMMEMBER or FROMLONLAT can be specified for geosearch",
+		do(t, conn, r, "GEOSEARCH", "Sicily", "BYRADIUS", "200", "km", "COUNT", "1", "ASC").(error).Error())
+	require.Equal(t, "ERR the ANY argument requires COUNT argument",
+		do(t, conn, r, "GEOSEARCH", "Sicily", "FROMLONLAT", "15", "37", "BYRADIUS", "200", "km", "ANY").(error).Error())
+	require.Equal(t, "ERR could not decode requested zset member",
+		do(t, conn, r, "GEOSEARCH", "Sicily", "FROMMEMBER", "missing", "BYRADIUS", "200", "km").(error).Error())
+	require.Equal(t, "ERR GEOSEARCHSTORE is not compatible with WITHDIST, WITHHASH and WITHCOORD options",
+		do(t, conn, r, "GEOSEARCHSTORE", "dst", "Sicily", "FROMLONLAT", "15", "37", "BYRADIUS", "200", "km", "WITHDIST").(error).Error())
+}
@@ -0,0 +1,142 @@
+// Package glob implements the glob-style pattern matching of redis (stringmatchlen),
+// used by KEYS, SCAN and CONFIG GET.
+//
+//	h?llo     matches hello, hallo and hxllo
+//	h*llo     matches hllo and heeeello
+//	h[ae]llo  matches hello and hallo, but not hillo
+//	h[^e]llo  matches hallo, hbllo, ... but not hello
+//	h[a-b]llo matches hallo and hbllo
+//	\         escapes the next character
+//
+// ref: https://github.com/redis/redis/blob/7.2.0/src/util.c#L59
+package glob
+
+// maxNesting bounds the recursion of consecutive '*' so that abusive patterns can not exhaust the stack.
+const maxNesting = 1000
+
+// Match reports whether str matches pattern, nocase enables ASCII case-insensitive matching.
+func Match(pattern, str string, nocase bool) bool {
+	skipLongerMatches := false
+	return match(pattern, str, nocase, &skipLongerMatches, 0)
+}
+
+// MatchAll reports whether pattern matches every string, callers can use it to skip matching altogether.
+func MatchAll(pattern string) bool {
+	if pattern == "" {
+		return false
+	}
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] != '*' {
+			return false
+		}
+	}
+	return true
+}
+
+// skipLongerMatches is set once the remaining of a '*' failed against every suffix of the string,
+// in which case trying longer matches of a previous '*' can not succeed either.
+func match(p, s string, nocase bool, skipLongerMatches *bool, nesting int) bool {
+	if nesting > maxNesting {
+		return false
+	}
+	pi, si := 0, 0
+	for pi < len(p) && si < len(s) {
+		switch p[pi] {
+		case '*':
+			for pi+1 < len(p) && p[pi+1] == '*' {
+				pi++
+			}
+			if pi+1 == len(p) {
+				return true
+			}
+			for si < len(s) {
+				if match(p[pi+1:], s[si:], nocase, skipLongerMatches, nesting+1) {
+					return true
+				}
+				if *skipLongerMatches {
+					return false
+				}
+				si++
+			}
+			*skipLongerMatches = true
+			return false
+		case '?':
+			si++
+		case '[':
+			pi++
+			not := pi < len(p) && p[pi] == '^'
+			if not {
+				pi++
+			}
+			matched := false
+			for {
+				if pi+1 < len(p) && p[pi] == '\\' {
+					pi++
+					if p[pi] == s[si] {
+						matched = true
+					}
+				} else if pi >= len(p) {
+					// unterminated class, the '[' is treated as the end of the pattern
+					pi--
+					break
+				} else if p[pi] == ']' {
+					break
+				} else if pi+2 < len(p) && p[pi+1] == '-' {
+					start, end, c := p[pi], p[pi+2], s[si]
+					if start > end {
+						start, end = end, start
+					}
+					if nocase {
+						start, end, c = toLower(start), toLower(end), toLower(c)
+					}
+					pi += 2
+					if c >= start && c <= end {
+						matched = true
+					}
+				} else if equal(p[pi], s[si], nocase) {
+					matched = true
+				}
+				pi++
+			}
+			if not {
+				matched = !matched
+			}
+			if !matched {
+				return false
+			}
+			si++
+		case '\\':
+			if pi+1 < len(p) {
+				pi++
+			}
+			fallthrough
+		default:
+			if !equal(p[pi], s[si], nocase) {
+				return false
+			}
+			si++
+		}
+		pi++
+		if si == len(s) {
+			for pi < len(p) && p[pi] == '*' {
+				pi++
+			}
+			break
+		}
+	}
+	return pi == len(p) && si == len(s)
+}
+
+func equal(a, b byte, nocase bool) bool {
+	if nocase {
+		return toLower(a) == toLower(b)
+	}
+	return a == b
+}
+
+func toLower(c byte) byte {
+	if c >= 'A' && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
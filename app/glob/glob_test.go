@@ -0,0 +1,68 @@
+package glob
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		str     string
+		nocase  bool
+		want    bool
+	}{
+		// same as redis, callers use MatchAll to match empty strings
+		{"*", "", false, false},
+		{"*", "anything", false, true},
+		{"", "", false, true},
+		{"", "a", false, false},
+		{"user:*", "user:1", false, true},
+		{"user:*", "user:", false, true},
+		{"user:*", "userX", false, false},
+		{"a.b", "a.b", false, true},
+		{"a.b", "axb", false, false},
+		{"h?llo", "hello", false, true},
+		{"h?llo", "hllo", false, false},
+		{"h*llo", "hllo", false, true},
+		{"h*llo", "heeeello", false, true},
+		{"h[ae]llo", "hallo", false, true},
+		{"h[ae]llo", "hillo", false, false},
+		{"h[^e]llo", "hallo", false, true},
+		{"h[^e]llo", "hello", false, false},
+		{"h[a-b]llo", "hbllo", false, true},
+		{"h[b-a]llo", "hbllo", false, true},
+		{"h[a-b]llo", "hcllo", false, false},
+		{"h[A-B]llo", "hbllo", true, true},
+		{"h\\*llo", "h*llo", false, true},
+		{"h\\*llo", "hello", false, false},
+		{"h[\\]]llo", "h]llo", false, true},
+		{"[", "a", false, false},
+		{"a[", "a", false, false},
+		{"[abc", "a", false, true},
+		{"a\\", "a\\", false, true},
+		{"*a*b*", "xaxxbx", false, true},
+		{"*a*b*", "xbxxax", false, false},
+		{"HELLO", "hello", true, true},
+		{"HELLO", "hello", false, false},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.want, Match(tt.pattern, tt.str, tt.nocase), "pattern %q str %q", tt.pattern, tt.str)
+	}
+}
+
+func TestMatchAbusivePattern(t *testing.T) {
+	// exponential without skipLongerMatches
+	pattern := strings.Repeat("a*", 30) + "b"
+	require.False(t, Match(pattern, strings.Repeat("a", 60), false))
+	require.False(t, Match(strings.Repeat("*", 2000)+"x"+strings.Repeat("*?", 1500), "x", false))
+}
+
+func TestMatchAll(t *testing.T) {
+	require.True(t, MatchAll("*"))
+	require.True(t, MatchAll("***"))
+	require.False(t, MatchAll(""))
+	require.False(t, MatchAll("*a"))
+}
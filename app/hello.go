@@ -0,0 +1,212 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/database"
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+const redisVersion = "7.2.0"
+
+var (
+	errNoAuth      = &database.CodedError{Code: "NOAUTH", Msg: "Authentication required."}
+	errHelloNoAuth = &database.CodedError{Code: "NOAUTH", Msg: "HELLO must be called with the client already authenticated, otherwise the HELLO <proto> AUTH <user> <pass> option can be used to authenticate the client and select the RESP protocol version at the same time"}
+	errWrongPass   = &database.CodedError{Code: "WRONGPASS", Msg: "invalid username-password pair or user is disabled."}
+	errNoProto     = &database.CodedError{Code: "NOPROTO", Msg: "unsupported protocol version"}
+)
+
+// noAuthCommands can be sent before authenticating when requirepass is set.
+var noAuthCommands = map[string]bool{
+	"AUTH":  true,
+	"HELLO": true,
+}
+
+// respWriter is the connection of a client, it lets the handlers reply in the protocol negotiated with HELLO.
+type respWriter struct {
+	io.Writer
+	state   *clientState
+	replied bool // whether the current command started its reply
+	failed  bool // whether the reply of the current command is an error
+}
+
+// Write records whether the reply of the current command is an error, from its first byte.
+func (w *respWriter) Write(p []byte) (int, error) {
+	if !w.replied && len(p) > 0 {
+		w.replied = true
+		w.failed = p[0] == '-'
+	}
+	return w.Writer.Write(p)
+}
+
+// startCommand resets the reply recorded for the previous command of w.
+func startCommand(w io.Writer) {
+	if rw, ok := w.(*respWriter); ok {
+		rw.replied, rw.failed = false, false
+	}
+}
+
+// commandFailed tells if the command replied an error to w. The commands of the master are applied without
+// replying, they did not fail on the master.
+func commandFailed(w io.Writer) bool {
+	rw, ok := w.(*respWriter)
+	return ok && rw.failed
+}
+
+// protocol returns the protocol version used to reply to w, RESP2 unless the client negotiated RESP3.
+func protocol(w io.Writer) int {
+	if rw, ok := w.(*respWriter); ok && rw.state.proto == resp.RESP3 {
+		return resp.RESP3
+	}
+	return resp.RESP2
+}
+
+// nullBulkString is the null reply of the commands replying a bulk string.
+func nullBulkString(w io.Writer) []byte {
+	if protocol(w) == resp.RESP3 {
+		return resp.NewNull()
+	}
+	return resp.NewNullBulkString()
+}
+
+// nullArray is the null reply of the commands replying an array.
+func nullArray(w io.Writer) []byte {
+	if protocol(w) == resp.RESP3 {
+		return resp.NewNull()
+	}
+	return resp.NewNullArray()
+}
+
+// mapReply encodes the keys and values, given alternately, as a map, or as a flat array in RESP2.
+func mapReply(w io.Writer, kvs [][]byte) []byte {
+	if protocol(w) == resp.RESP3 {
+		return resp.NewMap(kvs)
+	}
+	return resp.NewArray(kvs)
+}
+
+// pairsReply encodes the keys and values, given alternately, as a map, or as an array of key, value pairs in RESP2.
+func pairsReply(w io.Writer, kvs [][]byte) []byte {
+	if protocol(w) == resp.RESP3 {
+		return resp.NewMap(kvs)
+	}
+	pairs := make([][]byte, 0, len(kvs)/2)
+	for i := 0; i+1 < len(kvs); i += 2 {
+		pairs = append(pairs, resp.NewArray(kvs[i:i+2]))
+	}
+	return resp.NewArray(pairs)
+}
+
+// boolReply encodes b as a boolean, or as the integer 1 or 0 in RESP2.
+func boolReply(w io.Writer, b bool) []byte {
+	if protocol(w) == resp.RESP3 {
+		return resp.NewBoolean(b)
+	}
+	if b {
+		return resp.NewInt(1)
+	}
+	return resp.NewInt(0)
+}
+
+// doubleReply encodes f as a double, or as a bulk string in RESP2.
+func doubleReply(w io.Writer, f float64) []byte {
+	if protocol(w) == resp.RESP3 {
+		return resp.NewDouble(f)
+	}
+	return resp.NewBulkString(formatFloat(f))
+}
+
+// verbatimReply encodes s as a verbatim text, or as a bulk string in RESP2.
+func verbatimReply(w io.Writer, s string) []byte {
+	if protocol(w) == resp.RESP3 {
+		return resp.NewVerbatimString("txt", s)
+	}
+	return resp.NewBulkString(s)
+}
+
+// checkPassword tells if the credentials are the ones of the default user,
+// any password is accepted when requirepass is not set.
+func (s *server) checkPassword(user, pass string) bool {
+	return user == "default" && (s.config.requirepass == "" || pass == s.config.requirepass)
+}
+
+// handleAuth handles AUTH [username] password.
+func (s *server) handleAuth(conn io.Writer, arr []string, state *clientState) error {
+	if len(arr) != 2 && len(arr) != 3 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	user, pass := "default", arr[len(arr)-1]
+	if len(arr) == 3 {
+		user = arr[1]
+	} else if s.config.requirepass == "" {
+		return writeError(conn, errors.New("AUTH <password> called without any password configured for the default user. Are you sure your configuration is correct?"))
+	}
+	if !s.checkPassword(user, pass) {
+		return writeError(conn, errWrongPass)
+	}
+	state.authenticated = true
+	return writeOK(conn)
+}
+
+// handleHello handles HELLO [protover [AUTH username password] [SETNAME clientname]].
+// The protocol version is switched once all the options are validated, so the reply already uses it.
+func (s *server) handleHello(conn io.Writer, arr []string, state *clientState) error {
+	proto := state.proto
+	if len(arr) > 1 {
+		v, err := strconv.Atoi(arr[1])
+		if err != nil {
+			return writeError(conn, errors.New("Protocol version is not an integer or out of range"))
+		}
+		if v != resp.RESP2 && v != resp.RESP3 {
+			return writeError(conn, errNoProto)
+		}
+		proto = v
+	}
+	var name *string
+	for i := 2; i < len(arr); i++ {
+		switch opt := strings.ToUpper(arr[i]); {
+		case opt == "AUTH" && i+2 < len(arr):
+			if !s.checkPassword(arr[i+1], arr[i+2]) {
+				return writeError(conn, errWrongPass)
+			}
+			state.authenticated = true
+			i += 2
+		case opt == "SETNAME" && i+1 < len(arr):
+			if !validClientName(arr[i+1]) {
+				return writeError(conn, errInvalidName)
+			}
+			name = &arr[i+1]
+			i++
+		default:
+			return writeError(conn, fmt.Errorf("Syntax error in HELLO option '%s'", arr[i]))
+		}
+	}
+	if !state.authenticated && s.config.requirepass != "" {
+		return writeError(conn, errHelloNoAuth)
+	}
+	state.proto = proto
+	if name != nil {
+		state.client.setName(*name)
+	}
+	role := s.role
+	if role == RoleSlave {
+		role = "replica"
+	}
+	info := mapReply(conn, [][]byte{
+		resp.NewBulkString("server"), resp.NewBulkString("redis"),
+		resp.NewBulkString("version"), resp.NewBulkString(redisVersion),
+		resp.NewBulkString("proto"), resp.NewInt(state.proto),
+		resp.NewBulkString("id"), resp.NewInt(int(state.client.id)),
+		resp.NewBulkString("mode"), resp.NewBulkString("standalone"),
+		resp.NewBulkString("role"), resp.NewBulkString(role),
+		resp.NewBulkString("modules"), resp.NewArray(nil),
+	})
+	if _, err := conn.Write(info); err != nil {
+		return fmt.Errorf("error writing to connection: %s", err.Error())
+	}
+	return nil
+}
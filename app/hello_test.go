@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/codecrafters-io/redis-starter-go/app/database"
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHello(t *testing.T) {
+	s := newServer(host, "0", []*database.DB{database.NewDB()}, RoleMaster, testCfg)
+	conn, r := pipeClient(t, s)
+
+	res := do(t, conn, r, "HELLO").([]any)
+	require.Equal(t, []any{"server", "redis", "version", redisVersion, "proto", 2}, res[:6])
+	require.Equal(t, []any{"mode", "standalone", "role", "master", "modules", []any{}}, res[8:])
+	require.Equal(t, errors.New("NOPROTO unsupported protocol version"), do(t, conn, r, "HELLO", "4"))
+	require.Equal(t, errors.New("ERR Protocol version is not an integer or out of range"), do(t, conn, r, "HELLO", "x"))
+	require.Equal(t, errors.New("ERR Syntax error in HELLO option 'foo'"), do(t, conn, r, "HELLO", "3", "foo"))
+	require.Equal(t, errors.New("ERR Syntax error in HELLO option 'SETNAME'"), do(t, conn, r, "HELLO", "3", "SETNAME"))
+	require.Equal(t, errors.New("ERR Client names cannot contain spaces, newlines or special characters."), do(t, conn, r, "HELLO", "3", "SETNAME", "a b"))
+
+	info := do(t, conn, r, "HELLO", "3", "AUTH", "default", "any", "SETNAME", "app").(map[string]any)
+	require.Equal(t, 3, info["proto"])
+	require.Equal(t, "master", info["role"])
+	require.Equal(t, info, do(t, conn, r, "HELLO"))
+
+	require.Equal(t, 2, do(t, conn, r, "HSET", "h", "a", "1", "b", "2"))
+	require.Equal(t, map[string]any{"a": "1", "b": "2"}, do(t, conn, r, "HGETALL", "h"))
+	require.Equal(t, map[string]any{}, do(t, conn, r, "HGETALL", "missing"))
+	require.Equal(t, 1, do(t, conn, r, "ZADD", "z", "1.5", "m"))
+	require.Equal(t, 1.5, do(t, conn, r, "ZSCORE", "z", "m"))
+	require.Equal(t, map[string]any{"maxmemory": "0"}, do(t, conn, r, "CONFIG", "GET", "maxmemory"))
+
+	// the nulls are encoded with the RESP3 null type
+	_, err := conn.Write(resp.NewCommand([]string{"ZSCORE", "z", "missing"}))
+	require.NoError(t, err)
+	b := make([]byte, 3)
+	_, err = io.ReadFull(r, b)
+	require.NoError(t, err)
+	require.Equal(t, "_\r\n", string(b))
+
+	// the queued commands are replied in the negotiated protocol
+	require.Equal(t, "OK", do(t, conn, r, "MULTI"))
+	require.Equal(t, "QUEUED", do(t, conn, r, "ZSCORE", "z", "m"))
+	require.Equal(t, "QUEUED", do(t, conn, r, "HGETALL", "h"))
+	require.Equal(t, []any{1.5, map[string]any{"a": "1", "b": "2"}}, do(t, conn, r, "EXEC"))
+
+	require.Equal(t, 2, do(t, conn, r, "HELLO", "2").([]any)[5])
+	require.Equal(t, "1.5", do(t, conn, r, "ZSCORE", "z", "m"))
+	require.Len(t, do(t, conn, r, "HGETALL", "h"), 4)
+	require.Nil(t, do(t, conn, r, "ZSCORE", "z", "missing"))
+}
+
+func TestAuth(t *testing.T) {
+	cfg := config{requirepass: "secret"}
+	s := newServer(host, "0", []*database.DB{database.NewDB()}, RoleMaster, cfg)
+	conn, r := pipeClient(t, s)
+
+	noAuth := errors.New("NOAUTH Authentication required.")
+	wrongPass := errors.New("WRONGPASS invalid username-password pair or user is disabled.")
+	require.Equal(t, noAuth, do(t, conn, r, "PING"))
+	require.Equal(t, noAuth, do(t, conn, r, "MULTI"))
+	require.ErrorContains(t, do(t, conn, r, "HELLO", "3").(error), "NOAUTH HELLO must be called with the client already authenticated")
+	require.Equal(t, wrongPass, do(t, conn, r, "HELLO", "3", "AUTH", "default", "wrong"))
+	require.Equal(t, wrongPass, do(t, conn, r, "AUTH", "wrong"))
+	require.Equal(t, wrongPass, do(t, conn, r, "AUTH", "admin", "secret"))
+	require.Equal(t, noAuth, do(t, conn, r, "PING"))
+
+	require.Equal(t, 3, do(t, conn, r, "HELLO", "3", "AUTH", "default", "secret").(map[string]any)["proto"])
+	require.Equal(t, "PONG", do(t, conn, r, "PING"))
+
+	conn, r = pipeClient(t, s)
+	require.Equal(t, "OK", do(t, conn, r, "AUTH", "secret"))
+	require.Equal(t, "PONG", do(t, conn, r, "PING"))
+
+	s = newServer(host, "0", []*database.DB{database.NewDB()}, RoleMaster, testCfg)
+	conn, r = pipeClient(t, s)
+	require.ErrorContains(t, do(t, conn, r, "AUTH", "secret").(error), "ERR AUTH <password> called without any password configured")
+	require.Equal(t, "OK", do(t, conn, r, "AUTH", "default", "secret"))
+}
+
+// replyType sends a command through the client and returns the type of its reply along with the reply.
+func replyType(t *testing.T, conn net.Conn, r *bufio.Reader, args ...string) (byte, any) {
+	_, err := conn.Write(resp.NewCommand(args))
+	require.NoError(t, err)
+	b, err := r.Peek(1)
+	require.NoError(t, err)
+	typ := b[0]
+	res, err := readReply(r)
+	require.NoError(t, err)
+	return typ, res
+}
+
+func TestHelloReplyTypes(t *testing.T) {
+	s := newServer(host, "0", []*database.DB{database.NewDB()}, RoleMaster, testCfg)
+	conn, r := pipeClient(t, s)
+	require.Equal(t, 3, do(t, conn, r, "HELLO", "3").(map[string]any)["proto"])
+
+	require.Equal(t, true, do(t, conn, r, "BF.ADD", "bf", "a"))
+	require.Equal(t, false, do(t, conn, r, "BF.ADD", "bf", "a"))
+	require.Equal(t, []any{true, false}, do(t, conn, r, "BF.MEXISTS", "bf", "a", "b"))
+	require.Equal(t, 1, do(t, conn, r, "BF.INFO", "bf").(map[string]any)["Number of items inserted"])
+	require.Equal(t, true, do(t, conn, r, "CF.ADD", "cf", "a"))
+	require.Equal(t, false, do(t, conn, r, "CF.EXISTS", "cf", "b"))
+	require.Equal(t, "OK", do(t, conn, r, "CMS.INITBYDIM", "cms", "10", "2"))
+	require.Equal(t, map[string]any{"width": 10, "depth": 2, "count": 0}, do(t, conn, r, "CMS.INFO", "cms"))
+	require.Equal(t, "OK", do(t, conn, r, "TOPK.RESERVE", "tk", "2", "8", "7", "0.9"))
+	require.Equal(t, map[string]any{"k": 2, "width": 8, "depth": 7, "decay": 0.9}, do(t, conn, r, "TOPK.INFO", "tk"))
+
+	require.Equal(t, "1-1", do(t, conn, r, "XADD", "s", "1-1", "f", "v"))
+	require.Equal(t, map[string]any{"s": []any{[]any{"1-1", []any{"f", "v"}}}}, do(t, conn, r, "XREAD", "STREAMS", "s", "0"))
+	require.Nil(t, do(t, conn, r, "XREAD", "STREAMS", "s", "1-1"))
+
+	require.Equal(t, "OK", do(t, conn, r, "SET", "a", "ohmytext"))
+	require.Equal(t, "OK", do(t, conn, r, "SET", "b", "mynewtext"))
+	require.Equal(t, map[string]any{
+		"matches": []any{[]any{[]any{4, 7}, []any{5, 8}}, []any{[]any{2, 3}, []any{0, 1}}},
+		"len":     6,
+	}, do(t, conn, r, "LCS", "a", "b", "IDX"))
+
+	require.Equal(t, 1, do(t, conn, r, "GEOADD", "g", "13.361389", "38.115556", "Palermo"))
+	pos := do(t, conn, r, "GEOPOS", "g", "Palermo").([]any)[0].([]any)
+	require.InDelta(t, 13.361389, pos[0], 1e-5)
+	require.InDelta(t, 38.115556, pos[1], 1e-5)
+
+	stats := do(t, conn, r, "MEMORY", "STATS").(map[string]any)
+	require.Contains(t, stats, "db.0")
+	require.IsType(t, map[string]any{}, stats["db.0"])
+	require.IsType(t, 0.0, stats["dataset.percentage"])
+
+	typ, info := replyType(t, conn, r, "INFO", "replication")
+	require.Equal(t, byte(resp.TypeVerbatimString), typ)
+	require.Contains(t, info, "role:master")
+	typ, _ = replyType(t, conn, r, "MEMORY", "DOCTOR")
+	require.Equal(t, byte(resp.TypeVerbatimString), typ)
+
+	// the RESP2 replies are unchanged
+	do(t, conn, r, "HELLO", "2")
+	require.Equal(t, 0, do(t, conn, r, "BF.ADD", "bf", "a"))
+	require.Equal(t, []any{[]any{"s", []any{[]any{"1-1", []any{"f", "v"}}}}}, do(t, conn, r, "XREAD", "STREAMS", "s", "0"))
+	require.Equal(t, []any{"13.36138933897018433", "38.11555639549629859"}, do(t, conn, r, "GEOPOS", "g", "Palermo").([]any)[0])
+	typ, _ = replyType(t, conn, r, "INFO", "replication")
+	require.Equal(t, byte(resp.TypeBulkString), typ)
+}
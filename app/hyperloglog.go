@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/codecrafters-io/redis-starter-go/app/database"
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+func handlePFAdd(conn io.Writer, arr []string, db *database.DB) error {
+	if len(arr) < 2 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	updated, err := db.PFAdd(arr[1], arr[2:])
+	if err != nil {
+		return writeError(conn, err)
+	}
+	return writeBoolInt(conn, updated)
+}
+
+func handlePFCount(conn io.Writer, arr []string, db *database.DB) error {
+	if len(arr) < 2 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	card, err := db.PFCount(arr[1:])
+	if err != nil {
+		return writeError(conn, err)
+	}
+	return writeInt64(conn, int64(card))
+}
+
+func handlePFMerge(conn io.Writer, arr []string, db *database.DB) error {
+	if len(arr) < 2 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	if err := db.PFMerge(arr[1], arr[2:]); err != nil {
+		return writeError(conn, err)
+	}
+	if _, err := conn.Write(resp.NewSimpleString("OK")); err != nil {
+		return fmt.Errorf("error writing to connection: %s", err.Error())
+	}
+	return nil
+}
+
+// handlePFDebug is the internal command used by the tests to inspect the representation of a HyperLogLog.
+func handlePFDebug(conn io.Writer, arr []string, db *database.DB) error {
+	if len(arr) != 3 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	res, err := db.PFDebug(arr[1], arr[2])
+	if err != nil {
+		return writeError(conn, err)
+	}
+	var msg []byte
+	switch v := res.(type) {
+	case []int:
+		regs := make([][]byte, len(v))
+		for i, r := range v {
+			regs[i] = resp.NewInt(r)
+		}
+		msg = resp.NewArray(regs)
+	case bool:
+		return writeBoolInt(conn, v)
+	case database.HLLEncoding:
+		msg = resp.NewSimpleString(string(v))
+	case string:
+		msg = resp.NewBulkString(v)
+	}
+	if _, err := conn.Write(msg); err != nil {
+		return fmt.Errorf("error writing to connection: %s", err.Error())
+	}
+	return nil
+}
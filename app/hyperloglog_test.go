@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/codecrafters-io/redis-starter-go/app/database"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHyperLogLogCommands(t *testing.T) {
+	s := newServer(host, "0", []*database.DB{database.NewDB()}, RoleMaster, testCfg)
+	conn, r := pipeClient(t, s)
+
+	require.Equal(t, 1, do(t, conn, r, "PFADD", "hll", "a", "b", "c", "d", "e", "f", "g"))
+	require.Equal(t, 0, do(t, conn, r, "PFADD", "hll", "a"))
+	require.Equal(t, 7, do(t, conn, r, "PFCOUNT", "hll"))
+	require.Equal(t, 1, do(t, conn, r, "PFADD", "hll2", "h", "a"))
+	require.Equal(t, 8, do(t, conn, r, "PFCOUNT", "hll", "hll2"))
+	require.Equal(t, "OK", do(t, conn, r, "PFMERGE", "dst", "hll", "hll2"))
+	require.Equal(t, 8, do(t, conn, r, "PFCOUNT", "dst"))
+	require.Equal(t, 0, do(t, conn, r, "PFCOUNT", "missing"))
+
+	require.Equal(t, "sparse", do(t, conn, r, "PFDEBUG", "ENCODING", "dst"))
+	require.Equal(t, 1, do(t, conn, r, "PFDEBUG", "TODENSE", "dst"))
+	require.Equal(t, 0, do(t, conn, r, "PFDEBUG", "TODENSE", "dst"))
+	require.Len(t, do(t, conn, r, "PFDEBUG", "GETREG", "dst"), 16384)
+	require.Equal(t, "ERR Unknown PFDEBUG subcommand 'FOO'", do(t, conn, r, "PFDEBUG", "FOO", "dst").(error).Error())
+	require.Equal(t, "ERR The specified key does not exist", do(t, conn, r, "PFDEBUG", "ENCODING", "missing").(error).Error())
+
+	do(t, conn, r, "SET", "s", "foo")
+	require.Equal(t, "WRONGTYPE Key is not a valid HyperLogLog string value.", do(t, conn, r, "PFADD", "s", "a").(error).Error())
+	require.Equal(t, "WRONGTYPE Key is not a valid HyperLogLog string value.", do(t, conn, r, "PFCOUNT", "hll", "s").(error).Error())
+	require.Equal(t, "ERR wrong number of arguments for 'pfcount' command", do(t, conn, r, "PFCOUNT").(error).Error())
+}
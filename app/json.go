@@ -0,0 +1,311 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/database"
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+// jsonPathArg returns the optional path argument at index i, the root legacy path if it is missing like RedisJSON.
+func jsonPathArg(arr []string, i int) string {
+	if i < len(arr) {
+		return arr[i]
+	}
+	return "."
+}
+
+func jsonResult(conn io.Writer, v any) []byte {
+	switch v := v.(type) {
+	case int64:
+		return resp.NewInt(int(v))
+	case string:
+		return resp.NewBulkString(v)
+	case []string:
+		res := make([][]byte, len(v))
+		for i, s := range v {
+			res[i] = resp.NewBulkString(s)
+		}
+		return resp.NewArray(res)
+	}
+	return nullBulkString(conn)
+}
+
+// writeJSONResults replies a result for every match of a JSONPath, or the first result for a legacy path.
+// The results are nil if the key does not exist, which is replied as null.
+func writeJSONResults(conn io.Writer, path string, results []any) error {
+	var b []byte
+	switch {
+	case results == nil:
+		b = nullBulkString(conn)
+	case database.JSONPathIsLegacy(path):
+		var first any
+		if len(results) > 0 {
+			first = results[0]
+		}
+		b = jsonResult(conn, first)
+	default:
+		res := make([][]byte, len(results))
+		for i, r := range results {
+			res[i] = jsonResult(conn, r)
+		}
+		b = resp.NewArray(res)
+	}
+	if _, err := conn.Write(b); err != nil {
+		return fmt.Errorf("error writing to connection: %s", err.Error())
+	}
+	return nil
+}
+
+func writeBulkStringOrNull(conn io.Writer, s string, ok bool) error {
+	b := nullBulkString(conn)
+	if ok {
+		b = resp.NewBulkString(s)
+	}
+	if _, err := conn.Write(b); err != nil {
+		return fmt.Errorf("error writing to connection: %s", err.Error())
+	}
+	return nil
+}
+
+func handleJSONSet(conn io.Writer, arr []string, db *database.DB) error {
+	if len(arr) != 4 && len(arr) != 5 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	var nx, xx bool
+	if len(arr) == 5 {
+		switch strings.ToUpper(arr[4]) {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		default:
+			return writeError(conn, errSyntax)
+		}
+	}
+	ok, err := db.JSONSet(arr[1], arr[2], arr[3], nx, xx)
+	if err != nil {
+		return writeError(conn, err)
+	}
+	if !ok {
+		return writeBulkStringOrNull(conn, "", false)
+	}
+	return writeOK(conn)
+}
+
+func handleJSONGet(conn io.Writer, arr []string, db *database.DB) error {
+	if len(arr) < 2 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	var f database.JSONFormat
+	var paths []string
+	for i := 2; i < len(arr); i++ {
+		opt := strings.ToUpper(arr[i])
+		if i+1 < len(arr) && (opt == "INDENT" || opt == "NEWLINE" || opt == "SPACE") {
+			switch opt {
+			case "INDENT":
+				f.Indent = arr[i+1]
+			case "NEWLINE":
+				f.Newline = arr[i+1]
+			case "SPACE":
+				f.Space = arr[i+1]
+			}
+			i++
+			continue
+		}
+		paths = append(paths, arr[i])
+	}
+	s, ok, err := db.JSONGet(arr[1], paths, f)
+	if err != nil {
+		return writeError(conn, err)
+	}
+	return writeBulkStringOrNull(conn, s, ok)
+}
+
+func handleJSONMGet(conn io.Writer, arr []string, db *database.DB) error {
+	if len(arr) < 3 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	values, err := db.JSONMGet(arr[1:len(arr)-1], arr[len(arr)-1])
+	if err != nil {
+		return writeError(conn, err)
+	}
+	res := make([][]byte, len(values))
+	for i, v := range values {
+		res[i] = nullBulkString(conn)
+		if v != nil {
+			res[i] = resp.NewBulkString(*v)
+		}
+	}
+	if _, err := conn.Write(resp.NewArray(res)); err != nil {
+		return fmt.Errorf("error writing to connection: %s", err.Error())
+	}
+	return nil
+}
+
+// handleJSONDel handles JSON.DEL and JSON.FORGET.
+func handleJSONDel(conn io.Writer, arr []string, db *database.DB) error {
+	if len(arr) != 2 && len(arr) != 3 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	n, err := db.JSONDel(arr[1], jsonPathArg(arr, 2))
+	if err != nil {
+		return writeError(conn, err)
+	}
+	if _, err := conn.Write(resp.NewInt(n)); err != nil {
+		return fmt.Errorf("error writing to connection: %s", err.Error())
+	}
+	return nil
+}
+
+func handleJSONType(conn io.Writer, arr []string, db *database.DB) error {
+	if len(arr) != 2 && len(arr) != 3 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	path := jsonPathArg(arr, 2)
+	types, err := db.JSONType(arr[1], path)
+	if err != nil {
+		return writeError(conn, err)
+	}
+	// like TYPE, the type of a legacy path is a simple string
+	if database.JSONPathIsLegacy(path) && len(types) > 0 {
+		if _, err := conn.Write(resp.NewSimpleString(types[0].(string))); err != nil {
+			return fmt.Errorf("error writing to connection: %s", err.Error())
+		}
+		return nil
+	}
+	return writeJSONResults(conn, path, types)
+}
+
+func handleJSONNumIncrBy(conn io.Writer, arr []string, db *database.DB) error {
+	if len(arr) != 4 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	s, err := db.JSONNumIncrBy(arr[1], arr[2], arr[3])
+	if err != nil {
+		return writeError(conn, err)
+	}
+	return writeBulkStringOrNull(conn, s, true)
+}
+
+func handleJSONStrAppend(conn io.Writer, arr []string, db *database.DB) error {
+	if len(arr) != 3 && len(arr) != 4 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	path := jsonPathArg(arr[:len(arr)-1], 2)
+	res, err := db.JSONStrAppend(arr[1], path, arr[len(arr)-1])
+	if err != nil {
+		return writeError(conn, err)
+	}
+	return writeJSONResults(conn, path, res)
+}
+
+func handleJSONArrAppend(conn io.Writer, arr []string, db *database.DB) error {
+	if len(arr) < 4 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	res, err := db.JSONArrAppend(arr[1], arr[2], arr[3:])
+	if err != nil {
+		return writeError(conn, err)
+	}
+	return writeJSONResults(conn, arr[2], res)
+}
+
+func handleJSONArrIndex(conn io.Writer, arr []string, db *database.DB) error {
+	if len(arr) < 4 || len(arr) > 6 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	bounds := []int{0, 0}
+	for i, s := range arr[4:] {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return writeError(conn, errNotInteger)
+		}
+		bounds[i] = n
+	}
+	res, err := db.JSONArrIndex(arr[1], arr[2], arr[3], bounds[0], bounds[1])
+	if err != nil {
+		return writeError(conn, err)
+	}
+	return writeJSONResults(conn, arr[2], res)
+}
+
+func handleJSONArrInsert(conn io.Writer, arr []string, db *database.DB) error {
+	if len(arr) < 5 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	index, err := strconv.Atoi(arr[3])
+	if err != nil {
+		return writeError(conn, errNotInteger)
+	}
+	res, err := db.JSONArrInsert(arr[1], arr[2], index, arr[4:])
+	if err != nil {
+		return writeError(conn, err)
+	}
+	return writeJSONResults(conn, arr[2], res)
+}
+
+func handleJSONArrLen(conn io.Writer, arr []string, db *database.DB) error {
+	if len(arr) != 2 && len(arr) != 3 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	path := jsonPathArg(arr, 2)
+	res, err := db.JSONArrLen(arr[1], path)
+	if err != nil {
+		return writeError(conn, err)
+	}
+	return writeJSONResults(conn, path, res)
+}
+
+func handleJSONArrPop(conn io.Writer, arr []string, db *database.DB) error {
+	if len(arr) < 2 || len(arr) > 4 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	path := jsonPathArg(arr, 2)
+	index := -1
+	if len(arr) == 4 {
+		var err error
+		if index, err = strconv.Atoi(arr[3]); err != nil {
+			return writeError(conn, errNotInteger)
+		}
+	}
+	res, err := db.JSONArrPop(arr[1], path, index)
+	if err != nil {
+		return writeError(conn, err)
+	}
+	return writeJSONResults(conn, path, res)
+}
+
+func handleJSONArrTrim(conn io.Writer, arr []string, db *database.DB) error {
+	if len(arr) != 5 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	start, err := strconv.Atoi(arr[3])
+	if err != nil {
+		return writeError(conn, errNotInteger)
+	}
+	stop, err := strconv.Atoi(arr[4])
+	if err != nil {
+		return writeError(conn, errNotInteger)
+	}
+	res, err := db.JSONArrTrim(arr[1], arr[2], start, stop)
+	if err != nil {
+		return writeError(conn, err)
+	}
+	return writeJSONResults(conn, arr[2], res)
+}
+
+func handleJSONObjKeys(conn io.Writer, arr []string, db *database.DB) error {
+	if len(arr) != 2 && len(arr) != 3 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	path := jsonPathArg(arr, 2)
+	res, err := db.JSONObjKeys(arr[1], path)
+	if err != nil {
+		return writeError(conn, err)
+	}
+	return writeJSONResults(conn, path, res)
+}
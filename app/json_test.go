@@ -0,0 +1,77 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/codecrafters-io/redis-starter-go/app/database"
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONCommands(t *testing.T) {
+	s := newServer(host, "0", []*database.DB{database.NewDB()}, RoleMaster, testCfg)
+	conn, r := pipeClient(t, s)
+
+	require.Equal(t, "OK", do(t, conn, r, "JSON.SET", "doc", "$", `{"a":2,"b":{"a":"x","arr":[1,2]}}`))
+	require.Nil(t, do(t, conn, r, "JSON.SET", "doc", "$", `{}`, "NX"))
+	require.Equal(t, "ERR new objects must be created at the root", do(t, conn, r, "JSON.SET", "other", "$.a", "1").(error).Error())
+	require.Equal(t, "ReJSON-RL", do(t, conn, r, "TYPE", "doc"))
+
+	require.Equal(t, `{"a":2,"b":{"a":"x","arr":[1,2]}}`, do(t, conn, r, "JSON.GET", "doc"))
+	require.Equal(t, `[2,"x"]`, do(t, conn, r, "JSON.GET", "doc", "$..a"))
+	require.Equal(t, "[\n  2\n]", do(t, conn, r, "JSON.GET", "doc", "INDENT", "  ", "NEWLINE", "\n", "$.a"))
+	require.Equal(t, `{"$.a":[2],"$.b.arr":[[1,2]]}`, do(t, conn, r, "JSON.GET", "doc", "$.a", "$.b.arr"))
+	require.Nil(t, do(t, conn, r, "JSON.GET", "missing"))
+	require.Equal(t, "ERR Path '.c' does not exist", do(t, conn, r, "JSON.GET", "doc", ".c").(error).Error())
+	require.Equal(t, []any{`[2]`, nil}, do(t, conn, r, "JSON.MGET", "doc", "missing", "$.a"))
+
+	require.Equal(t, "object", do(t, conn, r, "JSON.TYPE", "doc"))
+	require.Equal(t, []any{"integer", "string"}, do(t, conn, r, "JSON.TYPE", "doc", "$..a"))
+	require.Equal(t, `[3,null]`, do(t, conn, r, "JSON.NUMINCRBY", "doc", "$..a", "1"))
+	require.Equal(t, `4.5`, do(t, conn, r, "JSON.NUMINCRBY", "doc", ".a", "1.5"))
+	require.Equal(t, "WRONGTYPE wrong type of path value - expected a number but found string",
+		do(t, conn, r, "JSON.NUMINCRBY", "doc", ".b.a", "1").(error).Error())
+	require.Equal(t, []any{nil, 3}, do(t, conn, r, "JSON.STRAPPEND", "doc", "$..a", `"yz"`))
+	require.Equal(t, 5, do(t, conn, r, "JSON.STRAPPEND", "doc", ".b.a", `"!!"`))
+
+	require.Equal(t, []any{4}, do(t, conn, r, "JSON.ARRAPPEND", "doc", "$.b.arr", "3", `"s"`))
+	require.Equal(t, 5, do(t, conn, r, "JSON.ARRINSERT", "doc", ".b.arr", "0", "0"))
+	require.Equal(t, "ERR index out of bounds", do(t, conn, r, "JSON.ARRINSERT", "doc", "$.b.arr", "9", "0").(error).Error())
+	require.Equal(t, []any{4}, do(t, conn, r, "JSON.ARRINDEX", "doc", "$.b.arr", `"s"`))
+	require.Equal(t, []any{-1}, do(t, conn, r, "JSON.ARRINDEX", "doc", "$.b.arr", "0", "1"))
+	require.Equal(t, []any{nil, 5}, do(t, conn, r, "JSON.ARRLEN", "doc", "$.b.*"))
+	require.Equal(t, `"s"`, do(t, conn, r, "JSON.ARRPOP", "doc", ".b.arr"))
+	require.Equal(t, []any{"0"}, do(t, conn, r, "JSON.ARRPOP", "doc", "$.b.arr", "0"))
+	require.Equal(t, []any{2}, do(t, conn, r, "JSON.ARRTRIM", "doc", "$.b.arr", "1", "-1"))
+	require.Equal(t, `[2,3]`, do(t, conn, r, "JSON.GET", "doc", ".b.arr"))
+	require.Equal(t, []any{"a", "arr"}, do(t, conn, r, "JSON.OBJKEYS", "doc", ".b"))
+	require.Equal(t, []any{nil, []any{"a", "arr"}}, do(t, conn, r, "JSON.OBJKEYS", "doc", "$.*"))
+	require.Nil(t, do(t, conn, r, "JSON.ARRLEN", "missing"))
+	require.Equal(t, "ERR could not perform this operation on a key that doesn't exist",
+		do(t, conn, r, "JSON.ARRAPPEND", "missing", "$", "1").(error).Error())
+
+	require.Equal(t, 1, do(t, conn, r, "JSON.DEL", "doc", "$.b.a"))
+	require.Equal(t, 0, do(t, conn, r, "JSON.FORGET", "doc", "$.missing"))
+	require.Equal(t, 1, do(t, conn, r, "JSON.DEL", "doc"))
+	require.Nil(t, do(t, conn, r, "JSON.GET", "doc"))
+
+	do(t, conn, r, "SET", "str", "v")
+	require.Equal(t, "ERR wrong data type", do(t, conn, r, "JSON.GET", "str").(error).Error())
+	require.Contains(t, do(t, conn, r, "JSON.GET", "doc", "$[").(error).Error(), "invalid JSONPath")
+	require.Contains(t, do(t, conn, r, "JSON.SET", "doc", "$", "{").(error).Error(), "invalid JSON")
+}
+
+func TestPropagateJSON(t *testing.T) {
+	s := newServer(host, "0", []*database.DB{database.NewDB()}, RoleMaster, testCfg)
+	bl := s.replicationBacklog.RegisterReplica("test")
+	conn, r := pipeClient(t, s)
+
+	do(t, conn, r, "JSON.SET", "doc", "$", `{"a":[1]}`)
+	do(t, conn, r, "JSON.GET", "doc")
+	do(t, conn, r, "JSON.ARRAPPEND", "doc", "$.a", "2")
+
+	// the path updates are propagated as they are, not as the whole document
+	require.Equal(t, append(resp.NewCommand([]string{"SELECT", "0"}), resp.NewCommand([]string{"JSON.SET", "doc", "$", `{"a":[1]}`})...),
+		(<-bl.Broadcast).Data)
+	require.Equal(t, resp.NewCommand([]string{"JSON.ARRAPPEND", "doc", "$.a", "2"}), (<-bl.Broadcast).Data)
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/database"
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+var errDBIndexOutOfRange = fmt.Errorf("DB index is out of range")
+
+// parseDBIndex parses and validates a db index argument.
+func (s *server) parseDBIndex(arg string) (int, error) {
+	idx, err := strconv.Atoi(arg)
+	if err != nil {
+		return 0, fmt.Errorf("value is not an integer or out of range")
+	}
+	if idx < 0 || idx >= len(s.dbs) {
+		return 0, errDBIndexOutOfRange
+	}
+	return idx, nil
+}
+
+func (s *server) handleSelect(conn io.Writer, arr []string, state *clientState) error {
+	if len(arr) != 2 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	idx, err := s.parseDBIndex(arr[1])
+	if err != nil {
+		return writeError(conn, err)
+	}
+	state.db = idx
+	if _, err := conn.Write(resp.NewSimpleString("OK")); err != nil {
+		return fmt.Errorf("error writing to connection: %s", err.Error())
+	}
+	return nil
+}
+
+func handleRename(conn io.Writer, arr []string, db *database.DB) error {
+	if len(arr) != 3 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	nx := strings.ToUpper(arr[0]) == "RENAMENX"
+	renamed, err := db.Rename(arr[1], arr[2], nx)
+	if err != nil {
+		return writeError(conn, err)
+	}
+	if nx {
+		return writeBoolInt(conn, renamed)
+	}
+	if _, err := conn.Write(resp.NewSimpleString("OK")); err != nil {
+		return fmt.Errorf("error writing to connection: %s", err.Error())
+	}
+	return nil
+}
+
+func (s *server) handleMove(conn io.Writer, arr []string, state *clientState) error {
+	if len(arr) != 3 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	dst, err := s.parseDBIndex(arr[2])
+	if err != nil {
+		return writeError(conn, err)
+	}
+	if dst == state.db {
+		return writeError(conn, fmt.Errorf("source and destination objects are the same"))
+	}
+	return writeBoolInt(conn, database.Move(s.dbs[state.db], s.dbs[dst], arr[1]))
+}
+
+func (s *server) handleCopy(conn io.Writer, arr []string, state *clientState) error {
+	if len(arr) < 3 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	dst := state.db
+	replace := false
+	for i := 3; i < len(arr); i++ {
+		switch strings.ToUpper(arr[i]) {
+		case "REPLACE":
+			replace = true
+		case "DB":
+			if i+1 >= len(arr) {
+				return writeError(conn, errSyntax)
+			}
+			idx, err := s.parseDBIndex(arr[i+1])
+			if err != nil {
+				return writeError(conn, err)
+			}
+			dst = idx
+			i++
+		default:
+			return writeError(conn, errSyntax)
+		}
+	}
+	if dst == state.db && arr[1] == arr[2] {
+		return writeError(conn, fmt.Errorf("source and destination objects are the same"))
+	}
+	return writeBoolInt(conn, database.Copy(s.dbs[state.db], s.dbs[dst], arr[1], arr[2], replace))
+}
+
+func (s *server) handleSwapDB(conn io.Writer, arr []string) error {
+	if len(arr) != 3 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	a, err := s.parseDBIndex(arr[1])
+	if err != nil {
+		return writeError(conn, err)
+	}
+	b, err := s.parseDBIndex(arr[2])
+	if err != nil {
+		return writeError(conn, err)
+	}
+	if a != b {
+		database.Swap(s.dbs[a], s.dbs[b])
+	}
+	if _, err := conn.Write(resp.NewSimpleString("OK")); err != nil {
+		return fmt.Errorf("error writing to connection: %s", err.Error())
+	}
+	return nil
+}
+
+// handleDel handles DEL and UNLINK, DEL frees the values in the background too if lazyfree-lazy-user-del is set.
+func (s *server) handleDel(conn io.Writer, arr []string, db *database.DB) error {
+	if len(arr) < 2 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	async := strings.ToUpper(arr[0]) == "UNLINK" || s.config.lazyfreeLazyUserDel
+	if _, err := conn.Write(resp.NewInt(db.Del(arr[1:], async))); err != nil {
+		return fmt.Errorf("error writing to connection: %s", err.Error())
+	}
+	return nil
+}
+
+// handleFlush handles FLUSHDB and FLUSHALL [ASYNC|SYNC].
+func (s *server) handleFlush(conn io.Writer, arr []string, state *clientState) error {
+	if len(arr) > 2 {
+		return writeError(conn, errSyntax)
+	}
+	async := false
+	if len(arr) == 2 {
+		switch strings.ToUpper(arr[1]) {
+		case "ASYNC":
+			async = true
+		case "SYNC":
+		default:
+			return writeError(conn, errSyntax)
+		}
+	}
+	if strings.ToUpper(arr[0]) == "FLUSHALL" {
+		for _, db := range s.dbs {
+			db.Flush(async)
+		}
+	} else {
+		s.dbs[state.db].Flush(async)
+	}
+	if _, err := conn.Write(resp.NewSimpleString("OK")); err != nil {
+		return fmt.Errorf("error writing to connection: %s", err.Error())
+	}
+	return nil
+}
+
+func handleDBSize(conn io.Writer, arr []string, db *database.DB) error {
+	if len(arr) != 1 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	if _, err := conn.Write(resp.NewInt(db.Size())); err != nil {
+		return fmt.Errorf("error writing to connection: %s", err.Error())
+	}
+	return nil
+}
+
+func handleRandomKey(conn io.Writer, arr []string, db *database.DB) error {
+	if len(arr) != 1 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	key, ok := db.RandomKey()
+	if !ok {
+		if _, err := conn.Write(nullBulkString(conn)); err != nil {
+			return fmt.Errorf("error writing to connection: %s", err.Error())
+		}
+		return nil
+	}
+	if _, err := conn.Write(resp.NewBulkString(key)); err != nil {
+		return fmt.Errorf("error writing to connection: %s", err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/database"
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestDBs(n int) []*database.DB {
+	dbs := make([]*database.DB, n)
+	for i := range dbs {
+		dbs[i] = database.NewDB()
+	}
+	return dbs
+}
+
+func TestSelect(t *testing.T) {
+	s := newServer(host, "0", newTestDBs(2), RoleMaster, testCfg)
+	conn1, r1 := pipeClient(t, s)
+	conn2, r2 := pipeClient(t, s)
+
+	require.Equal(t, "OK", do(t, conn1, r1, "SELECT", "1"))
+	require.Equal(t, "OK", do(t, conn1, r1, "SET", "k", "db1"))
+	require.Nil(t, do(t, conn2, r2, "GET", "k"))
+	require.Equal(t, "db1", do(t, conn1, r1, "GET", "k"))
+	require.Equal(t, "ERR DB index is out of range", do(t, conn1, r1, "SELECT", "2").(error).Error())
+
+	// SWAPDB is visible to the clients connected to both databases
+	require.Equal(t, "OK", do(t, conn2, r2, "SWAPDB", "0", "1"))
+	require.Equal(t, "db1", do(t, conn2, r2, "GET", "k"))
+	require.Nil(t, do(t, conn1, r1, "GET", "k"))
+}
+
+func TestMoveCopyRename(t *testing.T) {
+	dbs := newTestDBs(2)
+	exp := time.Now().Add(time.Hour).UnixMilli()
+	dbs[0].SetExp("k", "v", exp)
+	s := newServer(host, "0", dbs, RoleMaster, testCfg)
+	conn, r := pipeClient(t, s)
+
+	require.Equal(t, 1, do(t, conn, r, "COPY", "k", "k2"))
+	require.Equal(t, 0, do(t, conn, r, "COPY", "k", "k2"))
+	require.Equal(t, 1, do(t, conn, r, "COPY", "k", "k", "DB", "1"))
+	require.Equal(t, "v", dbs[1].Get("k"))
+
+	require.Equal(t, "OK", do(t, conn, r, "RENAME", "k", "renamed"))
+	require.Nil(t, do(t, conn, r, "GET", "k"))
+	require.Equal(t, "ERR no such key", do(t, conn, r, "RENAME", "k", "x").(error).Error())
+	require.Equal(t, 0, do(t, conn, r, "RENAMENX", "renamed", "k2"))
+	require.Equal(t, 1, do(t, conn, r, "RENAMENX", "renamed", "k3"))
+	require.Equal(t, "v", dbs[0].Get("k3"))
+
+	dbs[1].Set("k3", "other")
+	require.Equal(t, 0, do(t, conn, r, "MOVE", "k3", "1"))
+	require.Equal(t, 1, do(t, conn, r, "MOVE", "k2", "1"))
+	require.Equal(t, "v", dbs[1].Get("k2"))
+	require.Equal(t, "ERR source and destination objects are the same", do(t, conn, r, "MOVE", "k3", "0").(error).Error())
+}
+
+func TestPropagateSelect(t *testing.T) {
+	s := newServer(host, "0", newTestDBs(2), RoleMaster, testCfg)
+	bl := s.replicationBacklog.RegisterReplica("test")
+	conn, r := pipeClient(t, s)
+
+	do(t, conn, r, "SET", "a", "1")
+	do(t, conn, r, "SET", "b", "1")
+	do(t, conn, r, "SELECT", "1")
+	do(t, conn, r, "SET", "c", "1")
+
+	selectCmd := func(db string) []byte { return resp.NewCommand([]string{"SELECT", db}) }
+	require.Equal(t, append(selectCmd("0"), resp.NewCommand([]string{"SET", "a", "1"})...), (<-bl.Broadcast).Data)
+	require.Equal(t, resp.NewCommand([]string{"SET", "b", "1"}), (<-bl.Broadcast).Data)
+	require.Equal(t, append(selectCmd("1"), resp.NewCommand([]string{"SET", "c", "1"})...), (<-bl.Broadcast).Data)
+}
+
+func TestFlushAndDel(t *testing.T) {
+	dbs := newTestDBs(2)
+	s := newServer(host, "0", dbs, RoleMaster, testCfg)
+	conn, r := pipeClient(t, s)
+
+	require.Nil(t, do(t, conn, r, "RANDOMKEY"))
+	do(t, conn, r, "SET", "a", "1")
+	do(t, conn, r, "SET", "b", "1")
+	do(t, conn, r, "SADD", "set", "m1", "m2")
+	require.Equal(t, 3, do(t, conn, r, "DBSIZE"))
+	require.Contains(t, []any{"a", "b", "set"}, do(t, conn, r, "RANDOMKEY"))
+
+	require.Equal(t, 2, do(t, conn, r, "DEL", "a", "set", "missing"))
+	require.Equal(t, 1, do(t, conn, r, "UNLINK", "b"))
+	require.Equal(t, 0, do(t, conn, r, "DBSIZE"))
+
+	dbs[1].Set("c", "1")
+	do(t, conn, r, "SET", "a", "1")
+	require.Equal(t, "OK", do(t, conn, r, "FLUSHDB", "ASYNC"))
+	require.Equal(t, 0, do(t, conn, r, "DBSIZE"))
+	require.Equal(t, 1, dbs[1].Size())
+	require.Equal(t, "OK", do(t, conn, r, "FLUSHALL", "SYNC"))
+	require.Equal(t, 0, dbs[1].Size())
+	require.Equal(t, "ERR syntax error", do(t, conn, r, "FLUSHALL", "NOW").(error).Error())
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/database"
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+var errLCSLenAndIdx = errors.New("If you want both the length and indexes, please just use IDX.")
+
+// handleLCS limits the table of LCS to proto-max-bulk-len, so that two large strings cannot exhaust the memory.
+func (s *server) handleLCS(conn io.Writer, arr []string, db *database.DB) error {
+	if len(arr) < 3 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	opts := database.LCSOptions{MaxMemory: s.config.protoMaxBulkLen}
+	getLen, withMatchLen := false, false
+	for i := 3; i < len(arr); i++ {
+		switch opt := strings.ToUpper(arr[i]); {
+		case opt == "LEN":
+			getLen = true
+		case opt == "IDX":
+			opts.Idx = true
+		case opt == "WITHMATCHLEN":
+			withMatchLen = true
+		case opt == "MINMATCHLEN" && i+1 < len(arr):
+			n, err := strconv.ParseInt(arr[i+1], 10, 64)
+			if err != nil {
+				return writeError(conn, errNotInteger)
+			}
+			opts.MinMatchLen = int(max(n, 0))
+			i++
+		default:
+			return writeError(conn, errSyntax)
+		}
+	}
+	if getLen && opts.Idx {
+		return writeError(conn, errLCSLenAndIdx)
+	}
+	res, err := db.LCS(arr[1], arr[2], opts)
+	if err != nil {
+		return writeError(conn, err)
+	}
+	var msg []byte
+	switch {
+	case getLen:
+		msg = resp.NewInt(res.Len)
+	case opts.Idx:
+		matches := make([][]byte, len(res.Matches))
+		for i, m := range res.Matches {
+			match := [][]byte{
+				resp.NewArray([][]byte{resp.NewInt(m.A[0]), resp.NewInt(m.A[1])}),
+				resp.NewArray([][]byte{resp.NewInt(m.B[0]), resp.NewInt(m.B[1])}),
+			}
+			if withMatchLen {
+				match = append(match, resp.NewInt(m.Len))
+			}
+			matches[i] = resp.NewArray(match)
+		}
+		msg = mapReply(conn, [][]byte{
+			resp.NewBulkString("matches"), resp.NewArray(matches),
+			resp.NewBulkString("len"), resp.NewInt(res.Len),
+		})
+	default:
+		msg = resp.NewBulkString(res.Str)
+	}
+	if _, err := conn.Write(msg); err != nil {
+		return fmt.Errorf("error writing to connection: %s", err.Error())
+	}
+	return nil
+}
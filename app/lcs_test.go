@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/codecrafters-io/redis-starter-go/app/database"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLCSCommand(t *testing.T) {
+	s := newServer(host, "0", []*database.DB{database.NewDB()}, RoleMaster, testCfg)
+	conn, r := pipeClient(t, s)
+
+	do(t, conn, r, "SET", "key1", "ohmytext")
+	do(t, conn, r, "SET", "key2", "mynewtext")
+	require.Equal(t, "mytext", do(t, conn, r, "LCS", "key1", "key2"))
+	require.Equal(t, 6, do(t, conn, r, "LCS", "key1", "key2", "LEN"))
+	require.Equal(t, []any{
+		"matches", []any{
+			[]any{[]any{4, 7}, []any{5, 8}},
+			[]any{[]any{2, 3}, []any{0, 1}},
+		},
+		"len", 6,
+	}, do(t, conn, r, "LCS", "key1", "key2", "IDX"))
+	require.Equal(t, []any{
+		"matches", []any{
+			[]any{[]any{4, 7}, []any{5, 8}, 4},
+		},
+		"len", 6,
+	}, do(t, conn, r, "LCS", "key1", "key2", "IDX", "MINMATCHLEN", "4", "WITHMATCHLEN"))
+
+	require.Equal(t, "ERR If you want both the length and indexes, please just use IDX.", do(t, conn, r, "LCS", "key1", "key2", "LEN", "IDX").(error).Error())
+	require.Equal(t, "ERR syntax error", do(t, conn, r, "LCS", "key1", "key2", "MINMATCHLEN").(error).Error())
+	do(t, conn, r, "HSET", "h", "f", "v")
+	require.Equal(t, "ERR The specified keys must contain string values", do(t, conn, r, "LCS", "key1", "h").(error).Error())
+}
+
+func TestLCSMaxMemory(t *testing.T) {
+	// the table of two 8 bytes strings takes 81 cells of 4 bytes
+	s := newServer(host, "0", []*database.DB{database.NewDB()}, RoleMaster, config{protoMaxBulkLen: 300})
+	conn, r := pipeClient(t, s)
+
+	do(t, conn, r, "SET", "key1", "ohmytext")
+	do(t, conn, r, "SET", "key2", "mynewtxt")
+	require.ErrorContains(t, do(t, conn, r, "LCS", "key1", "key2").(error), "ERR Insufficient memory")
+}
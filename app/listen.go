@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var errNoListener = errors.New("Configured to not listen anywhere, exiting.")
+
+// bindAddrs returns the addresses to listen on, the host of the server unless bind is set.
+func (s *server) bindAddrs() []string {
+	if len(s.config.bind) > 0 {
+		return s.config.bind
+	}
+	return []string{s.host}
+}
+
+// listen opens the listeners of the server: one per bind address on the TCP port, unless the port is 0,
+// one per bind address on the TLS port if set, and one on the unix socket if set.
+func (s *server) listen() ([]net.Listener, error) {
+	lc := s.netConfig
+	if lc == nil {
+		lc = &net.ListenConfig{}
+	}
+	var listeners []net.Listener
+	closeAll := func() {
+		for _, l := range listeners {
+			l.Close()
+		}
+	}
+	if s.port != "0" {
+		ls, err := s.listenTCP(lc, s.port)
+		if err != nil {
+			return nil, err
+		}
+		listeners = append(listeners, ls...)
+	}
+	if s.config.tlsPort != "0" {
+		tlsConfig, err := s.config.serverTLSConfig()
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		ls, err := s.listenTCP(lc, s.config.tlsPort)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		for _, l := range ls {
+			listeners = append(listeners, tls.NewListener(l, tlsConfig))
+		}
+	}
+	if s.config.unixsocket != "" {
+		// a socket left behind by a previous run would make the listen fail
+		if err := os.Remove(s.config.unixsocket); err != nil && !errors.Is(err, os.ErrNotExist) {
+			closeAll()
+			return nil, fmt.Errorf("error removing unix socket: %v", err.Error())
+		}
+		l, err := lc.Listen(context.Background(), "unix", s.config.unixsocket)
+		if err != nil {
+			closeAll()
+			return nil, fmt.Errorf("error listening: %v", err.Error())
+		}
+		listeners = append(listeners, l)
+		if s.config.unixsocketperm != 0 {
+			if err := os.Chmod(s.config.unixsocket, s.config.unixsocketperm); err != nil {
+				closeAll()
+				return nil, fmt.Errorf("error setting unix socket permissions: %v", err.Error())
+			}
+		}
+	}
+	if len(listeners) == 0 {
+		return nil, errNoListener
+	}
+	return listeners, nil
+}
+
+// listenTCP listens on the port of every bind address. An address prefixed with - is skipped if it is not available,
+// * stands for all the IPv4 addresses and ::* for all the IPv6 ones.
+// ref: https://github.com/redis/redis/blob/7.2.0/redis.conf#L61
+func (s *server) listenTCP(lc *net.ListenConfig, port string) ([]net.Listener, error) {
+	var listeners []net.Listener
+	for _, addr := range s.bindAddrs() {
+		optional := strings.HasPrefix(addr, "-")
+		network, host := bindNetwork(strings.TrimPrefix(addr, "-"))
+		l, err := lc.Listen(context.Background(), network, net.JoinHostPort(host, port))
+		if err != nil {
+			if optional && unavailableAddr(err) {
+				continue
+			}
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, fmt.Errorf("error listening: %v", err.Error())
+		}
+		listeners = append(listeners, l)
+	}
+	return listeners, nil
+}
+
+// bindNetwork returns the network and the host to listen on for a bind address, the IP literals are only
+// listened on with their own IP version, so that * and ::* do not both take the port.
+func bindNetwork(addr string) (string, string) {
+	switch addr {
+	case "*":
+		return "tcp4", "0.0.0.0"
+	case "::*":
+		return "tcp6", "::"
+	}
+	ip := net.ParseIP(addr)
+	switch {
+	case ip == nil:
+		return "tcp", addr
+	case ip.To4() != nil:
+		return "tcp4", addr
+	}
+	return "tcp6", addr
+}
+
+// unavailableAddr tells if listening failed because the address or its IP version is not available on the host.
+func unavailableAddr(err error) bool {
+	return errors.Is(err, syscall.EADDRNOTAVAIL) || errors.Is(err, syscall.EAFNOSUPPORT) || errors.Is(err, syscall.EPROTONOSUPPORT)
+}
+
+// serve accepts the connections of a listener until it is closed, all the listeners share the client handler.
+func (s *server) serve(l net.Listener, h func(net.Conn) error) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			fmt.Println("error accepting connection: ", err.Error())
+			os.Exit(1)
+		}
+		if err := setKeepAlive(conn, s.config.tcpKeepalive); err != nil {
+			fmt.Println(err)
+		}
+		go func(conn net.Conn) {
+			// a broken client connection should not take down the server
+			if err := h(conn); err != nil {
+				fmt.Println(err)
+			}
+		}(conn)
+	}
+}
+
+// setKeepAlive enables the TCP keepalives with the given period, or disables them for 0.
+func setKeepAlive(conn net.Conn, period time.Duration) error {
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		conn = tlsConn.NetConn()
+	}
+	tc, ok := conn.(*net.TCPConn)
+	if !ok {
+		return nil
+	}
+	if err := tc.SetKeepAlive(period > 0); err != nil {
+		return fmt.Errorf("error setting keepalive: %s", err.Error())
+	}
+	if period > 0 {
+		if err := tc.SetKeepAlivePeriod(period); err != nil {
+			return fmt.Errorf("error setting keepalive: %s", err.Error())
+		}
+	}
+	return nil
+}
+
+// parseUnixsocketperm parses the permissions of the unix socket, given in octal like chmod.
+func parseUnixsocketperm(s string) (os.FileMode, error) {
+	perm, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || perm > 0o777 {
+		return 0, fmt.Errorf("invalid unixsocketperm %q", s)
+	}
+	return os.FileMode(perm), nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/database"
+	"github.com/stretchr/testify/require"
+)
+
+// freePort returns a TCP port nothing listens on.
+func freePort(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	return strconv.Itoa(l.Addr().(*net.TCPAddr).Port)
+}
+
+func TestBindNetwork(t *testing.T) {
+	for addr, want := range map[string][2]string{
+		"*":         {"tcp4", "0.0.0.0"},
+		"::*":       {"tcp6", "::"},
+		"127.0.0.1": {"tcp4", "127.0.0.1"},
+		"::1":       {"tcp6", "::1"},
+		"localhost": {"tcp", "localhost"},
+	} {
+		network, host := bindNetwork(addr)
+		require.Equal(t, want, [2]string{network, host}, addr)
+	}
+}
+
+func TestListen(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "redis.sock")
+	port := freePort(t)
+	// the optional addresses which are not available are skipped
+	cfg := config{bind: []string{"127.0.0.1", "-::1", "-192.0.2.1"}, unixsocket: sock, unixsocketperm: 0o700}
+	s := newServer(host, port, []*database.DB{database.NewDB()}, RoleMaster, cfg)
+	shutdown := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	go func() {
+		s.Start(shutdown, s.handler)
+		close(done)
+	}()
+
+	var conn net.Conn
+	require.Eventually(t, func() bool {
+		var err error
+		conn, err = net.Dial("unix", sock)
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	require.Equal(t, "PONG", do(t, conn, r, "PING"))
+	require.Contains(t, do(t, conn, r, "CLIENT", "INFO"), "addr="+sock+":0 laddr="+sock+":0 ")
+	require.Equal(t, []any{"unixsocketperm", "700"}, do(t, conn, r, "CONFIG", "GET", "unixsocketperm"))
+	st, err := os.Stat(sock)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o700), st.Mode().Perm())
+
+	// the TCP clients are handled by the same server
+	tcpConn, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", port))
+	require.NoError(t, err)
+	defer tcpConn.Close()
+	tcpR := bufio.NewReader(tcpConn)
+	require.Equal(t, "OK", do(t, tcpConn, tcpR, "SET", "k", "v"))
+	require.Equal(t, "v", do(t, conn, r, "GET", "k"))
+
+	shutdown <- os.Interrupt
+	<-done
+	_, err = os.Stat(sock)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestListenPortZero(t *testing.T) {
+	s := newServer(host, "0", []*database.DB{database.NewDB()}, RoleMaster, config{bind: []string{"127.0.0.1"}})
+	_, err := s.listen()
+	require.ErrorIs(t, err, errNoListener)
+
+	// a socket left behind is replaced
+	sock := filepath.Join(t.TempDir(), "redis.sock")
+	require.NoError(t, os.WriteFile(sock, nil, 0o600))
+	s.config.unixsocket = sock
+	listeners, err := s.listen()
+	require.NoError(t, err)
+	require.Len(t, listeners, 1)
+	require.Equal(t, "unix", listeners[0].Addr().Network())
+	listeners[0].Close()
+
+	s = newServer(host, freePort(t), []*database.DB{database.NewDB()}, RoleMaster, config{bind: []string{"192.0.2.1"}})
+	_, err = s.listen()
+	require.ErrorContains(t, err, "error listening")
+}
@@ -0,0 +1,479 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/database"
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+// oomError is replied to the commands which may use more memory when the limit cannot be enforced by evicting keys.
+const oomError = "-OOM command not allowed when used memory > 'maxmemory'.\r\n"
+
+// parseMemory parses a memory size with an optional unit like the redis configuration, e.g. 100mb.
+// k, m and g are powers of 1000, kb, mb and gb are powers of 1024.
+func parseMemory(s string) (int64, error) {
+	units := []struct {
+		suffix string
+		mul    int64
+	}{
+		{"kb", 1 << 10}, {"mb", 1 << 20}, {"gb", 1 << 30},
+		{"k", 1000}, {"m", 1000 * 1000}, {"g", 1000 * 1000 * 1000}, {"b", 1},
+	}
+	lower := strings.ToLower(s)
+	mul := int64(1)
+	for _, u := range units {
+		if strings.HasSuffix(lower, u.suffix) {
+			lower = strings.TrimSuffix(lower, u.suffix)
+			mul = u.mul
+			break
+		}
+	}
+	n, err := strconv.ParseInt(lower, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid memory size %q", s)
+	}
+	return n * mul, nil
+}
+
+func validPolicy(policy string) bool {
+	return slices.Contains(database.Policies, policy)
+}
+
+// usedMemory returns the estimated memory of the keys of all the dbs.
+func (s *server) usedMemory() int64 {
+	var used int64
+	for _, db := range s.dbs {
+		used += db.UsedMemory()
+	}
+	return used
+}
+
+// performEvictions evicts keys until the used memory is under maxmemory, it returns false if the limit cannot be reached.
+// The evictions are propagated as DEL so that the replicas, which do not evict by themselves, stay consistent.
+// ref: https://github.com/redis/redis/blob/7.2.0/src/evict.c#L528
+func (s *server) performEvictions() bool {
+	if s.config.maxmemory == 0 || s.role != RoleMaster {
+		return true
+	}
+	for s.usedMemory() > s.config.maxmemory {
+		idx, key, ok := s.evictor.Evict(s.dbs, s.config.maxmemoryPolicy, s.config.maxmemorySamples)
+		if !ok {
+			return false
+		}
+		s.evictedKeys.Add(1)
+		s.propagate(idx, []string{"DEL", key})
+		s.invalidate(nil, []string{key})
+	}
+	return true
+}
+
+// maxValueSize is the largest value a command may allocate from its size arguments, like a filter reserved for a capacity.
+// It is proto-max-bulk-len, lowered to maxmemory if set, so that a wrong size gets an error instead of exhausting the memory.
+func (s *server) maxValueSize() int64 {
+	if s.config.maxmemory > 0 {
+		return min(s.config.protoMaxBulkLen, s.config.maxmemory)
+	}
+	return s.config.protoMaxBulkLen
+}
+
+func writeOOM(conn io.Writer) error {
+	if _, err := conn.Write([]byte(oomError)); err != nil {
+		return fmt.Errorf("error writing to connection: %s", err.Error())
+	}
+	return nil
+}
+
+// humanBytes formats a memory size like the *_human fields of INFO.
+func humanBytes(n int64) string {
+	f := float64(n)
+	switch {
+	case f < 1024:
+		return fmt.Sprintf("%dB", n)
+	case f < 1024*1024:
+		return fmt.Sprintf("%.2fK", f/1024)
+	case f < 1024*1024*1024:
+		return fmt.Sprintf("%.2fM", f/(1024*1024))
+	}
+	return fmt.Sprintf("%.2fG", f/(1024*1024*1024))
+}
+
+// handleInfo replies the requested sections of INFO, all of them if none is given.
+// ref: https://redis.io/docs/latest/commands/info/
+func (s *server) handleInfo(conn io.Writer, arr []string) error {
+	sections := []struct {
+		name   string
+		fields func() []string
+	}{
+		{"replication", func() []string {
+			return []string{
+				"role:" + s.role,
+				"master_replid:" + s.masterReplid,
+				fmt.Sprintf("master_repl_offset:%d", s.masterOffset),
+			}
+		}},
+		{"clients", func() []string {
+			return []string{
+				fmt.Sprintf("connected_clients:%d", s.connectedClients.Load()),
+				fmt.Sprintf("maxclients:%d", s.config.maxclients),
+			}
+		}},
+		{"memory", func() []string {
+			used := s.usedMemory()
+			return []string{
+				fmt.Sprintf("used_memory:%d", used),
+				"used_memory_human:" + humanBytes(used),
+				fmt.Sprintf("maxmemory:%d", s.config.maxmemory),
+				"maxmemory_human:" + humanBytes(s.config.maxmemory),
+				"maxmemory_policy:" + s.config.maxmemoryPolicy,
+			}
+		}},
+		{"stats", func() []string {
+			return []string{
+				fmt.Sprintf("rejected_connections:%d", s.rejectedConns.Load()),
+				fmt.Sprintf("evicted_keys:%d", s.evictedKeys.Load()),
+			}
+		}},
+	}
+	requested := map[string]bool{}
+	for _, name := range arr[1:] {
+		requested[strings.ToLower(name)] = true
+	}
+	all := len(requested) == 0 || requested["all"] || requested["everything"] || requested["default"]
+	res := []string{}
+	for _, sec := range sections {
+		if !all && !requested[sec.name] {
+			continue
+		}
+		header := "# " + strings.ToUpper(sec.name[:1]) + sec.name[1:]
+		res = append(res, header+"\n"+strings.Join(sec.fields(), "\n"))
+	}
+	if _, err := conn.Write(verbatimReply(conn, strings.Join(res, "\n\n"))); err != nil {
+		return fmt.Errorf("error writing to connection: %s", err.Error())
+	}
+	return nil
+}
+
+// readBufferSize is the size of the bufio.Reader of every connection.
+const readBufferSize = 4096
+
+var memoryHelp = []string{
+	"MEMORY <subcommand> [<arg> [value] [opt] ...]. Subcommands are:",
+	"DOCTOR",
+	"    Return memory problems reports.",
+	"STATS",
+	"    Return information about the memory usage of the server.",
+	"USAGE <key> [SAMPLES <count>]",
+	"    Return memory in bytes used by <key> and its value. Nested values are",
+	"    sampled up to <count> times (default: 5, 0 means sample all).",
+	"BIGKEYS",
+	"    Scan the selected database for the biggest key of every type, by length.",
+	"MEMKEYS [SAMPLES <count>]",
+	"    Scan the selected database for the biggest key of every type, by memory usage.",
+	"HELP",
+	"    Print this help.",
+}
+
+func (s *server) handleMemory(conn io.Writer, arr []string, db *database.DB) error {
+	if len(arr) < 2 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	var res []byte
+	switch sub := strings.ToUpper(arr[1]); {
+	case sub == "HELP" && len(arr) == 2:
+		lines := make([][]byte, len(memoryHelp))
+		for i, l := range memoryHelp {
+			lines[i] = resp.NewSimpleString(l)
+		}
+		res = resp.NewArray(lines)
+	case sub == "USAGE" && (len(arr) == 3 || len(arr) == 5):
+		samples, err := parseMemorySamples(arr[3:])
+		if err != nil {
+			return writeError(conn, err)
+		}
+		usage, ok := db.MemoryUsage(arr[2], samples)
+		if !ok {
+			res = nullBulkString(conn)
+		} else {
+			res = resp.NewInt(int(usage))
+		}
+	case sub == "STATS" && len(arr) == 2:
+		res = s.memoryStats().reply(conn)
+	case sub == "DOCTOR" && len(arr) == 2:
+		res = verbatimReply(conn, s.memoryStats().doctor())
+	case sub == "BIGKEYS" && len(arr) == 2:
+		res = resp.NewBulkString(findBigKeys(db, false, 0))
+	case sub == "MEMKEYS" && (len(arr) == 2 || len(arr) == 4):
+		samples, err := parseMemorySamples(arr[2:])
+		if err != nil {
+			return writeError(conn, err)
+		}
+		res = resp.NewBulkString(findBigKeys(db, true, samples))
+	default:
+		return writeError(conn, fmt.Errorf("unknown subcommand or wrong number of arguments for '%s'. Try MEMORY HELP.", arr[1]))
+	}
+	if _, err := conn.Write(res); err != nil {
+		return fmt.Errorf("error writing to connection: %s", err.Error())
+	}
+	return nil
+}
+
+// parseMemorySamples parses the optional [SAMPLES count] argument.
+func parseMemorySamples(args []string) (int, error) {
+	if len(args) == 0 {
+		return database.DefaultMemorySamples, nil
+	}
+	if !strings.EqualFold(args[0], "SAMPLES") {
+		return 0, errSyntax
+	}
+	samples, err := strconv.Atoi(args[1])
+	if err != nil || samples < 0 {
+		return 0, errors.New("value is out of range, must be positive")
+	}
+	return samples, nil
+}
+
+// memoryStats is the breakdown of MEMORY STATS, the dataset is estimated like the limit of maxmemory,
+// the Go runtime heap is reported separately since it also accounts the garbage not collected yet.
+type memoryStats struct {
+	dbs               []database.MemoryStats
+	replicationBuffer int64
+	clientsReplicas   int64
+	clientsNormal     int64
+	clients           int64 // connected clients, excluding the replicas
+	runtime           runtime.MemStats
+}
+
+func (s *server) memoryStats() memoryStats {
+	st := memoryStats{dbs: make([]database.MemoryStats, len(s.dbs))}
+	for i, db := range s.dbs {
+		st.dbs[i] = db.MemoryStats()
+	}
+	replicas, pending := s.replicationBacklog.Stats()
+	st.replicationBuffer = pending
+	st.clientsReplicas = int64(replicas) * readBufferSize
+	// the read buffer of every client and the replies waiting to be sent to it
+	for _, c := range s.clients.list() {
+		if c.getType() == clientTypeReplica {
+			continue
+		}
+		st.clients++
+		st.clientsNormal += readBufferSize + int64(c.out.Buffered())
+	}
+	runtime.ReadMemStats(&st.runtime)
+	return st
+}
+
+func (st memoryStats) overhead() int64 {
+	total := st.replicationBuffer + st.clientsReplicas + st.clientsNormal
+	for _, db := range st.dbs {
+		total += db.OverheadMain + db.OverheadExpires
+	}
+	return total
+}
+
+func (st memoryStats) dataset() int64 {
+	var total int64
+	for _, db := range st.dbs {
+		total += db.Dataset()
+	}
+	return total
+}
+
+func (st memoryStats) keys() int {
+	keys := 0
+	for _, db := range st.dbs {
+		keys += db.Keys
+	}
+	return keys
+}
+
+// reply encodes the stats as a map, or as name, value pairs in RESP2.
+func (st memoryStats) reply(w io.Writer) []byte {
+	total := st.overhead() + st.dataset()
+	res := [][]byte{}
+	add := func(name string, value []byte) {
+		res = append(res, resp.NewBulkString(name), value)
+	}
+	add("total.allocated", resp.NewInt(int(total)))
+	add("replication.backlog", resp.NewInt(int(st.replicationBuffer)))
+	add("clients.slaves", resp.NewInt(int(st.clientsReplicas)))
+	add("clients.normal", resp.NewInt(int(st.clientsNormal)))
+	for i, db := range st.dbs {
+		if db.Keys == 0 {
+			continue
+		}
+		add(fmt.Sprintf("db.%d", i), mapReply(w, [][]byte{
+			resp.NewBulkString("overhead.hashtable.main"), resp.NewInt(int(db.OverheadMain)),
+			resp.NewBulkString("overhead.hashtable.expires"), resp.NewInt(int(db.OverheadExpires)),
+		}))
+	}
+	add("overhead.total", resp.NewInt(int(st.overhead())))
+	keys := st.keys()
+	add("keys.count", resp.NewInt(keys))
+	bytesPerKey := 0
+	if keys > 0 {
+		bytesPerKey = int(total) / keys
+	}
+	add("keys.bytes-per-key", resp.NewInt(bytesPerKey))
+	add("dataset.bytes", resp.NewInt(int(st.dataset())))
+	add("dataset.percentage", doubleReply(w, percentage(st.dataset(), total)))
+	add("runtime.heap.allocated", resp.NewInt(int(st.runtime.HeapAlloc)))
+	add("runtime.heap.inuse", resp.NewInt(int(st.runtime.HeapInuse)))
+	add("runtime.heap.sys", resp.NewInt(int(st.runtime.HeapSys)))
+	add("runtime.gc.count", resp.NewInt(int(st.runtime.NumGC)))
+	return mapReply(w, res)
+}
+
+func percentage(part, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part) * 100 / float64(total)
+}
+
+// Thresholds of MEMORY DOCTOR.
+// ref: https://github.com/redis/redis/blob/7.2.0/src/object.c#L1435
+const (
+	doctorEmptyThreshold      = 5 << 20
+	doctorHeapRatioThreshold  = 1.4
+	doctorClientsThreshold    = 200 << 10
+	doctorReplBufferThreshold = 10 << 20
+)
+
+// doctor reports the likely memory issues, like MEMORY DOCTOR in redis.
+func (st memoryStats) doctor() string {
+	total := st.overhead() + st.dataset()
+	if int64(st.runtime.HeapAlloc) < doctorEmptyThreshold && total < doctorEmptyThreshold {
+		return "Hi Sam, this instance is empty or is using very little memory, my issues detector can't be used in these conditions. " +
+			"Please, leave for your mission on Earth and fill it with some data. The new Sam and I will be back to our programming as soon as I finished rebooting."
+	}
+	issues := []string{}
+	if ratio := float64(st.runtime.HeapInuse) / float64(max(total, 1)); ratio > doctorHeapRatioThreshold {
+		issues = append(issues, fmt.Sprintf(" * High heap usage: the Go heap in use is %.2f times the estimated memory of the dataset. "+
+			"This may be garbage not collected yet, values recently deleted, or copies held by commands in flight.", ratio))
+	}
+	if st.clients > 0 && st.clientsNormal/st.clients > doctorClientsThreshold {
+		issues = append(issues, " * Big client buffers: the clients use on average more than 200k of buffers.")
+	}
+	if st.replicationBuffer > doctorReplBufferThreshold {
+		issues = append(issues, " * Big replica buffers: more than 10 MB of commands are waiting to be sent to the replicas. "+
+			"The replicas may be slow or the link with them may be saturated.")
+	}
+	if len(issues) == 0 {
+		return "Hi Sam, I can't find any memory issue in your instance. I can only account for what occurs on this base."
+	}
+	return "Sam, I detected a few issues in this Redis instance memory implants:\n\n" + strings.Join(issues, "\n\n") +
+		"\n\nI'm here to keep you safe, Sam. I want to help you."
+}
+
+// bigKeysTypes are the types reported by BIGKEYS and MEMKEYS with the unit of their length.
+var bigKeysTypes = []struct {
+	typ  string
+	unit string
+}{
+	{database.TypeString, "bytes"},
+	{database.TypeList, "items"},
+	{database.TypeHash, "fields"},
+	{database.TypeSet, "members"},
+	{database.TypeZSet, "members"},
+	{database.TypeStream, "entries"},
+	{database.TypeBloom, "items"},
+	{database.TypeCuckoo, "items"},
+	{database.TypeCMS, "counters"},
+	{database.TypeTopK, "items"},
+	{database.TypeJSON, "bytes"},
+}
+
+// findBigKeys scans the db for the biggest key of every type like redis-cli --bigkeys, or --memkeys if mem is set.
+// The keyspace is iterated with SCAN so that other clients are not blocked for the whole scan.
+// ref: https://github.com/redis/redis/blob/7.2.0/src/redis-cli.c#L8787
+func findBigKeys(db *database.DB, mem bool, samples int) string {
+	type typeStats struct {
+		count   int
+		total   int64
+		biggest string
+		size    int64
+	}
+	stats := map[string]*typeStats{}
+	for _, t := range bigKeysTypes {
+		stats[t.typ] = &typeStats{}
+	}
+	sampled := 0
+	keyLen := 0
+	cursor := uint64(0)
+	for {
+		var keys []string
+		cursor, keys = db.Scan(cursor, database.ScanOptions{})
+		for _, key := range keys {
+			typ, length, ok := db.Len(key)
+			if !ok {
+				continue
+			}
+			size := int64(length)
+			if mem {
+				if size, ok = db.MemoryUsage(key, samples); !ok {
+					continue
+				}
+			}
+			st, ok := stats[typ]
+			if !ok {
+				continue
+			}
+			sampled++
+			keyLen += len(key)
+			st.count++
+			st.total += size
+			if st.biggest == "" || size > st.size {
+				st.biggest, st.size = key, size
+			}
+		}
+		if cursor == 0 {
+			break
+		}
+	}
+	var sb strings.Builder
+	if mem {
+		sb.WriteString("# Scanning the entire keyspace to find biggest keys as well as\n# average sizes per key type.  You can use SAMPLES to get\n# more accurate memory usage of the nested values.\n\n")
+	} else {
+		sb.WriteString("# Scanning the entire keyspace to find biggest keys as well as\n# average sizes per key type.\n\n")
+	}
+	sb.WriteString("-------- summary -------\n\n")
+	avgKeyLen := 0.0
+	if sampled > 0 {
+		avgKeyLen = float64(keyLen) / float64(sampled)
+	}
+	fmt.Fprintf(&sb, "Sampled %d keys in the keyspace!\n", sampled)
+	fmt.Fprintf(&sb, "Total key length in bytes is %d (avg len %.2f)\n\n", keyLen, avgKeyLen)
+	for _, t := range bigKeysTypes {
+		st := stats[t.typ]
+		unit := t.unit
+		if mem {
+			unit = "bytes"
+		}
+		if st.biggest != "" {
+			fmt.Fprintf(&sb, "Biggest %6s found '%s' has %d %s\n", t.typ, st.biggest, st.size, unit)
+		}
+	}
+	sb.WriteString("\n")
+	for _, t := range bigKeysTypes {
+		st := stats[t.typ]
+		unit := t.unit
+		if mem {
+			unit = "bytes"
+		}
+		avg := 0.0
+		if st.count > 0 {
+			avg = float64(st.total) / float64(st.count)
+		}
+		fmt.Fprintf(&sb, "%d %ss with %d %s (%.2f%% of keys, avg size %.2f)\n",
+			st.count, t.typ, st.total, unit, percentage(int64(st.count), int64(max(sampled, 1))), avg)
+	}
+	return sb.String()
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/database"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMemory(t *testing.T) {
+	for in, want := range map[string]int64{"0": 0, "100": 100, "1k": 1000, "1kb": 1024, "2MB": 2 << 20, "1g": 1e9, "10b": 10} {
+		got, err := parseMemory(in)
+		require.NoError(t, err, in)
+		require.Equal(t, want, got, in)
+	}
+	_, err := parseMemory("1tb")
+	require.Error(t, err)
+}
+
+func TestMaxmemoryEviction(t *testing.T) {
+	cfg := config{maxmemory: 2000, maxmemoryPolicy: database.PolicyAllKeysLRU, maxmemorySamples: 5}
+	s := newServer(host, "0", []*database.DB{database.NewDB()}, RoleMaster, cfg)
+	conn, r := pipeClient(t, s)
+
+	for i := 0; i < 100; i++ {
+		require.Equal(t, "OK", do(t, conn, r, "SET", fmt.Sprintf("key%d", i), strings.Repeat("v", 50)))
+	}
+	// the limit is enforced before the next command
+	do(t, conn, r, "PING")
+	require.LessOrEqual(t, s.usedMemory(), cfg.maxmemory)
+	require.Less(t, do(t, conn, r, "DBSIZE"), 100)
+	require.Equal(t, "OK", do(t, conn, r, "SET", "key99", "v"))
+
+	info := do(t, conn, r, "INFO", "stats").(string)
+	require.Contains(t, info, "# Stats\n")
+	require.Regexp(t, `evicted_keys:[1-9]\d*`, info)
+	require.Contains(t, do(t, conn, r, "INFO", "memory"), "maxmemory_policy:allkeys-lru")
+	require.Equal(t, []any{"maxmemory", "2000"}, do(t, conn, r, "CONFIG", "GET", "maxmemory"))
+}
+
+func TestMaxmemoryNoEviction(t *testing.T) {
+	cfg := config{maxmemory: 500, maxmemoryPolicy: database.PolicyNoEviction}
+	s := newServer(host, "0", []*database.DB{database.NewDB()}, RoleMaster, cfg)
+	conn, r := pipeClient(t, s)
+
+	do(t, conn, r, "SET", "big", strings.Repeat("v", 1000))
+	err, ok := do(t, conn, r, "SET", "k", "v").(error)
+	require.True(t, ok)
+	require.Equal(t, "OOM command not allowed when used memory > 'maxmemory'.", err.Error())
+	// reads and deletions are still allowed
+	require.Equal(t, strings.Repeat("v", 1000), do(t, conn, r, "GET", "big"))
+	require.Equal(t, 1, do(t, conn, r, "DEL", "big"))
+	require.Equal(t, "OK", do(t, conn, r, "SET", "k", "v"))
+	require.Contains(t, do(t, conn, r, "INFO"), "evicted_keys:0")
+}
+
+func TestMemoryCommand(t *testing.T) {
+	s := newServer(host, "0", []*database.DB{database.NewDB()}, RoleMaster, testCfg)
+	conn, r := pipeClient(t, s)
+
+	do(t, conn, r, "SET", "small", "v")
+	do(t, conn, r, "SET", "big", strings.Repeat("v", 100))
+	do(t, conn, r, "HSET", "h", "f1", "v", "f2", "v")
+	require.Greater(t, do(t, conn, r, "MEMORY", "USAGE", "big"), 100)
+	require.Equal(t, do(t, conn, r, "MEMORY", "USAGE", "h"), do(t, conn, r, "MEMORY", "USAGE", "h", "SAMPLES", "0"))
+	require.Nil(t, do(t, conn, r, "MEMORY", "USAGE", "missing"))
+	require.Equal(t, "ERR syntax error", do(t, conn, r, "MEMORY", "USAGE", "h", "FOO", "1").(error).Error())
+
+	stats := do(t, conn, r, "MEMORY", "STATS").([]any)
+	fields := map[any]any{}
+	for i := 0; i < len(stats); i += 2 {
+		fields[stats[i]] = stats[i+1]
+	}
+	require.Equal(t, 3, fields["keys.count"])
+	require.Contains(t, fields, "db.0")
+	require.Contains(t, fields, "runtime.heap.allocated")
+	require.Equal(t, 1, fields["clients.normal"].(int)/readBufferSize)
+
+	require.Contains(t, do(t, conn, r, "MEMORY", "DOCTOR"), "this instance is empty or is using very little memory")
+
+	bigkeys := do(t, conn, r, "MEMORY", "BIGKEYS").(string)
+	require.Contains(t, bigkeys, "Sampled 3 keys in the keyspace!")
+	require.Contains(t, bigkeys, "Biggest string found 'big' has 100 bytes")
+	require.Contains(t, bigkeys, "Biggest   hash found 'h' has 2 fields")
+	require.Contains(t, bigkeys, "2 strings with 101 bytes (66.67% of keys, avg size 50.50)")
+	memkeys := do(t, conn, r, "MEMORY", "MEMKEYS", "SAMPLES", "0").(string)
+	require.Regexp(t, `Biggest string found 'big' has \d+ bytes`, memkeys)
+}
+
+func TestMemoryBigKeysModuleTypes(t *testing.T) {
+	s := newServer(host, "0", []*database.DB{database.NewDB()}, RoleMaster, testCfg)
+	conn, r := pipeClient(t, s)
+
+	require.Equal(t, []any{1, 1}, do(t, conn, r, "BF.MADD", "bf", "a", "b"))
+	require.Equal(t, 1, do(t, conn, r, "CF.ADD", "cf", "a"))
+	require.Equal(t, "OK", do(t, conn, r, "CMS.INITBYDIM", "cms", "10", "2"))
+	require.Equal(t, "OK", do(t, conn, r, "TOPK.RESERVE", "topk", "3"))
+	require.Equal(t, []any{nil}, do(t, conn, r, "TOPK.ADD", "topk", "a"))
+	require.Equal(t, "OK", do(t, conn, r, "JSON.SET", "doc", "$", `{"a":[1,2]}`))
+
+	bigkeys := do(t, conn, r, "MEMORY", "BIGKEYS").(string)
+	require.Contains(t, bigkeys, "Sampled 5 keys in the keyspace!")
+	require.Contains(t, bigkeys, "Biggest MBbloom-- found 'bf' has 2 items")
+	require.Contains(t, bigkeys, "Biggest MBbloomCF found 'cf' has 1 items")
+	require.Contains(t, bigkeys, "Biggest CMSk-TYPE found 'cms' has 20 counters")
+	require.Contains(t, bigkeys, "Biggest TopK-TYPE found 'topk' has 1 items")
+	require.Regexp(t, `Biggest ReJSON-RL found 'doc' has \d+ bytes`, bigkeys)
+	require.Contains(t, bigkeys, "1 MBbloom--s with 2 items (20.00% of keys, avg size 2.00)")
+	memkeys := do(t, conn, r, "MEMORY", "MEMKEYS").(string)
+	require.Regexp(t, `Biggest CMSk-TYPE found 'cms' has \d+ bytes`, memkeys)
+}
+
+func TestMemoryDoctorClientBuffers(t *testing.T) {
+	s := newServer(host, "0", []*database.DB{database.NewDB()}, RoleMaster, testCfg)
+	slow, slowR := pipeClient(t, s)
+	conn, r := pipeClient(t, s)
+	require.Equal(t, "OK", do(t, slow, slowR, "SET", "big", strings.Repeat("x", 2<<20)))
+
+	// the replies left unread by a client are accounted to clients.normal, past the size of an empty instance
+	go func() {
+		_, _ = slow.Write(pipeline(3, "GET", "big"))
+	}()
+	require.Eventually(t, func() bool {
+		stats := do(t, conn, r, "MEMORY", "STATS").([]any)
+		i := slices.Index(stats, any("clients.normal"))
+		return stats[i+1].(int) > 5<<20
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Contains(t, do(t, conn, r, "MEMORY", "DOCTOR"), "Big client buffers")
+}
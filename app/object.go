@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/database"
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+// lruClockMax is the mask of the 24 bits LRU clock reported by DEBUG OBJECT, in seconds like redis.
+const lruClockMax = 1<<24 - 1
+
+var (
+	errFreqNotTracked = errors.New("An LFU maxmemory policy is not selected, access frequency not tracked. Please note that when switching between policies at runtime LRU and LFU data will take some time to adjust.")
+	errIdleNotTracked = errors.New("An LFU maxmemory policy is selected, idle time not tracked. Please note that when switching between policies at runtime LRU and LFU data will take some time to adjust.")
+)
+
+var objectHelp = []string{
+	"OBJECT <subcommand> [<arg> [value] [opt] ...]. Subcommands are:",
+	"ENCODING <key>",
+	"    Return the kind of internal representation used in order to store the value",
+	"    associated with a <key>.",
+	"FREQ <key>",
+	"    Return the access frequency index of the <key>. The returned integer is",
+	"    proportional to the logarithm of the recent access frequency of the key.",
+	"IDLETIME <key>",
+	"    Return the idle time of the <key>, that is the approximated number of",
+	"    seconds elapsed since the last access to the key.",
+	"REFCOUNT <key>",
+	"    Return the number of references of the value associated with the specified",
+	"    <key>.",
+	"HELP",
+	"    Print this help.",
+}
+
+// handleObject handles the OBJECT subcommands. Like redis, the access frequency is only reported with an LFU
+// maxmemory-policy and the idle time with the other policies.
+func (s *server) handleObject(conn io.Writer, arr []string, db *database.DB) error {
+	if len(arr) < 2 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	sub := strings.ToUpper(arr[1])
+	if sub == "HELP" {
+		res := make([][]byte, len(objectHelp))
+		for i, l := range objectHelp {
+			res[i] = resp.NewSimpleString(l)
+		}
+		if _, err := conn.Write(resp.NewArray(res)); err != nil {
+			return fmt.Errorf("error writing to connection: %s", err.Error())
+		}
+		return nil
+	}
+	switch sub {
+	case "ENCODING", "FREQ", "IDLETIME", "REFCOUNT":
+	default:
+		return writeError(conn, fmt.Errorf("unknown subcommand '%s'. Try OBJECT HELP.", arr[1]))
+	}
+	if len(arr) != 3 {
+		return writeError(conn, fmt.Errorf("unknown subcommand or wrong number of arguments for '%s'. Try OBJECT HELP.", arr[1]))
+	}
+	info, ok := db.Object(arr[2])
+	if !ok {
+		if _, err := conn.Write(nullBulkString(conn)); err != nil {
+			return fmt.Errorf("error writing to connection: %s", err.Error())
+		}
+		return nil
+	}
+	var res []byte
+	switch sub {
+	case "ENCODING":
+		res = resp.NewBulkString(info.Encoding)
+	case "FREQ":
+		if !database.LFUPolicy(s.config.maxmemoryPolicy) {
+			return writeError(conn, errFreqNotTracked)
+		}
+		res = resp.NewInt(info.Freq)
+	case "IDLETIME":
+		if database.LFUPolicy(s.config.maxmemoryPolicy) {
+			return writeError(conn, errIdleNotTracked)
+		}
+		res = resp.NewInt(int(info.Idle.Seconds()))
+	case "REFCOUNT":
+		// values are never shared between keys
+		res = resp.NewInt(1)
+	}
+	if _, err := conn.Write(res); err != nil {
+		return fmt.Errorf("error writing to connection: %s", err.Error())
+	}
+	return nil
+}
+
+// handleDebug handles DEBUG OBJECT, the only DEBUG subcommand supported.
+func handleDebug(conn io.Writer, arr []string, db *database.DB) error {
+	if len(arr) < 2 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	switch strings.ToUpper(arr[1]) {
+	case "OBJECT":
+		if len(arr) != 3 {
+			return writeWrongArgs(conn, arr[0])
+		}
+		info, ok := db.Object(arr[2])
+		if !ok {
+			return writeError(conn, database.ErrNoSuchKey)
+		}
+		msg := fmt.Sprintf("refcount:1 encoding:%s lru:%d lru_seconds_idle:%d",
+			info.Encoding, (info.LastAccessMS/1000)&lruClockMax, int(info.Idle.Seconds()))
+		if _, err := conn.Write(resp.NewSimpleString(msg)); err != nil {
+			return fmt.Errorf("error writing to connection: %s", err.Error())
+		}
+		return nil
+	default:
+		return writeError(conn, fmt.Errorf("unknown subcommand '%s'", arr[1]))
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/codecrafters-io/redis-starter-go/app/database"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObject(t *testing.T) {
+	s := newServer(host, "0", []*database.DB{database.NewDB()}, RoleMaster, testCfg)
+	conn, r := pipeClient(t, s)
+
+	do(t, conn, r, "SET", "k", "123")
+	require.Equal(t, "int", do(t, conn, r, "OBJECT", "ENCODING", "k"))
+	require.Equal(t, 0, do(t, conn, r, "OBJECT", "IDLETIME", "k"))
+	require.Equal(t, 1, do(t, conn, r, "OBJECT", "REFCOUNT", "k"))
+	require.Equal(t, "ERR An LFU maxmemory policy is not selected, access frequency not tracked. Please note that when switching between policies at runtime LRU and LFU data will take some time to adjust.",
+		do(t, conn, r, "OBJECT", "FREQ", "k").(error).Error())
+	require.Nil(t, do(t, conn, r, "OBJECT", "ENCODING", "missing"))
+	require.Regexp(t, `^refcount:1 encoding:int lru:\d+ lru_seconds_idle:0$`, do(t, conn, r, "DEBUG", "OBJECT", "k"))
+	require.Equal(t, "ERR no such key", do(t, conn, r, "DEBUG", "OBJECT", "missing").(error).Error())
+	require.Equal(t, "ERR unknown subcommand 'foo'. Try OBJECT HELP.", do(t, conn, r, "OBJECT", "foo", "k").(error).Error())
+}
+
+func TestObjectLFU(t *testing.T) {
+	s := newServer(host, "0", []*database.DB{database.NewDB()}, RoleMaster, config{maxmemoryPolicy: database.PolicyVolatileLFU})
+	conn, r := pipeClient(t, s)
+
+	do(t, conn, r, "SET", "k", "123")
+	require.GreaterOrEqual(t, do(t, conn, r, "OBJECT", "FREQ", "k"), 5)
+	require.Equal(t, "ERR An LFU maxmemory policy is selected, idle time not tracked. Please note that when switching between policies at runtime LRU and LFU data will take some time to adjust.",
+		do(t, conn, r, "OBJECT", "IDLETIME", "k").(error).Error())
+	require.Nil(t, do(t, conn, r, "OBJECT", "FREQ", "missing"))
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/replication"
+)
+
+// replyChunkBytes is the size of the output buffer of a client. The replies are written into the buffer,
+// which is queued to be sent once full or once the requests already read from the client were all executed,
+// so that a pipeline of commands is replied with a few writes instead of one write per command.
+// ref: https://github.com/redis/redis/blob/7.2.0/src/server.h#L164
+const replyChunkBytes = 16 * 1024
+
+// Client classes of client-output-buffer-limit, a client is in the pubsub class while subscribed to a channel.
+const (
+	clientClassNormal  = "normal"
+	clientClassReplica = "replica"
+	clientClassPubsub  = "pubsub"
+)
+
+const defaultClientOutputBufferLimit = "normal 0 0 0 replica 256mb 64mb 60 pubsub 32mb 8mb 60"
+
+var (
+	errOutputBufferLimit = errors.New("output buffer limit exceeded")
+	errOutputClosed      = errors.New("output closed")
+)
+
+// closeDrainTimeout bounds the time given to a client being closed to read its pending replies.
+const closeDrainTimeout = 10 * time.Second
+
+// clientOutput is the output buffer of a client. The replies are buffered until flushed, then queued along with
+// the messages pushed by the other connections, such as the invalidations and the messages of the channels,
+// and a goroutine writes the queue to the connection. Neither the client nor the connections pushing to it
+// wait for the writes, so a client which does not read its replies accumulates them, like in redis, until the
+// pending bytes exceed the limit of its class and it is disconnected.
+// ref: https://github.com/redis/redis/blob/7.2.0/src/networking.c#L3999
+type clientOutput struct {
+	conn      net.Conn
+	mu        sync.Mutex
+	cond      *sync.Cond // signals the queued chunks to the writer, and their writes to sync
+	buf       []byte     // replies not flushed yet
+	queue     [][]byte   // chunks waiting to be written
+	writing   bool       // whether the writer is writing chunks taken from the queue
+	pending   int64      // size of buf, queue and of the chunks being written
+	limit     replication.OutputBufferLimit
+	softSince time.Time
+	client    string // description of the client for the log
+	err       error  // set once the client exceeded its limit, the connection failed or the output was closed
+	discard   bool   // the replies are dropped, set by CLIENT REPLY OFF and SKIP
+}
+
+func newClientOutput(conn net.Conn, limit replication.OutputBufferLimit, client string) *clientOutput {
+	o := &clientOutput{conn: conn, limit: limit, client: client}
+	o.cond = sync.NewCond(&o.mu)
+	go o.writeLoop()
+	return o
+}
+
+func (o *clientOutput) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.discard && o.err == nil {
+		return len(p), nil
+	}
+	return o.write(p)
+}
+
+// write buffers p, the buffer is queued once it holds a chunk so that large replies are sent as they are produced.
+// The client is disconnected if the pending bytes exceed its limit.
+func (o *clientOutput) write(p []byte) (int, error) {
+	if o.err != nil {
+		return 0, o.err
+	}
+	if o.limit.Exceeded(o.pending+int64(len(p)), &o.softSince, time.Now()) {
+		fmt.Printf("Client %s scheduled to be closed ASAP for overcoming of output buffer limits.\n", o.client)
+		o.fail(errOutputBufferLimit)
+		// unblocks the reads of the client and the write in progress
+		o.conn.Close()
+		return 0, errOutputBufferLimit
+	}
+	o.buf = append(o.buf, p...)
+	o.pending += int64(len(p))
+	if len(o.buf) >= replyChunkBytes {
+		o.enqueue()
+	}
+	return len(p), nil
+}
+
+// enqueue hands the buffered replies to the writer.
+func (o *clientOutput) enqueue() {
+	if len(o.buf) == 0 {
+		return
+	}
+	o.queue = append(o.queue, o.buf)
+	o.buf = nil
+	o.cond.Broadcast()
+}
+
+// fail drops the pending bytes, the later writes return err.
+func (o *clientOutput) fail(err error) {
+	o.err = err
+	o.buf, o.queue, o.pending = nil, nil, 0
+	o.cond.Broadcast()
+}
+
+// writeLoop writes the queued chunks to the connection until the output fails or is closed.
+func (o *clientOutput) writeLoop() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for {
+		for len(o.queue) == 0 && o.err == nil {
+			o.cond.Wait()
+		}
+		if o.err != nil {
+			return
+		}
+		chunks := net.Buffers(o.queue)
+		o.queue = nil
+		o.writing = true
+		o.mu.Unlock()
+		n, err := chunks.WriteTo(o.conn)
+		o.mu.Lock()
+		o.writing = false
+		if o.err != nil {
+			return
+		}
+		o.pending -= n
+		if err != nil {
+			o.fail(err)
+			return
+		}
+		o.cond.Broadcast()
+	}
+}
+
+// Push sends a message pushed to the client, along with the buffered replies. The pushes are sent
+// even when the replies are discarded. Push does not wait for the write, the client is disconnected
+// if the message exceeds its output buffer limit.
+func (o *clientOutput) Push(p []byte) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if _, err := o.write(p); err == nil {
+		o.enqueue()
+	}
+}
+
+// Flush queues the buffered replies to be sent, without waiting for the write.
+// They are dropped once the client exceeded its limit.
+func (o *clientOutput) Flush() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.err == errOutputBufferLimit {
+		return nil
+	}
+	o.enqueue()
+	return o.err
+}
+
+// close waits, for at most timeout, for the pending bytes to be written and stops the writer, the later writes
+// are dropped. A zero timeout drops the pending bytes and closes the connection at once, for a client closed on
+// a timeout or an error which may never read them. close is called before the connection is closed or handed
+// over to the replication.
+func (o *clientOutput) close(timeout time.Duration) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if timeout == 0 {
+		if o.err == nil {
+			o.fail(errOutputClosed)
+		}
+		// unblocks the write in progress
+		o.conn.Close()
+		return nil
+	}
+	o.enqueue()
+	if err := o.conn.SetWriteDeadline(time.Now().Add(timeout)); err != nil {
+		o.fail(err)
+	}
+	for o.err == nil && (len(o.queue) > 0 || o.writing) {
+		o.cond.Wait()
+	}
+	err := o.err
+	if err == nil {
+		o.fail(errOutputClosed)
+		err = o.conn.SetWriteDeadline(time.Time{})
+	}
+	if err == errOutputBufferLimit {
+		return nil
+	}
+	return err
+}
+
+// Buffered returns the size of the replies and messages not written to the connection yet.
+func (o *clientOutput) Buffered() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return int(o.pending)
+}
+
+func (o *clientOutput) setDiscard(discard bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.discard = discard
+}
+
+func (o *clientOutput) setLimit(limit replication.OutputBufferLimit) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.limit = limit
+	o.softSince = time.Time{}
+}
+
+// flush sends the replies buffered for the client of w. The commands blocking the client call it before
+// waiting, so that the replies of the commands pipelined before them are not held back.
+func flush(w io.Writer) error {
+	rw, ok := w.(*respWriter)
+	if !ok {
+		return nil
+	}
+	out, ok := rw.Writer.(*clientOutput)
+	if !ok {
+		return nil
+	}
+	if err := out.Flush(); err != nil {
+		return fmt.Errorf("error writing to connection: %s", err.Error())
+	}
+	return nil
+}
+
+// parseClientOutputBufferLimit parses the limits of client-output-buffer-limit, given as groups of
+// <class> <hard limit> <soft limit> <soft seconds>. The classes which are not given keep their limit in limits.
+func parseClientOutputBufferLimit(s string, limits map[string]replication.OutputBufferLimit) error {
+	fields := strings.Fields(s)
+	if len(fields)%4 != 0 {
+		return errors.New("Wrong number of arguments in buffer limit configuration.")
+	}
+	parsed := map[string]replication.OutputBufferLimit{}
+	for i := 0; i < len(fields); i += 4 {
+		class := strings.ToLower(fields[i])
+		if class == "slave" {
+			class = clientClassReplica
+		}
+		if class != clientClassNormal && class != clientClassReplica && class != clientClassPubsub {
+			return errors.New("Invalid client class specified in buffer limit configuration.")
+		}
+		hard, err1 := parseMemory(fields[i+1])
+		soft, err2 := parseMemory(fields[i+2])
+		seconds, err3 := strconv.Atoi(fields[i+3])
+		if err1 != nil || err2 != nil || err3 != nil || hard < 0 || soft < 0 || seconds < 0 {
+			return errors.New("Error in hard, soft or soft_seconds setting in buffer limit configuration.")
+		}
+		parsed[class] = replication.OutputBufferLimit{Hard: hard, Soft: soft, SoftSeconds: seconds}
+	}
+	for class, l := range parsed {
+		limits[class] = l
+	}
+	return nil
+}
+
+// formatClientOutputBufferLimit formats the limits like CONFIG GET in redis, which still names the replicas slave.
+func formatClientOutputBufferLimit(limits map[string]replication.OutputBufferLimit) string {
+	res := []string{}
+	for _, class := range []string{clientClassNormal, clientClassReplica, clientClassPubsub} {
+		l := limits[class]
+		name := class
+		if class == clientClassReplica {
+			name = "slave"
+		}
+		res = append(res, fmt.Sprintf("%s %d %d %d", name, l.Hard, l.Soft, l.SoftSeconds))
+	}
+	return strings.Join(res, " ")
+}
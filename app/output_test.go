@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/database"
+	"github.com/codecrafters-io/redis-starter-go/app/replication"
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+	"github.com/stretchr/testify/require"
+)
+
+// countingConn counts the writes to the connection.
+type countingConn struct {
+	net.Conn
+	writes atomic.Int64
+}
+
+func (c *countingConn) Write(b []byte) (int, error) {
+	c.writes.Add(1)
+	return c.Conn.Write(b)
+}
+
+// tcpClient starts the handler of s on a loopback TCP connection, which unlike net.Pipe buffers the data
+// so that a pipeline can be written before reading the replies.
+func tcpClient(tb testing.TB, s *server) (net.Conn, *bufio.Reader, *countingConn) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(tb, err)
+	defer l.Close()
+	accepted := make(chan *countingConn, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			close(accepted)
+			return
+		}
+		cc := &countingConn{Conn: conn}
+		accepted <- cc
+		_ = s.handler(cc)
+	}()
+	client, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(tb, err)
+	require.NoError(tb, client.SetDeadline(time.Now().Add(time.Minute)))
+	tb.Cleanup(func() { client.Close() })
+	return client, bufio.NewReader(client), <-accepted
+}
+
+func pipeline(n int, args ...string) []byte {
+	return bytes.Repeat(resp.NewCommand(args), n)
+}
+
+func TestPipelineBatchesReplies(t *testing.T) {
+	s := newServer(host, "0", []*database.DB{database.NewDB()}, RoleMaster, testCfg)
+	conn, r, server := tcpClient(t, s)
+
+	require.Equal(t, "OK", do(t, conn, r, "SET", "k", "v"))
+	writes := server.writes.Load()
+	_, err := conn.Write(pipeline(1000, "GET", "k"))
+	require.NoError(t, err)
+	for i := 0; i < 1000; i++ {
+		res, err := readReply(r)
+		require.NoError(t, err)
+		require.Equal(t, "v", res)
+	}
+	require.Less(t, server.writes.Load()-writes, int64(100))
+
+	// the replies larger than the buffer are sent as they are produced
+	require.Equal(t, "OK", do(t, conn, r, "SET", "big", string(bytes.Repeat([]byte("x"), 3*replyChunkBytes))))
+	_, err = conn.Write(pipeline(10, "GET", "big"))
+	require.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		res, err := readReply(r)
+		require.NoError(t, err)
+		require.Len(t, res, 3*replyChunkBytes)
+	}
+}
+
+func TestFlushBeforeBlocking(t *testing.T) {
+	s := newServer(host, "0", []*database.DB{database.NewDB()}, RoleMaster, testCfg)
+	conn, r, _ := tcpClient(t, s)
+
+	// the reply of SET is sent while XREAD waits for an entry
+	req := append(resp.NewCommand([]string{"SET", "k", "v"}), resp.NewCommand([]string{"XREAD", "BLOCK", "0", "STREAMS", "s", "$"})...)
+	_, err := conn.Write(req)
+	require.NoError(t, err)
+	res, err := readReply(r)
+	require.NoError(t, err)
+	require.Equal(t, "OK", res)
+
+	other, otherR := pipeClient(t, s)
+	require.Equal(t, "1-1", do(t, other, otherR, "XADD", "s", "1-1", "f", "v"))
+	res, err = readReply(r)
+	require.NoError(t, err)
+	require.Equal(t, []any{[]any{"s", []any{[]any{"1-1", []any{"f", "v"}}}}}, res)
+}
+
+func TestParseClientOutputBufferLimit(t *testing.T) {
+	limits := map[string]replication.OutputBufferLimit{}
+	require.NoError(t, parseClientOutputBufferLimit(defaultClientOutputBufferLimit, limits))
+	require.Equal(t, "normal 0 0 0 slave 268435456 67108864 60 pubsub 33554432 8388608 60", formatClientOutputBufferLimit(limits))
+	require.NoError(t, parseClientOutputBufferLimit("slave 1mb 512kb 10 NORMAL 100 50 1", limits))
+	require.Equal(t, replication.OutputBufferLimit{Hard: 1 << 20, Soft: 512 << 10, SoftSeconds: 10}, limits[clientClassReplica])
+	require.Equal(t, replication.OutputBufferLimit{Hard: 100, Soft: 50, SoftSeconds: 1}, limits[clientClassNormal])
+
+	require.EqualError(t, parseClientOutputBufferLimit("normal 0 0", limits), "Wrong number of arguments in buffer limit configuration.")
+	require.EqualError(t, parseClientOutputBufferLimit("master 0 0 0", limits), "Invalid client class specified in buffer limit configuration.")
+	require.EqualError(t, parseClientOutputBufferLimit("pubsub 1 x 0", limits), "Error in hard, soft or soft_seconds setting in buffer limit configuration.")
+	// nothing is changed when a group is invalid
+	require.EqualError(t, parseClientOutputBufferLimit("pubsub 0 0 0 normal 0 0 -1", limits), "Error in hard, soft or soft_seconds setting in buffer limit configuration.")
+	require.Equal(t, int64(32<<20), limits[clientClassPubsub].Hard)
+}
+
+func TestClientOutputBufferLimit(t *testing.T) {
+	cfg := config{outputLimits: map[string]replication.OutputBufferLimit{clientClassNormal: {Hard: 1000}}}
+	s := newServer(host, "0", []*database.DB{database.NewDB()}, RoleMaster, cfg)
+	conn, r := pipeClient(t, s)
+
+	require.Equal(t, "OK", do(t, conn, r, "SET", "big", string(bytes.Repeat([]byte("x"), 1000))))
+	res := do(t, conn, r, "CONFIG", "GET", "client-output-buffer-limit")
+	require.Equal(t, []any{"client-output-buffer-limit", "normal 1000 0 0 slave 0 0 0 pubsub 0 0 0"}, res)
+	// the reply exceeding the hard limit is not sent and the client is disconnected
+	_, err := conn.Write(resp.NewCommand([]string{"GET", "big"}))
+	require.NoError(t, err)
+	_, err = r.ReadByte()
+	require.ErrorIs(t, err, io.EOF)
+}
+
+func TestSlowSubscriberDisconnected(t *testing.T) {
+	cfg := config{outputLimits: map[string]replication.OutputBufferLimit{clientClassPubsub: {Hard: 1 << 20}}}
+	s := newServer(host, "0", []*database.DB{database.NewDB()}, RoleMaster, cfg)
+	sub, subR, _ := tcpClient(t, s)
+	require.Equal(t, []any{"subscribe", "news", 1}, do(t, sub, subR, "SUBSCRIBE", "news"))
+
+	// the subscriber stops reading, the publisher is not blocked by it and it is disconnected past 1mb
+	pub, pubR := pipeClient(t, s)
+	msg := string(bytes.Repeat([]byte("x"), 64<<10))
+	done := make(chan int)
+	go func() {
+		for i := 0; i < 2048; i++ {
+			if do(t, pub, pubR, "PUBLISH", "news", msg) == 0 {
+				done <- i
+				return
+			}
+		}
+		close(done)
+	}()
+	select {
+	case n, ok := <-done:
+		require.True(t, ok, "the subscriber was never disconnected")
+		// up to the limit, plus what the kernel buffers took before the subscriber stopped reading
+		require.Less(t, n, 1024)
+	case <-time.After(10 * time.Second):
+		t.Fatal("PUBLISH blocked on a subscriber which does not read")
+	}
+	// the connection is closed, with a reset if data was left unread
+	_, err := io.Copy(io.Discard, subR)
+	require.NotErrorIs(t, err, os.ErrDeadlineExceeded)
+}
+
+func TestSlowClientDisconnected(t *testing.T) {
+	cfg := config{outputLimits: map[string]replication.OutputBufferLimit{clientClassNormal: {Hard: 1 << 20}}}
+	s := newServer(host, "0", []*database.DB{database.NewDB()}, RoleMaster, cfg)
+	conn, r, _ := tcpClient(t, s)
+	require.Equal(t, "OK", do(t, conn, r, "SET", "k", string(bytes.Repeat([]byte("x"), 1000))))
+
+	// every reply fits the limit, their sum does not while the client is not reading them
+	go func() {
+		_, _ = conn.Write(pipeline(100000, "GET", "k"))
+	}()
+	require.Eventually(t, func() bool {
+		return s.connectedClients.Load() == 0
+	}, 10*time.Second, 10*time.Millisecond, "the client was never disconnected")
+	_, err := io.Copy(io.Discard, r)
+	require.NotErrorIs(t, err, os.ErrDeadlineExceeded)
+}
+
+func TestTimedOutClientNotReadingClosed(t *testing.T) {
+	s := newServer(host, "0", []*database.DB{database.NewDB()}, RoleMaster, config{timeout: 100 * time.Millisecond})
+	conn, r, _ := tcpClient(t, s)
+	require.Equal(t, "OK", do(t, conn, r, "SET", "k", string(bytes.Repeat([]byte("x"), 1<<20))))
+
+	// the replies are never read, the client is still closed once idle
+	_, err := conn.Write(pipeline(50, "GET", "k"))
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		return s.connectedClients.Load() == 0
+	}, 5*time.Second, 10*time.Millisecond)
+	_, err = io.Copy(io.Discard, r)
+	require.NotErrorIs(t, err, os.ErrDeadlineExceeded)
+}
+
+func BenchmarkPipelinedGet(b *testing.B) {
+	s := newServer(host, "0", []*database.DB{database.NewDB()}, RoleMaster, testCfg)
+	conn, r, server := tcpClient(b, s)
+	_, err := conn.Write(resp.NewCommand([]string{"SET", "k", "v"}))
+	require.NoError(b, err)
+	_, err = readReply(r)
+	require.NoError(b, err)
+
+	const depth = 1000
+	req := pipeline(depth, "GET", "k")
+	writes := server.writes.Load()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		errc := make(chan error, 1)
+		go func() {
+			_, err := conn.Write(req)
+			errc <- err
+		}()
+		for j := 0; j < depth; j++ {
+			if _, err := readReply(r); err != nil {
+				b.Fatal(err)
+			}
+		}
+		require.NoError(b, <-errc)
+	}
+	b.StopTimer()
+	b.ReportMetric(float64(b.N*depth)/b.Elapsed().Seconds(), "cmds/s")
+	b.ReportMetric(float64(server.writes.Load()-writes)/float64(b.N), "writes/op")
+}
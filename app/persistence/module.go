@@ -0,0 +1,133 @@
+package persistence
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/codecrafters-io/redis-starter-go/app/database"
+)
+
+// Values of module types are saved as the module type id followed by opcodes announcing every field.
+// ref: https://github.com/redis/redis/blob/7.2.0/src/rdb.c#L1113
+const (
+	moduleEncoding = 0x07 // RDB_TYPE_MODULE_2
+
+	moduleOpCodeEOF    = 0
+	moduleOpCodeUint   = 2
+	moduleOpCodeDouble = 4
+	moduleOpCodeString = 5
+)
+
+// readLen reads a length with any of the length encodings, including the 64 bits one.
+func readLen(buf *bytes.Buffer) (uint64, error) {
+	b, err := buf.ReadByte()
+	if err != nil {
+		return 0, fmt.Errorf("fail to read length: %w", err)
+	}
+	switch {
+	case b>>6 == 0b00:
+		return uint64(b & 0x3f), nil
+	case b>>6 == 0b01:
+		next, err := buf.ReadByte()
+		if err != nil {
+			return 0, fmt.Errorf("fail to read length: %w", err)
+		}
+		return uint64(b&0x3f)<<8 | uint64(next), nil
+	case b == 0x80:
+		next := make([]byte, 4)
+		if _, err := io.ReadFull(buf, next); err != nil {
+			return 0, fmt.Errorf("fail to read length: %w", err)
+		}
+		return uint64(binary.BigEndian.Uint32(next)), nil
+	case b == 0x81:
+		next := make([]byte, 8)
+		if _, err := io.ReadFull(buf, next); err != nil {
+			return 0, fmt.Errorf("fail to read length: %w", err)
+		}
+		return binary.BigEndian.Uint64(next), nil
+	}
+	return 0, fmt.Errorf("unexpected length encoding %#x", b)
+}
+
+// appendLen appends a length with the smallest length encoding.
+func appendLen(b []byte, n uint64) []byte {
+	switch {
+	case n < 1<<6:
+		return append(b, byte(n))
+	case n < 1<<14:
+		return append(b, byte(n>>8)|0x40, byte(n))
+	case n <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(b, 0x80), uint32(n))
+	}
+	return binary.BigEndian.AppendUint64(append(b, 0x81), n)
+}
+
+// appendString appends a length prefixed string.
+func appendString(b []byte, s string) []byte {
+	return append(appendLen(b, uint64(len(s))), s...)
+}
+
+// readModuleValue reads the type id and the fields of a module value.
+func readModuleValue(buf *bytes.Buffer) (database.ModuleType, []any, error) {
+	id, err := readLen(buf)
+	if err != nil {
+		return database.ModuleType{}, nil, fmt.Errorf("fail to read module id: %w", err)
+	}
+	t := database.ModuleTypeFromID(id)
+	fields := []any{}
+	for {
+		opCode, err := readLen(buf)
+		if err != nil {
+			return t, nil, fmt.Errorf("fail to read module opcode: %w", err)
+		}
+		switch opCode {
+		case moduleOpCodeEOF:
+			return t, fields, nil
+		case moduleOpCodeUint:
+			v, err := readLen(buf)
+			if err != nil {
+				return t, nil, err
+			}
+			fields = append(fields, v)
+		case moduleOpCodeDouble:
+			v := make([]byte, 8)
+			if _, err := io.ReadFull(buf, v); err != nil {
+				return t, nil, fmt.Errorf("fail to read double: %w", err)
+			}
+			fields = append(fields, math.Float64frombits(binary.LittleEndian.Uint64(v)))
+		case moduleOpCodeString:
+			n, err := readLen(buf)
+			if err != nil {
+				return t, nil, err
+			}
+			s := make([]byte, n)
+			if _, err := io.ReadFull(buf, s); err != nil {
+				return t, nil, fmt.Errorf("fail to read string: %w", err)
+			}
+			fields = append(fields, string(s))
+		default:
+			return t, nil, fmt.Errorf("unknown module opcode %d", opCode)
+		}
+	}
+}
+
+// appendModuleValue appends the type id and the fields of a module value.
+func appendModuleValue(b []byte, t database.ModuleType, fields []any) ([]byte, error) {
+	b = appendLen(b, t.ID())
+	for _, f := range fields {
+		switch v := f.(type) {
+		case uint64:
+			b = appendLen(append(b, moduleOpCodeUint), v)
+		case float64:
+			b = binary.LittleEndian.AppendUint64(append(b, moduleOpCodeDouble), math.Float64bits(v))
+		case string:
+			b = appendString(append(b, moduleOpCodeString), v)
+		default:
+			return nil, fmt.Errorf("unsupported module field %T", f)
+		}
+	}
+	return append(b, moduleOpCodeEOF), nil
+}
@@ -8,13 +8,20 @@ import (
 	"github.com/codecrafters-io/redis-starter-go/app/database"
 )
 
+// DefaultDatabases is the default number of databases.
+const DefaultDatabases = redisDefaultDBSize
+
 type Config struct {
 	Dir        string
 	Dbfilename string
+	Databases  int // number of databases, DefaultDatabases if 0
 }
 
 func LoadRDB(config Config) ([]*database.DB, error) {
-	defaultDBs := make([]*database.DB, redisDefaultDBSize)
+	if config.Databases <= 0 {
+		config.Databases = DefaultDatabases
+	}
+	defaultDBs := make([]*database.DB, config.Databases)
 	for i := range defaultDBs {
 		defaultDBs[i] = database.NewDB()
 	}
@@ -33,8 +40,14 @@ func LoadRDB(config Config) ([]*database.DB, error) {
 	if err != nil {
 		return nil, fmt.Errorf("fail to unmarshal rdb file: %w", err)
 	}
-	dbs := make([]*database.DB, len(rdb.DBs))
+	dbs := defaultDBs
 	for i, db := range rdb.DBs {
+		if i >= len(dbs) {
+			if len(db.Datas) > 0 {
+				return nil, fmt.Errorf("rdb file contains db %d but only %d databases are configured", i, len(dbs))
+			}
+			continue
+		}
 		dbs[i] = database.NewFromLoad(db.Datas)
 	}
 	return dbs, nil
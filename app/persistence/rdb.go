@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"sort"
 	"strconv"
 
 	"github.com/codecrafters-io/redis-starter-go/app/database"
@@ -281,6 +282,19 @@ func readTable(buf *bytes.Buffer, size uint32) (map[string]*database.Data, error
 			}
 			m[key] = database.NewString(val, ts)
 			count += 1
+		case moduleEncoding:
+			key, _, err := readStringEncoding(buf)
+			if err != nil {
+				return m, fmt.Errorf("fail to read key")
+			}
+			t, fields, err := readModuleValue(buf)
+			if err != nil {
+				return m, fmt.Errorf("fail to read module value: %w", err)
+			}
+			if m[key], err = database.NewModuleValue(t, fields, ts); err != nil {
+				return m, err
+			}
+			count += 1
 		default:
 			return m, fmt.Errorf("key type %v not supported yet", keyType)
 		}
@@ -298,8 +312,14 @@ func (d *RDB) MarshalRDB() ([]byte, error) {
 		return nil, err
 	}
 	b = append(b, aux...)
-	// TODO
-	// MarshalHashTable
+	for _, db := range d.DBs {
+		if len(db.Datas) == 0 {
+			continue
+		}
+		if b, err = db.MarshalHashTable(b); err != nil {
+			return nil, err
+		}
+	}
 
 	// TODO: implement checksum
 	// ref: https://github.com/reborndb/go/blob/master/redis/rdb/digest/crc64.go
@@ -311,6 +331,41 @@ func (d *RDB) MarshalRDB() ([]byte, error) {
 	return b, nil
 }
 
+// MarshalHashTable appends the database section: its index, the sizes of the hash tables and the keys sorted.
+func (db *Database) MarshalHashTable(b []byte) ([]byte, error) {
+	keys := make([]string, 0, len(db.Datas))
+	expires := 0
+	for key, data := range db.Datas {
+		keys = append(keys, key)
+		if data.ExpireTimestampMS != database.NO_EXPIRY {
+			expires++
+		}
+	}
+	sort.Strings(keys)
+	b = appendLen(append(b, opCodeDatabaseSec), uint64(db.Index))
+	b = appendLen(append(b, opCodeHashSize), uint64(len(keys)))
+	b = appendLen(b, uint64(expires))
+	for _, key := range keys {
+		data := db.Datas[key]
+		if data.ExpireTimestampMS != database.NO_EXPIRY {
+			b = binary.LittleEndian.AppendUint64(append(b, opCodeExpireTimeMS), data.ExpireTimestampMS)
+		}
+		if data.Type == database.TypeString {
+			b = appendString(appendString(append(b, stringEncoding), key), data.Value)
+			continue
+		}
+		t, fields, ok := data.ModuleValue()
+		if !ok {
+			return nil, fmt.Errorf("key type %s not supported yet", data.Type)
+		}
+		var err error
+		if b, err = appendModuleValue(appendString(append(b, moduleEncoding), key), t, fields); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
 func (a Aux) MarshalAux() ([]byte, error) {
 	b := make([]byte, 0)
 	b = append(b, opCodeAux)
@@ -421,7 +476,7 @@ func decodeSizeUint(b *bytes.Buffer) (size uint32, special bool, err error) {
 		if err != nil {
 			return 0, false, fmt.Errorf("input slice is too short: %w", err)
 		}
-		size = uint32(binary.BigEndian.Uint16([]byte{firstByte & 0b11000000, secondByte})) // Combine the last 6 bits of the first byte and the next byte
+		size = uint32(binary.BigEndian.Uint16([]byte{firstByte & 0b00111111, secondByte})) // Combine the last 6 bits of the first byte and the next byte
 	case 0b10:
 		next4B := make([]byte, 4)
 		n, err := b.Read(next4B)
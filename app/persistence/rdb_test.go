@@ -3,8 +3,10 @@ package persistence
 import (
 	"encoding/hex"
 	"os"
+	"strings"
 	"testing"
 
+	"github.com/codecrafters-io/redis-starter-go/app/database"
 	"github.com/stretchr/testify/require"
 )
 
@@ -104,3 +106,57 @@ func TestUnMarshalRDBWithExpiredKey(t *testing.T) {
 	require.Equal(t, "blueberry", v4.Value)
 	require.Equal(t, uint64(1956528000000), v4.ExpireTimestampMS)
 }
+
+func TestMarshalRDBModuleValues(t *testing.T) {
+	db := database.NewDB()
+	_, err := db.BFAdd("bf", []string{"a", "b"})
+	require.NoError(t, err)
+	_, err = db.CFAdd("cf", "a", false)
+	require.NoError(t, err)
+	require.NoError(t, db.CMSInitByDim("cms", 100, 3, 1<<20))
+	_, err = db.CMSIncrBy("cms", []database.CMSIncr{{Item: "a", Value: 3}})
+	require.NoError(t, err)
+	require.NoError(t, db.TopKReserve("topk", 2, 8, 7, 0.9, 1<<20))
+	_, err = db.TopKIncrBy("topk", []database.TopKIncr{{Item: "a", Value: 2}})
+	require.NoError(t, err)
+	_, err = db.JSONSet("json", "$", `{"a":[1,2.5,"x"]}`, false, false)
+	require.NoError(t, err)
+	db.SetExp("str", strings.Repeat("x", 100), 1956528000000)
+
+	rdb := RDB{
+		Aux: mockAux,
+		DBs: []*Database{{Index: 0}, {Index: 1, Datas: db.Snapshot()}},
+	}
+	b, err := rdb.MarshalRDB()
+	require.NoError(t, err)
+
+	loaded, err := UnMarshalRDB(b)
+	require.NoError(t, err)
+	require.Empty(t, loaded.DBs[0].Datas)
+	datas := loaded.DBs[1].Datas
+	require.Len(t, datas, 6)
+	require.Equal(t, database.TypeBloom, datas["bf"].Type)
+	require.Equal(t, database.TypeCuckoo, datas["cf"].Type)
+	require.Equal(t, strings.Repeat("x", 100), datas["str"].Value)
+	require.Equal(t, uint64(1956528000000), datas["str"].ExpireTimestampMS)
+
+	restored := database.NewFromLoad(datas)
+	exists, err := restored.BFExists("bf", []string{"a", "b", "c"})
+	require.NoError(t, err)
+	require.Equal(t, []bool{true, true, false}, exists)
+	exists, err = restored.CFExists("cf", []string{"a", "b"})
+	require.NoError(t, err)
+	require.Equal(t, []bool{true, false}, exists)
+	counts, err := restored.CMSQuery("cms", []string{"a", "b"})
+	require.NoError(t, err)
+	require.Equal(t, []uint32{3, 0}, counts)
+	info, err := restored.TopKInfo("topk")
+	require.NoError(t, err)
+	require.Equal(t, 0.9, info.Decay)
+	list, err := restored.TopKList("topk")
+	require.NoError(t, err)
+	require.Equal(t, []database.TopKItem{{Item: "a", Count: 2}}, list)
+	doc, _, err := restored.JSONGet("json", nil, database.JSONFormat{})
+	require.NoError(t, err)
+	require.Equal(t, `{"a":[1,2.5,"x"]}`, doc)
+}
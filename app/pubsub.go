@@ -0,0 +1,291 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/codecrafters-io/redis-starter-go/app/glob"
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+// trackingChannel is the channel the RESP2 clients subscribe to for the invalidations of the clients redirecting to them.
+const trackingChannel = "__redis__:invalidate"
+
+// subscribedCommands are the commands a RESP2 client can send while subscribed to a channel,
+// since the messages and the replies could not be told apart otherwise.
+var subscribedCommands = map[string]bool{
+	"SUBSCRIBE":    true,
+	"UNSUBSCRIBE":  true,
+	"PSUBSCRIBE":   true,
+	"PUNSUBSCRIBE": true,
+	"PING":         true,
+}
+
+// pubsub tracks the channels and the patterns the clients are subscribed to.
+type pubsub struct {
+	mu       sync.RWMutex
+	channels subscriptionIndex
+	patterns subscriptionIndex
+}
+
+// subscriptionIndex indexes subscriptions, to channels or to patterns, by name and by client.
+type subscriptionIndex struct {
+	byName   map[string]map[*client]bool
+	byClient map[*client]map[string]bool
+}
+
+func newSubscriptionIndex() subscriptionIndex {
+	return subscriptionIndex{byName: map[string]map[*client]bool{}, byClient: map[*client]map[string]bool{}}
+}
+
+func (idx subscriptionIndex) add(c *client, name string) {
+	if idx.byName[name] == nil {
+		idx.byName[name] = map[*client]bool{}
+	}
+	idx.byName[name][c] = true
+	if idx.byClient[c] == nil {
+		idx.byClient[c] = map[string]bool{}
+	}
+	idx.byClient[c][name] = true
+}
+
+func (idx subscriptionIndex) remove(c *client, name string) {
+	delete(idx.byName[name], c)
+	if len(idx.byName[name]) == 0 {
+		delete(idx.byName, name)
+	}
+	delete(idx.byClient[c], name)
+	if len(idx.byClient[c]) == 0 {
+		delete(idx.byClient, c)
+	}
+}
+
+// names returns the channels or the patterns of the client, in lexicographical order.
+func (idx subscriptionIndex) names(c *client) []string {
+	res := make([]string, 0, len(idx.byClient[c]))
+	for name := range idx.byClient[c] {
+		res = append(res, name)
+	}
+	slices.Sort(res)
+	return res
+}
+
+func newPubsub() *pubsub {
+	return &pubsub{channels: newSubscriptionIndex(), patterns: newSubscriptionIndex()}
+}
+
+// subscribe subscribes the client to the channel and returns the number of channels and patterns it is subscribed to.
+func (p *pubsub) subscribe(c *client, channel string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.channels.add(c, channel)
+	return p.countLocked(c)
+}
+
+// unsubscribe unsubscribes the client from the channel and returns the number of channels and patterns
+// it is still subscribed to.
+func (p *pubsub) unsubscribe(c *client, channel string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.channels.remove(c, channel)
+	return p.countLocked(c)
+}
+
+// psubscribe subscribes the client to the pattern and returns the number of channels and patterns it is subscribed to.
+func (p *pubsub) psubscribe(c *client, pattern string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.patterns.add(c, pattern)
+	return p.countLocked(c)
+}
+
+// punsubscribe unsubscribes the client from the pattern and returns the number of channels and patterns
+// it is still subscribed to.
+func (p *pubsub) punsubscribe(c *client, pattern string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.patterns.remove(c, pattern)
+	return p.countLocked(c)
+}
+
+// subscriptions returns the channels the client is subscribed to, in lexicographical order.
+func (p *pubsub) subscriptions(c *client) []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.channels.names(c)
+}
+
+// patternSubscriptions returns the patterns the client is subscribed to, in lexicographical order.
+func (p *pubsub) patternSubscriptions(c *client) []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.patterns.names(c)
+}
+
+// count returns the number of channels and patterns the client is subscribed to.
+func (p *pubsub) count(c *client) int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.countLocked(c)
+}
+
+func (p *pubsub) countLocked(c *client) int {
+	return len(p.channels.byClient[c]) + len(p.patterns.byClient[c])
+}
+
+func (p *pubsub) isSubscribed(c *client, channel string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.channels.byName[channel][c]
+}
+
+func (p *pubsub) subscribers(channel string) []*client {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	res := make([]*client, 0, len(p.channels.byName[channel]))
+	for c := range p.channels.byName[channel] {
+		res = append(res, c)
+	}
+	return res
+}
+
+// patternSubscriber is a client subscribed to a pattern matching a channel.
+type patternSubscriber struct {
+	client  *client
+	pattern string
+}
+
+// patternSubscribers returns the clients subscribed to the patterns matching the channel, once per pattern.
+func (p *pubsub) patternSubscribers(channel string) []patternSubscriber {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	var res []patternSubscriber
+	for pattern, clients := range p.patterns.byName {
+		if !glob.Match(pattern, channel, false) {
+			continue
+		}
+		for c := range clients {
+			res = append(res, patternSubscriber{client: c, pattern: pattern})
+		}
+	}
+	return res
+}
+
+// pubsubMessage encodes a message of the pubsub protocol, as a push in RESP3.
+func pubsubMessage(proto int, kind, channel string, payload []byte) []byte {
+	return pubsubElems(proto, [][]byte{resp.NewBulkString(kind), resp.NewBulkString(channel), payload})
+}
+
+// pubsubPatternMessage encodes a message published to a channel matching a pattern, as a push in RESP3.
+func pubsubPatternMessage(proto int, pattern, channel string, payload []byte) []byte {
+	return pubsubElems(proto, [][]byte{resp.NewBulkString("pmessage"), resp.NewBulkString(pattern), resp.NewBulkString(channel), payload})
+}
+
+func pubsubElems(proto int, elems [][]byte) []byte {
+	if proto == resp.RESP3 {
+		return resp.NewPush(elems)
+	}
+	return resp.NewArray(elems)
+}
+
+// subscribed switches the class of the client once it subscribed to its first channel or pattern, or unsubscribed
+// from its last one.
+func (s *server) subscribed(c *client, n int) {
+	if n > 0 {
+		c.setType(clientTypePubsub)
+		c.out.setLimit(s.config.outputLimits[clientClassPubsub])
+		return
+	}
+	c.setType(clientTypeNormal)
+	c.out.setLimit(s.config.outputLimits[clientClassNormal])
+}
+
+// handleSubscribe handles SUBSCRIBE channel [channel ...] and PSUBSCRIBE pattern [pattern ...].
+func (s *server) handleSubscribe(conn io.Writer, arr []string, state *clientState) error {
+	if len(arr) < 2 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	kind, subscribe := "subscribe", s.pubsub.subscribe
+	if strings.EqualFold(arr[0], "PSUBSCRIBE") {
+		kind, subscribe = "psubscribe", s.pubsub.psubscribe
+	}
+	n := 0
+	for _, name := range arr[1:] {
+		n = subscribe(state.client, name)
+		if _, err := conn.Write(pubsubMessage(protocol(conn), kind, name, resp.NewInt(n))); err != nil {
+			return fmt.Errorf("error writing to connection: %s", err.Error())
+		}
+	}
+	s.subscribed(state.client, n)
+	return nil
+}
+
+// handleUnsubscribe handles UNSUBSCRIBE [channel [channel ...]] and PUNSUBSCRIBE [pattern [pattern ...]],
+// all the channels, or all the patterns, are unsubscribed when none is given.
+func (s *server) handleUnsubscribe(conn io.Writer, arr []string, state *clientState) error {
+	kind, unsubscribe, subscriptions := "unsubscribe", s.pubsub.unsubscribe, s.pubsub.subscriptions
+	if strings.EqualFold(arr[0], "PUNSUBSCRIBE") {
+		kind, unsubscribe, subscriptions = "punsubscribe", s.pubsub.punsubscribe, s.pubsub.patternSubscriptions
+	}
+	names := arr[1:]
+	if len(names) == 0 {
+		names = subscriptions(state.client)
+	}
+	if len(names) == 0 {
+		elems := [][]byte{resp.NewBulkString(kind), nullBulkString(conn), resp.NewInt(s.pubsub.count(state.client))}
+		if _, err := conn.Write(pubsubElems(protocol(conn), elems)); err != nil {
+			return fmt.Errorf("error writing to connection: %s", err.Error())
+		}
+		return nil
+	}
+	n := 0
+	for _, name := range names {
+		n = unsubscribe(state.client, name)
+		if _, err := conn.Write(pubsubMessage(protocol(conn), kind, name, resp.NewInt(n))); err != nil {
+			return fmt.Errorf("error writing to connection: %s", err.Error())
+		}
+	}
+	s.subscribed(state.client, n)
+	return nil
+}
+
+// handlePublish handles PUBLISH channel message, it replies the number of clients the message was sent to,
+// through the channel or through the patterns matching it.
+func (s *server) handlePublish(conn io.Writer, arr []string) error {
+	if len(arr) != 3 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	subscribers := s.pubsub.subscribers(arr[1])
+	for _, c := range subscribers {
+		c.push(pubsubMessage(c.protocol(), "message", arr[1], resp.NewBulkString(arr[2])))
+	}
+	// a client subscribed to several patterns matching the channel receives the message once per pattern
+	patternSubscribers := s.pubsub.patternSubscribers(arr[1])
+	for _, ps := range patternSubscribers {
+		ps.client.push(pubsubPatternMessage(ps.client.protocol(), ps.pattern, arr[1], resp.NewBulkString(arr[2])))
+	}
+	if _, err := conn.Write(resp.NewInt(len(subscribers) + len(patternSubscribers))); err != nil {
+		return fmt.Errorf("error writing to connection: %s", err.Error())
+	}
+	return nil
+}
+
+// handleSubscribedPing handles PING [message] while a RESP2 client is subscribed, it replies like a message.
+func handleSubscribedPing(conn io.Writer, arr []string) error {
+	msg := ""
+	if len(arr) > 1 {
+		msg = arr[1]
+	}
+	if _, err := conn.Write(resp.NewArray([][]byte{resp.NewBulkString("pong"), resp.NewBulkString(msg)})); err != nil {
+		return fmt.Errorf("error writing to connection: %s", err.Error())
+	}
+	return nil
+}
+
+// errSubscribed is replied to the commands a RESP2 client cannot send while subscribed.
+func errSubscribed(cmd string) error {
+	return fmt.Errorf("Can't execute '%s': only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING / QUIT / RESET are allowed in this context", strings.ToLower(cmd))
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/codecrafters-io/redis-starter-go/app/database"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPubSub(t *testing.T) {
+	s := newServer(host, "0", []*database.DB{database.NewDB()}, RoleMaster, testCfg)
+	conn, r, _ := tcpClient(t, s)
+	sub, subR, _ := tcpClient(t, s)
+	sub3, sub3R, _ := tcpClient(t, s)
+
+	require.Equal(t, 0, do(t, conn, r, "PUBLISH", "news", "hello"))
+	require.Equal(t, []any{"subscribe", "news", 1}, do(t, sub, subR, "SUBSCRIBE", "news", "sports"))
+	require.Equal(t, []any{"subscribe", "sports", 2}, readPush(t, subR))
+	require.Equal(t, 3, do(t, sub3, sub3R, "HELLO", "3").(map[string]any)["proto"])
+	require.Equal(t, []any{"subscribe", "news", 1}, do(t, sub3, sub3R, "SUBSCRIBE", "news"))
+	require.True(t, strings.HasPrefix(do(t, conn, r, "CLIENT", "LIST", "TYPE", "pubsub").(string), "id=2 "))
+
+	require.Equal(t, 2, do(t, conn, r, "PUBLISH", "news", "hello"))
+	require.Equal(t, []any{"message", "news", "hello"}, readPush(t, subR))
+	require.Equal(t, []any{"message", "news", "hello"}, readPush(t, sub3R))
+
+	// a RESP2 client can only manage its subscriptions, a RESP3 client can send any command
+	require.Equal(t, errors.New("ERR Can't execute 'get': only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING / QUIT / RESET are allowed in this context"), do(t, sub, subR, "GET", "k"))
+	require.Equal(t, []any{"pong", ""}, do(t, sub, subR, "PING"))
+	require.Nil(t, do(t, sub3, sub3R, "GET", "k"))
+	require.Equal(t, "PONG", do(t, sub3, sub3R, "PING"))
+
+	require.Equal(t, []any{"unsubscribe", "news", 1}, do(t, sub, subR, "UNSUBSCRIBE"))
+	require.Equal(t, []any{"unsubscribe", "sports", 0}, readPush(t, subR))
+	require.Equal(t, []any{"unsubscribe", nil, 0}, do(t, sub, subR, "UNSUBSCRIBE"))
+	require.Nil(t, do(t, sub, subR, "GET", "k"))
+	require.Equal(t, 1, do(t, conn, r, "PUBLISH", "news", "again"))
+	require.Equal(t, []any{"message", "news", "again"}, readPush(t, sub3R))
+}
+
+func TestPatternSubscribe(t *testing.T) {
+	s := newServer(host, "0", []*database.DB{database.NewDB()}, RoleMaster, testCfg)
+	conn, r, _ := tcpClient(t, s)
+	sub, subR, _ := tcpClient(t, s)
+	sub3, sub3R, _ := tcpClient(t, s)
+
+	require.Equal(t, []any{"psubscribe", "news.*", 1}, do(t, sub, subR, "PSUBSCRIBE", "news.*", "news.[ab]*"))
+	require.Equal(t, []any{"psubscribe", "news.[ab]*", 2}, readPush(t, subR))
+	require.Equal(t, []any{"subscribe", "news.art", 3}, do(t, sub, subR, "SUBSCRIBE", "news.art"))
+	require.Equal(t, 3, do(t, sub3, sub3R, "HELLO", "3").(map[string]any)["proto"])
+	require.Equal(t, []any{"psubscribe", "*", 1}, do(t, sub3, sub3R, "PSUBSCRIBE", "*"))
+	require.Equal(t, []any{"pong", ""}, do(t, sub, subR, "PING"))
+
+	// the message is sent once per matching pattern, and once for the channel
+	require.Equal(t, 4, do(t, conn, r, "PUBLISH", "news.art", "hello"))
+	msgs := []any{readPush(t, subR), readPush(t, subR), readPush(t, subR)}
+	require.ElementsMatch(t, []any{
+		[]any{"message", "news.art", "hello"},
+		[]any{"pmessage", "news.*", "news.art", "hello"},
+		[]any{"pmessage", "news.[ab]*", "news.art", "hello"},
+	}, msgs)
+	require.Equal(t, []any{"pmessage", "*", "news.art", "hello"}, readPush(t, sub3R))
+	require.Equal(t, 2, do(t, conn, r, "PUBLISH", "news.tech", "hi"))
+	require.Equal(t, []any{"pmessage", "news.*", "news.tech", "hi"}, readPush(t, subR))
+	require.Equal(t, []any{"pmessage", "*", "news.tech", "hi"}, readPush(t, sub3R))
+
+	// unsubscribing from the patterns keeps the channels
+	require.Equal(t, []any{"punsubscribe", "news.*", 2}, do(t, sub, subR, "PUNSUBSCRIBE"))
+	require.Equal(t, []any{"punsubscribe", "news.[ab]*", 1}, readPush(t, subR))
+	require.Equal(t, []any{"punsubscribe", nil, 1}, do(t, sub, subR, "PUNSUBSCRIBE"))
+	require.Equal(t, errors.New("ERR Can't execute 'get': only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING / QUIT / RESET are allowed in this context"), do(t, sub, subR, "GET", "k"))
+	require.Equal(t, []any{"unsubscribe", "news.art", 0}, do(t, sub, subR, "UNSUBSCRIBE"))
+	require.Nil(t, do(t, sub, subR, "GET", "k"))
+	require.Equal(t, 1, do(t, conn, r, "PUBLISH", "news.art", "again"))
+	require.Equal(t, []any{"pmessage", "*", "news.art", "again"}, readPush(t, sub3R))
+}
@@ -2,11 +2,13 @@ package main
 
 import (
 	"bufio"
+	"crypto/tls"
 	"fmt"
 	"io"
 	"net"
 	"os"
 	"strconv"
+	"strings"
 
 	appbufio "github.com/codecrafters-io/redis-starter-go/app/bufio"
 	"github.com/codecrafters-io/redis-starter-go/app/database"
@@ -52,12 +54,25 @@ func (s *replicaServer) Start(shutdown chan os.Signal) {
 	s.server.Start(shutdown, s.server.handler)
 }
 
+// dialMaster connects to the master, over TLS with tls-replication.
+func (s *replicaServer) dialMaster() (net.Conn, error) {
+	addr := net.JoinHostPort(s.masterHost, s.masterPort)
+	if !s.config.tlsReplication {
+		return net.Dial("tcp", addr)
+	}
+	tlsConfig, err := s.config.replicationTLSConfig(s.masterHost)
+	if err != nil {
+		return nil, err
+	}
+	return tls.Dial("tcp", addr, tlsConfig)
+}
+
 // handshake sends the handshake message to the master
 func (s *replicaServer) sendHandshake() (*bufio.Reader, io.WriteCloser, error) {
 	if s.role != RoleSlave {
 		return nil, nil, fmt.Errorf("replica role is not slave")
 	}
-	conn, err := net.Dial("tcp", fmt.Sprintf("%s:%s", s.masterHost, s.masterPort))
+	conn, err := s.dialMaster()
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
@@ -126,6 +141,7 @@ func (s *replicaServer) sendHandshake() (*bufio.Reader, io.WriteCloser, error) {
 func (s *replicaServer) replHandler(ir *bufio.Reader, wc io.WriteCloser) error {
 	r := appbufio.NewTrackedBufioReader(ir)
 	defer wc.Close()
+	state := &clientState{}
 	for {
 		typ, err := resp.CheckDataType(r)
 		if err != nil {
@@ -149,22 +165,15 @@ func (s *replicaServer) replHandler(ir *bufio.Reader, wc io.WriteCloser) error {
 				return fmt.Errorf("error writing to connection: %s", err.Error())
 			}
 		}
-		switch arr[0] {
+		switch strings.ToUpper(arr[0]) {
 		// All other propagated commands (like PING, SET etc.) should be read and processed, but a response should not be sent back to the master.
 		case "PING": // https://redis.io/docs/latest/commands/ping/
 		// https://redis.io/docs/latest/commands/echo/
 		// [ECHO, message]
 		case "ECHO":
-		// https://redis.io/docs/latest/commands/set/
-		// [SET, key, value]
-		case "SET":
-			if err := handleSet(wc, arr, s.db); err != nil {
-				return err
-			}
 		// [GET, key]
 		// All other propagated commands (like PING, SET etc.) should be read and processed, but a response should not be sent back to the master.
 		case "GET":
-		// TODO
 		// REPLCONF <option> <value> <option> <value> ...
 		// This command is used by a replica in order to configure the replication process before starting it with the SYNC command.
 		// ref: https://github.com/redis/redis/blob/811c5d7aeb0b76494d78efe61e418f574c310ec0/src/replication.c#L1114C4-L1114C50
@@ -184,9 +193,10 @@ func (s *replicaServer) replHandler(ir *bufio.Reader, wc io.WriteCloser) error {
 				}
 			}
 
+		// write commands (SET, SELECT, ...) are applied, the state keeps track of the db selected by the master.
 		default:
-			if _, err := wc.Write([]byte(resp.NewErrorMSG("unknown command " + arr[0]))); err != nil {
-				return fmt.Errorf("error writing to connection: %s", err.Error())
+			if err := s.handleWriteOnlyCmd(io.Discard, arr, state); err != nil {
+				return err
 			}
 		}
 		s.replicaConf.masterOffset += r.NAndReset()
@@ -18,6 +18,10 @@ func TestHandshake(t *testing.T) {
 	c := make(chan os.Signal, 1)
 	defer close(c)
 	go master.Start(c, master.handler)
+	// wait until master is listening
+	conn, err := dialWithRetry(3, "localhost", masterPort)
+	require.NoError(t, err)
+	conn.Close()
 
 	rs, err := newReplicaServer("localhost", replicaPort, mockdbs, &replicaConf{masterHost: "localhost", masterPort: masterPort}, testCfg)
 	setTestServerReusePort(nil, rs)
@@ -36,6 +40,10 @@ func TestPropogate(t *testing.T) {
 	c := make(chan os.Signal, 1)
 	defer close(c)
 	go master.Start(c, master.handler)
+	// wait until master is listening
+	conn, err := dialWithRetry(3, "localhost", masterPort)
+	require.NoError(t, err)
+	conn.Close()
 
 	rs, err := newReplicaServer("localhost", replicaPort, mockdbs, &replicaConf{masterHost: "localhost", masterPort: masterPort}, testCfg)
 	setTestServerReusePort(nil, rs)
@@ -99,6 +107,10 @@ func TestPropogateAndAck(t *testing.T) {
 	c := make(chan os.Signal, 1)
 	defer close(c)
 	go master.Start(c, master.handler)
+	// wait until master is listening
+	conn, err := dialWithRetry(3, "localhost", masterPort)
+	require.NoError(t, err)
+	conn.Close()
 
 	rs, err := newReplicaServer("localhost", replicaPort, mockdbs, &replicaConf{masterHost: "localhost", masterPort: masterPort}, testCfg)
 	setTestServerReusePort(nil, rs)
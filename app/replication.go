@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/codecrafters-io/redis-starter-go/app/persistence"
+	"github.com/codecrafters-io/redis-starter-go/app/replication"
 	"github.com/codecrafters-io/redis-starter-go/app/resp"
 )
 
@@ -106,11 +107,34 @@ func (s *server) handleReiplicaHanshake(conn net.Conn, r *bufio.Reader, port str
 
 func (s *server) handleReplica(conn net.Conn, r *bufio.Reader, port string) error {
 	id := replicaID(conn.RemoteAddr().String(), port)
+	s.replMu.Lock()
+	// the stream of a new replica starts after the snapshot, the next propagated command has to select its db
+	s.replSelectedDB = -1
 	replicaBacklog := s.replicationBacklog.RegisterReplica(id)
+	s.replMu.Unlock()
+	defer s.replicationBacklog.UnregisterReplica(id, replicaBacklog)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-replicaBacklog.Dropped():
+			// unblocks a write to a replica which does not read anymore
+			fmt.Printf("Client %s scheduled to be closed ASAP for overcoming of output buffer limits.\n", id)
+			conn.Close()
+		case <-done:
+		}
+	}()
 
 	// read from master broadcast channel
-	for msg := range replicaBacklog.Broadcast {
+	for {
+		var msg replication.Msg
+		select {
+		case msg = <-replicaBacklog.Broadcast:
+		case <-replicaBacklog.Dropped():
+			return nil
+		}
 		_, err := conn.Write(msg.Data)
+		replicaBacklog.Sent(msg)
 		if err != nil {
 			return fmt.Errorf("error writing to connection: %s", err.Error())
 		}
@@ -169,7 +193,6 @@ func (s *server) handleReplica(conn net.Conn, r *bufio.Reader, port string) erro
 			}
 		}
 	}
-	return nil
 }
 
 func replicaID(remoteAddr, port string) string {
@@ -11,13 +11,50 @@ import (
 type ReplicatinoBacklog struct {
 	mu      sync.RWMutex
 	maxSize int
+	limit   OutputBufferLimit
 	backlog map[string]*replicaBacklog
 }
 
+// replicaBacklog queues the messages of a replica without limit of count, so that a slow replica does not block
+// the broadcast, and feeds them to Broadcast. The replica is dropped once the size of the queued messages
+// exceeds the output buffer limit.
 type replicaBacklog struct {
-	Broadcast      chan Msg
-	expectedOffset uint64
-	currentOffset  uint64
+	Broadcast    chan Msg
+	pendingBytes atomic.Int64 // size of the messages not sent to the replica yet
+
+	mu             sync.Mutex
+	expectedOffset uint64 // offset of the replication stream once the queued messages are processed
+	currentOffset  uint64 // offset last acknowledged by the replica
+	queue          []Msg
+	limit          OutputBufferLimit
+	softSince      time.Time     // since when the soft limit is exceeded, zero if it is not
+	notify         chan struct{} // signals the queued messages to the feeding goroutine
+	dropped        chan struct{}
+	dropOnce       sync.Once
+}
+
+// OutputBufferLimit is a class of client-output-buffer-limit. A client is disconnected once the size of its
+// pending replies reaches the hard limit, or stays over the soft limit for SoftSeconds. Zero disables a limit.
+// ref: https://github.com/redis/redis/blob/7.2.0/src/networking.c#L3940
+type OutputBufferLimit struct {
+	Hard        int64
+	Soft        int64
+	SoftSeconds int
+}
+
+// Exceeded tells if the pending bytes reach the limits, softSince keeps track of when the soft limit started to be exceeded.
+func (l OutputBufferLimit) Exceeded(pending int64, softSince *time.Time, now time.Time) bool {
+	if l.Hard > 0 && pending >= l.Hard {
+		return true
+	}
+	if l.Soft == 0 || pending < l.Soft {
+		*softSince = time.Time{}
+		return false
+	}
+	if softSince.IsZero() {
+		*softSince = now
+	}
+	return now.Sub(*softSince) >= time.Duration(l.SoftSeconds)*time.Second
 }
 
 // Msg represents a message in backlog
@@ -34,15 +71,40 @@ func NewReplicationBacklog(maxSize int) *ReplicatinoBacklog {
 	}
 }
 
+// SetLimit sets the output buffer limit of the replicas registered afterwards.
+func (r *ReplicatinoBacklog) SetLimit(limit OutputBufferLimit) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.limit = limit
+}
+
 // RegisterReplica registers a replica to the replication backlog
 func (r *ReplicatinoBacklog) RegisterReplica(id string) *replicaBacklog {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	broadcast := make(chan Msg, r.maxSize)
-	r.backlog[id] = &replicaBacklog{
-		Broadcast: broadcast,
+	bl := &replicaBacklog{
+		Broadcast: make(chan Msg, r.maxSize),
+		limit:     r.limit,
+		notify:    make(chan struct{}, 1),
+		dropped:   make(chan struct{}),
 	}
-	return r.backlog[id]
+	if old, ok := r.backlog[id]; ok {
+		old.drop()
+	}
+	r.backlog[id] = bl
+	go bl.feed()
+	return bl
+}
+
+// UnregisterReplica removes the replica from the replication backlog once it is disconnected.
+func (r *ReplicatinoBacklog) UnregisterReplica(id string, bl *replicaBacklog) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// the replica may have reconnected with the same id in the meantime
+	if r.backlog[id] == bl {
+		delete(r.backlog, id)
+	}
+	bl.drop()
 }
 
 // BroardcastBacklog add the message to all the replica's backlog
@@ -58,8 +120,8 @@ func (r *ReplicatinoBacklog) BroardcastBacklog(msg Msg) {
 func (r *ReplicatinoBacklog) InSyncReplicas(timeout time.Duration, waitCount int) int {
 	r.mu.RLock()
 	insyncCount := &atomic.Int32{}
-	for id, rplc := range r.backlog {
-		go r.checkInSync(id, rplc, insyncCount, timeout)
+	for _, rplc := range r.backlog {
+		go r.checkInSync(rplc, insyncCount, timeout)
 	}
 	r.mu.RUnlock()
 
@@ -68,14 +130,14 @@ func (r *ReplicatinoBacklog) InSyncReplicas(timeout time.Duration, waitCount int
 	return c
 }
 
-func (r *ReplicatinoBacklog) checkInSync(id string, bl *replicaBacklog, count *atomic.Int32, timeout time.Duration) {
+func (r *ReplicatinoBacklog) checkInSync(bl *replicaBacklog, count *atomic.Int32, timeout time.Duration) {
 	// not supposed to send a GETACK to the replicas if there was nothing to sent before
-	if bl.expectedOffset == 0 {
+	// because replica is acking the offset BEFORE processing [REPLCONF GETACK *]
+	expOffset, _ := bl.offsets()
+	if expOffset == 0 {
 		count.Add(1)
 		return
 	}
-	// because replica is acking the offset BEFORE processing [REPLCONF GETACK *]
-	expOffset := bl.expectedOffset
 	msg := Msg{
 		Data:               resp.NewArray([][]byte{resp.NewBulkString("REPLCONF"), resp.NewBulkString("GETACK"), resp.NewBulkString("*")}),
 		ShouldWaitResponse: true,
@@ -84,18 +146,100 @@ func (r *ReplicatinoBacklog) checkInSync(id string, bl *replicaBacklog, count *a
 	bl.AddBacklog(msg)
 
 	<-time.After(timeout + time.Millisecond)
-	updatedBl := r.backlog[id]
-	if updatedBl.currentOffset == expOffset {
+	if _, current := bl.offsets(); current == expOffset {
 		count.Add(1)
 		return
 	}
 }
 
 func (bl *replicaBacklog) Ack(offset uint64) {
+	bl.mu.Lock()
+	defer bl.mu.Unlock()
 	bl.currentOffset = offset
 }
 
+// offsets returns the expected offset of the replica and the one it acknowledged.
+func (bl *replicaBacklog) offsets() (expected, current uint64) {
+	bl.mu.Lock()
+	defer bl.mu.Unlock()
+	return bl.expectedOffset, bl.currentOffset
+}
+
+// Sent marks a message received from Broadcast as written to the replica.
+func (bl *replicaBacklog) Sent(msg Msg) {
+	bl.pendingBytes.Add(-int64(len(msg.Data)))
+}
+
+// Stats returns the number of replicas and the size of the messages waiting to be sent to them.
+func (r *ReplicatinoBacklog) Stats() (int, int64) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var pending int64
+	for _, bl := range r.backlog {
+		pending += bl.pendingBytes.Load()
+	}
+	return len(r.backlog), pending
+}
+
+// AddBacklog queues the message, the replica is dropped instead if its output buffer limit is exceeded.
 func (bl *replicaBacklog) AddBacklog(msg Msg) {
-	bl.Broadcast <- msg
+	bl.mu.Lock()
+	defer bl.mu.Unlock()
+	if bl.isDropped() {
+		return
+	}
+	pending := bl.pendingBytes.Add(int64(len(msg.Data)))
+	if bl.limit.Exceeded(pending, &bl.softSince, time.Now()) {
+		bl.drop()
+		return
+	}
+	bl.queue = append(bl.queue, msg)
 	bl.expectedOffset += uint64(len(msg.Data))
+	select {
+	case bl.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Dropped is closed once the replica exceeded its output buffer limit or was unregistered,
+// the connection to the replica should be closed then.
+func (bl *replicaBacklog) Dropped() <-chan struct{} {
+	return bl.dropped
+}
+
+func (bl *replicaBacklog) isDropped() bool {
+	select {
+	case <-bl.dropped:
+		return true
+	default:
+		return false
+	}
+}
+
+func (bl *replicaBacklog) drop() {
+	bl.dropOnce.Do(func() { close(bl.dropped) })
+}
+
+// feed moves the queued messages to Broadcast in order, until the replica is dropped.
+func (bl *replicaBacklog) feed() {
+	for {
+		bl.mu.Lock()
+		if len(bl.queue) == 0 {
+			bl.mu.Unlock()
+			select {
+			case <-bl.notify:
+				continue
+			case <-bl.dropped:
+				return
+			}
+		}
+		msg := bl.queue[0]
+		bl.queue = bl.queue[1:]
+		bl.mu.Unlock()
+		select {
+		case bl.Broadcast <- msg:
+		case <-bl.dropped:
+			return
+		}
+	}
 }
@@ -0,0 +1,58 @@
+package replication
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutputBufferLimitExceeded(t *testing.T) {
+	l := OutputBufferLimit{Hard: 100, Soft: 50, SoftSeconds: 10}
+	var since time.Time
+	now := time.Now()
+	require.False(t, l.Exceeded(49, &since, now))
+	require.True(t, l.Exceeded(100, &since, now))
+
+	require.False(t, l.Exceeded(50, &since, now))
+	require.Equal(t, now, since)
+	require.False(t, l.Exceeded(60, &since, now.Add(9*time.Second)))
+	require.True(t, l.Exceeded(60, &since, now.Add(10*time.Second)))
+	// going back under the soft limit resets the timer
+	require.False(t, l.Exceeded(10, &since, now.Add(11*time.Second)))
+	require.True(t, since.IsZero())
+
+	require.False(t, OutputBufferLimit{}.Exceeded(1<<40, &since, now))
+}
+
+func TestSlowReplica(t *testing.T) {
+	r := NewReplicationBacklog(10)
+	bl := r.RegisterReplica("slow")
+	// the broadcast does not block on a replica which does not read
+	for i := 0; i < 100; i++ {
+		r.BroardcastBacklog(Msg{Data: []byte("*1\r\n$4\r\nPING\r\n")})
+	}
+	_, pending := r.Stats()
+	require.Equal(t, int64(100*14), pending)
+	for i := 0; i < 100; i++ {
+		msg := <-bl.Broadcast
+		bl.Sent(msg)
+	}
+	_, pending = r.Stats()
+	require.Zero(t, pending)
+
+	r.SetLimit(OutputBufferLimit{Hard: 100})
+	bl = r.RegisterReplica("limited")
+	for i := 0; i < 10; i++ {
+		r.BroardcastBacklog(Msg{Data: []byte("*1\r\n$4\r\nPING\r\n")})
+	}
+	select {
+	case <-bl.Dropped():
+	case <-time.After(time.Second):
+		t.Fatal("the replica exceeding its limit should be dropped")
+	}
+
+	r.UnregisterReplica("limited", bl)
+	n, _ := r.Stats()
+	require.Equal(t, 1, n)
+}
@@ -0,0 +1,122 @@
+package resp
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ReadInline reads an inline command, a line of whitespace separated arguments such as typed over telnet,
+// once its first byte was read with CheckDataType. An empty line is returned as an empty array.
+// ref: https://github.com/redis/redis/blob/7.2.0/src/networking.c#L1803
+func ReadInline(r reader, first byte) ([]string, error) {
+	line := ""
+	if first != '\n' {
+		rest, err := readRESPMsg(r, "too big inline request")
+		if err != nil {
+			return nil, err
+		}
+		line = string(first) + rest
+	}
+	return splitArgs(line)
+}
+
+// splitArgs splits a line into arguments like redis-cli does: the arguments are separated by whitespaces,
+// and can be quoted. A double quoted argument understands the escapes \n, \r, \t, \b, \a and \xHH,
+// a single quoted argument only \'. A closing quote must be followed by a whitespace or the end of the line.
+// ref: https://github.com/redis/redis/blob/7.2.0/src/sds.c#L1041
+func splitArgs(line string) ([]string, error) {
+	errUnbalanced := &ProtocolError{Msg: "unbalanced quotes in request"}
+	args := []string{}
+	i := 0
+	for {
+		for i < len(line) && isSpace(line[i]) {
+			i++
+		}
+		if i == len(line) {
+			return args, nil
+		}
+		var arg strings.Builder
+		inDouble, inSingle := false, false
+		for done := false; !done; {
+			switch {
+			case inDouble:
+				if i == len(line) {
+					return nil, errUnbalanced
+				}
+				switch c := line[i]; {
+				case c == '\\' && i+3 < len(line) && line[i+1] == 'x' && isHex(line[i+2]) && isHex(line[i+3]):
+					b, _ := strconv.ParseUint(line[i+2:i+4], 16, 8)
+					arg.WriteByte(byte(b))
+					i += 3
+				case c == '\\' && i+1 < len(line):
+					i++
+					switch line[i] {
+					case 'n':
+						arg.WriteByte('\n')
+					case 'r':
+						arg.WriteByte('\r')
+					case 't':
+						arg.WriteByte('\t')
+					case 'b':
+						arg.WriteByte('\b')
+					case 'a':
+						arg.WriteByte('\a')
+					default:
+						arg.WriteByte(line[i])
+					}
+				case c == '"':
+					// the closing quote must be followed by a space or nothing at all
+					if i+1 < len(line) && !isSpace(line[i+1]) {
+						return nil, errUnbalanced
+					}
+					done = true
+				default:
+					arg.WriteByte(c)
+				}
+			case inSingle:
+				if i == len(line) {
+					return nil, errUnbalanced
+				}
+				switch c := line[i]; {
+				case c == '\\' && i+1 < len(line) && line[i+1] == '\'':
+					i++
+					arg.WriteByte('\'')
+				case c == '\'':
+					if i+1 < len(line) && !isSpace(line[i+1]) {
+						return nil, errUnbalanced
+					}
+					done = true
+				default:
+					arg.WriteByte(c)
+				}
+			default:
+				if i == len(line) {
+					done = true
+					continue
+				}
+				switch c := line[i]; c {
+				case ' ', '\n', '\r', '\t':
+					done = true
+				case '"':
+					inDouble = true
+				case '\'':
+					inSingle = true
+				default:
+					arg.WriteByte(c)
+				}
+			}
+			if i < len(line) {
+				i++
+			}
+		}
+		args = append(args, arg.String())
+	}
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\v' || c == '\f'
+}
+
+func isHex(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
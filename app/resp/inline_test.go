@@ -0,0 +1,43 @@
+package resp
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadInline(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     string
+		want    []string
+		wantErr string
+	}{
+		{"words", "SET a b\r\n", []string{"SET", "a", "b"}, ""},
+		{"extra whitespaces", "  PING \t\n", []string{"PING"}, ""},
+		{"empty line", "\r\n", []string{}, ""},
+		{"bare newline", "\n", []string{}, ""},
+		{"double quotes", `SET "a key" "x\"y\n\x41"` + "\r\n", []string{"SET", "a key", "x\"y\nA"}, ""},
+		{"single quotes", `SET k 'it\'s "raw" \n'` + "\r\n", []string{"SET", "k", `it's "raw" \n`}, ""},
+		{"empty quoted", `ECHO ""` + "\r\n", []string{"ECHO", ""}, ""},
+		{"unbalanced", `ECHO "abc` + "\r\n", nil, "Protocol error: unbalanced quotes in request"},
+		{"quote not followed by space", `ECHO "a"b` + "\r\n", nil, "Protocol error: unbalanced quotes in request"},
+		{"too big", strings.Repeat("a", maxLineLen+2) + "\r\n", nil, "Protocol error: too big inline request"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := bufio.NewReader(strings.NewReader(tt.req))
+			typ, err := CheckDataType(r)
+			require.NoError(t, err)
+			got, err := ReadInline(r, typ)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -1,9 +1,11 @@
 package resp
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"strconv"
 	"strings"
 	"syscall"
@@ -19,60 +21,115 @@ const (
 	TypeArray        = '*'
 	TypeError        = '-'
 	TypeInt          = ':'
+
+	// RESP3 types, replied only to the clients that negotiated the protocol version 3 with HELLO.
+	TypeNull           = '_'
+	TypeDouble         = ','
+	TypeBoolean        = '#'
+	TypeVerbatimString = '='
+	TypeMap            = '%'
+	TypePush           = '>'
+)
+
+// Protocol versions negotiated with HELLO.
+const (
+	RESP2 = 2
+	RESP3 = 3
+)
+
+// Limits of the requests, a request exceeding them is a protocol error.
+const (
+	DefaultMaxBulkLen = 512 * 1024 * 1024 // default proto-max-bulk-len
+	MaxMultiBulkLen   = 1024 * 1024
+	maxLineLen        = 64 * 1024 // length of an inline command or of the line declaring an array or a bulk string
 )
 
+// ProtocolError is a malformed request. The rest of the stream cannot be parsed anymore,
+// so it is replied to the client before closing the connection.
+type ProtocolError struct {
+	Msg string
+}
+
+func (e *ProtocolError) Error() string {
+	return "Protocol error: " + e.Msg
+}
+
 type reader interface {
-	ReadString(delim byte) (string, error)
-	ReadByte() (byte, error)
+	io.Reader
+	io.ByteReader
 }
 
+// HandleRESPArray reads a request with the default limits, once its type was read with CheckDataType.
 func HandleRESPArray(r reader) ([]string, error) {
-	elCountMsg, err := readRESPMsg(r)
+	return ReadCommand(r, DefaultMaxBulkLen)
+}
+
+// ReadCommand reads the array of bulk strings of a request, once its type was read with CheckDataType.
+// The bulk strings are read by their exact length so they can contain CRLF, and must not be longer than maxBulkLen.
+// An empty array is returned for the arrays of zero or negative length, which are ignored by redis.
+// ref: https://github.com/redis/redis/blob/7.2.0/src/networking.c#L1916
+func ReadCommand(r reader, maxBulkLen int64) ([]string, error) {
+	elCountMsg, err := readRESPMsg(r, "too big mbulk count string")
 	if err != nil {
-		return nil, fmt.Errorf("error reading from connection: %w", err)
+		return nil, err
 	}
 	// base-10 value.
 	elCount, err := strconv.ParseInt(elCountMsg, 10, 64)
-	if err != nil {
-		return nil, fmt.Errorf("unable to parse element count: %w", err)
+	if err != nil || elCount > MaxMultiBulkLen {
+		return nil, &ProtocolError{Msg: "invalid multibulk length"}
 	}
-	res := make([]string, elCount)
+	if elCount <= 0 {
+		return []string{}, nil
+	}
+	// the slice grows as the elements arrive rather than trusting the declared length
+	res := make([]string, 0, min(elCount, 1024))
 	for i := int64(0); i < elCount; i++ {
-		typmsg, err := readRESPMsg(r)
+		typ, err := r.ReadByte()
 		if err != nil {
 			return nil, fmt.Errorf("error reading from connection: %w", err)
 		}
-		switch typmsg[0] {
-		case TypeBulkString:
-			len, err := strconv.ParseInt(typmsg[1:], 10, 64)
-			if err != nil {
-				return nil, fmt.Errorf("unable to parse string length: %w", err)
-			}
-			s, err := readRESPMsg(r)
-			if err != nil {
-				return nil, fmt.Errorf("error reading string: %w", err)
-			}
-			res[i] = s[:len]
-			// TODO
-		case TypeSimpleString:
-			// TODO
-		case TypeArray:
-			// TODO
+		if typ != TypeBulkString {
+			return nil, &ProtocolError{Msg: fmt.Sprintf("expected '%c', got '%c'", TypeBulkString, typ)}
+		}
+		lenMsg, err := readRESPMsg(r, "too big bulk count string")
+		if err != nil {
+			return nil, err
+		}
+		n, err := strconv.ParseInt(lenMsg, 10, 64)
+		if err != nil || n < 0 || n > maxBulkLen {
+			return nil, &ProtocolError{Msg: "invalid bulk length"}
 		}
+		// the buffer grows as the payload arrives too, the trailing CRLF is skipped like redis does
+		var b bytes.Buffer
+		if _, err := io.CopyN(&b, r, n+2); err != nil {
+			return nil, fmt.Errorf("error reading string: %w", err)
+		}
+		res = append(res, string(b.Bytes()[:n]))
 	}
 	return res, nil
 }
 
-// readRESPMsg reads a RESP message from the reader and trim the line break.
-func readRESPMsg(r reader) (string, error) {
-	msg, err := r.ReadString('\n')
-	if err != nil {
-		return "", fmt.Errorf("error reading from connection: %s", err.Error())
+// readRESPMsg reads a line and trim the line break, the line longer than maxLineLen is a protocol error with the message tooBig.
+func readRESPMsg(r reader, tooBig string) (string, error) {
+	var line []byte
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return "", fmt.Errorf("error reading from connection: %w", err)
+		}
+		if b == '\n' {
+			break
+		}
+		if len(line) == maxLineLen {
+			return "", &ProtocolError{Msg: tooBig}
+		}
+		line = append(line, b)
 	}
 	// The \r\n (CRLF) is the protocol's terminator, which always separates its parts.
-	return strings.TrimRight(msg, "\r\n"), nil
+	return strings.TrimSuffix(string(line), "\r"), nil
 }
 
+// CheckDataType reads the type of the next request, any byte is returned since the caller decides how to parse it.
 func CheckDataType(r reader) (byte, error) {
 	b, err := r.ReadByte()
 	if err != nil {
@@ -85,17 +142,7 @@ func CheckDataType(r reader) (byte, error) {
 		}
 		return 0, fmt.Errorf("error reading byte from connection 5: %v", err.Error())
 	}
-
-	switch b {
-	case TypeSimpleString:
-		return TypeSimpleString, nil
-	case TypeBulkString:
-		return TypeBulkString, nil
-	case TypeArray:
-		return TypeArray, nil
-	default:
-		return 0, fmt.Errorf("unknown type: %c", b)
-	}
+	return b, nil
 }
 
 func NewSimpleString(msg string) []byte {
@@ -110,16 +157,21 @@ func NewNullBulkString() []byte {
 	return []byte(fmt.Sprintf("%c-1\r\n", TypeBulkString))
 }
 
+func NewNullArray() []byte {
+	return []byte(fmt.Sprintf("%c-1\r\n", TypeArray))
+}
+
 func NewArray(arr [][]byte) []byte {
-	prefix := []byte(fmt.Sprintf("%c%d\r\n", TypeArray, len(arr)))
-	for _, v := range arr {
-		prefix = append(prefix, v...)
-	}
-	return prefix
+	return aggregate(TypeArray, len(arr), arr)
 }
 
 func NewErrorMSG(msg string) []byte {
-	return []byte(fmt.Sprintf("%cERR %s\r\n", TypeError, msg))
+	return NewError("ERR", msg)
+}
+
+// NewError encodes an error with the given code, such as WRONGTYPE.
+func NewError(code, msg string) []byte {
+	return []byte(fmt.Sprintf("%c%s %s\r\n", TypeError, code, msg))
 }
 
 func NewRDBFile(f []byte) []byte {
@@ -127,10 +179,11 @@ func NewRDBFile(f []byte) []byte {
 	return append(prefix, f...)
 }
 
-func NewSetCmd(arr []string) []byte {
-	a := [][]byte{NewBulkString("SET"), NewBulkString(arr[1]), NewBulkString(arr[2])}
-	if len(arr) > 3 {
-		a = append(a, NewBulkString(arr[3]), NewBulkString(arr[4]))
+// NewCommand encodes a command as an array of bulk strings.
+func NewCommand(arr []string) []byte {
+	a := make([][]byte, len(arr))
+	for i, s := range arr {
+		a[i] = NewBulkString(s)
 	}
 	return NewArray(a)
 }
@@ -139,6 +192,57 @@ func NewInt(i int) []byte {
 	return []byte(fmt.Sprintf("%c%d\r\n", TypeInt, i))
 }
 
+func NewNull() []byte {
+	return []byte(fmt.Sprintf("%c\r\n", TypeNull))
+}
+
+// NewDouble encodes a double, the infinities are encoded as inf and -inf.
+func NewDouble(f float64) []byte {
+	var s string
+	switch {
+	case math.IsInf(f, 1):
+		s = "inf"
+	case math.IsInf(f, -1):
+		s = "-inf"
+	case math.IsNaN(f):
+		s = "nan"
+	default:
+		s = strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	return []byte(fmt.Sprintf("%c%s\r\n", TypeDouble, s))
+}
+
+func NewBoolean(b bool) []byte {
+	v := 'f'
+	if b {
+		v = 't'
+	}
+	return []byte(fmt.Sprintf("%c%c\r\n", TypeBoolean, v))
+}
+
+// NewVerbatimString encodes a string along with its three characters format, such as txt or mkd.
+func NewVerbatimString(format, msg string) []byte {
+	return []byte(fmt.Sprintf("%c%d\r\n%s:%s\r\n", TypeVerbatimString, len(format)+1+len(msg), format, msg))
+}
+
+// NewMap encodes a map from its encoded keys and values, given alternately.
+func NewMap(kvs [][]byte) []byte {
+	return aggregate(TypeMap, len(kvs)/2, kvs)
+}
+
+// NewPush encodes out of band data, such as an invalidation message, the first element is the kind of the data.
+func NewPush(elems [][]byte) []byte {
+	return aggregate(TypePush, len(elems), elems)
+}
+
+func aggregate(typ byte, n int, elems [][]byte) []byte {
+	prefix := []byte(fmt.Sprintf("%c%d\r\n", typ, n))
+	for _, v := range elems {
+		prefix = append(prefix, v...)
+	}
+	return prefix
+}
+
 func NewStreamEntries(ents []database.Entry) []byte {
 	res := make([][]byte, len(ents))
 	for i, e := range ents {
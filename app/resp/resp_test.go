@@ -0,0 +1,63 @@
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     string
+		want    []string
+		wantErr string
+	}{
+		{"bulk strings", "*2\r\n$3\r\nGET\r\n$3\r\nkey\r\n", []string{"GET", "key"}, ""},
+		{"embedded CRLF", "*2\r\n$4\r\nECHO\r\n$5\r\na\r\nb\n\r\n", []string{"ECHO", "a\r\nb\n"}, ""},
+		{"empty bulk string", "*1\r\n$0\r\n\r\n", []string{""}, ""},
+		{"empty array", "*0\r\n", []string{}, ""},
+		{"negative array", "*-1\r\n", []string{}, ""},
+		{"invalid multibulk length", "*x\r\n", nil, "Protocol error: invalid multibulk length"},
+		{"too many elements", "*1048577\r\n", nil, "Protocol error: invalid multibulk length"},
+		{"not a bulk string", "*1\r\n+PING\r\n", nil, "Protocol error: expected '$', got '+'"},
+		{"nested array", "*1\r\n*1\r\n", nil, "Protocol error: expected '$', got '*'"},
+		{"invalid bulk length", "*1\r\n$-1\r\n", nil, "Protocol error: invalid bulk length"},
+		{"bulk too long", "*1\r\n$11\r\nhello world\r\n", nil, "Protocol error: invalid bulk length"},
+		{"line too long", "*" + strings.Repeat("1", maxLineLen+1) + "\r\n", nil, "Protocol error: too big mbulk count string"},
+		{"truncated bulk string", "*1\r\n$5\r\nhel", nil, "error reading string: EOF"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := bufio.NewReader(strings.NewReader(tt.req))
+			typ, err := CheckDataType(r)
+			require.NoError(t, err)
+			require.Equal(t, byte(TypeArray), typ)
+			got, err := ReadCommand(r, 10)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+
+	var protoErr *ProtocolError
+	_, err := ReadCommand(bufio.NewReader(strings.NewReader("x\r\n")), 10)
+	require.True(t, errors.As(err, &protoErr))
+}
+
+func TestRESP3Encoding(t *testing.T) {
+	require.Equal(t, "_\r\n", string(NewNull()))
+	require.Equal(t, ",1.5\r\n", string(NewDouble(1.5)))
+	require.Equal(t, ",-inf\r\n", string(NewDouble(math.Inf(-1))))
+	require.Equal(t, "#t\r\n", string(NewBoolean(true)))
+	require.Equal(t, "=9\r\ntxt:hello\r\n", string(NewVerbatimString("txt", "hello")))
+	require.Equal(t, "%1\r\n+a\r\n:1\r\n", string(NewMap([][]byte{NewSimpleString("a"), NewInt(1)})))
+	require.Equal(t, ">1\r\n+a\r\n", string(NewPush([][]byte{NewSimpleString("a")})))
+}
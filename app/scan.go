@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/database"
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+var errSyntax = fmt.Errorf("syntax error")
+
+// parseScanOptions parses the [MATCH pattern] [COUNT count] [TYPE type] [NOVALUES] arguments of SCAN family commands.
+// TYPE is only accepted by SCAN and NOVALUES only by HSCAN.
+func parseScanOptions(args []string, cmd string) (database.ScanOptions, bool, error) {
+	opts := database.ScanOptions{Count: database.DefaultScanCount}
+	noValues := false
+	for i := 0; i < len(args); i++ {
+		opt := strings.ToUpper(args[i])
+		if opt == "NOVALUES" && cmd == "HSCAN" {
+			noValues = true
+			continue
+		}
+		if i+1 >= len(args) {
+			return opts, false, errSyntax
+		}
+		switch {
+		case opt == "MATCH":
+			opts.Match = args[i+1]
+		case opt == "COUNT":
+			count, err := strconv.Atoi(args[i+1])
+			if err != nil {
+				return opts, false, fmt.Errorf("value is not an integer or out of range")
+			}
+			if count < 1 {
+				return opts, false, errSyntax
+			}
+			opts.Count = count
+		case opt == "TYPE" && cmd == "SCAN":
+			opts.Type = strings.ToLower(args[i+1])
+		default:
+			return opts, false, errSyntax
+		}
+		i++
+	}
+	return opts, noValues, nil
+}
+
+func parseCursor(s string) (uint64, error) {
+	cursor, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid cursor")
+	}
+	return cursor, nil
+}
+
+func newScanReply(cursor uint64, elements [][]byte) []byte {
+	return resp.NewArray([][]byte{
+		resp.NewBulkString(strconv.FormatUint(cursor, 10)),
+		resp.NewArray(elements),
+	})
+}
+
+func handleScan(conn io.Writer, arr []string, db *database.DB) error {
+	if len(arr) < 2 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	cursor, err := parseCursor(arr[1])
+	if err != nil {
+		return writeError(conn, err)
+	}
+	opts, _, err := parseScanOptions(arr[2:], "SCAN")
+	if err != nil {
+		return writeError(conn, err)
+	}
+	next, keys := db.Scan(cursor, opts)
+	res := make([][]byte, len(keys))
+	for i, k := range keys {
+		res[i] = resp.NewBulkString(k)
+	}
+	if _, err := conn.Write(newScanReply(next, res)); err != nil {
+		return fmt.Errorf("error writing to connection: %s", err.Error())
+	}
+	return nil
+}
+
+// handleCollectionScan handles HSCAN, SSCAN and ZSCAN which share the same arguments.
+func handleCollectionScan(conn io.Writer, arr []string, db *database.DB) error {
+	cmd := strings.ToUpper(arr[0])
+	if len(arr) < 3 {
+		return writeWrongArgs(conn, cmd)
+	}
+	key := arr[1]
+	cursor, err := parseCursor(arr[2])
+	if err != nil {
+		return writeError(conn, err)
+	}
+	opts, noValues, err := parseScanOptions(arr[3:], cmd)
+	if err != nil {
+		return writeError(conn, err)
+	}
+	var next uint64
+	res := [][]byte{}
+	switch cmd {
+	case "HSCAN":
+		var kvs []database.KeyValue
+		next, kvs, err = db.HScan(key, cursor, opts)
+		for _, kv := range kvs {
+			res = append(res, resp.NewBulkString(kv.Key))
+			if !noValues {
+				res = append(res, resp.NewBulkString(kv.Value))
+			}
+		}
+	case "SSCAN":
+		var members []string
+		next, members, err = db.SScan(key, cursor, opts)
+		for _, m := range members {
+			res = append(res, resp.NewBulkString(m))
+		}
+	case "ZSCAN":
+		var members []database.ZMember
+		next, members, err = db.ZScan(key, cursor, opts)
+		for _, m := range members {
+			res = append(res, resp.NewBulkString(m.Member), resp.NewBulkString(formatFloat(m.Score)))
+		}
+	}
+	if err != nil {
+		return writeError(conn, err)
+	}
+	if _, err := conn.Write(newScanReply(next, res)); err != nil {
+		return fmt.Errorf("error writing to connection: %s", err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/codecrafters-io/redis-starter-go/app/database"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScan(t *testing.T) {
+	dbs := []*database.DB{database.NewDB()}
+	for i := 0; i < 100; i++ {
+		dbs[defaultDBIdx].Set(fmt.Sprintf("key:%d", i), "v")
+	}
+	_, err := dbs[defaultDBIdx].XAdd("stream", "1-1", []database.KeyValue{{Key: "a", Value: "b"}})
+	require.NoError(t, err)
+	s := newServer(host, "0", dbs, RoleMaster, testCfg)
+	conn, r := pipeClient(t, s)
+
+	seen := map[string]bool{}
+	cursor := "0"
+	for {
+		res := do(t, conn, r, "SCAN", cursor, "COUNT", "7", "TYPE", "string")
+		arr := res.([]any)
+		cursor = arr[0].(string)
+		for _, k := range arr[1].([]any) {
+			seen[k.(string)] = true
+		}
+		if cursor == "0" {
+			break
+		}
+	}
+	require.Len(t, seen, 100)
+	require.NotContains(t, seen, "stream")
+
+	require.Equal(t, "ERR invalid cursor", do(t, conn, r, "SCAN", "abc").(error).Error())
+	require.Equal(t, "ERR syntax error", do(t, conn, r, "SCAN", "0", "COUNT", "0").(error).Error())
+}
+
+func TestCollectionScan(t *testing.T) {
+	s := newServer(host, "0", []*database.DB{database.NewDB()}, RoleMaster, testCfg)
+	conn, r := pipeClient(t, s)
+
+	require.Equal(t, 2, do(t, conn, r, "HSET", "h", "f1", "v1", "f2", "v2"))
+	require.Equal(t, 2, do(t, conn, r, "SADD", "s", "m1", "m2", "m1"))
+	require.Equal(t, 1, do(t, conn, r, "ZADD", "z", "1.5", "m1"))
+
+	res := do(t, conn, r, "HSCAN", "h", "0", "COUNT", "100").([]any)
+	require.Equal(t, "0", res[0])
+	require.ElementsMatch(t, []any{"f1", "v1", "f2", "v2"}, res[1])
+	res = do(t, conn, r, "HSCAN", "h", "0", "NOVALUES").([]any)
+	require.ElementsMatch(t, []any{"f1", "f2"}, res[1])
+	res = do(t, conn, r, "SSCAN", "s", "0").([]any)
+	require.ElementsMatch(t, []any{"m1", "m2"}, res[1])
+	res = do(t, conn, r, "ZSCAN", "z", "0").([]any)
+	require.Equal(t, []any{"m1", "1.5"}, res[1])
+	res = do(t, conn, r, "SSCAN", "missing", "0").([]any)
+	require.Equal(t, []any{"0", []any{}}, res)
+	require.ErrorContains(t, do(t, conn, r, "ZSCAN", "h", "0").(error), "wrong data type")
+}
+
+func TestKeysGlobPattern(t *testing.T) {
+	dbs := []*database.DB{database.NewDB()}
+	for _, k := range []string{"user:1", "user:2", "userX", "a.b", "axb"} {
+		dbs[defaultDBIdx].Set(k, "v")
+	}
+	s := newServer(host, "0", dbs, RoleMaster, config{})
+	s.config.persistence.Dir = "/tmp"
+	conn, r := pipeClient(t, s)
+
+	require.ElementsMatch(t, []any{"user:1", "user:2"}, do(t, conn, r, "KEYS", "user:*"))
+	require.ElementsMatch(t, []any{"a.b"}, do(t, conn, r, "KEYS", "a.b"))
+	require.ElementsMatch(t, []any{"a.b", "axb"}, do(t, conn, r, "KEYS", "a?b"))
+	require.Equal(t, []any{}, do(t, conn, r, "KEYS", "["))
+	res := do(t, conn, r, "SCAN", "0", "MATCH", "user:[12]", "COUNT", "100").([]any)
+	require.ElementsMatch(t, []any{"user:1", "user:2"}, res[1])
+	require.Equal(t, []any{"dir", "/tmp"}, do(t, conn, r, "CONFIG", "GET", "DI*"))
+}
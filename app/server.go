@@ -3,20 +3,22 @@ package main
 import (
 	"bufio"
 	"bytes"
-	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"net"
 	"os"
 	"os/signal"
-	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/codecrafters-io/redis-starter-go/app/database"
+	"github.com/codecrafters-io/redis-starter-go/app/glob"
 	"github.com/codecrafters-io/redis-starter-go/app/persistence"
 	"github.com/codecrafters-io/redis-starter-go/app/replication"
 	"github.com/codecrafters-io/redis-starter-go/app/resp"
@@ -33,19 +35,84 @@ const (
 
 func main() {
 	role := RoleMaster
-	p := flag.String("port", "6379", "port to bind to")
+	p := flag.String("port", "6379", "port to bind to, 0 to not listen on TCP")
+	bind := flag.String("bind", "localhost", "space separated addresses to listen on, * for all the IPv4 ones and ::* for all the IPv6 ones")
+	unixsocket := flag.String("unixsocket", "", "path of a unix socket to listen on")
+	unixsocketpermFlag := flag.String("unixsocketperm", "0", "permissions of the unix socket in octal, 0 for the default")
+	tlsPort := flag.String("tls-port", "0", "port to accept TLS connections on, 0 to disable")
+	tlsCertFile := flag.String("tls-cert-file", "", "certificate presented to the clients, and to the master with tls-replication")
+	tlsKeyFile := flag.String("tls-key-file", "", "private key of tls-cert-file")
+	tlsCACertFile := flag.String("tls-ca-cert-file", "", "CA certificates to verify the clients and the master with, the ones of the system if not set")
+	tlsAuthClients := flag.String("tls-auth-clients", tlsAuthClientsYes, "whether the TLS clients must present a certificate (yes|no|optional)")
+	tlsReplication := flag.String("tls-replication", "no", "connect to the master over TLS (yes|no)")
 	replicaOf := flag.String("replicaof", "", "replicaof host port")
 	dir := flag.String("dir", "", "directory to store db file")
 	dbfilename := flag.String("dbfilename", "", "rdb file name")
+	databases := flag.Int("databases", persistence.DefaultDatabases, "number of databases")
+	lazyfreeLazyUserDel := flag.String("lazyfree-lazy-user-del", "no", "make DEL free values in background like UNLINK (yes|no)")
+	maxmemoryFlag := flag.String("maxmemory", "0", "memory limit of the keys, e.g. 100mb, 0 for no limit")
+	maxmemoryPolicy := flag.String("maxmemory-policy", database.PolicyNoEviction, "how to free memory when maxmemory is reached")
+	maxmemorySamples := flag.Int("maxmemory-samples", database.DefaultMaxmemorySamples, "number of keys sampled by the LRU, LFU and TTL policies")
+	requirepass := flag.String("requirepass", "", "password of the default user, clients must AUTH when set")
+	protoMaxBulkLenFlag := flag.String("proto-max-bulk-len", "512mb", "maximum length of a bulk string in a request")
+	clientOutputBufferLimit := flag.String("client-output-buffer-limit", defaultClientOutputBufferLimit, "<class> <hard limit> <soft limit> <soft seconds> for the normal, replica and pubsub clients")
+	timeout := flag.Int("timeout", 0, "close the connection after a client is idle for N seconds, 0 to disable")
+	tcpKeepalive := flag.Int("tcp-keepalive", 300, "period in seconds of the TCP keepalives sent to the clients, 0 to disable")
+	maxclients := flag.Int("maxclients", defaultMaxclients, "maximum number of connected clients")
 	flag.Parse()
 	if *dir == "" && *dbfilename != "" {
 		panic("dbfilename should be provided with dir")
 	}
+	maxmemory, err := parseMemory(*maxmemoryFlag)
+	if err != nil {
+		panic(err)
+	}
+	protoMaxBulkLen, err := parseMemory(*protoMaxBulkLenFlag)
+	if err != nil {
+		panic(err)
+	}
+	outputLimits := map[string]replication.OutputBufferLimit{}
+	if err := parseClientOutputBufferLimit(defaultClientOutputBufferLimit, outputLimits); err != nil {
+		panic(err)
+	}
+	if err := parseClientOutputBufferLimit(*clientOutputBufferLimit, outputLimits); err != nil {
+		panic(err)
+	}
+	unixsocketperm, err := parseUnixsocketperm(*unixsocketpermFlag)
+	if err != nil {
+		panic(err)
+	}
+	if !validTLSAuthClients(*tlsAuthClients) {
+		panic(fmt.Errorf("invalid tls-auth-clients %q", *tlsAuthClients))
+	}
+	if !validPolicy(*maxmemoryPolicy) {
+		panic(fmt.Errorf("invalid maxmemory-policy %q", *maxmemoryPolicy))
+	}
 	cfg := config{
 		persistence: persistence.Config{
 			Dir:        *dir,
 			Dbfilename: *dbfilename,
+			Databases:  *databases,
 		},
+		lazyfreeLazyUserDel: *lazyfreeLazyUserDel == "yes",
+		maxmemory:           maxmemory,
+		maxmemoryPolicy:     *maxmemoryPolicy,
+		maxmemorySamples:    *maxmemorySamples,
+		requirepass:         *requirepass,
+		protoMaxBulkLen:     protoMaxBulkLen,
+		outputLimits:        outputLimits,
+		timeout:             time.Duration(*timeout) * time.Second,
+		tcpKeepalive:        time.Duration(*tcpKeepalive) * time.Second,
+		maxclients:          *maxclients,
+		bind:                strings.Fields(*bind),
+		unixsocket:          *unixsocket,
+		unixsocketperm:      unixsocketperm,
+		tlsPort:             *tlsPort,
+		tlsCertFile:         *tlsCertFile,
+		tlsKeyFile:          *tlsKeyFile,
+		tlsCACertFile:       *tlsCACertFile,
+		tlsAuthClients:      *tlsAuthClients,
+		tlsReplication:      *tlsReplication == "yes",
 	}
 	dbs, err := persistence.LoadRDB(cfg.persistence)
 	if err != nil {
@@ -77,22 +144,69 @@ type server struct {
 	host               string
 	port               string
 	dbs                []*database.DB
-	db                 *database.DB // selected db
 	role               string
 	masterReplid       string
 	masterOffset       uint64
 	replicationBacklog *replication.ReplicatinoBacklog
+	replMu             sync.Mutex // serializes propagation so SELECT and the command are sent together
+	replSelectedDB     int        // db selected in the replication stream, -1 if unknown
 	config             config
+	evictor            *database.Evictor
+	evictedKeys        atomic.Int64
+	connectedClients   atomic.Int64
+	rejectedConns      atomic.Int64
+	lastClientID       atomic.Int64
+	clients            *clientRegistry
+	pause              clientPause
+	pubsub             *pubsub
+	tracker            *tracker
 	netConfig          *net.ListenConfig // for testing
 }
 
 type config struct {
-	persistence persistence.Config
+	persistence         persistence.Config
+	lazyfreeLazyUserDel bool
+	maxmemory           int64 // 0 for no limit
+	maxmemoryPolicy     string
+	maxmemorySamples    int
+	requirepass         string                                   // empty for no authentication
+	protoMaxBulkLen     int64                                    // 0 for the default
+	outputLimits        map[string]replication.OutputBufferLimit // client-output-buffer-limit by client class
+	timeout             time.Duration                            // idle time after which a client is closed, 0 to disable
+	tcpKeepalive        time.Duration                            // 0 to disable the keepalives
+	maxclients          int                                      // 0 for the default
+	bind                []string                                 // addresses to listen on, the host of the server if empty
+	unixsocket          string                                   // empty to not listen on a unix socket
+	unixsocketperm      os.FileMode                              // 0 to keep the permissions of the socket
+	tlsPort             string                                   // 0 to not accept TLS connections
+	tlsCertFile         string
+	tlsKeyFile          string
+	tlsCACertFile       string // empty for the CA certificates of the system
+	tlsAuthClients      string // yes, no or optional
+	tlsReplication      bool   // connect to the master over TLS
 }
 
+const defaultMaxclients = 10000
+
+var errMaxClients = errors.New("max number of clients reached")
+
 const defaultDBIdx = 0
 
 func newServer(host, port string, dbs []*database.DB, role string, config config) *server {
+	if config.protoMaxBulkLen == 0 {
+		config.protoMaxBulkLen = resp.DefaultMaxBulkLen
+	}
+	if config.maxclients == 0 {
+		config.maxclients = defaultMaxclients
+	}
+	if config.tlsPort == "" {
+		config.tlsPort = "0"
+	}
+	if config.tlsAuthClients == "" {
+		config.tlsAuthClients = tlsAuthClientsYes
+	}
+	backlog := replication.NewReplicationBacklog(backlogSizePerReplica)
+	backlog.SetLimit(config.outputLimits[clientClassReplica])
 	return &server{
 		host:         host,
 		port:         port,
@@ -101,84 +215,157 @@ func newServer(host, port string, dbs []*database.DB, role string, config config
 		masterOffset: 0,
 
 		role:               role,
-		replicationBacklog: replication.NewReplicationBacklog(backlogSizePerReplica),
+		replicationBacklog: backlog,
 		config:             config,
-		db:                 dbs[defaultDBIdx],
+		evictor:            database.NewEvictor(),
+		replSelectedDB:     -1,
+		clients:            newClientRegistry(),
+		pubsub:             newPubsub(),
+		tracker:            newTracker(),
 	}
 }
 
 func (s *server) Start(shutdown chan os.Signal, h func(net.Conn) error) {
 	signal.Notify(shutdown, os.Interrupt, syscall.SIGTERM)
-	var l net.Listener
-	var err error
-	if s.netConfig != nil {
-		ctx := context.Background()
-		l, err = s.netConfig.Listen(ctx, "tcp", fmt.Sprintf("%s:%s", s.host, s.port))
-	} else {
-		l, err = net.Listen("tcp", fmt.Sprintf("%s:%s", s.host, s.port))
-	}
+	listeners, err := s.listen()
 	if err != nil {
-		err := fmt.Errorf("error listening: %v", err.Error())
 		fmt.Println(err)
 		os.Exit(1)
 	}
-
-	go func() {
-		for {
-			conn, err := l.Accept()
-			if err != nil {
-				fmt.Println("error accepting connection: ", err.Error())
-				os.Exit(1)
-			}
-			go func(conn net.Conn) {
-				if err := h(conn); err != nil {
-					fmt.Println(err)
-					os.Exit(1)
-				}
-			}(conn)
-		}
-	}()
+	for _, l := range listeners {
+		go s.serve(l, h)
+	}
 	sig := <-shutdown
+	for _, l := range listeners {
+		l.Close()
+	}
 	fmt.Printf("[%s, %s] Shutting down server: %v\n", s.port, s.role, sig)
 }
 
 type clientState struct {
-	isMulti  bool
-	cmdQueue [][]string
+	isMulti       bool
+	cmdQueue      [][]string
+	db            int     // index of the selected db
+	client        *client // entry in the client registry, nil for the master of a replica
+	proto         int     // protocol version negotiated with HELLO
+	authenticated bool
+	replyOff      bool // set by CLIENT REPLY OFF
+	replySkip     bool // set by CLIENT REPLY SKIP until the next command
+	caching       bool // set by CLIENT CACHING until the next command
 }
 
 func (s *server) handler(conn net.Conn) (err error) {
 	r := bufio.NewReader(conn)
 	defer conn.Close()
-	state := &clientState{}
+	defer s.connectedClients.Add(-1)
+	if s.connectedClients.Add(1) > int64(s.config.maxclients) {
+		s.rejectedConns.Add(1)
+		return writeError(conn, errMaxClients)
+	}
+	id := s.lastClientID.Add(1)
+	out := newClientOutput(conn, s.config.outputLimits[clientClassNormal], fmt.Sprintf("id=%d addr=%s", id, conn.RemoteAddr()))
+	c := newClient(id, conn, out)
+	s.clients.add(c)
+	defer func() {
+		s.clients.remove(c)
+		s.disableTracking(c)
+		for _, channel := range s.pubsub.subscriptions(c) {
+			s.pubsub.unsubscribe(c, channel)
+		}
+		for _, pattern := range s.pubsub.patternSubscriptions(c) {
+			s.pubsub.punsubscribe(c, pattern)
+		}
+	}()
+	state := &clientState{client: c, proto: resp.RESP2}
+	w := &respWriter{Writer: out, state: state}
+	abort := false // the pending replies are dropped when the client is closed on a timeout or killed
+	defer func() {
+		if abort || err != nil {
+			out.close(0)
+		} else {
+			out.close(closeDrainTimeout)
+		}
+	}()
 	for {
+		// the replies are sent before waiting for the next requests, once the pipelined ones are executed
+		if r.Buffered() == 0 {
+			if err := out.Flush(); err != nil {
+				return fmt.Errorf("error writing to connection: %s", err.Error())
+			}
+			// an idle client is closed after timeout, unless it waits for the messages of its channels
+			if s.config.timeout > 0 {
+				deadline := time.Time{}
+				if s.pubsub.count(c) == 0 {
+					deadline = time.Now().Add(s.config.timeout)
+				}
+				if err := conn.SetReadDeadline(deadline); err != nil {
+					return fmt.Errorf("error setting deadline: %s", err.Error())
+				}
+			}
+		}
+		c.update(state, out.Buffered())
+		// a client killing itself is closed once its reply is sent
+		if c.isKilled() {
+			return nil
+		}
 		typ, err := resp.CheckDataType(r)
 		if err != nil {
+			if c.isKilled() || errors.Is(err, os.ErrDeadlineExceeded) {
+				abort = true
+				return nil
+			}
 			if err == io.EOF {
 				return nil
 			}
 			return fmt.Errorf("error reading byte from connection: %s", err.Error())
 		}
+		var arr []string
 		if typ != resp.TypeArray {
-			if _, err := conn.Write(resp.NewErrorMSG("expecting type array")); err != nil {
-				return fmt.Errorf("error writing to connection: %s", err.Error())
-			}
-			return nil
+			arr, err = resp.ReadInline(r, typ)
+		} else {
+			arr, err = resp.ReadCommand(r, s.config.protoMaxBulkLen)
+		}
+		var protoErr *resp.ProtocolError
+		if errors.As(err, &protoErr) {
+			// the rest of the stream cannot be parsed, so the connection is closed once the error is replied
+			return writeError(w, protoErr)
 		}
-		arr, err := resp.HandleRESPArray(r)
 		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
 			return fmt.Errorf("error reading resp array from connection: %s", err.Error())
 		}
 		if len(arr) == 0 {
-			if _, err := conn.Write(resp.NewErrorMSG("empty array")); err != nil {
+			continue
+		}
+		c.commandStarted(arr, r.Buffered())
+		out.setDiscard(state.replyOff || state.replySkip)
+		state.replySkip = false
+		if s.config.requirepass != "" && !state.authenticated && !noAuthCommands[strings.ToUpper(arr[0])] {
+			if err := writeError(w, errNoAuth); err != nil {
+				return err
+			}
+			continue
+		}
+		if state.proto == resp.RESP2 && s.pubsub.count(c) > 0 && !subscribedCommands[strings.ToUpper(arr[0])] {
+			if err := writeError(w, errSubscribed(arr[0])); err != nil {
+				return err
+			}
+			continue
+		}
+		// the commands are held while the clients are paused, the replies of the previous ones are sent first
+		if cmd := strings.ToUpper(arr[0]); s.pause.blocks(mayWrite(cmd, state)) {
+			if err := out.Flush(); err != nil {
 				return fmt.Errorf("error writing to connection: %s", err.Error())
 			}
+			s.pause.wait(mayWrite(cmd, state))
 		}
 		// these are command need to handle before queueing
 		switch strings.ToUpper(arr[0]) {
 		case "REPLCONF":
 			if len(arr) != 3 {
-				if _, err := conn.Write(resp.NewErrorMSG("expecting 3 arguments")); err != nil {
+				if _, err := w.Write(resp.NewErrorMSG("expecting 3 arguments")); err != nil {
 					return fmt.Errorf("error writing to connection: %s", err.Error())
 				}
 				return nil
@@ -186,11 +373,19 @@ func (s *server) handler(conn net.Conn) (err error) {
 			switch arr[1] {
 			case "listening-port":
 				// should hand over the connection ownership to replica connection and not use the reader here anymore.
+				if err := out.close(closeDrainTimeout); err != nil {
+					return fmt.Errorf("error writing to connection: %s", err.Error())
+				}
+				c.setType(clientTypeReplica)
+				// the replicas are never closed for being idle
+				if err := conn.SetReadDeadline(time.Time{}); err != nil {
+					return fmt.Errorf("error setting deadline: %s", err.Error())
+				}
 				return s.handleReiplicaHanshake(conn, r, arr[2])
 			}
 		// https://redis.io/docs/latest/commands/exec/
 		case "EXEC":
-			if err := s.handleExec(conn, state); err != nil {
+			if err := s.handleExec(w, state); err != nil {
 				return err
 			}
 			continue
@@ -199,11 +394,11 @@ func (s *server) handler(conn net.Conn) (err error) {
 			if state.isMulti {
 				state.isMulti = false
 				state.cmdQueue = nil
-				if _, err := conn.Write(resp.NewSimpleString("OK")); err != nil {
+				if _, err := w.Write(resp.NewSimpleString("OK")); err != nil {
 					return fmt.Errorf("error writing to connection: %s", err.Error())
 				}
 			} else {
-				if _, err := conn.Write(resp.NewErrorMSG("DISCARD without MULTI")); err != nil {
+				if _, err := w.Write(resp.NewErrorMSG("DISCARD without MULTI")); err != nil {
 					return fmt.Errorf("error writing to connection: %s", err.Error())
 				}
 			}
@@ -211,12 +406,12 @@ func (s *server) handler(conn net.Conn) (err error) {
 		}
 
 		if state.isMulti {
-			if err := s.handleQueuing(conn, arr, state); err != nil {
+			if err := s.handleQueuing(w, arr, state); err != nil {
 				return err
 			}
 			continue
 		}
-		if err := s.handleWriteOnlyCmd(conn, arr, state); err != nil {
+		if err := s.handleWriteOnlyCmd(w, arr, state); err != nil {
 			return err
 		}
 	}
@@ -240,7 +435,7 @@ func (s *server) handleExec(conn io.Writer, state *clientState) error {
 		res := make([][]byte, len(state.cmdQueue))
 		for i, cmd := range state.cmdQueue {
 			buff := bytes.NewBuffer(nil)
-			if err := s.handleWriteOnlyCmd(buff, cmd, state); err != nil {
+			if err := s.handleWriteOnlyCmd(&respWriter{Writer: buff, state: state}, cmd, state); err != nil {
 				return err
 			}
 			res[i] = buff.Bytes()
@@ -256,10 +451,29 @@ func (s *server) handleExec(conn io.Writer, state *clientState) error {
 
 // when entering this function, we do not read from connection anymore.
 func (s *server) handleWriteOnlyCmd(conn io.Writer, arr []string, state *clientState) error {
-	switch strings.ToUpper(arr[0]) {
+	db := s.dbs[state.db]
+	cmd := strings.ToUpper(arr[0])
+	info, ok := commandTable[cmd]
+	if !ok {
+		if _, err := conn.Write([]byte(resp.NewErrorMSG("unknown command " + arr[0]))); err != nil {
+			return fmt.Errorf("error writing to connection: %s", err.Error())
+		}
+		return nil
+	}
+	if !s.performEvictions() && info.flags&cmdDenyOOM != 0 {
+		return writeOOM(conn)
+	}
+	startCommand(conn)
+	switch cmd {
 	// https://redis.io/docs/latest/commands/ping/
 	// [PING]
 	case "PING":
+		if state.client != nil && protocol(conn) == resp.RESP2 && s.pubsub.count(state.client) > 0 {
+			if err := handleSubscribedPing(conn, arr); err != nil {
+				return err
+			}
+			break
+		}
 		if err := handlePing(conn); err != nil {
 			return err
 		}
@@ -272,47 +486,38 @@ func (s *server) handleWriteOnlyCmd(conn io.Writer, arr []string, state *clientS
 	// https://redis.io/docs/latest/commands/set/
 	// [SET, key, value]
 	case "SET":
-		if err := handleSet(conn, arr, s.db); err != nil {
+		if err := handleSet(conn, arr, db); err != nil {
 			return err
 		}
-		if s.role == RoleMaster {
-			// store commands in replication buffer
-			msg := replication.Msg{
-				Data:               resp.NewSetCmd(arr),
-				ShouldWaitResponse: false,
-			}
-			s.replicationBacklog.BroardcastBacklog(msg)
-			_, err := conn.Write(resp.NewSimpleString("OK"))
-			if err != nil {
-				return fmt.Errorf("error writing to connection: %s", err.Error())
-			}
+		if _, err := conn.Write(resp.NewSimpleString("OK")); err != nil {
+			return fmt.Errorf("error writing to connection: %s", err.Error())
 		}
 	// [GET, key]
 	case "GET":
-		if err := handleGet(conn, arr, s.db); err != nil {
+		if err := handleGet(conn, arr, db); err != nil {
 			return err
 		}
 
 	// https://redis.io/docs/latest/commands/incr/
 	case "INCR":
-		if err := handleIncr(conn, arr, s.db); err != nil {
+		if err := handleIncr(conn, arr, db); err != nil {
 			return err
 		}
 
 	case "XADD":
-		if err := handleXAdd(conn, arr, s.db); err != nil {
+		if err := handleXAdd(conn, arr, db); err != nil {
 			return err
 		}
 	// https://redis.io/docs/latest/commands/xrange/
 	// XRANGE key start end [COUNT count]
 	case "XRANGE":
-		if err := handleXRange(conn, arr, s.db); err != nil {
+		if err := handleXRange(conn, arr, db); err != nil {
 			return err
 		}
 	// https://redis.io/docs/latest/commands/xread/
 	// XREAD [COUNT count] [BLOCK milliseconds] STREAMS key [key ...] id [id ...]
 	case "XREAD":
-		if err := handleXRead(conn, arr, s.db); err != nil {
+		if err := handleXRead(conn, arr, db); err != nil {
 			return err
 		}
 	// https://redis.io/docs/latest/commands/multi/
@@ -335,7 +540,7 @@ func (s *server) handleWriteOnlyCmd(conn io.Writer, arr []string, state *clientS
 			}
 			return nil
 		}
-		typ := s.db.Type(arr[1])
+		typ := db.Type(arr[1])
 		if typ == "" {
 			if _, err := conn.Write(resp.NewSimpleString("none")); err != nil {
 				return fmt.Errorf("error writing to connection: %s", err.Error())
@@ -347,19 +552,508 @@ func (s *server) handleWriteOnlyCmd(conn io.Writer, arr []string, state *clientS
 		}
 		// https://redis.io/docs/latest/commands/keys/
 	case "KEYS":
-		if err := handleKeys(conn, arr, s.db); err != nil {
+		if err := handleKeys(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/scan/
+	// SCAN cursor [MATCH pattern] [COUNT count] [TYPE type]
+	case "SCAN":
+		if err := handleScan(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/hscan/
+	// HSCAN key cursor [MATCH pattern] [COUNT count] [NOVALUES]
+	// SSCAN key cursor [MATCH pattern] [COUNT count]
+	// ZSCAN key cursor [MATCH pattern] [COUNT count]
+	case "HSCAN", "SSCAN", "ZSCAN":
+		if err := handleCollectionScan(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/hset/
+	// HSET key field value [field value ...]
+	case "HSET":
+		if err := handleHSet(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/sadd/
+	// SADD key member [member ...]
+	case "SADD":
+		if err := handleSAdd(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/zadd/
+	// ZADD key score member [score member ...]
+	case "ZADD":
+		if err := handleZAdd(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/hgetall/
+	// HGETALL key
+	case "HGETALL":
+		if err := handleHGetAll(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/zscore/
+	// ZSCORE key member
+	case "ZSCORE":
+		if err := handleZScore(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/lpush/
+	// LPUSH key element [element ...]
+	// RPUSH key element [element ...]
+	case "LPUSH", "RPUSH":
+		if err := handlePush(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/lrange/
+	// LRANGE key start stop
+	case "LRANGE":
+		if err := handleLRange(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/sort/
+	// SORT key [BY pattern] [LIMIT offset count] [GET pattern [GET pattern ...]] [ASC | DESC] [ALPHA] [STORE destination]
+	// SORT_RO key [BY pattern] [LIMIT offset count] [GET pattern [GET pattern ...]] [ASC | DESC] [ALPHA]
+	case "SORT", "SORT_RO":
+		if err := s.handleSort(conn, arr, state); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/setbit/
+	// SETBIT key offset value
+	case "SETBIT":
+		if err := handleSetBit(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/getbit/
+	// GETBIT key offset
+	case "GETBIT":
+		if err := handleGetBit(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/bitcount/
+	// BITCOUNT key [start end [BYTE | BIT]]
+	case "BITCOUNT":
+		if err := handleBitCount(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/bitpos/
+	// BITPOS key bit [start [end [BYTE | BIT]]]
+	case "BITPOS":
+		if err := handleBitPos(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/bitop/
+	// BITOP <AND | OR | XOR | NOT | DIFF | DIFF1 | ANDOR | ONE> destkey key [key ...]
+	case "BITOP":
+		if err := handleBitOp(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/bitfield/
+	// BITFIELD key [GET encoding offset | [OVERFLOW <WRAP | SAT | FAIL>] <SET encoding offset value | INCRBY encoding offset increment> ...]
+	// BITFIELD_RO key [GET encoding offset [GET encoding offset ...]]
+	case "BITFIELD", "BITFIELD_RO":
+		if err := handleBitField(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/pfadd/
+	// PFADD key [element [element ...]]
+	case "PFADD":
+		if err := handlePFAdd(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/pfcount/
+	// PFCOUNT key [key ...]
+	case "PFCOUNT":
+		if err := handlePFCount(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/pfmerge/
+	// PFMERGE destkey [sourcekey [sourcekey ...]]
+	case "PFMERGE":
+		if err := handlePFMerge(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/pfdebug/
+	// PFDEBUG <GETREG | DECODE | ENCODING | TODENSE> key
+	case "PFDEBUG":
+		if err := handlePFDebug(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/lcs/
+	// LCS key1 key2 [LEN] [IDX] [MINMATCHLEN min-match-len] [WITHMATCHLEN]
+	case "LCS":
+		if err := s.handleLCS(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/geoadd/
+	// GEOADD key [NX | XX] [CH] longitude latitude member [longitude latitude member ...]
+	case "GEOADD":
+		if err := handleGeoAdd(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/geodist/
+	// GEODIST key member1 member2 [M | KM | FT | MI]
+	case "GEODIST":
+		if err := handleGeoDist(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/geopos/
+	// GEOPOS key [member [member ...]]
+	case "GEOPOS":
+		if err := handleGeoPos(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/geohash/
+	// GEOHASH key [member [member ...]]
+	case "GEOHASH":
+		if err := handleGeoHash(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/geosearch/
+	// GEOSEARCH key <FROMMEMBER member | FROMLONLAT longitude latitude> <BYRADIUS radius <M | KM | FT | MI> | BYBOX width height <M | KM | FT | MI>>
+	//   [ASC | DESC] [COUNT count [ANY]] [WITHCOORD] [WITHDIST] [WITHHASH]
+	case "GEOSEARCH":
+		if err := handleGeoSearch(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/geosearchstore/
+	// GEOSEARCHSTORE destination source <FROMMEMBER member | FROMLONLAT longitude latitude> <BYRADIUS radius <M | KM | FT | MI> | BYBOX width height <M | KM | FT | MI>>
+	//   [ASC | DESC] [COUNT count [ANY]] [STOREDIST]
+	case "GEOSEARCHSTORE":
+		if err := handleGeoSearchStore(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/bf.reserve/
+	// BF.RESERVE key error_rate capacity [EXPANSION expansion] [NONSCALING]
+	case "BF.RESERVE":
+		if err := s.handleBFReserve(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/bf.add/
+	// BF.ADD key item
+	// BF.MADD key item [item ...]
+	case "BF.ADD", "BF.MADD":
+		if err := handleBFAdd(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/bf.exists/
+	// BF.EXISTS key item
+	// BF.MEXISTS key item [item ...]
+	case "BF.EXISTS", "BF.MEXISTS":
+		if err := handleBFExists(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/bf.info/
+	// BF.INFO key [CAPACITY | SIZE | FILTERS | ITEMS | EXPANSION]
+	case "BF.INFO":
+		if err := handleBFInfo(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/cf.reserve/
+	// CF.RESERVE key capacity [BUCKETSIZE bucketsize] [MAXITERATIONS maxiterations] [EXPANSION expansion]
+	case "CF.RESERVE":
+		if err := s.handleCFReserve(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/cf.add/
+	// CF.ADD key item
+	// CF.ADDNX key item
+	case "CF.ADD", "CF.ADDNX":
+		if err := handleCFAdd(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/cf.exists/
+	// CF.EXISTS key item
+	// CF.MEXISTS key item [item ...]
+	case "CF.EXISTS", "CF.MEXISTS":
+		if err := handleCFExists(conn, arr, db); err != nil {
 			return err
 		}
+	// https://redis.io/docs/latest/commands/cf.del/
+	// CF.DEL key item
+	case "CF.DEL":
+		if err := handleCFDel(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/cf.count/
+	// CF.COUNT key item
+	case "CF.COUNT":
+		if err := handleCFCount(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/cf.info/
+	// CF.INFO key
+	case "CF.INFO":
+		if err := handleCFInfo(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/cms.initbydim/
+	// CMS.INITBYDIM key width depth
+	// CMS.INITBYPROB key error probability
+	case "CMS.INITBYDIM", "CMS.INITBYPROB":
+		if err := s.handleCMSInit(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/cms.incrby/
+	// CMS.INCRBY key item increment [item increment ...]
+	case "CMS.INCRBY":
+		if err := handleCMSIncrBy(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/cms.query/
+	// CMS.QUERY key item [item ...]
+	case "CMS.QUERY":
+		if err := handleCMSQuery(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/cms.merge/
+	// CMS.MERGE destination numKeys source [source ...] [WEIGHTS weight [weight ...]]
+	case "CMS.MERGE":
+		if err := handleCMSMerge(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/cms.info/
+	// CMS.INFO key
+	case "CMS.INFO":
+		if err := handleCMSInfo(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/topk.reserve/
+	// TOPK.RESERVE key topk [width depth decay]
+	case "TOPK.RESERVE":
+		if err := s.handleTopKReserve(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/topk.add/
+	// TOPK.ADD key items [items ...]
+	// TOPK.INCRBY key item increment [item increment ...]
+	case "TOPK.ADD", "TOPK.INCRBY":
+		if err := handleTopKAdd(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/topk.query/
+	// TOPK.QUERY key item [item ...]
+	case "TOPK.QUERY":
+		if err := handleTopKQuery(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/topk.list/
+	// TOPK.LIST key [WITHCOUNT]
+	case "TOPK.LIST":
+		if err := handleTopKList(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/topk.info/
+	// TOPK.INFO key
+	case "TOPK.INFO":
+		if err := handleTopKInfo(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/json.set/
+	// JSON.SET key path value [NX | XX]
+	case "JSON.SET":
+		if err := handleJSONSet(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/json.get/
+	// JSON.GET key [INDENT indent] [NEWLINE newline] [SPACE space] [path [path ...]]
+	case "JSON.GET":
+		if err := handleJSONGet(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/json.mget/
+	// JSON.MGET key [key ...] path
+	case "JSON.MGET":
+		if err := handleJSONMGet(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/json.del/
+	// JSON.DEL key [path]
+	// JSON.FORGET key [path]
+	case "JSON.DEL", "JSON.FORGET":
+		if err := handleJSONDel(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/json.type/
+	// JSON.TYPE key [path]
+	case "JSON.TYPE":
+		if err := handleJSONType(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/json.numincrby/
+	// JSON.NUMINCRBY key path value
+	case "JSON.NUMINCRBY":
+		if err := handleJSONNumIncrBy(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/json.strappend/
+	// JSON.STRAPPEND key [path] value
+	case "JSON.STRAPPEND":
+		if err := handleJSONStrAppend(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/json.arrappend/
+	// JSON.ARRAPPEND key path value [value ...]
+	case "JSON.ARRAPPEND":
+		if err := handleJSONArrAppend(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/json.arrindex/
+	// JSON.ARRINDEX key path value [start [stop]]
+	case "JSON.ARRINDEX":
+		if err := handleJSONArrIndex(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/json.arrinsert/
+	// JSON.ARRINSERT key path index value [value ...]
+	case "JSON.ARRINSERT":
+		if err := handleJSONArrInsert(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/json.arrlen/
+	// JSON.ARRLEN key [path]
+	case "JSON.ARRLEN":
+		if err := handleJSONArrLen(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/json.arrpop/
+	// JSON.ARRPOP key [path [index]]
+	case "JSON.ARRPOP":
+		if err := handleJSONArrPop(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/json.arrtrim/
+	// JSON.ARRTRIM key path start stop
+	case "JSON.ARRTRIM":
+		if err := handleJSONArrTrim(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/json.objkeys/
+	// JSON.OBJKEYS key [path]
+	case "JSON.OBJKEYS":
+		if err := handleJSONObjKeys(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/client/
+	// CLIENT subcommand [arg ...]
+	case "CLIENT":
+		if err := s.handleClient(conn, arr, state); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/subscribe/
+	// SUBSCRIBE channel [channel ...]
+	// https://redis.io/docs/latest/commands/psubscribe/
+	// PSUBSCRIBE pattern [pattern ...]
+	case "SUBSCRIBE", "PSUBSCRIBE":
+		if err := s.handleSubscribe(conn, arr, state); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/unsubscribe/
+	// UNSUBSCRIBE [channel [channel ...]]
+	// https://redis.io/docs/latest/commands/punsubscribe/
+	// PUNSUBSCRIBE [pattern [pattern ...]]
+	case "UNSUBSCRIBE", "PUNSUBSCRIBE":
+		if err := s.handleUnsubscribe(conn, arr, state); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/publish/
+	// PUBLISH channel message
+	case "PUBLISH":
+		if err := s.handlePublish(conn, arr); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/hello/
+	// HELLO [protover [AUTH username password] [SETNAME clientname]]
+	case "HELLO":
+		if err := s.handleHello(conn, arr, state); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/auth/
+	// AUTH [username] password
+	case "AUTH":
+		if err := s.handleAuth(conn, arr, state); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/select/
+	// SELECT index
+	case "SELECT":
+		if err := s.handleSelect(conn, arr, state); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/rename/
+	// RENAME key newkey
+	// RENAMENX key newkey
+	case "RENAME", "RENAMENX":
+		if err := handleRename(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/move/
+	// MOVE key db
+	case "MOVE":
+		if err := s.handleMove(conn, arr, state); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/copy/
+	// COPY source destination [DB destination-db] [REPLACE]
+	case "COPY":
+		if err := s.handleCopy(conn, arr, state); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/del/
+	// DEL key [key ...]
+	// UNLINK key [key ...]
+	case "DEL", "UNLINK":
+		if err := s.handleDel(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/flushdb/
+	// FLUSHDB [ASYNC | SYNC]
+	// FLUSHALL [ASYNC | SYNC]
+	case "FLUSHDB", "FLUSHALL":
+		if err := s.handleFlush(conn, arr, state); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/dbsize/
+	case "DBSIZE":
+		if err := handleDBSize(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/randomkey/
+	case "RANDOMKEY":
+		if err := handleRandomKey(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/object/
+	// OBJECT ENCODING|FREQ|IDLETIME|REFCOUNT key
+	case "OBJECT":
+		if err := s.handleObject(conn, arr, db); err != nil {
+			return err
+		}
+	// DEBUG OBJECT key
+	case "DEBUG":
+		if err := handleDebug(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/swapdb/
+	// SWAPDB index1 index2
+	case "SWAPDB":
+		if err := s.handleSwapDB(conn, arr); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/memory-usage/
+	// MEMORY USAGE key [SAMPLES count]
+	// MEMORY STATS | DOCTOR | BIGKEYS | MEMKEYS [SAMPLES count]
+	case "MEMORY":
+		if err := s.handleMemory(conn, arr, db); err != nil {
+			return err
+		}
+	// https://redis.io/docs/latest/commands/info/
+	// INFO [section [section ...]]
 	case "INFO":
-		if len(arr) > 1 {
-			if arr[1] == "replication" {
-				if _, err := conn.Write(resp.NewBulkString(fmt.Sprintf("role:%s\nmaster_replid:%s\nmaster_repl_offset:%d", s.role, s.masterReplid, s.masterOffset))); err != nil {
-					return fmt.Errorf("error writing to connection: %s", err.Error())
-				}
-
-			}
+		if err := s.handleInfo(conn, arr); err != nil {
+			return err
 		}
-		// TODO
 	// REPLCONF <option> <value> <option> <value> ...
 	// This command is used by a replica in order to configure the replication process before starting it with the SYNC command.
 	// ref: https://github.com/redis/redis/blob/811c5d7aeb0b76494d78efe61e418f574c310ec0/src/replication.c#L1114C4-L1114C50
@@ -402,19 +1096,10 @@ func (s *server) handleWriteOnlyCmd(conn io.Writer, arr []string, state *clientS
 			}
 			return nil
 		}
-		switch arr[1] {
+		switch strings.ToUpper(arr[1]) {
 		case "GET":
-			res := [][]byte{}
-			for i := 2; i < len(arr); i++ {
-				switch arr[i] {
-				case "dir":
-					res = append(res, resp.NewBulkString("dir"), resp.NewBulkString(s.config.persistence.Dir)) // key, value
-				case "dbfilename":
-					res = append(res, resp.NewBulkString("dbfilename"), resp.NewBulkString(s.config.persistence.Dbfilename)) // key, value
-				}
-			}
-			if _, err := conn.Write(resp.NewArray(res)); err != nil {
-				return fmt.Errorf("error writing to connection: %s", err.Error())
+			if err := s.handleConfigGet(conn, arr[2:]); err != nil {
+				return err
 			}
 		}
 
@@ -423,9 +1108,116 @@ func (s *server) handleWriteOnlyCmd(conn io.Writer, arr []string, state *clientS
 			return fmt.Errorf("error writing to connection: %s", err.Error())
 		}
 	}
+	// a command which failed did not change the keyspace
+	failed := commandFailed(conn)
+	if !failed && info.flags&(cmdWrite|cmdNoPropagate) == cmdWrite {
+		s.propagate(state.db, arr)
+	}
+	s.trackKeys(state, cmd, arr, failed)
 	return nil
 }
 
+// propagate adds the command to the replication backlog, preceded by a SELECT when the command was executed
+// on another db than the one currently selected in the replication stream.
+func (s *server) propagate(db int, arr []string) {
+	if s.role != RoleMaster {
+		return
+	}
+	s.replMu.Lock()
+	defer s.replMu.Unlock()
+	data := resp.NewCommand(arr)
+	if db != s.replSelectedDB {
+		data = append(resp.NewCommand([]string{"SELECT", strconv.Itoa(db)}), data...)
+		s.replSelectedDB = db
+	}
+	s.replicationBacklog.BroardcastBacklog(replication.Msg{
+		Data:               data,
+		ShouldWaitResponse: false,
+	})
+}
+
+// handleConfigGet replies the parameters matching any of the glob-style patterns, case-insensitively, as a map.
+func (s *server) handleConfigGet(conn io.Writer, patterns []string) error {
+	params := []struct {
+		name  string
+		value string
+	}{
+		{"dir", s.config.persistence.Dir},
+		{"dbfilename", s.config.persistence.Dbfilename},
+		{"databases", strconv.Itoa(len(s.dbs))},
+		{"lazyfree-lazy-user-del", yesNo(s.config.lazyfreeLazyUserDel)},
+		{"maxmemory", strconv.FormatInt(s.config.maxmemory, 10)},
+		{"maxmemory-policy", s.config.maxmemoryPolicy},
+		{"maxmemory-samples", strconv.Itoa(s.config.maxmemorySamples)},
+		{"requirepass", s.config.requirepass},
+		{"proto-max-bulk-len", strconv.FormatInt(s.config.protoMaxBulkLen, 10)},
+		{"client-output-buffer-limit", formatClientOutputBufferLimit(s.config.outputLimits)},
+		{"timeout", strconv.Itoa(int(s.config.timeout.Seconds()))},
+		{"tcp-keepalive", strconv.Itoa(int(s.config.tcpKeepalive.Seconds()))},
+		{"maxclients", strconv.Itoa(s.config.maxclients)},
+		{"bind", strings.Join(s.bindAddrs(), " ")},
+		{"port", s.port},
+		{"unixsocket", s.config.unixsocket},
+		{"unixsocketperm", strconv.FormatUint(uint64(s.config.unixsocketperm), 8)},
+		{"tls-port", s.config.tlsPort},
+		{"tls-cert-file", s.config.tlsCertFile},
+		{"tls-key-file", s.config.tlsKeyFile},
+		{"tls-ca-cert-file", s.config.tlsCACertFile},
+		{"tls-auth-clients", s.config.tlsAuthClients},
+		{"tls-replication", yesNo(s.config.tlsReplication)},
+	}
+	res := [][]byte{}
+	for _, p := range params {
+		for _, pattern := range patterns {
+			if glob.Match(pattern, p.name, true) {
+				res = append(res, resp.NewBulkString(p.name), resp.NewBulkString(p.value)) // key, value
+				break
+			}
+		}
+	}
+	if _, err := conn.Write(mapReply(conn, res)); err != nil {
+		return fmt.Errorf("error writing to connection: %s", err.Error())
+	}
+	return nil
+}
+
+func writeWrongArgs(conn io.Writer, cmd string) error {
+	if _, err := conn.Write(resp.NewErrorMSG(fmt.Sprintf("wrong number of arguments for '%s' command", strings.ToLower(cmd)))); err != nil {
+		return fmt.Errorf("error writing to connection: %s", err.Error())
+	}
+	return nil
+}
+
+func writeError(conn io.Writer, err error) error {
+	msg := resp.NewErrorMSG(err.Error())
+	var coded *database.CodedError
+	if errors.As(err, &coded) {
+		msg = resp.NewError(coded.Code, coded.Msg)
+	}
+	if _, err := conn.Write(msg); err != nil {
+		return fmt.Errorf("error writing to connection: %s", err.Error())
+	}
+	return nil
+}
+
+func writeBoolInt(conn io.Writer, b bool) error {
+	i := 0
+	if b {
+		i = 1
+	}
+	if _, err := conn.Write(resp.NewInt(i)); err != nil {
+		return fmt.Errorf("error writing to connection: %s", err.Error())
+	}
+	return nil
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
 func handlePing(conn io.Writer) error {
 	if _, err := conn.Write(resp.NewSimpleString("PONG")); err != nil {
 		return fmt.Errorf("error writing to connection: %s", err.Error())
@@ -475,7 +1267,7 @@ func handleGet(conn io.Writer, arr []string, db *database.DB) error {
 	}
 	value := db.Get(arr[1])
 	if value == "" {
-		if _, err := conn.Write(resp.NewNullBulkString()); err != nil {
+		if _, err := conn.Write(nullBulkString(conn)); err != nil {
 			return fmt.Errorf("error writing to connection: %s", err.Error())
 		}
 	} else {
@@ -535,6 +1327,9 @@ func handleWait(conn io.Writer, arr []string, backlog *replication.ReplicatinoBa
 			return fmt.Errorf("error writing to connection: %s", err.Error())
 		}
 	}
+	if err := flush(conn); err != nil {
+		return err
+	}
 	count := backlog.InSyncReplicas(time.Millisecond*time.Duration(timeout), replCount)
 	if _, err := conn.Write(resp.NewInt(count)); err != nil {
 		return fmt.Errorf("error writing to connection: %s", err.Error())
@@ -546,15 +1341,7 @@ func handleKeys(conn io.Writer, arr []string, db *database.DB) error {
 	if len(arr) != 2 {
 		return fmt.Errorf("invliad keys length")
 	}
-	pattern := arr[1]
-	if pattern == "*" {
-		pattern = ".*"
-	}
-	regex, err := regexp.Compile(pattern)
-	if err != nil {
-		return fmt.Errorf("invliad patter:  %w", err)
-	}
-	keys := db.Keys(regex)
+	keys := db.Keys(arr[1])
 	res := make([][]byte, len(keys))
 	for i, k := range keys {
 		res[i] = resp.NewBulkString(k)
@@ -654,11 +1441,19 @@ func handleXRead(conn io.Writer, arr []string, db *database.DB) error {
 	streamsCount := (len(arr) - streamArgsIdx) / 2
 	keys := arr[streamArgsIdx : streamArgsIdx+streamsCount]
 	ids := arr[streamArgsIdx+streamsCount : streamArgsIdx+2*streamsCount]
+	// the streams with entries, as key, entries pairs
 	res := [][]byte{}
 
+	// the client can be woken by CLIENT UNBLOCK while it waits
+	var unblock <-chan struct{}
+	unblocked := func() bool { return false }
+	if block != database.NO_BLOCKING {
+		unblock, unblocked = clientOf(conn).block()
+	}
+	woken := false
 	for i, key := range keys {
 		startID := ids[i]
-		ents, outCh, err := db.Xread(key, startID, block)
+		ents, outCh, err := db.Xread(key, startID, block, unblock)
 		if err != nil {
 			if _, err := conn.Write(resp.NewErrorMSG(err.Error())); err != nil {
 				return fmt.Errorf("error writing to connection: %s", err.Error())
@@ -667,24 +1462,44 @@ func handleXRead(conn io.Writer, arr []string, db *database.DB) error {
 		}
 		if outCh == nil {
 			if len(ents) > 0 {
-				resEntries := resp.NewStreamEntries(ents)
-				res = append(res, resp.NewArray([][]byte{resp.NewBulkString(key), resEntries}))
+				res = append(res, resp.NewBulkString(key), resp.NewStreamEntries(ents))
 			}
 		} else {
 			// there is no immediate response, we need to wait for the response from the channel
-			ent := <-outCh
-			resEntries := resp.NewStreamEntries([]database.Entry{ent})
-			res = append(res, resp.NewArray([][]byte{resp.NewBulkString(key), resEntries}))
+			if err := flush(conn); err != nil {
+				return err
+			}
+			select {
+			case ent := <-outCh:
+				res = append(res, resp.NewBulkString(key), resp.NewStreamEntries([]database.Entry{ent}))
+			case <-unblock:
+				woken = true
+			}
 		}
-
+		select {
+		case <-unblock:
+			woken = true
+		default:
+		}
+		if woken {
+			break
+		}
+	}
+	withErr := unblocked()
+	if woken && withErr {
+		return writeError(conn, errUnblocked)
+	}
+	if woken {
+		// replied like on timeout
+		res = nil
 	}
 	if len(res) == 0 {
-		if _, err := conn.Write(resp.NewNullBulkString()); err != nil {
+		if _, err := conn.Write(nullBulkString(conn)); err != nil {
 			return fmt.Errorf("error writing to connection: %s", err.Error())
 		}
 		return nil
 	}
-	if _, err := conn.Write(resp.NewArray(res)); err != nil {
+	if _, err := conn.Write(pairsReply(conn, res)); err != nil {
 		return fmt.Errorf("error writing to connection: %s", err.Error())
 	}
 	return nil
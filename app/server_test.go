@@ -2,6 +2,8 @@ package main
 
 import (
 	"bufio"
+	"errors"
+	"io"
 	"net"
 	"os"
 	"testing"
@@ -118,3 +120,90 @@ func TestIncr(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, resp.NewErrorMSG("value is not an integer or out of range"), res2)
 }
+
+func TestProtocolError(t *testing.T) {
+	s := newServer(host, "0", []*database.DB{database.NewDB()}, RoleMaster, config{protoMaxBulkLen: 4})
+	conn, r := pipeClient(t, s)
+	require.Equal(t, "PONG", do(t, conn, r, "PING"))
+	require.Equal(t, "hey", do(t, conn, r, "ECHO", "hey"))
+	// the empty requests are ignored
+	_, err := conn.Write([]byte("*0\r\n"))
+	require.NoError(t, err)
+	require.Equal(t, "PONG", do(t, conn, r, "PING"))
+	require.EqualError(t, do(t, conn, r, "ECHO", "hello").(error), "ERR Protocol error: invalid bulk length")
+	_, err = r.ReadByte()
+	require.ErrorIs(t, err, io.EOF)
+
+	conn, r = pipeClient(t, s)
+	_, err = conn.Write([]byte("*1\r\n*1\r\n"))
+	require.NoError(t, err)
+	res, err := readReply(r)
+	require.NoError(t, err)
+	require.EqualError(t, res.(error), "ERR Protocol error: expected '$', got '*'")
+}
+
+func TestInlineCommands(t *testing.T) {
+	s := newServer(host, "0", []*database.DB{database.NewDB()}, RoleMaster, testCfg)
+	conn, r := pipeClient(t, s)
+	inline := func(line string) any {
+		_, err := conn.Write([]byte(line))
+		require.NoError(t, err)
+		res, err := readReply(r)
+		require.NoError(t, err)
+		return res
+	}
+
+	require.Equal(t, "PONG", inline("PING\r\n"))
+	// the empty lines are ignored
+	require.Equal(t, "OK", inline("\r\n\nSET \"a key\" 'hello world'\n"))
+	require.Equal(t, "hello world", do(t, conn, r, "GET", "a key"))
+	require.Equal(t, "a\nb", inline("ECHO \"a\\nb\"\r\n"))
+	// inline and RESP requests can be pipelined together
+	require.Equal(t, "PONG", inline("PING\r\n*1\r\n$4\r\nPING\r\n"))
+	res, err := readReply(r)
+	require.NoError(t, err)
+	require.Equal(t, "PONG", res)
+	require.EqualError(t, inline("ECHO \"abc\r\n").(error), "ERR Protocol error: unbalanced quotes in request")
+}
+
+func TestMaxClients(t *testing.T) {
+	s := newServer(host, "0", []*database.DB{database.NewDB()}, RoleMaster, config{maxclients: 1})
+	conn, r := pipeClient(t, s)
+	require.Equal(t, "PONG", do(t, conn, r, "PING"))
+
+	// the client over the limit is told why before being closed
+	rejected, rejectedR := pipeClient(t, s)
+	res, err := readReply(rejectedR)
+	require.NoError(t, err)
+	require.Equal(t, errors.New("ERR max number of clients reached"), res)
+	_, err = rejectedR.ReadByte()
+	require.ErrorIs(t, err, io.EOF)
+	rejected.Close()
+
+	require.Contains(t, do(t, conn, r, "INFO", "stats"), "rejected_connections:1")
+	require.Contains(t, do(t, conn, r, "INFO", "clients"), "connected_clients:1\nmaxclients:1")
+	conn.Close()
+	require.Eventually(t, func() bool { return s.connectedClients.Load() == 0 }, time.Second, 5*time.Millisecond)
+	conn, r = pipeClient(t, s)
+	require.Equal(t, "PONG", do(t, conn, r, "PING"))
+}
+
+func TestIdleTimeout(t *testing.T) {
+	s := newServer(host, "0", []*database.DB{database.NewDB()}, RoleMaster, config{timeout: 100 * time.Millisecond})
+	conn, r := pipeClient(t, s)
+	sub, subR := pipeClient(t, s)
+	require.Equal(t, []any{"subscribe", "news", 1}, do(t, sub, subR, "SUBSCRIBE", "news"))
+
+	// the client is kept while active
+	for i := 0; i < 3; i++ {
+		time.Sleep(50 * time.Millisecond)
+		require.Equal(t, "PONG", do(t, conn, r, "PING"))
+	}
+	start := time.Now()
+	_, err := r.ReadByte()
+	require.ErrorIs(t, err, io.EOF)
+	require.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+
+	// the subscribed clients are not closed
+	require.Equal(t, []any{"pong", ""}, do(t, sub, subR, "PING"))
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/database"
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+// parseSortOptions parses the options of SORT, it returns the STORE destination, empty if not given.
+// STORE is a syntax error for SORT_RO.
+func parseSortOptions(args []string, readOnly bool) (database.SortOptions, string, error) {
+	opts := database.SortOptions{Count: -1}
+	store := ""
+	for i := 0; i < len(args); i++ {
+		left := len(args) - i - 1
+		switch strings.ToUpper(args[i]) {
+		case "ASC":
+			opts.Desc = false
+		case "DESC":
+			opts.Desc = true
+		case "ALPHA":
+			opts.Alpha = true
+		case "LIMIT":
+			if left < 2 {
+				return opts, "", errSyntax
+			}
+			offset, err1 := strconv.Atoi(args[i+1])
+			count, err2 := strconv.Atoi(args[i+2])
+			if err1 != nil || err2 != nil {
+				return opts, "", fmt.Errorf("value is not an integer or out of range")
+			}
+			opts.Offset, opts.Count = offset, count
+			i += 2
+		case "STORE":
+			if left < 1 || readOnly {
+				return opts, "", errSyntax
+			}
+			store = args[i+1]
+			i++
+		case "BY":
+			if left < 1 {
+				return opts, "", errSyntax
+			}
+			opts.By = args[i+1]
+			i++
+		case "GET":
+			if left < 1 {
+				return opts, "", errSyntax
+			}
+			opts.Get = append(opts.Get, args[i+1])
+			i++
+		default:
+			return opts, "", errSyntax
+		}
+	}
+	return opts, store, nil
+}
+
+// handleSort handles SORT and SORT_RO, a SORT with STORE is propagated to the replicas and invalidates the
+// tracked destination.
+func (s *server) handleSort(conn io.Writer, arr []string, state *clientState) error {
+	if len(arr) < 2 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	db := s.dbs[state.db]
+	opts, store, err := parseSortOptions(arr[2:], strings.EqualFold(arr[0], "SORT_RO"))
+	if err != nil {
+		return writeError(conn, err)
+	}
+	if store != "" {
+		n, err := db.SortStore(arr[1], store, opts)
+		if err != nil {
+			return writeError(conn, err)
+		}
+		s.propagate(state.db, arr)
+		s.invalidate(state.client, []string{store})
+		if _, err := conn.Write(resp.NewInt(n)); err != nil {
+			return fmt.Errorf("error writing to connection: %s", err.Error())
+		}
+		return nil
+	}
+	vals, err := db.Sort(arr[1], opts)
+	if err != nil {
+		return writeError(conn, err)
+	}
+	res := make([][]byte, len(vals))
+	for i, v := range vals {
+		if v == nil {
+			res[i] = nullBulkString(conn)
+		} else {
+			res[i] = resp.NewBulkString(*v)
+		}
+	}
+	if _, err := conn.Write(resp.NewArray(res)); err != nil {
+		return fmt.Errorf("error writing to connection: %s", err.Error())
+	}
+	return nil
+}
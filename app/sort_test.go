@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/codecrafters-io/redis-starter-go/app/database"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSort(t *testing.T) {
+	s := newServer(host, "0", []*database.DB{database.NewDB()}, RoleMaster, testCfg)
+	conn, r := pipeClient(t, s)
+
+	require.Equal(t, 3, do(t, conn, r, "RPUSH", "ids", "2", "3", "1"))
+	do(t, conn, r, "SET", "w_1", "3")
+	do(t, conn, r, "SET", "w_2", "2")
+	do(t, conn, r, "HSET", "obj_1", "name", "one")
+	require.Equal(t, []any{"1", "2", "3"}, do(t, conn, r, "SORT", "ids"))
+	require.Equal(t, []any{"3", "2", "1"}, do(t, conn, r, "SORT_RO", "ids", "BY", "w_*"))
+	require.Equal(t, []any{"2", nil, "3", nil}, do(t, conn, r, "sort", "ids", "by", "w_*", "desc", "limit", "1", "2", "get", "#", "get", "obj_*->name"))
+	require.Equal(t, []any{"1", "one"}, do(t, conn, r, "SORT", "ids", "LIMIT", "0", "1", "GET", "#", "GET", "obj_*->name"))
+
+	require.Equal(t, 3, do(t, conn, r, "SORT", "ids", "DESC", "STORE", "dst"))
+	require.Equal(t, []any{"3", "2", "1"}, do(t, conn, r, "LRANGE", "dst", "0", "-1"))
+	require.Equal(t, "list", do(t, conn, r, "TYPE", "dst"))
+
+	require.Equal(t, "ERR syntax error", do(t, conn, r, "SORT_RO", "ids", "STORE", "dst").(error).Error())
+	require.Equal(t, "ERR syntax error", do(t, conn, r, "SORT", "ids", "LIMIT", "1").(error).Error())
+	require.Equal(t, "ERR value is not an integer or out of range", do(t, conn, r, "SORT", "ids", "LIMIT", "a", "1").(error).Error())
+	do(t, conn, r, "RPUSH", "names", "b", "a")
+	require.Equal(t, "ERR One or more scores can't be converted into double", do(t, conn, r, "SORT", "names").(error).Error())
+	require.Equal(t, []any{"a", "b"}, do(t, conn, r, "SORT", "names", "ALPHA"))
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Values of tls-auth-clients.
+const (
+	tlsAuthClientsYes      = "yes"
+	tlsAuthClientsNo       = "no"
+	tlsAuthClientsOptional = "optional"
+)
+
+var errNoTLSCert = errors.New("tls-cert-file and tls-key-file must be set to use TLS")
+
+// validTLSAuthClients tells if the value of tls-auth-clients is valid.
+func validTLSAuthClients(v string) bool {
+	return v == tlsAuthClientsYes || v == tlsAuthClientsNo || v == tlsAuthClientsOptional
+}
+
+// tlsCertificate loads the certificate of tls-cert-file and tls-key-file. The same certificate is presented
+// to the clients by the server, and to the master by a replica.
+func (c config) tlsCertificate() (tls.Certificate, error) {
+	if c.tlsCertFile == "" || c.tlsKeyFile == "" {
+		return tls.Certificate{}, errNoTLSCert
+	}
+	cert, err := tls.LoadX509KeyPair(c.tlsCertFile, c.tlsKeyFile)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("error loading tls certificate: %s", err.Error())
+	}
+	return cert, nil
+}
+
+// tlsCACertPool loads the certificates of tls-ca-cert-file, nil for the certificates of the system.
+func (c config) tlsCACertPool() (*x509.CertPool, error) {
+	if c.tlsCACertFile == "" {
+		return nil, nil
+	}
+	pem, err := os.ReadFile(c.tlsCACertFile)
+	if err != nil {
+		return nil, fmt.Errorf("error loading tls ca certificate: %s", err.Error())
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("error loading tls ca certificate: no certificate found in %s", c.tlsCACertFile)
+	}
+	return pool, nil
+}
+
+// serverTLSConfig is the configuration of the tls-port listeners. The clients must present a certificate
+// signed by the CA unless tls-auth-clients is no, or optional and they present none.
+func (c config) serverTLSConfig() (*tls.Config, error) {
+	cert, err := c.tlsCertificate()
+	if err != nil {
+		return nil, err
+	}
+	pool, err := c.tlsCACertPool()
+	if err != nil {
+		return nil, err
+	}
+	auth := tls.RequireAndVerifyClientCert
+	switch c.tlsAuthClients {
+	case tlsAuthClientsNo:
+		auth = tls.NoClientCert
+	case tlsAuthClientsOptional:
+		auth = tls.VerifyClientCertIfGiven
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    pool,
+		ClientAuth:   auth,
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// replicationTLSConfig is the configuration of the connection of a replica to its master with tls-replication.
+func (c config) replicationTLSConfig(masterHost string) (*tls.Config, error) {
+	cert, err := c.tlsCertificate()
+	if err != nil {
+		return nil, err
+	}
+	pool, err := c.tlsCACertPool()
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      pool,
+		ServerName:   masterHost,
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/database"
+	"github.com/stretchr/testify/require"
+)
+
+// testCerts are the files of a self-signed CA and of a certificate it signed, valid for localhost,
+// which the tests use for both the servers and the clients.
+type testCerts struct {
+	ca, cert, key string
+}
+
+func newTestCerts(t *testing.T) testCerts {
+	dir := t.TempDir()
+	writePEM := func(name, typ string, der []byte) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0o600))
+		return path
+	}
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	ca, err := x509.ParseCertificate(caDER)
+	require.NoError(t, err)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	return testCerts{
+		ca:   writePEM("ca.crt", "CERTIFICATE", caDER),
+		cert: writePEM("redis.crt", "CERTIFICATE", der),
+		key:  writePEM("redis.key", "EC PRIVATE KEY", keyDER),
+	}
+}
+
+// config is the configuration of the servers listening on the TLS port only.
+func (c testCerts) config(tlsPort, authClients string) config {
+	return config{
+		bind:           []string{"127.0.0.1"},
+		tlsPort:        tlsPort,
+		tlsCertFile:    c.cert,
+		tlsKeyFile:     c.key,
+		tlsCACertFile:  c.ca,
+		tlsAuthClients: authClients,
+	}
+}
+
+// clientConfig is the configuration of a client trusting the CA, presenting the certificate if withCert.
+func (c testCerts) clientConfig(t *testing.T, withCert bool) *tls.Config {
+	cfg, err := config{tlsCertFile: c.cert, tlsKeyFile: c.key, tlsCACertFile: c.ca}.replicationTLSConfig("localhost")
+	require.NoError(t, err)
+	if !withCert {
+		cfg.Certificates = nil
+	}
+	return cfg
+}
+
+// startTLSServer starts a server listening on the TLS port only and returns the port.
+func startTLSServer(t *testing.T, certs testCerts, authClients string) string {
+	port := freePort(t)
+	s := newServer(host, "0", []*database.DB{database.NewDB()}, RoleMaster, certs.config(port, authClients))
+	shutdown := make(chan os.Signal, 1)
+	go s.Start(shutdown, s.handler)
+	t.Cleanup(func() { shutdown <- os.Interrupt })
+	require.Eventually(t, func() bool {
+		conn, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", port))
+		if err == nil {
+			conn.Close()
+		}
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+	return port
+}
+
+func TestTLS(t *testing.T) {
+	certs := newTestCerts(t)
+	port := startTLSServer(t, certs, tlsAuthClientsYes)
+	addr := net.JoinHostPort("127.0.0.1", port)
+
+	conn, err := tls.Dial("tcp", addr, certs.clientConfig(t, true))
+	require.NoError(t, err)
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	require.Equal(t, "PONG", do(t, conn, r, "PING"))
+	require.Equal(t, []any{"tls-port", port}, do(t, conn, r, "CONFIG", "GET", "tls-port"))
+
+	// the clients must present a certificate signed by the CA
+	conn, err = tls.Dial("tcp", addr, certs.clientConfig(t, false))
+	if err == nil {
+		defer conn.Close()
+		_, err = conn.Write([]byte("PING\r\n"))
+		if err == nil {
+			_, err = bufio.NewReader(conn).ReadString('\n')
+		}
+	}
+	require.Error(t, err)
+
+	// plaintext connections are not accepted on the TLS port
+	plain, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer plain.Close()
+	require.NoError(t, plain.SetDeadline(time.Now().Add(5*time.Second)))
+	_, err = plain.Write([]byte("PING\r\n"))
+	require.NoError(t, err)
+	line, _ := bufio.NewReader(plain).ReadString('\n')
+	require.NotEqual(t, "+PONG\r\n", line)
+}
+
+func TestTLSAuthClientsOptional(t *testing.T) {
+	certs := newTestCerts(t)
+	port := startTLSServer(t, certs, tlsAuthClientsOptional)
+
+	conn, err := tls.Dial("tcp", net.JoinHostPort("127.0.0.1", port), certs.clientConfig(t, false))
+	require.NoError(t, err)
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	require.Equal(t, "PONG", do(t, conn, r, "PING"))
+}
+
+func TestTLSReplication(t *testing.T) {
+	certs := newTestCerts(t)
+	port := startTLSServer(t, certs, tlsAuthClientsYes)
+
+	cfg := certs.config("0", tlsAuthClientsYes)
+	cfg.tlsReplication = true
+	rs, err := newReplicaServer("localhost", "0", []*database.DB{database.NewDB()}, &replicaConf{masterHost: "localhost", masterPort: port}, cfg)
+	require.NoError(t, err)
+	r, wc, err := rs.sendHandshake()
+	require.NoError(t, err)
+	require.NotNil(t, r)
+	wc.Close()
+}
+
+func TestTLSConfigErrors(t *testing.T) {
+	_, err := config{}.serverTLSConfig()
+	require.ErrorIs(t, err, errNoTLSCert)
+	_, err = config{tlsCertFile: "missing.crt", tlsKeyFile: "missing.key"}.serverTLSConfig()
+	require.ErrorContains(t, err, "error loading tls certificate")
+
+	certs := newTestCerts(t)
+	_, err = config{tlsCertFile: certs.cert, tlsKeyFile: certs.key, tlsCACertFile: certs.key}.serverTLSConfig()
+	require.ErrorContains(t, err, "no certificate found")
+	require.True(t, validTLSAuthClients("optional"))
+	require.False(t, validTLSAuthClients("maybe"))
+}
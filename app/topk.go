@@ -0,0 +1,143 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/redis-starter-go/app/database"
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+// topKMaxIncr is the largest increment of TOPK.INCRBY, the decays are drawn one occurrence at a time.
+const topKMaxIncr = 100000
+
+var (
+	errTopKBadK     = errors.New("TopK: invalid k")
+	errTopKBadWidth = errors.New("TopK: invalid width")
+	errTopKBadDepth = errors.New("TopK: invalid depth")
+	errTopKBadDecay = errors.New("TopK: invalid decay value. must be '<= 1' & '> 0'")
+	errTopKBadIncr  = errors.New("TopK: increment must be an integer greater or equal to 1 and less than or equal to 100000")
+)
+
+// handleTopKReserve limits the buckets and the heap to maxValueSize, so that a large k cannot exhaust the memory.
+func (s *server) handleTopKReserve(conn io.Writer, arr []string, db *database.DB) error {
+	if len(arr) != 3 && len(arr) != 6 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	k, err := strconv.ParseUint(arr[2], 10, 32)
+	if err != nil || k == 0 {
+		return writeError(conn, errTopKBadK)
+	}
+	width, depth, decay := uint64(database.TopKDefaultWidth), uint64(database.TopKDefaultDepth), database.TopKDefaultDecay
+	if len(arr) == 6 {
+		if width, err = strconv.ParseUint(arr[3], 10, 32); err != nil || width == 0 {
+			return writeError(conn, errTopKBadWidth)
+		}
+		if depth, err = strconv.ParseUint(arr[4], 10, 32); err != nil || depth == 0 {
+			return writeError(conn, errTopKBadDepth)
+		}
+		if decay, err = strconv.ParseFloat(arr[5], 64); err != nil || decay <= 0 || decay > 1 {
+			return writeError(conn, errTopKBadDecay)
+		}
+	}
+	if err := db.TopKReserve(arr[1], k, width, depth, decay, s.maxValueSize()); err != nil {
+		return writeError(conn, err)
+	}
+	return writeOK(conn)
+}
+
+// handleTopKAdd handles TOPK.ADD and TOPK.INCRBY, the items expelled from the top k are replied, null if none.
+func handleTopKAdd(conn io.Writer, arr []string, db *database.DB) error {
+	incrBy := strings.EqualFold(arr[0], "TOPK.INCRBY")
+	if len(arr) < 3 || incrBy && len(arr)%2 != 0 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	var incrs []database.TopKIncr
+	if incrBy {
+		for i := 2; i < len(arr); i += 2 {
+			n, err := strconv.ParseUint(arr[i+1], 10, 32)
+			if err != nil || n < 1 || n > topKMaxIncr {
+				return writeError(conn, errTopKBadIncr)
+			}
+			incrs = append(incrs, database.TopKIncr{Item: arr[i], Value: uint32(n)})
+		}
+	} else {
+		for _, item := range arr[2:] {
+			incrs = append(incrs, database.TopKIncr{Item: item, Value: 1})
+		}
+	}
+	expelled, err := db.TopKIncrBy(arr[1], incrs)
+	if err != nil {
+		return writeError(conn, err)
+	}
+	res := make([][]byte, len(expelled))
+	for i, item := range expelled {
+		res[i] = nullBulkString(conn)
+		if item != nil {
+			res[i] = resp.NewBulkString(*item)
+		}
+	}
+	if _, err := conn.Write(resp.NewArray(res)); err != nil {
+		return fmt.Errorf("error writing to connection: %s", err.Error())
+	}
+	return nil
+}
+
+func handleTopKQuery(conn io.Writer, arr []string, db *database.DB) error {
+	if len(arr) < 3 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	exists, err := db.TopKQuery(arr[1], arr[2:])
+	if err != nil {
+		return writeError(conn, err)
+	}
+	return writeBooleans(conn, exists)
+}
+
+func handleTopKList(conn io.Writer, arr []string, db *database.DB) error {
+	if len(arr) != 2 && len(arr) != 3 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	withCount := len(arr) == 3
+	if withCount && !strings.EqualFold(arr[2], "WITHCOUNT") {
+		return writeError(conn, errSyntax)
+	}
+	items, err := db.TopKList(arr[1])
+	if err != nil {
+		return writeError(conn, err)
+	}
+	res := make([][]byte, 0, 2*len(items))
+	for _, item := range items {
+		res = append(res, resp.NewBulkString(item.Item))
+		if withCount {
+			res = append(res, resp.NewInt(int(item.Count)))
+		}
+	}
+	if _, err := conn.Write(resp.NewArray(res)); err != nil {
+		return fmt.Errorf("error writing to connection: %s", err.Error())
+	}
+	return nil
+}
+
+func handleTopKInfo(conn io.Writer, arr []string, db *database.DB) error {
+	if len(arr) != 2 {
+		return writeWrongArgs(conn, arr[0])
+	}
+	info, err := db.TopKInfo(arr[1])
+	if err != nil {
+		return writeError(conn, err)
+	}
+	res := [][]byte{
+		resp.NewSimpleString("k"), resp.NewInt(info.K),
+		resp.NewSimpleString("width"), resp.NewInt(int(info.Width)),
+		resp.NewSimpleString("depth"), resp.NewInt(int(info.Depth)),
+		resp.NewSimpleString("decay"), doubleReply(conn, info.Decay),
+	}
+	if _, err := conn.Write(mapReply(conn, res)); err != nil {
+		return fmt.Errorf("error writing to connection: %s", err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/codecrafters-io/redis-starter-go/app/database"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTopKCommands(t *testing.T) {
+	s := newServer(host, "0", []*database.DB{database.NewDB()}, RoleMaster, testCfg)
+	conn, r := pipeClient(t, s)
+
+	require.Equal(t, "OK", do(t, conn, r, "TOPK.RESERVE", "topk", "2"))
+	require.Equal(t, "ERR TopK: key already exists", do(t, conn, r, "TOPK.RESERVE", "topk", "2").(error).Error())
+	require.Equal(t, []any{"k", 2, "width", 8, "depth", 7, "decay", "0.9"}, do(t, conn, r, "TOPK.INFO", "topk"))
+	require.Equal(t, "TopK-TYPE", do(t, conn, r, "TYPE", "topk"))
+
+	require.Equal(t, []any{nil, nil, nil}, do(t, conn, r, "TOPK.ADD", "topk", "a", "b", "a"))
+	require.Equal(t, []any{"a", "b"}, do(t, conn, r, "TOPK.LIST", "topk"))
+	require.Equal(t, []any{"b"}, do(t, conn, r, "TOPK.INCRBY", "topk", "c", "10"))
+	require.Equal(t, []any{"c", 10, "a", 2}, do(t, conn, r, "TOPK.LIST", "topk", "WITHCOUNT"))
+	require.Equal(t, []any{1, 0}, do(t, conn, r, "TOPK.QUERY", "topk", "c", "b"))
+
+	require.Equal(t, "ERR TopK: key does not exist", do(t, conn, r, "TOPK.LIST", "missing").(error).Error())
+	require.Equal(t, "ERR TopK: invalid k", do(t, conn, r, "TOPK.RESERVE", "x", "0").(error).Error())
+	require.Equal(t, "ERR TopK: invalid decay value. must be '<= 1' & '> 0'",
+		do(t, conn, r, "TOPK.RESERVE", "x", "2", "8", "7", "1.5").(error).Error())
+	require.Contains(t, do(t, conn, r, "TOPK.INCRBY", "topk", "c", "0").(error).Error(), "TopK: increment must be")
+	require.Contains(t, do(t, conn, r, "TOPK.RESERVE", "x", "2", "8").(error).Error(), "wrong number of arguments")
+}
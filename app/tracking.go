@@ -0,0 +1,364 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/codecrafters-io/redis-starter-go/app/resp"
+)
+
+// trackingOptions are the options given to CLIENT TRACKING ON.
+type trackingOptions struct {
+	on          bool
+	bcast       bool
+	optin       bool
+	optout      bool
+	noloop      bool
+	redirect    int64 // id of the client receiving the invalidations, 0 for the client itself
+	brokenRedir bool  // the client redirected to is gone
+	prefixes    []string
+}
+
+// tracker remembers which clients are interested in which keys, for server-assisted client side caching.
+// The clients are referred to by id so that the keys read by a client can be left behind once it disconnects:
+// they are dropped from the table once invalidated, like the ones of the connected clients.
+// ref: https://redis.io/docs/latest/develop/reference/client-side-caching/
+type tracker struct {
+	mu       sync.Mutex
+	keys     map[string]map[int64]bool // keys read by the clients in the default mode
+	prefixes map[string]map[int64]bool // prefixes of the clients in the BCAST mode
+}
+
+func newTracker() *tracker {
+	return &tracker{
+		keys:     map[string]map[int64]bool{},
+		prefixes: map[string]map[int64]bool{},
+	}
+}
+
+func (t *tracker) remember(id int64, keys []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, key := range keys {
+		if t.keys[key] == nil {
+			t.keys[key] = map[int64]bool{}
+		}
+		t.keys[key][id] = true
+	}
+}
+
+func (t *tracker) addPrefixes(id int64, prefixes []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, prefix := range prefixes {
+		if t.prefixes[prefix] == nil {
+			t.prefixes[prefix] = map[int64]bool{}
+		}
+		t.prefixes[prefix][id] = true
+	}
+}
+
+func (t *tracker) removePrefixes(id int64, prefixes []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, prefix := range prefixes {
+		delete(t.prefixes[prefix], id)
+		if len(t.prefixes[prefix]) == 0 {
+			delete(t.prefixes, prefix)
+		}
+	}
+}
+
+// interested returns the keys to invalidate by client id, the keys read are forgotten.
+func (t *tracker) interested(keys []string) map[int64][]string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	res := map[int64][]string{}
+	add := func(id int64, key string) {
+		for _, k := range res[id] {
+			if k == key {
+				return
+			}
+		}
+		res[id] = append(res[id], key)
+	}
+	for _, key := range keys {
+		for id := range t.keys[key] {
+			add(id, key)
+		}
+		delete(t.keys, key)
+		for prefix, ids := range t.prefixes {
+			if strings.HasPrefix(key, prefix) {
+				for id := range ids {
+					add(id, key)
+				}
+			}
+		}
+	}
+	return res
+}
+
+func (t *tracker) forgetAll() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.keys = map[string]map[int64]bool{}
+}
+
+// trackKeys sends the invalidations of the keys modified by a command, or remembers the keys read by a client
+// tracking them in the default mode. A failed command modified nothing, so it invalidates no key.
+func (s *server) trackKeys(state *clientState, cmd string, arr []string, failed bool) {
+	switch {
+	case isWrite(cmd):
+		if failed {
+			break
+		}
+		if cmd == "FLUSHDB" || cmd == "FLUSHALL" {
+			s.invalidateAll()
+		} else {
+			s.invalidate(state.client, commandKeys(cmd, arr))
+		}
+	case state.client != nil:
+		c := state.client
+		c.mu.Lock()
+		t := c.tracking
+		c.mu.Unlock()
+		if t.on && !t.bcast && (!t.optin || state.caching) && (!t.optout || !state.caching) {
+			if keys := commandKeys(cmd, arr); len(keys) > 0 {
+				s.tracker.remember(c.id, keys)
+			}
+		}
+	}
+	// CLIENT CACHING only applies to the next command
+	if !(cmd == "CLIENT" && len(arr) > 1 && strings.ToUpper(arr[1]) == "CACHING") {
+		state.caching = false
+	}
+}
+
+// invalidate sends the invalidations of the keys modified by self, nil for the master or an eviction.
+func (s *server) invalidate(self *client, keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+	for id, keys := range s.tracker.interested(keys) {
+		if c, ok := s.clients.get(id); ok {
+			s.sendInvalidation(c, self, keys, false)
+		}
+	}
+}
+
+// invalidateAll sends the invalidation of all the keys to the tracking clients once the dbs are flushed.
+func (s *server) invalidateAll() {
+	s.tracker.forgetAll()
+	for _, c := range s.clients.list() {
+		s.sendInvalidation(c, nil, nil, true)
+	}
+}
+
+// sendInvalidation sends the invalidation of the keys, or of all the keys for a flush, to the client itself
+// as a RESP3 push, or to the client it redirects to. A RESP2 client redirected to receives it as a message
+// of the __redis__:invalidate channel if subscribed to it.
+func (s *server) sendInvalidation(c *client, self *client, keys []string, flush bool) {
+	c.mu.Lock()
+	t, proto := c.tracking, c.proto
+	c.mu.Unlock()
+	if !t.on || (t.noloop && c == self && !flush) {
+		return
+	}
+	target := c
+	if t.redirect != 0 {
+		var ok bool
+		if target, ok = s.clients.get(t.redirect); !ok {
+			c.mu.Lock()
+			broken := c.tracking.brokenRedir
+			c.tracking.brokenRedir = true
+			c.mu.Unlock()
+			if !broken && proto == resp.RESP3 {
+				c.push(resp.NewPush([][]byte{resp.NewBulkString("tracking-redir-broken"), resp.NewInt(int(t.redirect))}))
+			}
+			return
+		}
+	}
+	targetProto := target.protocol()
+	var payload []byte
+	switch {
+	case flush && targetProto == resp.RESP3:
+		payload = resp.NewNull()
+	case flush:
+		payload = resp.NewNullBulkString()
+	default:
+		elems := make([][]byte, len(keys))
+		for i, key := range keys {
+			elems[i] = resp.NewBulkString(key)
+		}
+		payload = resp.NewArray(elems)
+	}
+	switch {
+	case targetProto == resp.RESP3:
+		target.push(resp.NewPush([][]byte{resp.NewBulkString("invalidate"), payload}))
+	case t.redirect != 0 && s.pubsub.isSubscribed(target, trackingChannel):
+		target.push(pubsubMessage(resp.RESP2, "message", trackingChannel, payload))
+	}
+}
+
+// handleClientTracking handles CLIENT TRACKING ON|OFF [REDIRECT client-id] [PREFIX prefix [PREFIX prefix ...]]
+// [BCAST] [OPTIN] [OPTOUT] [NOLOOP].
+func (s *server) handleClientTracking(conn io.Writer, arr []string, c *client) error {
+	var opts trackingOptions
+	for i := 3; i < len(arr); i++ {
+		switch opt := strings.ToUpper(arr[i]); {
+		case opt == "REDIRECT" && i+1 < len(arr):
+			if opts.redirect != 0 {
+				return writeError(conn, errors.New("A client can only redirect to a single other client"))
+			}
+			id, err := strconv.ParseInt(arr[i+1], 10, 64)
+			if err != nil {
+				return writeError(conn, errNotInteger)
+			}
+			if _, ok := s.clients.get(id); !ok {
+				return writeError(conn, errors.New("The client ID you want redirect to does not exist"))
+			}
+			opts.redirect = id
+			i++
+		case opt == "BCAST":
+			opts.bcast = true
+		case opt == "OPTIN":
+			opts.optin = true
+		case opt == "OPTOUT":
+			opts.optout = true
+		case opt == "NOLOOP":
+			opts.noloop = true
+		case opt == "PREFIX" && i+1 < len(arr):
+			opts.prefixes = append(opts.prefixes, arr[i+1])
+			i++
+		default:
+			return writeError(conn, errSyntax)
+		}
+	}
+	c.mu.Lock()
+	cur := c.tracking
+	c.mu.Unlock()
+	switch strings.ToUpper(arr[2]) {
+	case "ON":
+		switch {
+		case len(opts.prefixes) > 0 && !opts.bcast:
+			return writeError(conn, errors.New("PREFIX option requires BCAST mode to be enabled"))
+		case cur.on && cur.bcast != opts.bcast:
+			return writeError(conn, errors.New("You can't switch BCAST mode on/off before disabling tracking for this client, and then re-enabling it with a different mode."))
+		case opts.optin && opts.optout:
+			return writeError(conn, errors.New("You can't use both OPTIN and OPTOUT."))
+		case (opts.optin || opts.optout) && opts.bcast:
+			return writeError(conn, errors.New("OPTIN and OPTOUT are not compatible with BCAST"))
+		case cur.on && ((opts.optin && cur.optout) || (opts.optout && cur.optin)):
+			return writeError(conn, errors.New("You can't switch OPTIN/OPTOUT mode before disabling tracking for this client, and then re-enabling it with a different mode."))
+		}
+		if opts.bcast && len(opts.prefixes) == 0 && len(cur.prefixes) == 0 {
+			opts.prefixes = []string{""}
+		}
+		// the prefixes of a client must not overlap, or a key would be invalidated twice
+		prefixes := append([]string{}, cur.prefixes...)
+		for _, p := range opts.prefixes {
+			for _, other := range prefixes {
+				if p != other && (strings.HasPrefix(p, other) || strings.HasPrefix(other, p)) {
+					return writeError(conn, fmt.Errorf("Prefix '%s' overlaps with an existing prefix '%s'. Prefixes for a single client must not overlap.", p, other))
+				}
+			}
+			if !slices.Contains(prefixes, p) {
+				prefixes = append(prefixes, p)
+			}
+		}
+		opts.on = true
+		opts.prefixes = prefixes
+		c.mu.Lock()
+		c.tracking = opts
+		c.mu.Unlock()
+		s.tracker.addPrefixes(c.id, prefixes)
+	case "OFF":
+		s.disableTracking(c)
+	default:
+		return writeError(conn, errSyntax)
+	}
+	return writeOK(conn)
+}
+
+// disableTracking turns off the tracking of the client, the keys it read are forgotten once invalidated.
+func (s *server) disableTracking(c *client) {
+	c.mu.Lock()
+	prefixes := c.tracking.prefixes
+	c.tracking = trackingOptions{}
+	c.mu.Unlock()
+	s.tracker.removePrefixes(c.id, prefixes)
+}
+
+// handleClientCaching handles CLIENT CACHING YES|NO, which tells whether the keys read by the next command are tracked.
+func handleClientCaching(conn io.Writer, arr []string, state *clientState) error {
+	c := state.client
+	c.mu.Lock()
+	t := c.tracking
+	c.mu.Unlock()
+	if !t.on || (!t.optin && !t.optout) {
+		return writeError(conn, errors.New("CLIENT CACHING can be called only when the client is in tracking mode with OPTIN or OPTOUT mode enabled"))
+	}
+	switch strings.ToUpper(arr[2]) {
+	case "YES":
+		if !t.optin {
+			return writeError(conn, errors.New("CLIENT CACHING YES is only valid when tracking is enabled in OPTIN mode."))
+		}
+	case "NO":
+		if !t.optout {
+			return writeError(conn, errors.New("CLIENT CACHING NO is only valid when tracking is enabled in OPTOUT mode."))
+		}
+	default:
+		return writeError(conn, errSyntax)
+	}
+	state.caching = true
+	return writeOK(conn)
+}
+
+// trackingRedirect is the id reported by CLIENT GETREDIR and CLIENT LIST, -1 when the tracking is off.
+func (t trackingOptions) trackingRedirect() int64 {
+	if !t.on {
+		return -1
+	}
+	return t.redirect
+}
+
+// handleClientTrackingInfo handles CLIENT TRACKINGINFO.
+func handleClientTrackingInfo(conn io.Writer, state *clientState) error {
+	c := state.client
+	c.mu.Lock()
+	t := c.tracking
+	c.mu.Unlock()
+	flags := [][]byte{}
+	addFlag := func(ok bool, flag string) {
+		if ok {
+			flags = append(flags, resp.NewBulkString(flag))
+		}
+	}
+	addFlag(!t.on, "off")
+	addFlag(t.on, "on")
+	addFlag(t.bcast, "bcast")
+	addFlag(t.optin, "optin")
+	addFlag(t.optin && state.caching, "caching-yes")
+	addFlag(t.optout, "optout")
+	addFlag(t.optout && state.caching, "caching-no")
+	addFlag(t.noloop, "noloop")
+	addFlag(t.brokenRedir, "broken_redirect")
+	prefixes := make([][]byte, len(t.prefixes))
+	for i, p := range t.prefixes {
+		prefixes[i] = resp.NewBulkString(p)
+	}
+	info := mapReply(conn, [][]byte{
+		resp.NewBulkString("flags"), resp.NewArray(flags),
+		resp.NewBulkString("redirect"), resp.NewInt(int(t.trackingRedirect())),
+		resp.NewBulkString("prefixes"), resp.NewArray(prefixes),
+	})
+	if _, err := conn.Write(info); err != nil {
+		return fmt.Errorf("error writing to connection: %s", err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/redis-starter-go/app/database"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientTracking(t *testing.T) {
+	s := newServer(host, "0", []*database.DB{database.NewDB()}, RoleMaster, testCfg)
+	writer, writerR, _ := tcpClient(t, s)
+	conn, r, _ := tcpClient(t, s)
+	require.Equal(t, 3, do(t, conn, r, "HELLO", "3").(map[string]any)["proto"])
+
+	require.Equal(t, -1, do(t, conn, r, "CLIENT", "GETREDIR"))
+	require.Equal(t, "OK", do(t, conn, r, "CLIENT", "TRACKING", "ON"))
+	require.Equal(t, 0, do(t, conn, r, "CLIENT", "GETREDIR"))
+	require.Nil(t, do(t, conn, r, "GET", "k"))
+
+	// the key is invalidated once, until read again
+	require.Equal(t, "OK", do(t, writer, writerR, "SET", "k", "v"))
+	require.Equal(t, []any{"invalidate", []any{"k"}}, readPush(t, r))
+	require.Equal(t, "OK", do(t, writer, writerR, "SET", "k", "v2"))
+	require.Equal(t, "v2", do(t, conn, r, "GET", "k"))
+	// the client is notified of its own writes, after the reply
+	require.Equal(t, "OK", do(t, conn, r, "SET", "k", "v3"))
+	require.Equal(t, []any{"invalidate", []any{"k"}}, readPush(t, r))
+
+	require.Equal(t, "OK", do(t, conn, r, "CLIENT", "TRACKING", "ON", "NOLOOP"))
+	require.Equal(t, "v3", do(t, conn, r, "GET", "k"))
+	require.Equal(t, "OK", do(t, conn, r, "SET", "k", "v4"))
+	require.Equal(t, "PONG", do(t, conn, r, "PING"))
+
+	require.Equal(t, errors.New("ERR PREFIX option requires BCAST mode to be enabled"), do(t, conn, r, "CLIENT", "TRACKING", "ON", "PREFIX", "a"))
+	require.Equal(t, errors.New("ERR You can't switch BCAST mode on/off before disabling tracking for this client, and then re-enabling it with a different mode."),
+		do(t, conn, r, "CLIENT", "TRACKING", "ON", "BCAST"))
+	require.Equal(t, errors.New("ERR The client ID you want redirect to does not exist"), do(t, conn, r, "CLIENT", "TRACKING", "ON", "REDIRECT", "100"))
+	require.Equal(t, errors.New("ERR CLIENT CACHING can be called only when the client is in tracking mode with OPTIN or OPTOUT mode enabled"),
+		do(t, conn, r, "CLIENT", "CACHING", "YES"))
+
+	// the BCAST mode notifies the keys matching the prefixes, whether read or not
+	require.Equal(t, "OK", do(t, conn, r, "CLIENT", "TRACKING", "OFF"))
+	require.Equal(t, "OK", do(t, conn, r, "CLIENT", "TRACKING", "ON", "BCAST", "PREFIX", "user:", "PREFIX", "session:"))
+	require.Equal(t, errors.New("ERR Prefix 'user:1' overlaps with an existing prefix 'user:'. Prefixes for a single client must not overlap."),
+		do(t, conn, r, "CLIENT", "TRACKING", "ON", "BCAST", "PREFIX", "user:1"))
+	require.Equal(t, "OK", do(t, writer, writerR, "SET", "user:1", "a"))
+	require.Equal(t, []any{"invalidate", []any{"user:1"}}, readPush(t, r))
+	require.Equal(t, "OK", do(t, writer, writerR, "SET", "other", "a"))
+	require.Equal(t, 2, do(t, writer, writerR, "DEL", "user:1", "other", "session:1"))
+	require.Equal(t, []any{"invalidate", []any{"user:1", "session:1"}}, readPush(t, r))
+	info := do(t, conn, r, "CLIENT", "TRACKINGINFO").(map[string]any)
+	require.Equal(t, []any{"on", "bcast"}, info["flags"])
+	require.Equal(t, 0, info["redirect"])
+	require.Equal(t, []any{"user:", "session:"}, info["prefixes"])
+	require.Contains(t, do(t, conn, r, "CLIENT", "INFO"), " flags=tB ")
+
+	// the OPTIN mode only tracks the keys read by the command following CLIENT CACHING YES
+	require.Equal(t, "OK", do(t, conn, r, "CLIENT", "TRACKING", "OFF"))
+	require.Equal(t, "OK", do(t, conn, r, "CLIENT", "TRACKING", "ON", "OPTIN"))
+	require.Equal(t, errors.New("ERR CLIENT CACHING NO is only valid when tracking is enabled in OPTOUT mode."), do(t, conn, r, "CLIENT", "CACHING", "NO"))
+	require.Equal(t, "v4", do(t, conn, r, "GET", "k"))
+	require.Equal(t, "OK", do(t, conn, r, "CLIENT", "CACHING", "YES"))
+	require.Equal(t, []any{"on", "optin", "caching-yes"}, do(t, conn, r, "CLIENT", "TRACKINGINFO").(map[string]any)["flags"])
+	require.Equal(t, []any{"on", "optin"}, do(t, conn, r, "CLIENT", "TRACKINGINFO").(map[string]any)["flags"])
+	require.Equal(t, "OK", do(t, conn, r, "CLIENT", "CACHING", "YES"))
+	require.Nil(t, do(t, conn, r, "GET", "cached"))
+	require.Equal(t, "OK", do(t, writer, writerR, "SET", "k", "v5"))
+	require.Equal(t, "OK", do(t, writer, writerR, "SET", "cached", "v"))
+	require.Equal(t, []any{"invalidate", []any{"cached"}}, readPush(t, r))
+
+	// the keys read by every read command are tracked
+	require.Equal(t, "OK", do(t, conn, r, "CLIENT", "TRACKING", "OFF"))
+	require.Equal(t, "OK", do(t, conn, r, "CLIENT", "TRACKING", "ON"))
+	require.Equal(t, []any{false, false}, do(t, conn, r, "BF.MEXISTS", "bf", "a", "b"))
+	require.Equal(t, []any{0}, do(t, conn, r, "BITFIELD_RO", "bits", "GET", "u8", "0"))
+	require.Equal(t, []any{}, do(t, conn, r, "SORT_RO", "list"))
+	require.Equal(t, 1, do(t, writer, writerR, "BF.ADD", "bf", "a"))
+	require.Equal(t, []any{"invalidate", []any{"bf"}}, readPush(t, r))
+	require.Equal(t, []any{0}, do(t, writer, writerR, "BITFIELD", "bits", "SET", "u8", "0", "1"))
+	require.Equal(t, []any{"invalidate", []any{"bits"}}, readPush(t, r))
+	require.Equal(t, 1, do(t, writer, writerR, "RPUSH", "list", "1"))
+	require.Equal(t, []any{"invalidate", []any{"list"}}, readPush(t, r))
+	// a failed write invalidates nothing
+	require.Nil(t, do(t, conn, r, "GET", "str"))
+	require.Equal(t, "OK", do(t, writer, writerR, "SET", "str", "v"))
+	require.Equal(t, []any{"invalidate", []any{"str"}}, readPush(t, r))
+	require.Equal(t, "v", do(t, conn, r, "GET", "str"))
+	require.Error(t, do(t, writer, writerR, "INCR", "str").(error))
+	require.Equal(t, "PONG", do(t, conn, r, "PING"))
+	// SORT ... STORE invalidates its destination
+	require.Nil(t, do(t, conn, r, "GET", "sorted"))
+	require.Equal(t, 1, do(t, writer, writerR, "SORT", "list", "STORE", "sorted"))
+	require.Equal(t, []any{"invalidate", []any{"sorted"}}, readPush(t, r))
+
+	// a flush invalidates all the keys
+	require.Equal(t, "OK", do(t, writer, writerR, "FLUSHALL"))
+	require.Equal(t, []any{"invalidate", nil}, readPush(t, r))
+}
+
+func TestClientTrackingRedirect(t *testing.T) {
+	s := newServer(host, "0", []*database.DB{database.NewDB()}, RoleMaster, testCfg)
+	writer, writerR, _ := tcpClient(t, s)
+	sub, subR, _ := tcpClient(t, s)
+	conn, r, _ := tcpClient(t, s)
+
+	require.Equal(t, []any{"subscribe", trackingChannel, 1}, do(t, sub, subR, "SUBSCRIBE", trackingChannel))
+	subID := 2
+	require.Equal(t, "OK", do(t, conn, r, "CLIENT", "TRACKING", "ON", "REDIRECT", strconv.Itoa(subID)))
+	require.Equal(t, subID, do(t, conn, r, "CLIENT", "GETREDIR"))
+	require.Contains(t, do(t, conn, r, "CLIENT", "INFO"), " redir=2 ")
+	require.Nil(t, do(t, conn, r, "GET", "k"))
+
+	// a RESP2 client receives the invalidations as messages of the channel
+	require.Equal(t, "OK", do(t, writer, writerR, "SET", "k", "v"))
+	require.Equal(t, []any{"message", trackingChannel, []any{"k"}}, readPush(t, subR))
+	require.Equal(t, "OK", do(t, writer, writerR, "FLUSHDB"))
+	require.Equal(t, []any{"message", trackingChannel, nil}, readPush(t, subR))
+
+	require.Nil(t, do(t, conn, r, "GET", "k"))
+	sub.Close()
+	require.Eventually(t, func() bool {
+		_, ok := s.clients.get(int64(subID))
+		return !ok
+	}, time.Second, 5*time.Millisecond)
+	require.Equal(t, "OK", do(t, writer, writerR, "SET", "k", "v"))
+	flags := do(t, conn, r, "CLIENT", "TRACKINGINFO").([]any)[1].([]any)
+	require.True(t, slices.Contains(flags, any("broken_redirect")))
+	require.Contains(t, do(t, conn, r, "CLIENT", "INFO"), " flags=tR ")
+}
+
+// readPush reads a message pushed to the client.
+func readPush(t *testing.T, r *bufio.Reader) any {
+	t.Helper()
+	res, err := readReply(r)
+	require.NoError(t, err)
+	return res
+}